@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -62,17 +63,930 @@ type SetPanelYieldDataResponse struct {
 	YieldData []YieldData `json:"yield_data"`
 }
 
+// Units readings are reported in. Readings are stored in the canonical unit of their
+// measurement type and converted to the units asked for with the units parameter
+const (
+	UnitCelsius    = "°C"
+	UnitFahrenheit = "°F"
+	UnitPercent    = "%"
+	UnitFraction   = "fraction" // a percentage divided by 100, e.g. m³/m³ for volumetric water content
+)
+
+// SensorDataQuery narrows and pages a request for a sensor's readings,
+// zero values are left out of the request
+type SensorDataQuery struct {
+	Start  time.Time // Only readings on or after this time
+	End    time.Time // Only readings on or before this time
+	Limit  int       // Maximum number of readings in the page
+	Order  string    // "asc" (default) or "desc" by reading date
+	Cursor string    // NextCursor from the previous page
+	// IncludeReplaced reads the history of the sensors this one replaced, or was replaced by, too
+	IncludeReplaced bool
+	// Raw reads the readings as stored instead of calibrated by the sensor's calibrations
+	Raw bool
+	// Units are the units to convert readings to, any of celsius, fahrenheit, percent
+	// and fraction. Readings are returned in their canonical unit when empty
+	Units []string
+}
+
 type GetSensorMoistureDataResponse struct {
 	SensorMoistureData []SensorMoistureData `json:"sensor_moisture_data"`
+	Unit               string               `json:"unit"`                          // Unit of the soil moisture values, % volumetric water content unless converted
 	IsOnline           *bool                `json:"is_online,omitempty"`           // nil if no data, true/false if data exists
 	LastDataTimestamp  *time.Time           `json:"last_data_timestamp,omitempty"` // Most recent data timestamp
+	NextCursor         string               `json:"next_cursor,omitempty"`         // Empty when there are no more pages
+	// Resolution is hourly or daily when the range reaches back past the raw readings'
+	// retention and the readings are the averages of the rollups of that resolution
+	Resolution string `json:"resolution,omitempty"`
+	// RawRetentionCutoff is set when a page of raw readings without a start was asked
+	// for, readings before it are rolled up so the page may be missing them
+	RawRetentionCutoff *time.Time `json:"raw_retention_cutoff,omitempty"`
 }
 
 type GetSensorTemperatureDataResponse struct {
 	SensorTemperatureData []SensorTemperatureData `json:"sensor_temperature_data"`
+	Unit                  string                  `json:"unit"`                          // Unit of the soil temperature values, °C unless converted
 	IsOnline              *bool                   `json:"is_online,omitempty"`           // nil if no data, true/false if data exists
 	LastDataTimestamp     *time.Time              `json:"last_data_timestamp,omitempty"` // Most recent data timestamp
+	NextCursor            string                  `json:"next_cursor,omitempty"`         // Empty when there are no more pages
+	// Resolution is hourly or daily when the range reaches back past the raw readings'
+	// retention and the readings are the averages of the rollups of that resolution
+	Resolution string `json:"resolution,omitempty"`
+	// RawRetentionCutoff is set when a page of raw readings without a start was asked
+	// for, readings before it are rolled up so the page may be missing them
+	RawRetentionCutoff *time.Time `json:"raw_retention_cutoff,omitempty"`
+}
+
+// SensorDataBucket summarises a sensor's readings in one time bucket
+type SensorDataBucket struct {
+	BucketStart time.Time `json:"bucket_start"` // UTC start of the bucket
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Avg         float64   `json:"avg"`
+	Count       int       `json:"count"`
+}
+
+// GetSensorDataBucketsResponse is returned by the sensor reading endpoints
+// when a resolution other than raw is requested
+type GetSensorDataBucketsResponse struct {
+	SensorID          string             `json:"sensor_id"`
+	MeasurementType   string             `json:"measurement_type"`
+	Unit              string             `json:"unit"`
+	Resolution        string             `json:"resolution"`
+	Buckets           []SensorDataBucket `json:"buckets"`
+	IsOnline          *bool              `json:"is_online,omitempty"`           // nil if no data, true/false if data exists
+	LastDataTimestamp *time.Time         `json:"last_data_timestamp,omitempty"` // Most recent data timestamp
+}
+
+// MeasurementType describes a kind of reading in the measurement registry
+type MeasurementType struct {
+	ID             string   `json:"id"` // e.g. soil_moisture, used in /sensors/{sensor_id}/measurements/{id}
+	DisplayName    string   `json:"display_name"`
+	Unit           string   `json:"unit"`
+	MinValue       *float64 `json:"min_value,omitempty"`         // Lowest plausible reading, nil for no bound
+	MaxValue       *float64 `json:"max_value,omitempty"`         // Highest plausible reading, nil for no bound
+	MaxRatePerHour *float64 `json:"max_rate_per_hour,omitempty"` // Largest plausible change per hour, nil for no bound
+	MQTTIdentifier string   `json:"mqtt_identifier,omitempty"`   // Measurement or status id gateways publish under
+	// Days raw readings are kept before they are rolled up, and days the hourly and daily
+	// rollups are kept, 0 or unset keeps them forever
+	RawRetentionDays    int `json:"raw_retention_days,omitempty"`
+	HourlyRetentionDays int `json:"hourly_retention_days,omitempty"`
+	DailyRetentionDays  int `json:"daily_retention_days,omitempty"`
+}
+
+type GetMeasurementTypesResponse struct {
+	MeasurementTypes []MeasurementType `json:"measurement_types"`
+}
+
+// UpdateMeasurementTypeRequest changes the fields of a measurement type that are set
+type UpdateMeasurementTypeRequest struct {
+	DisplayName    *string  `json:"display_name,omitempty"`
+	Unit           *string  `json:"unit,omitempty"`
+	MinValue       *float64 `json:"min_value,omitempty"`
+	MaxValue       *float64 `json:"max_value,omitempty"`
+	MaxRatePerHour *float64 `json:"max_rate_per_hour,omitempty"`
+	MQTTIdentifier *string  `json:"mqtt_identifier,omitempty"`
+	// Retention periods in days, 0 keeps readings of the tier forever
+	RawRetentionDays    *int `json:"raw_retention_days,omitempty"`
+	HourlyRetentionDays *int `json:"hourly_retention_days,omitempty"`
+	DailyRetentionDays  *int `json:"daily_retention_days,omitempty"`
+	// ClearMinValue and ClearMaxValue remove the bound, they cannot be
+	// combined with a new value for it
+	ClearMinValue bool `json:"clear_min_value,omitempty"`
+	ClearMaxValue bool `json:"clear_max_value,omitempty"`
+}
+
+// SensorMeasurement is a single reading of any measurement type
+type SensorMeasurement struct {
+	ID    int       `json:"id,omitempty"`
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+	// SensorID is the sensor that took the reading when a history of several sensors is read
+	SensorID string `json:"sensor_id,omitempty"`
+}
+
+type SetSensorMeasurementsRequest struct {
+	Measurements []SensorMeasurement `json:"measurements"`
+	OnConflict   string              `json:"on_conflict,omitempty"` // "ignore" (default) keeps stored readings, "update" replaces their values
+}
+
+// SensorMeasurementResult is the outcome of saving one reading of a batch
+type SensorMeasurementResult struct {
+	Index  int    `json:"index"`  // Position of the reading in the request
+	Status string `json:"status"` // inserted, updated, duplicate, quarantined or rejected
+	ID     int    `json:"id,omitempty"`
+	Reason string `json:"reason,omitempty"` // Why the reading was a duplicate, quarantined or rejected
+}
+
+// SetSensorMeasurementsResponse is returned by every endpoint that saves readings,
+// the batch is saved in one transaction so a failed request saved nothing
+type SetSensorMeasurementsResponse struct {
+	Message    string `json:"message"`
+	Inserted   int    `json:"inserted"`
+	Updated    int    `json:"updated"`
+	Duplicates int    `json:"duplicates"`
+	// Quarantined readings broke a quality rule and are held back for an admin to review
+	Quarantined int                       `json:"quarantined"`
+	Rejected    int                       `json:"rejected"`
+	Results     []SensorMeasurementResult `json:"results"`
+}
+
+type GetSensorMeasurementsResponse struct {
+	SensorID          string              `json:"sensor_id"`
+	MeasurementType   string              `json:"measurement_type"`
+	Unit              string              `json:"unit"`
+	Measurements      []SensorMeasurement `json:"measurements"`
+	IsOnline          *bool               `json:"is_online,omitempty"`           // nil if no data, true/false if data exists
+	LastDataTimestamp *time.Time          `json:"last_data_timestamp,omitempty"` // Most recent data timestamp
+	NextCursor        string              `json:"next_cursor,omitempty"`         // Empty when there are no more pages
+	// Resolution is hourly or daily when the range reaches back past the raw readings'
+	// retention and the readings are the averages of the rollups of that resolution
+	Resolution string `json:"resolution,omitempty"`
+	// RawRetentionCutoff is set when a page of raw readings without a start was asked
+	// for, readings before it are rolled up so the page may be missing them
+	RawRetentionCutoff *time.Time `json:"raw_retention_cutoff,omitempty"`
+}
+
+// QuerySensorsRequest selects the series returned by POST /sensors/query,
+// one series for each sensor and measurement type
+type QuerySensorsRequest struct {
+	SensorIDs []string `json:"sensor_ids"`
+	// MeasurementTypes are registry ids or the aliases moisture, temperature and battery,
+	// every registered type when empty
+	MeasurementTypes []string  `json:"measurement_types,omitempty"`
+	Start            time.Time `json:"start,omitempty"` // Only readings on or after this time
+	End              time.Time `json:"end,omitempty"`   // Only readings on or before this time
+	// Resolution is hourly, daily, weekly or monthly for buckets, raw (the default) for readings
+	Resolution string `json:"resolution,omitempty"`
+	// IncludeReplaced adds the readings of the sensors each sensor replaced,
+	// or was replaced by, to its series
+	IncludeReplaced bool `json:"include_replaced,omitempty"`
+	// Raw returns readings as stored instead of calibrated by the sensors' calibrations
+	Raw bool `json:"raw,omitempty"`
+	// Units to convert readings to, any of celsius, fahrenheit, percent and fraction,
+	// each series states the unit of its values
+	Units []string `json:"units,omitempty"`
+}
+
+// SensorSeries is a sensor's readings of one measurement type in the requested window
+type SensorSeries struct {
+	SensorID          string              `json:"sensor_id"`
+	MeasurementType   string              `json:"measurement_type"`
+	Unit              string              `json:"unit"`
+	Measurements      []SensorMeasurement `json:"measurements,omitempty"`        // Set for raw series
+	Buckets           []SensorDataBucket  `json:"buckets,omitempty"`             // Set for aggregated series
+	Truncated         bool                `json:"truncated,omitempty"`           // True if only the earliest readings were returned
+	IsOnline          *bool               `json:"is_online,omitempty"`           // nil if no data, true/false if data exists
+	LastDataTimestamp *time.Time          `json:"last_data_timestamp,omitempty"` // Most recent data timestamp
+}
+
+type QuerySensorsResponse struct {
+	Resolution string         `json:"resolution"`
+	Series     []SensorSeries `json:"series"`
+}
+
+// SensorLastReading is a sensor's newest reading of one measurement type
+type SensorLastReading struct {
+	MeasurementType string    `json:"measurement_type"`
+	Unit            string    `json:"unit"`
+	Date            time.Time `json:"date"`
+	Value           float64   `json:"value"`
+}
+
+// SensorStatus is the state of one sensor of the fleet
+type SensorStatus struct {
+	SensorID string `json:"sensor_id"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	// State is online, offline or never_seen for sensors without readings
+	State    string     `json:"state"`
+	IsOnline bool       `json:"is_online"`
+	LastSeen *time.Time `json:"last_seen,omitempty"` // Time of the newest reading of any type
+	// StateSince is when the sensor entered its current state, an offline sensor went
+	// offline when its last reading became too old
+	StateSince           *time.Time          `json:"state_since,omitempty"`
+	StateDurationSeconds int64               `json:"state_duration_seconds,omitempty"`
+	BatteryLevel         *float64            `json:"battery_level,omitempty"`
+	BatteryLevelDate     *time.Time          `json:"battery_level_date,omitempty"`
+	LastReadings         []SensorLastReading `json:"last_readings"`
+}
+
+// SensorDataGap is a stretch of time a sensor went without reporting for longer than
+// its reporting interval allows
+type SensorDataGap struct {
+	Start           time.Time `json:"start"` // Last reading before the gap, or the start of the range
+	End             time.Time `json:"end"`   // Reading that ended the gap, or the end of the range
+	DurationSeconds int64     `json:"duration_seconds"`
+	MissedReadings  int       `json:"missed_readings"` // Readings the sensor was expected to send in the gap
+}
+
+// SensorCoverage is how completely a sensor reported over a range, judged against its
+// reporting interval. The range is narrowed to when the sensor was installed and in
+// service, and never extends past now
+type SensorCoverage struct {
+	SensorID                 string    `json:"sensor_id"`
+	Name                     string    `json:"name"`
+	Start                    time.Time `json:"start"`
+	End                      time.Time `json:"end"`
+	ReportingIntervalSeconds int       `json:"reporting_interval_seconds"`
+	ExpectedReadings         int       `json:"expected_readings"`
+	ReceivedReadings         int       `json:"received_readings"` // Distinct times the sensor reported at
+	// CoveragePercent is the share of the range not lost to gaps
+	CoveragePercent   float64 `json:"coverage_percent"`
+	MissingSeconds    int64   `json:"missing_seconds"`
+	GapCount          int     `json:"gap_count"`
+	LongestGapSeconds int64   `json:"longest_gap_seconds"`
+	// Gaps are listed for a single sensor's report, not in fleet rankings
+	Gaps []SensorDataGap `json:"gaps,omitempty"`
+}
+
+// GetSensorsCoverageResponse ranks the sensors in service by coverage, least complete first
+type GetSensorsCoverageResponse struct {
+	Start   time.Time        `json:"start"`
+	End     time.Time        `json:"end"`
+	Sensors []SensorCoverage `json:"sensors"`
+}
+
+// BatteryForecast is when a sensor's battery level is predicted to fall to the cutoff,
+// from a straight line fitted to its readings since the battery was last swapped
+type BatteryForecast struct {
+	SensorID string `json:"sensor_id"`
+	Name     string `json:"name"`
+	// Status is forecast, below_cutoff, not_discharging or insufficient_data,
+	// the prediction is only given for forecast
+	Status        string     `json:"status"`
+	Cutoff        float64    `json:"cutoff"`
+	CurrentLevel  *float64   `json:"current_level,omitempty"`
+	LastReadingAt *time.Time `json:"last_reading_at,omitempty"`
+	// LastSwapAt is the first reading of the new battery when a swap was found in
+	// the window, readings before it are left out of the fit
+	LastSwapAt   *time.Time `json:"last_swap_at,omitempty"`
+	FitStart     *time.Time `json:"fit_start,omitempty"`
+	ReadingsUsed int        `json:"readings_used"`
+	// DischargeRatePerDay is the fitted fall in level per day, negative while it is rising
+	DischargeRatePerDay *float64   `json:"discharge_rate_per_day,omitempty"`
+	PredictedAt         *time.Time `json:"predicted_at,omitempty"`
+	// EarliestAt and LatestAt are the 95% confidence range of PredictedAt, either is
+	// left out when the readings are too scattered to bound it
+	EarliestAt *time.Time `json:"earliest_at,omitempty"`
+	LatestAt   *time.Time `json:"latest_at,omitempty"`
+	// DaysRemaining is from now until PredictedAt, 0 once the battery is below the cutoff
+	DaysRemaining *float64 `json:"days_remaining,omitempty"`
+}
+
+// GetBatteryForecastsResponse lists the sensors in service predicted to need a battery
+// swap by Until, those already below the cutoff first and then the soonest due
+type GetBatteryForecastsResponse struct {
+	Cutoff     float64           `json:"cutoff"`
+	WithinDays int               `json:"within_days"`
+	Until      time.Time         `json:"until"`
+	Sensors    []BatteryForecast `json:"sensors"`
+}
+
+// BatteryForecastQuery sets up a battery forecast, zero values are left out of the
+// request so the server defaults are used
+type BatteryForecastQuery struct {
+	Cutoff     float64 // Battery level the forecast is for, 10 by default
+	WindowDays int     // Days of readings the line is fitted to, 30 by default
+	WithinDays int     // Sensors due within this many days are listed by the fleet forecast, 14 by default
+}
+
+type GetSensorsStatusResponse struct {
+	Sensors   []SensorStatus `json:"sensors"`
+	Online    int            `json:"online"`
+	Offline   int            `json:"offline"`
+	NeverSeen int            `json:"never_seen"`
+}
+
+// Zone is a field block bounded by a GeoJSON Polygon, sensors are placed in the zone
+// holding their coordinates unless they were assigned to a zone by hand
+type Zone struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	CropType  string          `json:"crop_type,omitempty"`
+	Boundary  json.RawMessage `json:"boundary"`   // GeoJSON Polygon
+	SensorIDs []string        `json:"sensor_ids"` // Sensors in service in the zone
+	CreatedBy string          `json:"created_by,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type GetZonesResponse struct {
+	Zones []Zone `json:"zones"`
+}
+
+// CreateZoneRequest adds a zone, the boundary is a GeoJSON Polygon or a Feature holding one
+type CreateZoneRequest struct {
+	Name     string          `json:"name"`
+	CropType string          `json:"crop_type,omitempty"`
+	Boundary json.RawMessage `json:"boundary"`
+}
+
+// UpdateZoneRequest changes the fields of a zone that are set
+type UpdateZoneRequest struct {
+	Name     *string         `json:"name,omitempty"`
+	CropType *string         `json:"crop_type,omitempty"`
+	Boundary json.RawMessage `json:"boundary,omitempty"`
+}
+
+// AssignSensorZoneRequest assigns a sensor to a zone by hand, a null zone id hands
+// the sensor's zone back to being worked out from its coordinates
+type AssignSensorZoneRequest struct {
+	ZoneID *string `json:"zone_id"`
+}
+
+// ZoneSeriesQuery selects the series returned by GET /zones/{zone_id}/series,
+// zero values are left out of the request
+type ZoneSeriesQuery struct {
+	MeasurementTypes []string  // Registry ids or aliases, soil moisture and temperature when empty
+	Resolution       string    // "hourly" (default), "daily", "weekly" or "monthly"
+	Start            time.Time // Defaults to a week before End
+	End              time.Time // Defaults to now
+	Raw              bool      // Aggregate readings as stored instead of calibrated
+	Units            []string  // Units to convert readings to, see SensorDataQuery
+}
+
+// ZoneDataBucket summarises the readings of a zone's sensors in one time bucket
+type ZoneDataBucket struct {
+	BucketStart time.Time `json:"bucket_start"` // UTC start of the bucket
+	Mean        float64   `json:"mean"`         // Mean of the sensors' averages, every sensor weighs the same
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	SensorCount int       `json:"sensor_count"` // Sensors with readings in the bucket
+	Count       int       `json:"count"`        // Readings in the bucket
+}
+
+// ZoneSeries is a zone's readings of one measurement type
+type ZoneSeries struct {
+	MeasurementType string           `json:"measurement_type"`
+	Unit            string           `json:"unit"`
+	Buckets         []ZoneDataBucket `json:"buckets"`
+}
+
+// GetZoneSeriesResponse aggregates the readings of every sensor that is or was in a zone
+type GetZoneSeriesResponse struct {
+	ZoneID     string       `json:"zone_id"`
+	Resolution string       `json:"resolution"`
+	Start      time.Time    `json:"start"`
+	End        time.Time    `json:"end"`
+	SensorIDs  []string     `json:"sensor_ids"`
+	Series     []ZoneSeries `json:"series"`
+}
+
+// HeatmapQuery selects the surface returned by GET /heatmap, zero values are left out of
+// the request. Either BoundingBox or ZoneID is required, a zone's surface is clipped to
+// its boundary
+type HeatmapQuery struct {
+	BoundingBox     []float64 // west, south, east, north in degrees
+	ZoneID          string
+	At              time.Time // Time the surface is built for, defaults to now
+	CellSizeMeters  float64   // Defaults to 10
+	MeasurementType string    // Registry id or alias, soil moisture when empty
+	Method          string    // Interpolation method, "idw" (default)
+	Power           float64   // Power of the inverse distance weights, defaults to 2
+	RadiusMeters    float64   // How far sensors' readings reach, defaults to 500
+	Raw             bool      // Interpolate readings as stored instead of calibrated
+	Units           []string  // Units to convert readings to, see SensorDataQuery
+}
+
+// HeatmapSample is a sensor reading a heatmap was interpolated from
+type HeatmapSample struct {
+	SensorID  string    `json:"sensor_id"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Date      time.Time `json:"date"`
+	Value     float64   `json:"value"`
+}
+
+// Heatmap is a raster of values interpolated between the newest readings of the sensors
+// around an area at a point in time. Sensors are only used while their newest reading
+// is recent enough for them to count as online
+type Heatmap struct {
+	MeasurementType string    `json:"measurement_type"`
+	Unit            string    `json:"unit"`
+	At              time.Time `json:"at"`
+	Method          string    `json:"method"`
+	BoundingBox     []float64 `json:"bbox"` // west, south, east, north of the grid
+	Columns         int       `json:"columns"`
+	Rows            int       `json:"rows"`
+	CellSizeMeters  float64   `json:"cell_size_meters"`
+	Min             *float64  `json:"min,omitempty"` // Range of the values, nil if there are none
+	Max             *float64  `json:"max,omitempty"`
+	// Values holds the rows of cells from north to south, each from west to east,
+	// null where no sensor is in range or outside the zone
+	Values  [][]*float64    `json:"values"`
+	Samples []HeatmapSample `json:"samples"`
+}
+
+// AlertRule fires an alert for a sensor once its readings of a measurement type have been
+// above or below a threshold for a duration, and resolves it once they are back past the
+// threshold by the hysteresis. A rule watches one sensor, the sensors of a zone or, when
+// neither is set, every sensor. Thresholds are in the measurement type's unit and are
+// compared with calibrated readings. Recipients are told about the alerts the rule fires,
+// EscalationRecipients as well when a critical one goes unacknowledged: those with
+// notifications enabled are emailed and alert.escalated is sent to webhooks
+type AlertRule struct {
+	ID                   string    `json:"id"`
+	Name                 string    `json:"name"`
+	MeasurementType      string    `json:"measurement_type"`
+	SensorID             string    `json:"sensor_id,omitempty"`
+	ZoneID               string    `json:"zone_id,omitempty"`
+	Operator             string    `json:"operator"` // above or below
+	Threshold            float64   `json:"threshold"`
+	DurationSeconds      int       `json:"duration_seconds"`
+	Hysteresis           float64   `json:"hysteresis"`
+	Severity             string    `json:"severity"` // info, warning or critical
+	Enabled              bool      `json:"enabled"`
+	Recipients           []string  `json:"recipients"`
+	EscalationRecipients []string  `json:"escalation_recipients"`
+	CreatedBy            string    `json:"created_by,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+type GetAlertRulesResponse struct {
+	Rules []AlertRule `json:"rules"`
+}
+
+// CreateAlertRuleRequest adds an alert rule, severity defaults to warning and rules are
+// enabled unless Enabled is false
+type CreateAlertRuleRequest struct {
+	Name                 string   `json:"name"`
+	MeasurementType      string   `json:"measurement_type"` // Registry id or alias
+	SensorID             string   `json:"sensor_id,omitempty"`
+	ZoneID               string   `json:"zone_id,omitempty"`
+	Operator             string   `json:"operator"`
+	Threshold            float64  `json:"threshold"`
+	DurationSeconds      int      `json:"duration_seconds,omitempty"`
+	Hysteresis           float64  `json:"hysteresis,omitempty"`
+	Severity             string   `json:"severity,omitempty"`
+	Enabled              *bool    `json:"enabled,omitempty"`
+	Recipients           []string `json:"recipients,omitempty"`            // Usernames
+	EscalationRecipients []string `json:"escalation_recipients,omitempty"` // Usernames
+}
+
+// UpdateAlertRuleRequest changes the fields of an alert rule that are set, an empty
+// sensor or zone id takes the rule off that sensor or zone
+type UpdateAlertRuleRequest struct {
+	Name                 *string   `json:"name,omitempty"`
+	MeasurementType      *string   `json:"measurement_type,omitempty"`
+	SensorID             *string   `json:"sensor_id,omitempty"`
+	ZoneID               *string   `json:"zone_id,omitempty"`
+	Operator             *string   `json:"operator,omitempty"`
+	Threshold            *float64  `json:"threshold,omitempty"`
+	DurationSeconds      *int      `json:"duration_seconds,omitempty"`
+	Hysteresis           *float64  `json:"hysteresis,omitempty"`
+	Severity             *string   `json:"severity,omitempty"`
+	Enabled              *bool     `json:"enabled,omitempty"`
+	Recipients           *[]string `json:"recipients,omitempty"`
+	EscalationRecipients *[]string `json:"escalation_recipients,omitempty"`
+}
+
+// Alert is fired by a rule for a sensor, it is open until a reading, a user or disabling
+// or deleting the rule resolves it. While open it is firing until someone acknowledges
+// it, and snoozed until its snooze ends. The rule's condition is as it was when the alert
+// fired
+type Alert struct {
+	ID              int64      `json:"id"`
+	RuleID          string     `json:"rule_id,omitempty"` // Omitted once the rule is deleted
+	RuleName        string     `json:"rule_name"`
+	SensorID        string     `json:"sensor_id"`
+	MeasurementType string     `json:"measurement_type"`
+	Operator        string     `json:"operator"`
+	Threshold       float64    `json:"threshold"`
+	Severity        string     `json:"severity"`
+	State           string     `json:"state"`           // firing, acknowledged, snoozed or resolved
+	BreachingSince  time.Time  `json:"breaching_since"` // Reading the condition started holding at
+	FiredAt         time.Time  `json:"fired_at"`        // Reading the alert fired on
+	FiredValue      float64    `json:"fired_value"`
+	Recipients      []string   `json:"recipients"`
+	AcknowledgedAt  *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy  string     `json:"acknowledged_by,omitempty"`
+	SnoozedUntil    *time.Time `json:"snoozed_until,omitempty"`
+	AssignedTo      string     `json:"assigned_to,omitempty"`
+	EscalatedAt     *time.Time `json:"escalated_at,omitempty"`
+	EscalatedTo     []string   `json:"escalated_to,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	ResolvedValue   *float64   `json:"resolved_value,omitempty"` // Omitted unless a reading resolved it
+	ResolvedBy      string     `json:"resolved_by,omitempty"`    // Omitted unless a user resolved it
+	ResolutionNote  string     `json:"resolution_note,omitempty"`
+}
+
+// AlertsQuery selects the alerts returned by GET /alerts, zero values are left out of
+// the request
+type AlertsQuery struct {
+	RuleID   string
+	SensorID string
+	State    string    // firing, acknowledged, snoozed or resolved, any when empty
+	Start    time.Time // Only alerts fired on or after this time
+	End      time.Time // Only alerts fired on or before this time
+	BeforeID int64     // NextBeforeID of the previous page
+	Limit    int
+}
+
+type GetAlertsResponse struct {
+	Alerts []Alert `json:"alerts"`
+	// NextBeforeID is passed as before_id to get the next page, omitted on the last page
+	NextBeforeID *int64 `json:"next_before_id,omitempty"`
+}
+
+// AcknowledgeAlertRequest acknowledges an open alert, which stops it escalating
+type AcknowledgeAlertRequest struct {
+	Note string `json:"note,omitempty"`
+}
+
+// SnoozeAlertRequest snoozes an open alert until a time in the future, an unacknowledged
+// critical alert escalates once the escalation delay has passed after its snooze ends
+type SnoozeAlertRequest struct {
+	Until time.Time `json:"until"`
+	Note  string    `json:"note,omitempty"`
+}
+
+// AssignAlertRequest assigns an open alert to a user, an empty username unassigns it
+type AssignAlertRequest struct {
+	Username string `json:"username"`
+	Note     string `json:"note,omitempty"`
+}
+
+// ResolveAlertRequest resolves an open alert by hand, noting why. The rule fires again
+// if the sensor's readings keep breaking its condition for its duration
+type ResolveAlertRequest struct {
+	Note string `json:"note"`
 }
+
+// AlertEvent is a step in the history of an alert, Actor is omitted for steps the
+// service took itself: firing, escalating and resolving on a reading or rule change
+type AlertEvent struct {
+	ID           int64      `json:"id"`
+	AlertID      int64      `json:"alert_id"`
+	Action       string     `json:"action"` // fired, acknowledged, snoozed, assigned, escalated or resolved
+	Actor        string     `json:"actor,omitempty"`
+	Note         string     `json:"note,omitempty"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	AssignedTo   string     `json:"assigned_to,omitempty"`
+	Recipients   []string   `json:"recipients,omitempty"` // Users an escalation went to
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type GetAlertEventsResponse struct {
+	Events []AlertEvent `json:"events"`
+}
+
+// WebhookEvent is the body of every webhook delivery. Data holds a WebhookReadingsCreated
+// for readings.created, a WebhookSensorEvent for the sensor.* types, a DroneImage for
+// drone_image.uploaded, a WebhookUserCreated for user.created and a WebhookAlertEvent for
+// alert.escalated. Receivers check the X-Nexus-Signature header before trusting it and can
+// use ID to drop replayed duplicates
+type WebhookEvent struct {
+	ID        string          `json:"id"` // Shared by the deliveries of the event to every subscription
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookReading is a reading stored for a sensor
+type WebhookReading struct {
+	ID              int       `json:"id"`
+	MeasurementType string    `json:"measurement_type"`
+	Date            time.Time `json:"date"`
+	Value           float64   `json:"value"` // As stored, before any calibration
+}
+
+// WebhookReadingsCreated is sent once for each batch of readings a sensor reported
+type WebhookReadingsCreated struct {
+	SensorID string           `json:"sensor_id"`
+	Readings []WebhookReading `json:"readings"`
+}
+
+// WebhookSensorEvent is sent when a sensor is created, goes offline or comes back online,
+// is decommissioned or recommissioned, or is deleted. Purged is set on sensor.deleted,
+// which is only sent when a sensor is deleted along with its history
+type WebhookSensorEvent struct {
+	Sensor Sensor `json:"sensor"`
+	Purged bool   `json:"purged,omitempty"`
+}
+
+// WebhookAlertEvent is sent when a critical alert nobody acknowledged is escalated, the
+// alert's EscalatedTo are the users it was escalated to
+type WebhookAlertEvent struct {
+	Alert Alert `json:"alert"`
+}
+
+// WebhookUserCreated is sent when an admin creates a user
+type WebhookUserCreated struct {
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	CreatedBy string `json:"created_by"`
+}
+
+// WebhookSubscription is an endpoint events of EventTypes are posted to. Secret is only
+// returned when the subscription is created
+type WebhookSubscription struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	EventTypes  []string  `json:"event_types"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type GetWebhookSubscriptionsResponse struct {
+	Subscriptions []WebhookSubscription `json:"subscriptions"`
+}
+
+// CreateWebhookSubscriptionRequest adds a webhook subscription, a secret is generated
+// when none is given and subscriptions are enabled unless Enabled is false
+type CreateWebhookSubscriptionRequest struct {
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types"` // readings.created, sensor.offline, sensor.online, sensor.created, sensor.decommissioned, sensor.recommissioned, sensor.deleted, drone_image.uploaded, user.created or alert.escalated
+	Secret      string   `json:"secret,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Enabled     *bool    `json:"enabled,omitempty"`
+}
+
+// UpdateWebhookSubscriptionRequest changes the fields of a webhook subscription that are
+// set, deliveries already queued go to the new url and are signed with the new secret
+type UpdateWebhookSubscriptionRequest struct {
+	URL         *string  `json:"url,omitempty"`
+	EventTypes  []string `json:"event_types,omitempty"`
+	Secret      *string  `json:"secret,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Enabled     *bool    `json:"enabled,omitempty"`
+}
+
+// WebhookDelivery is an event sent to a subscription, pending until the endpoint accepts
+// it with a 2xx response or failed once every attempt was refused
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"` // Body posted to the endpoint
+	Status         string          `json:"status"`  // pending, delivered or failed
+	Attempts       int             `json:"attempts"`
+	Replays        int             `json:"replays"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"` // Omitted unless pending
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"` // Omitted when the last attempt got no response
+	LastError      string          `json:"last_error,omitempty"`
+	LastResponse   string          `json:"last_response,omitempty"` // Start of the body of the last response
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// WebhookDeliveriesQuery selects the deliveries returned by
+// GET /admin/webhooks/{subscription_id}/deliveries, zero values are left out of the request
+type WebhookDeliveriesQuery struct {
+	Status   string // pending, delivered or failed, any when empty
+	BeforeID int64  // NextBeforeID of the previous page
+	Limit    int
+}
+
+type GetWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	// NextBeforeID is passed as before_id to get the next page, omitted on the last page
+	NextBeforeID *int64 `json:"next_before_id,omitempty"`
+}
+
+// ReplayWebhookDeliveriesRequest queues failed deliveries of a subscription to be sent
+// again, every failed delivery when DeliveryIDs is empty
+type ReplayWebhookDeliveriesRequest struct {
+	DeliveryIDs []int64 `json:"delivery_ids,omitempty"`
+}
+
+type ReplayWebhookDeliveriesResponse struct {
+	Replayed int `json:"replayed"`
+}
+
+// NotificationPreferences is where and when the current user is emailed notifications.
+// Emails due during quiet hours are held back until they end, quiet hours that end before
+// they start run past midnight. UpdatedAt is omitted until the user saves preferences
+type NotificationPreferences struct {
+	Email           string     `json:"email"`
+	Enabled         bool       `json:"enabled"`
+	TimeZone        string     `json:"timezone"`                    // IANA time zone name such as Africa/Nairobi
+	QuietHoursStart string     `json:"quiet_hours_start,omitempty"` // HH:MM in TimeZone, omitted without quiet hours
+	QuietHoursEnd   string     `json:"quiet_hours_end,omitempty"`   // HH:MM in TimeZone, omitted without quiet hours
+	DigestHour      int        `json:"digest_hour"`                 // Hour of the day in TimeZone the daily digest is sent at
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+}
+
+// UpdateNotificationPreferencesRequest replaces the current user's notification
+// preferences. Notifications are enabled unless Enabled is false, TimeZone defaults to
+// UTC and DigestHour to 7. Leave both quiet hours empty for none
+type UpdateNotificationPreferencesRequest struct {
+	Email           string `json:"email"`
+	Enabled         *bool  `json:"enabled,omitempty"`
+	TimeZone        string `json:"timezone,omitempty"`
+	QuietHoursStart string `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   string `json:"quiet_hours_end,omitempty"`
+	DigestHour      *int   `json:"digest_hour,omitempty"`
+}
+
+// NotificationSubscription is a kind of notification the current user is emailed, about
+// one sensor or every sensor when SensorID is omitted
+type NotificationSubscription struct {
+	ID               string    `json:"id"`
+	Kind             string    `json:"kind"` // sensor_offline, battery_low or daily_digest
+	SensorID         string    `json:"sensor_id,omitempty"`
+	BatteryThreshold *float64  `json:"battery_threshold,omitempty"` // Percent, battery_low only
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type GetNotificationSubscriptionsResponse struct {
+	Subscriptions []NotificationSubscription `json:"subscriptions"`
+}
+
+// CreateNotificationSubscriptionRequest subscribes the current user to a kind of
+// notification. SensorID narrows sensor_offline and battery_low notifications to one
+// sensor, BatteryThreshold defaults to 20 percent
+type CreateNotificationSubscriptionRequest struct {
+	Kind             string   `json:"kind"`
+	SensorID         string   `json:"sensor_id,omitempty"`
+	BatteryThreshold *float64 `json:"battery_threshold,omitempty"`
+}
+
+// Email is an email to the current user, pending until the mail server accepts it or
+// failed once every attempt was refused
+type Email struct {
+	ID            int64      `json:"id"`
+	Recipient     string     `json:"recipient"`
+	Kind          string     `json:"kind"` // The notification kind it was sent for, or test
+	Subject       string     `json:"subject"`
+	Status        string     `json:"status"` // pending, sent or failed
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // Omitted unless pending, the end of quiet hours for held back emails
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// EmailsQuery selects the emails returned by GET /notifications/emails, zero values are
+// left out of the request
+type EmailsQuery struct {
+	Status   string // pending, sent or failed, any when empty
+	BeforeID int64  // NextBeforeID of the previous page
+	Limit    int
+}
+
+type GetEmailsResponse struct {
+	Emails []Email `json:"emails"`
+	// NextBeforeID is passed as before_id to get the next page, omitted on the last page
+	NextBeforeID *int64 `json:"next_before_id,omitempty"`
+}
+
+// SensorDataExportQuery selects the readings exported by GET /exports/sensors,
+// zero values are left out of the request
+type SensorDataExportQuery struct {
+	SensorIDs        []string  // Sensors to export, all sensors when empty
+	MeasurementTypes []string  // Registry ids or the aliases moisture, temperature and battery, all types when empty
+	Start            time.Time // Only readings on or after this time
+	End              time.Time // Only readings on or before this time
+	Format           string    // "csv" (default) or "ndjson"
+	Raw              bool      // Export readings as stored instead of calibrated
+	Units            []string  // Units to convert readings to, see SensorDataQuery
+}
+
+// SensorDataExportRow is one exported reading, each line of an ndjson export is one row
+type SensorDataExportRow struct {
+	SensorID        string    `json:"sensor_id"`
+	MeasurementType string    `json:"measurement_type"`
+	Date            time.Time `json:"date"`
+	Value           float64   `json:"value"`
+	Unit            string    `json:"unit"`
+}
+
+// SensorDataImportMapping says how the columns of an imported csv file map to readings.
+// Files are either long, with one reading per row named by MeasurementTypeColumn and
+// ValueColumn, or wide, with one column per measurement type listed in ValueColumns
+type SensorDataImportMapping struct {
+	// SensorIDColumn names the column holding the sensor id, or set SensorID when
+	// every row of the file is from the same sensor
+	SensorIDColumn string `json:"sensor_id_column,omitempty"`
+	SensorID       string `json:"sensor_id,omitempty"`
+	DateColumn     string `json:"date_column"`
+	// DateFormat is a go time layout for the date column, defaults to RFC3339.
+	// Dates without a zone are read as UTC
+	DateFormat            string `json:"date_format,omitempty"`
+	MeasurementTypeColumn string `json:"measurement_type_column,omitempty"`
+	ValueColumn           string `json:"value_column,omitempty"`
+	// ValueColumns maps column names to the measurement type of the values in them,
+	// empty cells are skipped
+	ValueColumns map[string]string `json:"value_columns,omitempty"`
+}
+
+// ImportJob reports the progress of a sensor data import
+type ImportJob struct {
+	ID                  string                  `json:"id"`
+	Status              string                  `json:"status"`
+	FileName            string                  `json:"file_name"`
+	Mapping             SensorDataImportMapping `json:"mapping"`
+	OnConflict          string                  `json:"on_conflict"`
+	CreatedBy           string                  `json:"created_by"`
+	TotalRows           int                     `json:"total_rows"`
+	ProcessedRows       int                     `json:"processed_rows"`
+	InsertedReadings    int                     `json:"inserted_readings"`
+	UpdatedReadings     int                     `json:"updated_readings"`
+	DuplicateReadings   int                     `json:"duplicate_readings"`
+	QuarantinedReadings int                     `json:"quarantined_readings"`
+	RejectedRows        int                     `json:"rejected_rows"`
+	Error               string                  `json:"error,omitempty"`
+	CreatedAt           time.Time               `json:"created_at"`
+	StartedAt           *time.Time              `json:"started_at,omitempty"`
+	FinishedAt          *time.Time              `json:"finished_at,omitempty"`
+}
+
+// SensorCalibration converts a sensor's raw readings of a measurement type taken from
+// EffectiveFrom until its next calibration of the type takes effect
+type SensorCalibration struct {
+	ID              int64     `json:"id"`
+	SensorID        string    `json:"sensor_id"`
+	MeasurementType string    `json:"measurement_type"`
+	EffectiveFrom   time.Time `json:"effective_from"`
+	// Coefficients of the calibration polynomial from the constant term up,
+	// calibrated = c0 + c1*raw + c2*raw^2 + ...
+	Coefficients []float64 `json:"coefficients"`
+	Note         string    `json:"note,omitempty"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CreateSensorCalibrationRequest adds a calibration to a sensor, either a linear one with
+// Gain (default 1) and Offset (default 0) or a polynomial one with Coefficients
+type CreateSensorCalibrationRequest struct {
+	MeasurementType string    `json:"measurement_type"`
+	EffectiveFrom   time.Time `json:"effective_from"`
+	Gain            *float64  `json:"gain,omitempty"`
+	Offset          *float64  `json:"offset,omitempty"`
+	Coefficients    []float64 `json:"coefficients,omitempty"`
+	Note            string    `json:"note,omitempty"`
+}
+
+type GetSensorCalibrationsResponse struct {
+	SensorID     string              `json:"sensor_id"`
+	Calibrations []SensorCalibration `json:"calibrations"`
+}
+
+// QuarantinedReading is a reading held back on ingest for breaking a quality rule
+// of its measurement type, until an admin releases or discards it
+type QuarantinedReading struct {
+	ID              int64      `json:"id"`
+	SensorID        string     `json:"sensor_id"`
+	MeasurementType string     `json:"measurement_type"`
+	Date            time.Time  `json:"date"`
+	Value           float64    `json:"value"`
+	ReasonCode      string     `json:"reason_code"` // out_of_range or rate_of_change
+	Reason          string     `json:"reason"`
+	Status          string     `json:"status"` // pending, released or discarded
+	CreatedAt       time.Time  `json:"created_at"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	ReviewedBy      string     `json:"reviewed_by,omitempty"`
+	MeasurementID   int        `json:"measurement_id,omitempty"` // Stored reading a released reading became
+}
+
+type GetQuarantinedReadingsResponse struct {
+	Readings []QuarantinedReading `json:"readings"`
+	// NextAfterID is passed as after_id to get the next page, omitted on the last page
+	NextAfterID *int64 `json:"next_after_id,omitempty"`
+}
+
+// ImportJobError is a row of an imported file that was not saved
+type ImportJobError struct {
+	RowNumber int    `json:"row_number"`
+	Message   string `json:"message"`
+}
+
+type GetImportJobErrorsResponse struct {
+	JobID  string           `json:"job_id"`
+	Errors []ImportJobError `json:"errors"`
+	// NextAfterRow is passed as after_row to get the next page, omitted on the last page
+	NextAfterRow *int `json:"next_after_row,omitempty"`
+}
+
 type ConsumptionData struct {
 	Date        time.Time `json:"date"`
 	CapacityKwh float64   `json:"capacity_kwh"`
@@ -97,6 +1011,85 @@ type Sensor struct {
 	Location         string    `json:"location"`
 	InstallationDate time.Time `json:"installation_date"`
 	SensorCoordinates
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty"` // Time of the newest reading of any type
+	OnlineSince *time.Time `json:"online_since,omitempty"` // When the sensor last came back online
+	// The sensor is offline once it goes ReportingIntervalSeconds + OfflineGraceSeconds without a reading
+	ReportingIntervalSeconds int `json:"reporting_interval_seconds"`
+	OfflineGraceSeconds      int `json:"offline_grace_seconds"`
+	// Decommissioned sensors are hidden from active views but their readings are kept
+	DecommissionedAt   *time.Time `json:"decommissioned_at,omitempty"`
+	DecommissionReason string     `json:"decommission_reason,omitempty"`
+	DecommissionedBy   string     `json:"decommissioned_by,omitempty"`
+	ReplacesSensorID   string     `json:"replaces_sensor_id,omitempty"` // The sensor this one was installed in place of
+	// The zone the sensor is in, worked out from its coordinates unless it was assigned by hand
+	ZoneID               string `json:"zone_id,omitempty"`
+	ZoneAssignedManually bool   `json:"zone_assigned_manually"`
+}
+
+// AddSensorRequest registers a sensor, coordinates are optional but must be set together
+type AddSensorRequest struct {
+	EUI       string   `json:"eui"`
+	Name      string   `json:"name"`
+	Location  string   `json:"location"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// DecommissionSensorRequest takes a sensor out of service, the reason is optional
+type DecommissionSensorRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReplaceSensorRequest names the sensor installed in place of a sensor, it is
+// created if it hasn't been registered or auto-created yet
+type ReplaceSensorRequest struct {
+	NewSensorID string `json:"new_sensor_id"`
+}
+
+// UpdateSensorRequest changes the fields of a sensor that are set
+type UpdateSensorRequest struct {
+	Name                     *string    `json:"name,omitempty"`
+	Location                 *string    `json:"location,omitempty"`
+	Latitude                 *float64   `json:"latitude,omitempty"`  // -90 to 90
+	Longitude                *float64   `json:"longitude,omitempty"` // -180 to 180
+	InstallationDate         *time.Time `json:"installation_date,omitempty"`
+	ReportingIntervalSeconds *int       `json:"reporting_interval_seconds,omitempty"`
+	OfflineGraceSeconds      *int       `json:"offline_grace_seconds,omitempty"`
+}
+
+// SensorsQuery filters the sensors listed by GET /sensors, zero values are left out of
+// the request. Sensors that haven't been placed at coordinates are left out by the
+// bounding box and radius filters
+type SensorsQuery struct {
+	IncludeDecommissioned bool
+	// BoundingBox is west, south, east, north in degrees, the box crosses
+	// the antimeridian when west is greater than east
+	BoundingBox []float64
+	// Near and RadiusMeters select the sensors within RadiusMeters of Near, nearest first
+	Near         *SensorCoordinates
+	RadiusMeters float64
+}
+
+// GeoJSONGeometry is a GeoJSON Point geometry
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"` // longitude, latitude
+}
+
+// GeoJSONFeature is a GeoJSON Feature, sensors that haven't been placed have no geometry
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Geometry   *GeoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONFeatureCollection is returned by GET /sensors?format=geojson, each sensor's
+// properties hold its state and newest reading of each measurement type as flat
+// fields so GIS tools can show them as attributes
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
 }
 
 type SensorMoistureData struct {
@@ -159,6 +1152,14 @@ type GetBatteryLevelDataResponse struct {
 	IsOnline          *bool              `json:"is_online,omitempty"`           // nil if no data, true/false if data exists
 	LastDataTimestamp *time.Time         `json:"last_data_timestamp,omitempty"` // Most recent data timestamp
 	BatteryLevelData  []BatteryLevelData `json:"battery_level_data"`
+	Unit              string             `json:"unit"`                  // Unit of the battery levels, % unless converted
+	NextCursor        string             `json:"next_cursor,omitempty"` // Empty when there are no more pages
+	// Resolution is hourly or daily when the range reaches back past the raw readings'
+	// retention and the readings are the averages of the rollups of that resolution
+	Resolution string `json:"resolution,omitempty"`
+	// RawRetentionCutoff is set when a page of raw readings without a start was asked
+	// for, readings before it are rolled up so the page may be missing them
+	RawRetentionCutoff *time.Time `json:"raw_retention_cutoff,omitempty"`
 }
 
 type SetBatteryLevelDataResponse struct {
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+const (
+	AlertActionFired        = "fired"
+	AlertActionAcknowledged = "acknowledged"
+	AlertActionSnoozed      = "snoozed"
+	AlertActionAssigned     = "assigned"
+	AlertActionEscalated    = "escalated"
+	AlertActionResolved     = "resolved"
+)
+
+var (
+	ErrorAlertResolved     = errors.New("alert is already resolved")
+	ErrorAlertAcknowledged = errors.New("alert is already acknowledged")
+)
+
+// AlertEvent is a step in the history of an alert, taken by a user or, when Actor is
+// empty, by the service itself
+type AlertEvent struct {
+	ID      int64 `bun:"id,pk,autoincrement"`
+	AlertID int64 `bun:"alert_id"`
+	// Action is one of the AlertAction values
+	Action       string     `bun:"action"`
+	Actor        string     `bun:"actor,nullzero"`
+	Note         string     `bun:"note,nullzero"`
+	SnoozedUntil *time.Time `bun:"snoozed_until"`
+	AssignedTo   string     `bun:"assigned_to,nullzero"`
+	Recipients   []string   `bun:"recipients,array,nullzero"` // Users an escalation went to
+	CreatedAt    time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// addAlertEvents records events in the history of their alerts
+func addAlertEvents(ctx context.Context, tx bun.Tx, events []AlertEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	_, err := tx.NewInsert().Model(&events).Returning("*").Exec(ctx)
+
+	return err
+}
+
+// GetAlertEvents returns the history of an alert oldest first
+func GetAlertEvents(ctx context.Context, db *bun.DB, alertID int64) ([]AlertEvent, error) {
+	var events []AlertEvent
+	err := db.NewSelect().Model(&events).Where("alert_id = ?", alertID).OrderExpr("id ASC").Scan(ctx)
+
+	return events, err
+}
+
+// ActOnAlert locks the open alert with the given id, lets act change it and saves the
+// changes along with the event act returns, all in one transaction. It returns
+// ErrorAlertResolved if the alert is already resolved, and the error of act if it fails
+func ActOnAlert(ctx context.Context, db *bun.DB, id int64, act func(alert *Alert) (AlertEvent, error)) (Alert, AlertEvent, error) {
+	var alert Alert
+	var event AlertEvent
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().Model(&alert).Where("id = ?", id).For("UPDATE").Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrorNoAlert
+		}
+		if err != nil {
+			return err
+		}
+
+		if alert.ResolvedAt != nil {
+			return ErrorAlertResolved
+		}
+
+		event, err = act(&alert)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model(&alert).
+			Column("acknowledged_at", "acknowledged_by", "snoozed_until", "assigned_to", "resolved_at", "resolved_by", "resolution_note").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		event.AlertID = alert.ID
+		_, err = tx.NewInsert().Model(&event).Returning("*").Exec(ctx)
+
+		return err
+	})
+
+	return alert, event, err
+}
+
+// EscalateAlerts escalates the open critical alerts nobody acknowledged within delay of
+// them firing or their snooze ending to the escalation recipients of their rule, passing
+// each alert escalated to notify in the same transaction and returning them. Alerts
+// another instance of the service is escalating are skipped
+func EscalateAlerts(ctx context.Context, db *bun.DB, now time.Time, delay time.Duration, notify func(ctx context.Context, tx bun.Tx, alert Alert) error) ([]Alert, error) {
+	var escalated []Alert
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var candidates []Alert
+		err := tx.NewSelect().
+			Model(&candidates).
+			Where("severity = ?", AlertSeverityCritical).
+			Where("resolved_at IS NULL").
+			Where("acknowledged_at IS NULL").
+			Where("escalated_at IS NULL").
+			Where("GREATEST(created_at, COALESCE(snoozed_until, created_at)) <= ?", now.Add(-delay)).
+			OrderExpr("id ASC").
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil || len(candidates) == 0 {
+			return err
+		}
+
+		var ruleIDs []uuid.UUID
+		for _, alert := range candidates {
+			if alert.RuleID != nil {
+				ruleIDs = append(ruleIDs, *alert.RuleID)
+			}
+		}
+		recipients := make(map[uuid.UUID][]string)
+		if len(ruleIDs) > 0 {
+			var rules []AlertRule
+			err = tx.NewSelect().Model(&rules).Where("id IN (?)", bun.In(ruleIDs)).Scan(ctx)
+			if err != nil {
+				return err
+			}
+			for _, rule := range rules {
+				recipients[rule.ID] = rule.EscalationRecipients
+			}
+		}
+
+		var events []AlertEvent
+		for _, alert := range candidates {
+			if !alert.EscalationDue(delay, now) {
+				continue
+			}
+
+			escalatedTo := []string{}
+			if alert.RuleID != nil {
+				escalatedTo = append(escalatedTo, recipients[*alert.RuleID]...)
+			}
+
+			_, err = tx.NewUpdate().
+				Model((*Alert)(nil)).
+				Set("escalated_at = ?", now).
+				Set("escalated_to = ?", pgdialect.Array(escalatedTo)).
+				Where("id = ?", alert.ID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			alert.EscalatedAt, alert.EscalatedTo = &now, escalatedTo
+			err = notify(ctx, tx, alert)
+			if err != nil {
+				return err
+			}
+
+			escalated = append(escalated, alert)
+			events = append(events, AlertEvent{AlertID: alert.ID, Action: AlertActionEscalated, Recipients: escalatedTo})
+		}
+
+		return addAlertEvents(ctx, tx, events)
+	})
+
+	return escalated, err
+}
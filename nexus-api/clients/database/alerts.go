@@ -0,0 +1,438 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+const (
+	AlertOperatorAbove = "above"
+	AlertOperatorBelow = "below"
+
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+
+	AlertStateFiring       = "firing"
+	AlertStateAcknowledged = "acknowledged"
+	AlertStateSnoozed      = "snoozed"
+	AlertStateResolved     = "resolved"
+)
+
+var (
+	ErrorNoAlertRule = errors.New("no alert rule found")
+	ErrorNoAlert     = errors.New("no alert found")
+)
+
+// AlertRule fires an alert for a sensor once its readings of MeasurementType have been
+// past Threshold for Duration, and resolves it once they are back by Hysteresis. A rule
+// watches SensorID, the sensors of ZoneID or, when neither is set, every sensor
+type AlertRule struct {
+	ID              uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	Name            string     `bun:"name"`
+	MeasurementType string     `bun:"measurement_type"`
+	SensorID        string     `bun:"sensor_id,nullzero"`
+	ZoneID          *uuid.UUID `bun:"zone_id,type:uuid"`
+	// Operator is one of the AlertOperator values
+	Operator        string  `bun:"operator"`
+	Threshold       float64 `bun:"threshold"`
+	DurationSeconds int     `bun:"duration_seconds"`
+	Hysteresis      float64 `bun:"hysteresis"`
+	// Severity is one of the AlertSeverity values
+	Severity string `bun:"severity"`
+	Enabled  bool   `bun:"enabled"`
+	// Recipients are the usernames told about the alerts the rule fires, and
+	// EscalationRecipients those told as well when a critical one goes unacknowledged
+	Recipients           []string  `bun:"recipients,array,nullzero"`
+	EscalationRecipients []string  `bun:"escalation_recipients,array,nullzero"`
+	CreatedBy            string    `bun:"created_by,nullzero"`
+	CreatedAt            time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt            time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// Duration returns how long the condition must hold before the rule fires
+func (r AlertRule) Duration() time.Duration {
+	return time.Duration(r.DurationSeconds) * time.Second
+}
+
+// Breached returns whether a reading breaks the rule's condition
+func (r AlertRule) Breached(value float64) bool {
+	if r.Operator == AlertOperatorBelow {
+		return value < r.Threshold
+	}
+
+	return value > r.Threshold
+}
+
+// Recovered returns whether a reading is far enough back past the threshold to resolve
+// an alert the rule fired
+func (r AlertRule) Recovered(value float64) bool {
+	if r.Operator == AlertOperatorBelow {
+		return value >= r.Threshold+r.Hysteresis
+	}
+
+	return value <= r.Threshold-r.Hysteresis
+}
+
+// Save adds the rule
+func (r *AlertRule) Save(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewInsert().Model(r).Returning("*").Exec(ctx)
+
+	return err
+}
+
+// Update saves changes to the rule. With restart its evaluation starts over from the next
+// readings and the alerts it has open are resolved, which callers ask for when the rule
+// is disabled or its condition changes
+func (r *AlertRule) Update(ctx context.Context, db *bun.DB, restart bool) error {
+	r.UpdatedAt = time.Now()
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().
+			Model(r).
+			Column("name", "measurement_type", "sensor_id", "zone_id", "operator", "threshold", "duration_seconds", "hysteresis", "severity", "enabled", "recipients", "escalation_recipients", "updated_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return ErrorNoAlertRule
+		}
+		if !restart {
+			return nil
+		}
+
+		_, err = tx.NewDelete().Model((*AlertRuleState)(nil)).Where("rule_id = ?", r.ID).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		return closeOpenAlerts(ctx, tx, r.ID, r.UpdatedAt, "Rule disabled or changed")
+	})
+}
+
+// closeOpenAlerts resolves the alerts a rule has open without a resolving reading,
+// recording note as the reason in their history
+func closeOpenAlerts(ctx context.Context, tx bun.Tx, ruleID uuid.UUID, at time.Time, note string) error {
+	var closed []int64
+	_, err := tx.NewUpdate().
+		Model((*Alert)(nil)).
+		Set("resolved_at = ?", at).
+		Set("resolution_note = ?", note).
+		Where("rule_id = ?", ruleID).
+		Where("resolved_at IS NULL").
+		Returning("id").
+		Exec(ctx, &closed)
+	if err != nil {
+		return err
+	}
+
+	events := make([]AlertEvent, 0, len(closed))
+	for _, alertID := range closed {
+		events = append(events, AlertEvent{AlertID: alertID, Action: AlertActionResolved, Note: note})
+	}
+
+	return addAlertEvents(ctx, tx, events)
+}
+
+// GetAlertRules returns every alert rule ordered by name
+func GetAlertRules(ctx context.Context, db *bun.DB) ([]AlertRule, error) {
+	var rules []AlertRule
+	err := db.NewSelect().Model(&rules).OrderExpr("name ASC, id ASC").Scan(ctx)
+
+	return rules, err
+}
+
+// GetAlertRule returns the rule with the given id or ErrorNoAlertRule if there is none
+func GetAlertRule(ctx context.Context, db *bun.DB, id uuid.UUID) (AlertRule, error) {
+	var rule AlertRule
+	err := db.NewSelect().Model(&rule).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AlertRule{}, ErrorNoAlertRule
+		}
+		return AlertRule{}, err
+	}
+
+	return rule, nil
+}
+
+// DeleteAlertRule deletes a rule, resolving the alerts it has open. Its alert history
+// is kept
+func DeleteAlertRule(ctx context.Context, db *bun.DB, id uuid.UUID) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := closeOpenAlerts(ctx, tx, id, time.Now(), "Rule deleted")
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.NewDelete().Model((*AlertRule)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return ErrorNoAlertRule
+		}
+
+		return nil
+	})
+}
+
+// GetSensorAlertRules returns the enabled rules watching the sensor's readings of the
+// measurement types, zoneID is the zone the sensor is in, nil for none
+func GetSensorAlertRules(ctx context.Context, db *bun.DB, sensorID string, zoneID *uuid.UUID, measurementTypes []string) ([]AlertRule, error) {
+	var rules []AlertRule
+	if len(measurementTypes) == 0 {
+		return rules, nil
+	}
+
+	err := db.NewSelect().
+		Model(&rules).
+		Where("enabled").
+		Where("measurement_type IN (?)", bun.In(measurementTypes)).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			q = q.Where("sensor_id = ?", sensorID).
+				WhereOr("(sensor_id IS NULL AND zone_id IS NULL)")
+			if zoneID != nil {
+				q = q.WhereOr("zone_id = ?", *zoneID)
+			}
+			return q
+		}).
+		OrderExpr("id ASC").
+		Scan(ctx)
+
+	return rules, err
+}
+
+// AlertRuleState is where a rule's evaluation of a sensor's readings got to
+type AlertRuleState struct {
+	RuleID   uuid.UUID `bun:"rule_id,pk,type:uuid"`
+	SensorID string    `bun:"sensor_id,pk"`
+	// LastReadingAt is the newest reading evaluated, older readings are skipped
+	LastReadingAt *time.Time `bun:"last_reading_at"`
+	// BreachingSince is the first of the run of readings breaking the condition
+	// the sensor is in, nil when it isn't in one or an alert already fired for it
+	BreachingSince *time.Time `bun:"breaching_since"`
+}
+
+// Alert is fired by a rule for a sensor, it is open until ResolvedAt is set. While open
+// users can acknowledge it, snooze it or assign it to someone, and it escalates to the
+// rule's escalation recipients if it is critical and nobody acknowledges it in time
+type Alert struct {
+	ID              int64      `bun:"id,pk,autoincrement"`
+	RuleID          *uuid.UUID `bun:"rule_id,type:uuid"` // nil once the rule is deleted
+	RuleName        string     `bun:"rule_name"`
+	SensorID        string     `bun:"sensor_id"`
+	MeasurementType string     `bun:"measurement_type"`
+	Operator        string     `bun:"operator"`
+	Threshold       float64    `bun:"threshold"`
+	Severity        string     `bun:"severity"`
+	BreachingSince  time.Time  `bun:"breaching_since"`
+	FiredAt         time.Time  `bun:"fired_at"`
+	FiredValue      float64    `bun:"fired_value"`
+	ResolvedAt      *time.Time `bun:"resolved_at"`
+	// ResolvedValue is the reading that resolved the alert, nil if the rule was
+	// disabled or deleted while it was open
+	ResolvedValue *float64 `bun:"resolved_value"`
+	// ResolvedBy is the user who resolved the alert by hand, empty if a reading or a
+	// change to the rule resolved it
+	ResolvedBy     string     `bun:"resolved_by,nullzero"`
+	ResolutionNote string     `bun:"resolution_note,nullzero"`
+	Recipients     []string   `bun:"recipients,array,nullzero"` // The rule's recipients when it fired
+	AcknowledgedAt *time.Time `bun:"acknowledged_at"`
+	AcknowledgedBy string     `bun:"acknowledged_by,nullzero"`
+	SnoozedUntil   *time.Time `bun:"snoozed_until"`
+	AssignedTo     string     `bun:"assigned_to,nullzero"`
+	EscalatedAt    *time.Time `bun:"escalated_at"`
+	EscalatedTo    []string   `bun:"escalated_to,array,nullzero"`
+	CreatedAt      time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// State returns the state of the alert at now: AlertStateResolved once it is resolved,
+// AlertStateSnoozed until its snooze ends, AlertStateAcknowledged once someone has
+// acknowledged it and AlertStateFiring otherwise
+func (a Alert) State(now time.Time) string {
+	switch {
+	case a.ResolvedAt != nil:
+		return AlertStateResolved
+	case a.SnoozedUntil != nil && now.Before(*a.SnoozedUntil):
+		return AlertStateSnoozed
+	case a.AcknowledgedAt != nil:
+		return AlertStateAcknowledged
+	}
+
+	return AlertStateFiring
+}
+
+// EscalatesAt returns when the alert escalates if nobody acknowledges it, delay after it
+// fired or, if it was snoozed, after the snooze ends
+func (a Alert) EscalatesAt(delay time.Duration) time.Time {
+	start := a.CreatedAt
+	if a.SnoozedUntil != nil && a.SnoozedUntil.After(start) {
+		start = *a.SnoozedUntil
+	}
+
+	return start.Add(delay)
+}
+
+// EscalationDue returns whether the alert is critical, open, unacknowledged and not yet
+// escalated at now, delay after it fired or its snooze ended
+func (a Alert) EscalationDue(delay time.Duration, now time.Time) bool {
+	return a.Severity == AlertSeverityCritical &&
+		a.ResolvedAt == nil &&
+		a.AcknowledgedAt == nil &&
+		a.EscalatedAt == nil &&
+		!now.Before(a.EscalatesAt(delay))
+}
+
+// EvaluateAlertRule locks the state of the rule for the sensor and the alert it has open
+// for it, if any, lets evaluate step them through new readings and saves what it returns:
+// the new state and the alerts it fired or resolved, which are returned as saved
+func EvaluateAlertRule(ctx context.Context, db *bun.DB, ruleID uuid.UUID, sensorID string, evaluate func(state AlertRuleState, open *Alert) (AlertRuleState, []*Alert)) ([]Alert, error) {
+	var changed []Alert
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		state := AlertRuleState{RuleID: ruleID, SensorID: sensorID}
+		_, err := tx.NewInsert().Model(&state).On("CONFLICT DO NOTHING").Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = tx.NewSelect().Model(&state).WherePK().For("UPDATE").Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		var open *Alert
+		var alert Alert
+		err = tx.NewSelect().
+			Model(&alert).
+			Where("rule_id = ?", ruleID).
+			Where("sensor_id = ?", sensorID).
+			Where("resolved_at IS NULL").
+			Scan(ctx)
+		if err == nil {
+			open = &alert
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		state, alerts := evaluate(state, open)
+
+		var events []AlertEvent
+		for _, alert := range alerts {
+			if alert.ID == 0 {
+				_, err = tx.NewInsert().Model(alert).Returning("*").Exec(ctx)
+				events = append(events, AlertEvent{AlertID: alert.ID, Action: AlertActionFired})
+			} else {
+				_, err = tx.NewUpdate().Model(alert).Column("resolved_at", "resolved_value").WherePK().Exec(ctx)
+			}
+			if err != nil {
+				return err
+			}
+			if alert.ResolvedAt != nil {
+				events = append(events, AlertEvent{AlertID: alert.ID, Action: AlertActionResolved})
+			}
+			changed = append(changed, *alert)
+		}
+
+		err = addAlertEvents(ctx, tx, events)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().Model(&state).Column("last_reading_at", "breaching_since").WherePK().Exec(ctx)
+
+		return err
+	})
+
+	return changed, err
+}
+
+// AlertFilter selects alerts from the history, newest first
+type AlertFilter struct {
+	RuleID   *uuid.UUID
+	SensorID string
+	// State is one of the AlertState values, empty for any
+	State string
+	// Only alerts fired in [Start, End], zero values leave the range open
+	Start time.Time
+	End   time.Time
+	// BeforeID continues the history after the last alert of a previous page
+	BeforeID int64
+	Limit    int
+}
+
+// GetAlerts returns the alerts selected by filter ordered newest first, fetching one more
+// than the limit so callers can tell if there is a next page
+func GetAlerts(ctx context.Context, db *bun.DB, filter AlertFilter) ([]Alert, error) {
+	query := db.NewSelect().Model((*Alert)(nil))
+
+	if filter.RuleID != nil {
+		query = query.Where("rule_id = ?", *filter.RuleID)
+	}
+	if filter.SensorID != "" {
+		query = query.Where("sensor_id = ?", filter.SensorID)
+	}
+	switch filter.State {
+	case AlertStateFiring:
+		query = query.Where("resolved_at IS NULL").
+			Where("acknowledged_at IS NULL").
+			Where("(snoozed_until IS NULL OR snoozed_until <= CURRENT_TIMESTAMP)")
+	case AlertStateAcknowledged:
+		query = query.Where("resolved_at IS NULL").
+			Where("acknowledged_at IS NOT NULL").
+			Where("(snoozed_until IS NULL OR snoozed_until <= CURRENT_TIMESTAMP)")
+	case AlertStateSnoozed:
+		query = query.Where("resolved_at IS NULL").Where("snoozed_until > CURRENT_TIMESTAMP")
+	case AlertStateResolved:
+		query = query.Where("resolved_at IS NOT NULL")
+	}
+	if !filter.Start.IsZero() {
+		query = query.Where("fired_at >= ?", filter.Start)
+	}
+	if !filter.End.IsZero() {
+		query = query.Where("fired_at <= ?", filter.End)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit + 1)
+	}
+
+	var alerts []Alert
+	err := query.OrderExpr("id DESC").Scan(ctx, &alerts)
+
+	return alerts, err
+}
+
+// GetAlert returns the alert with the given id or ErrorNoAlert if there is none
+func GetAlert(ctx context.Context, db *bun.DB, id int64) (Alert, error) {
+	var alert Alert
+	err := db.NewSelect().Model(&alert).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Alert{}, ErrorNoAlert
+		}
+		return Alert{}, err
+	}
+
+	return alert, nil
+}
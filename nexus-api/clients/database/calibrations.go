@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+var (
+	ErrorNoSensorCalibration        = errors.New("no sensor calibration found")
+	ErrorDuplicateSensorCalibration = errors.New("sensor already has a calibration of the measurement type effective from that date")
+)
+
+// SensorCalibration converts a sensor's raw readings of a measurement type taken on or
+// after EffectiveFrom, until the next calibration of the sensor and type takes over
+type SensorCalibration struct {
+	ID              int64     `bun:"id,pk,autoincrement"`
+	SensorID        string    `bun:"sensor_id"`
+	MeasurementType string    `bun:"measurement_type"`
+	EffectiveFrom   time.Time `bun:"effective_from"`
+	// Coefficients of the calibration polynomial from the constant term up,
+	// a linear calibration is {offset, gain}
+	Coefficients []float64 `bun:"coefficients,array"`
+	Note         string    `bun:"note,nullzero"`
+	CreatedBy    string    `bun:"created_by,nullzero"`
+	CreatedAt    time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// Apply returns the calibrated value of a raw reading
+func (c SensorCalibration) Apply(value float64) float64 {
+	var calibrated float64
+	for i := len(c.Coefficients) - 1; i >= 0; i-- {
+		calibrated = calibrated*value + c.Coefficients[i]
+	}
+
+	return calibrated
+}
+
+// Save adds the calibration, returning ErrorDuplicateSensorCalibration if the sensor
+// already has a calibration of the measurement type effective from the same date
+func (c *SensorCalibration) Save(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewInsert().Model(c).Returning("*").Exec(ctx)
+	if isUniqueViolation(err) {
+		return ErrorDuplicateSensorCalibration
+	}
+
+	return err
+}
+
+// SensorCalibrations holds the calibrations of sensors and measurement types
+// ordered by the date they take effect
+type SensorCalibrations map[string][]SensorCalibration
+
+// lookup returns the calibration of the sensor and measurement type in effect at date
+func (sc SensorCalibrations) lookup(sensorID string, measurementType string, date time.Time) (SensorCalibration, bool) {
+	calibrations := sc[sensorSeriesID(sensorID, measurementType)]
+
+	// the first calibration taking effect after date is preceded by the one in effect
+	next := sort.Search(len(calibrations), func(i int) bool {
+		return calibrations[i].EffectiveFrom.After(date)
+	})
+	if next == 0 {
+		return SensorCalibration{}, false
+	}
+
+	return calibrations[next-1], true
+}
+
+// Calibrate returns the calibrated value of a sensor's raw reading taken at date, readings
+// taken before the sensor's first calibration of the measurement type are returned as is
+func (sc SensorCalibrations) Calibrate(sensorID string, measurementType string, date time.Time, value float64) float64 {
+	calibration, ok := sc.lookup(sensorID, measurementType, date)
+	if !ok {
+		return value
+	}
+
+	return calibration.Apply(value)
+}
+
+// CalibrateBucket calibrates the summary of a sensor's raw readings in the bucket starting
+// at bucket.BucketStart with the calibration in effect at the bucket's start. The result
+// is exact for linear calibrations that don't change within the bucket, otherwise it is
+// an approximation as the raw readings behind the summary are not read
+func (sc SensorCalibrations) CalibrateBucket(sensorID string, measurementType string, bucket SensorDataBucket) SensorDataBucket {
+	calibration, ok := sc.lookup(sensorID, measurementType, bucket.BucketStart)
+	if !ok {
+		return bucket
+	}
+
+	bucket.Avg = calibration.Apply(bucket.Avg)
+	bucket.Min, bucket.Max = calibration.Apply(bucket.Min), calibration.Apply(bucket.Max)
+	if bucket.Min > bucket.Max {
+		bucket.Min, bucket.Max = bucket.Max, bucket.Min
+	}
+
+	return bucket
+}
+
+// GetSensorCalibrations returns the calibrations of the sensors and measurement types,
+// empty slices don't restrict the calibrations returned
+func GetSensorCalibrations(ctx context.Context, db *bun.DB, sensorIDs []string, measurementTypes []string) (SensorCalibrations, error) {
+	query := db.NewSelect().Model((*SensorCalibration)(nil))
+	if len(sensorIDs) > 0 {
+		query = query.Where("sensor_id IN (?)", bun.In(sensorIDs))
+	}
+	if len(measurementTypes) > 0 {
+		query = query.Where("measurement_type IN (?)", bun.In(measurementTypes))
+	}
+
+	var calibrations []SensorCalibration
+	err := query.OrderExpr("sensor_id, measurement_type, effective_from").Scan(ctx, &calibrations)
+	if err != nil {
+		return nil, err
+	}
+
+	bySeries := make(SensorCalibrations)
+	for _, calibration := range calibrations {
+		series := sensorSeriesID(calibration.SensorID, calibration.MeasurementType)
+		bySeries[series] = append(bySeries[series], calibration)
+	}
+
+	return bySeries, nil
+}
+
+// ListSensorCalibrations returns a sensor's calibrations ordered by measurement type and
+// the date they take effect, measurementType restricts them to one type when not empty
+func ListSensorCalibrations(ctx context.Context, db *bun.DB, sensorID string, measurementType string) ([]SensorCalibration, error) {
+	query := db.NewSelect().Model((*SensorCalibration)(nil)).Where("sensor_id = ?", sensorID)
+	if measurementType != "" {
+		query = query.Where("measurement_type = ?", measurementType)
+	}
+
+	var calibrations []SensorCalibration
+	err := query.OrderExpr("measurement_type, effective_from").Scan(ctx, &calibrations)
+
+	return calibrations, err
+}
+
+// DeleteSensorCalibration removes a calibration of the sensor, the readings it covered
+// are calibrated by the calibration before it again. Returns ErrorNoSensorCalibration
+// if the sensor has no calibration with the id
+func DeleteSensorCalibration(ctx context.Context, db *bun.DB, sensorID string, id int64) error {
+	result, err := db.NewDelete().
+		Model((*SensorCalibration)(nil)).
+		Where("id = ?", id).
+		Where("sensor_id = ?", sensorID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrorNoSensorCalibration
+	}
+
+	return nil
+}
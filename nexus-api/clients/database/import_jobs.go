@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+const (
+	ImportJobStatusPending   = "pending"
+	ImportJobStatusRunning   = "running"
+	ImportJobStatusCompleted = "completed"
+	ImportJobStatusFailed    = "failed"
+)
+
+var (
+	ErrorNoImportJob = errors.New("no import job found")
+)
+
+// ImportJob tracks the progress of loading a file of historical readings
+type ImportJob struct {
+	ID         uuid.UUID       `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	Status     string          `bun:"status"`
+	FileName   string          `bun:"file_name"`
+	Mapping    json.RawMessage `bun:"mapping,type:jsonb"`
+	OnConflict string          `bun:"on_conflict"`
+	CreatedBy  string          `bun:"created_by"`
+	// TotalRows is the number of data rows in the file, excluding the header
+	TotalRows     int `bun:"total_rows"`
+	ProcessedRows int `bun:"processed_rows"`
+	// a row of a wide file holds several readings, so readings are counted
+	// separately from the rows that were rejected
+	InsertedReadings  int `bun:"inserted_readings"`
+	UpdatedReadings   int `bun:"updated_readings"`
+	DuplicateReadings int `bun:"duplicate_readings"`
+	// QuarantinedReadings broke a quality rule and are held back for review
+	QuarantinedReadings int        `bun:"quarantined_readings"`
+	RejectedRows        int        `bun:"rejected_rows"`
+	Error               string     `bun:"error,nullzero"`
+	CreatedAt           time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	StartedAt           *time.Time `bun:"started_at"`
+	FinishedAt          *time.Time `bun:"finished_at"`
+}
+
+// ImportJobError records why one row of an imported file was not saved
+type ImportJobError struct {
+	ID    int       `bun:"id,pk,autoincrement"`
+	JobID uuid.UUID `bun:"job_id,type:uuid"`
+	// RowNumber is the 1 based line of the row in the file, the header is row 1
+	RowNumber int    `bun:"row_number"`
+	Message   string `bun:"message"`
+}
+
+// Save inserts the job, filling in the generated id and creation time
+func (j *ImportJob) Save(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewInsert().Model(j).Returning("*").Exec(ctx)
+
+	return err
+}
+
+// UpdateProgress stores the job's status, row counts, error and start and finish times
+func (j *ImportJob) UpdateProgress(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewUpdate().
+		Model(j).
+		Column("status", "processed_rows", "inserted_readings", "updated_readings", "duplicate_readings", "quarantined_readings", "rejected_rows", "error", "started_at", "finished_at").
+		WherePK().
+		Exec(ctx)
+
+	return err
+}
+
+// GetImportJob returns the import job with the given id or ErrorNoImportJob if there is none
+func GetImportJob(ctx context.Context, db *bun.DB, id uuid.UUID) (ImportJob, error) {
+	var job ImportJob
+	err := db.NewSelect().Model(&job).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ImportJob{}, ErrorNoImportJob
+		}
+		return ImportJob{}, err
+	}
+
+	return job, nil
+}
+
+// FailInterruptedImportJobs marks jobs that were still pending or running when
+// the service last stopped as failed, returning the number of jobs marked
+func FailInterruptedImportJobs(ctx context.Context, db *bun.DB) (int, error) {
+	result, err := db.NewUpdate().
+		Model((*ImportJob)(nil)).
+		Set("status = ?", ImportJobStatusFailed).
+		Set("error = ?", "import was interrupted by a restart of the service").
+		Set("finished_at = ?", time.Now()).
+		Where("status IN (?)", bun.In([]string{ImportJobStatusPending, ImportJobStatusRunning})).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	return int(rowsAffected), err
+}
+
+// SaveImportJobErrors inserts the row errors of a job
+func SaveImportJobErrors(ctx context.Context, db *bun.DB, jobErrors []ImportJobError) error {
+	if len(jobErrors) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().Model(&jobErrors).Exec(ctx)
+
+	return err
+}
+
+// GetImportJobErrors returns up to limit of the job's row errors after the given
+// row number in row order, a limit of 0 returns every remaining error
+func GetImportJobErrors(ctx context.Context, db *bun.DB, jobID uuid.UUID, afterRow int, limit int) ([]ImportJobError, error) {
+	var jobErrors []ImportJobError
+	query := db.NewSelect().
+		Model(&jobErrors).
+		Where("job_id = ?", jobID).
+		Where("row_number > ?", afterRow).
+		OrderExpr("row_number ASC, id ASC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Scan(ctx)
+
+	return jobErrors, err
+}
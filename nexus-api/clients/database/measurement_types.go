@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	MeasurementTypeSoilMoisture    = "soil_moisture"
+	MeasurementTypeSoilTemperature = "soil_temperature"
+	MeasurementTypeBatteryLevel    = "battery_level"
+)
+
+var (
+	ErrorNoMeasurementType        = errors.New("no measurement type found")
+	ErrorDuplicateMeasurementType = errors.New("measurement type id or mqtt identifier already in use")
+)
+
+// MeasurementType is an entry in the registry of kinds of readings sensors report
+type MeasurementType struct {
+	ID          string `bun:"id,pk"`
+	DisplayName string `bun:"display_name"`
+	Unit        string `bun:"unit"`
+	// MinValue and MaxValue bound the plausible readings, nil for no bound
+	MinValue *float64 `bun:"min_value"`
+	MaxValue *float64 `bun:"max_value"`
+	// MaxRatePerHour bounds how fast readings of a sensor may change, nil for no bound
+	MaxRatePerHour *float64 `bun:"max_rate_per_hour"`
+	// MQTTIdentifier is the measurement or status id gateways publish readings under
+	MQTTIdentifier string `bun:"mqtt_identifier,nullzero"`
+	// Days raw readings, hourly rollups and daily rollups are kept, 0 keeps them forever
+	RawRetentionDays    int       `bun:"raw_retention_days,nullzero"`
+	HourlyRetentionDays int       `bun:"hourly_retention_days,nullzero"`
+	DailyRetentionDays  int       `bun:"daily_retention_days,nullzero"`
+	CreatedAt           time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// Save adds the measurement type to the registry, returning
+// ErrorDuplicateMeasurementType if its id or mqtt identifier is taken
+func (mt *MeasurementType) Save(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewInsert().Model(mt).Returning("*").Exec(ctx)
+	if isUniqueViolation(err) {
+		return ErrorDuplicateMeasurementType
+	}
+
+	return err
+}
+
+// Update saves changes to the measurement type, returning
+// ErrorDuplicateMeasurementType if its mqtt identifier is taken
+func (mt *MeasurementType) Update(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewUpdate().Model(mt).WherePK().ExcludeColumn("created_at").Exec(ctx)
+	if isUniqueViolation(err) {
+		return ErrorDuplicateMeasurementType
+	}
+
+	return err
+}
+
+// GetMeasurementTypes returns every registered measurement type ordered by id
+func GetMeasurementTypes(ctx context.Context, db *bun.DB) ([]MeasurementType, error) {
+	var measurementTypes []MeasurementType
+	err := db.NewSelect().Model(&measurementTypes).OrderExpr("id ASC").Scan(ctx)
+
+	return measurementTypes, err
+}
+
+// GetMeasurementType returns the registered measurement type with the given id
+// or ErrorNoMeasurementType if there is none
+func GetMeasurementType(ctx context.Context, db *bun.DB, id string) (MeasurementType, error) {
+	var measurementType MeasurementType
+	err := db.NewSelect().Model(&measurementType).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return MeasurementType{}, ErrorNoMeasurementType
+		}
+		return MeasurementType{}, err
+	}
+
+	return measurementType, nil
+}
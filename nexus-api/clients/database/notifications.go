@@ -0,0 +1,516 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Kinds of notification users can subscribe to
+const (
+	NotificationKindSensorOffline = "sensor_offline"
+	NotificationKindBatteryLow    = "battery_low"
+	NotificationKindDailyDigest   = "daily_digest"
+)
+
+// NotificationKinds are the kinds of notification users can subscribe to
+var NotificationKinds = []string{
+	NotificationKindSensorOffline,
+	NotificationKindBatteryLow,
+	NotificationKindDailyDigest,
+}
+
+// Kinds of email sent without a subscription: EmailKindTest to users checking their
+// address and EmailKindAlertEscalated to the escalation recipients of an alert rule.
+// Every other email is of the kind of notification it was sent for
+const (
+	EmailKindTest           = "test"
+	EmailKindAlertEscalated = "alert_escalated"
+)
+
+// DefaultBatteryLowThreshold is the battery level in percent battery_low subscriptions
+// are notified below when they don't set their own threshold
+const DefaultBatteryLowThreshold = 20.0
+
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+)
+
+var (
+	ErrorNoNotificationPreferences         = errors.New("no notification preferences found")
+	ErrorNoNotificationSubscription        = errors.New("no notification subscription found")
+	ErrorDuplicateNotificationSubscription = errors.New("already subscribed to this notification")
+)
+
+// NotificationPreferences is where and when a user is emailed their notifications
+type NotificationPreferences struct {
+	bun.BaseModel `bun:"table:notification_preferences"`
+
+	UserName string `bun:"user_name,pk"`
+	Email    string `bun:"email"`
+	Enabled  bool   `bun:"enabled"`
+	// TimeZone is the IANA name of the time zone quiet hours, the digest hour and the
+	// times in emails are in
+	TimeZone string `bun:"timezone"`
+	// QuietHoursStart and QuietHoursEnd are minutes after local midnight, emails due
+	// between them are held back until they end. Both are nil when there are no quiet hours
+	QuietHoursStart *int `bun:"quiet_hours_start"`
+	QuietHoursEnd   *int `bun:"quiet_hours_end"`
+	// DigestHour is the local hour the daily digest is sent at
+	DigestHour     int        `bun:"digest_hour"`
+	LastDigestDate *time.Time `bun:"last_digest_date,type:date"`
+	UpdatedAt      time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// Save adds or replaces the user's preferences, keeping when their last digest was sent
+func (p *NotificationPreferences) Save(ctx context.Context, db *bun.DB) error {
+	p.UpdatedAt = time.Now()
+
+	_, err := db.NewInsert().
+		Model(p).
+		On("CONFLICT (user_name) DO UPDATE").
+		Set("email = EXCLUDED.email").
+		Set("enabled = EXCLUDED.enabled").
+		Set("timezone = EXCLUDED.timezone").
+		Set("quiet_hours_start = EXCLUDED.quiet_hours_start").
+		Set("quiet_hours_end = EXCLUDED.quiet_hours_end").
+		Set("digest_hour = EXCLUDED.digest_hour").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("*").
+		Exec(ctx)
+
+	return err
+}
+
+// Location returns the time zone of the preferences, UTC if it is unknown
+func (p NotificationPreferences) Location() *time.Location {
+	location, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// SendAt returns when an email due at now can be sent, now unless it falls in the quiet
+// hours in which case it is the end of them
+func (p NotificationPreferences) SendAt(now time.Time) time.Time {
+	if p.QuietHoursStart == nil || p.QuietHoursEnd == nil || *p.QuietHoursStart == *p.QuietHoursEnd {
+		return now
+	}
+	start, end := *p.QuietHoursStart, *p.QuietHoursEnd
+
+	local := now.In(p.Location())
+	minute := local.Hour()*60 + local.Minute()
+
+	quiet := minute >= start && minute < end
+	if start > end {
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return now
+	}
+
+	sendAt := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+	if !sendAt.After(local) {
+		sendAt = time.Date(local.Year(), local.Month(), local.Day()+1, end/60, end%60, 0, 0, local.Location())
+	}
+
+	return sendAt
+}
+
+// GetNotificationPreferences returns the user's preferences or
+// ErrorNoNotificationPreferences if they haven't set any
+func GetNotificationPreferences(ctx context.Context, db *bun.DB, username string) (NotificationPreferences, error) {
+	var preferences NotificationPreferences
+	err := db.NewSelect().Model(&preferences).Where("user_name = ?", username).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return NotificationPreferences{}, ErrorNoNotificationPreferences
+		}
+		return NotificationPreferences{}, err
+	}
+
+	return preferences, nil
+}
+
+// GetEnabledNotificationPreferences returns the preferences of the users who have
+// notifications enabled out of usernames. db can be the transaction saving what they are
+// notified about
+func GetEnabledNotificationPreferences(ctx context.Context, db bun.IDB, usernames []string) ([]NotificationPreferences, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	var preferences []NotificationPreferences
+	err := db.NewSelect().
+		Model(&preferences).
+		Where("user_name IN (?)", bun.In(usernames)).
+		Where("enabled").
+		OrderExpr("user_name ASC").
+		Scan(ctx)
+
+	return preferences, err
+}
+
+// NotificationSubscription is a kind of notification a user is emailed, about one sensor
+// or every sensor when SensorID is empty
+type NotificationSubscription struct {
+	ID       uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	UserName string    `bun:"user_name"`
+	// Kind is one of the NotificationKind values
+	Kind     string `bun:"kind"`
+	SensorID string `bun:"sensor_id,nullzero"`
+	// BatteryThreshold is the level in percent battery_low notifications are sent below,
+	// DefaultBatteryLowThreshold when nil
+	BatteryThreshold *float64  `bun:"battery_threshold"`
+	CreatedAt        time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// Threshold returns the battery level battery_low notifications are sent below
+func (s NotificationSubscription) Threshold() float64 {
+	if s.BatteryThreshold == nil {
+		return DefaultBatteryLowThreshold
+	}
+
+	return *s.BatteryThreshold
+}
+
+// Save adds the subscription, returning ErrorDuplicateNotificationSubscription if the
+// user is already subscribed to the same kind of notification for the same sensors
+func (s *NotificationSubscription) Save(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewInsert().Model(s).Returning("*").Exec(ctx)
+	if isUniqueViolation(err) {
+		return ErrorDuplicateNotificationSubscription
+	}
+
+	return err
+}
+
+// GetNotificationSubscriptions returns the user's subscriptions oldest first
+func GetNotificationSubscriptions(ctx context.Context, db *bun.DB, username string) ([]NotificationSubscription, error) {
+	var subscriptions []NotificationSubscription
+	err := db.NewSelect().
+		Model(&subscriptions).
+		Where("user_name = ?", username).
+		OrderExpr("created_at ASC, id ASC").
+		Scan(ctx)
+
+	return subscriptions, err
+}
+
+// DeleteNotificationSubscription deletes one of the user's subscriptions, returning
+// ErrorNoNotificationSubscription if they have none with the given id
+func DeleteNotificationSubscription(ctx context.Context, db *bun.DB, username string, id uuid.UUID) error {
+	result, err := db.NewDelete().
+		Model((*NotificationSubscription)(nil)).
+		Where("id = ?", id).
+		Where("user_name = ?", username).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrorNoNotificationSubscription
+	}
+
+	return nil
+}
+
+// NotificationRecipient is a subscription to notify along with the preferences of the
+// user it belongs to
+type NotificationRecipient struct {
+	Subscription NotificationSubscription
+	Preferences  NotificationPreferences
+}
+
+// GetNotificationRecipients returns the subscriptions to notifications of kind about the
+// sensor, or about every sensor, of the users with notifications enabled. db can be the
+// transaction saving what the notifications are about
+func GetNotificationRecipients(ctx context.Context, db bun.IDB, kind string, sensorID string) ([]NotificationRecipient, error) {
+	var subscriptions []NotificationSubscription
+	err := db.NewSelect().
+		Model(&subscriptions).
+		Where("kind = ?", kind).
+		Where("(sensor_id IS NULL OR sensor_id = ?)", sensorID).
+		Where("user_name IN (SELECT user_name FROM notification_preferences WHERE enabled)").
+		OrderExpr("user_name ASC, id ASC").
+		Scan(ctx)
+	if err != nil || len(subscriptions) == 0 {
+		return nil, err
+	}
+
+	usernames := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		usernames = append(usernames, subscription.UserName)
+	}
+
+	var preferences []NotificationPreferences
+	err = db.NewSelect().Model(&preferences).Where("user_name IN (?)", bun.In(usernames)).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	preferencesByUser := make(map[string]NotificationPreferences, len(preferences))
+	for _, userPreferences := range preferences {
+		preferencesByUser[userPreferences.UserName] = userPreferences
+	}
+
+	recipients := make([]NotificationRecipient, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		recipients = append(recipients, NotificationRecipient{
+			Subscription: subscription,
+			Preferences:  preferencesByUser[subscription.UserName],
+		})
+	}
+
+	return recipients, nil
+}
+
+// NotificationLowBattery records that a battery_low subscription was notified about a
+// sensor, until the sensor's battery is back above the subscription's threshold
+type NotificationLowBattery struct {
+	bun.BaseModel `bun:"table:notification_low_batteries"`
+
+	SubscriptionID uuid.UUID `bun:"subscription_id,pk,type:uuid"`
+	SensorID       string    `bun:"sensor_id,pk"`
+	NotifiedAt     time.Time `bun:"notified_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// UpdateLowBatteryNotifications checks the sensor's newest battery level against the
+// battery_low subscriptions covering it, passing the ones it just dropped below the
+// threshold of to notify in the same transaction. A subscription is notified once per
+// drop, it is notified again after the level has been back at or above its threshold
+func UpdateLowBatteryNotifications(ctx context.Context, db *bun.DB, sensorID string, notify func(ctx context.Context, tx bun.Tx, recipient NotificationRecipient, battery SensorLastSeen) error) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var battery SensorLastSeen
+		err := tx.NewSelect().
+			Model(&battery).
+			Where("sensor_id = ?", sensorID).
+			Where("measurement_type = ?", MeasurementTypeBatteryLevel).
+			Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		recipients, err := GetNotificationRecipients(ctx, tx, NotificationKindBatteryLow, sensorID)
+		if err != nil {
+			return err
+		}
+
+		for _, recipient := range recipients {
+			lowBattery := NotificationLowBattery{SubscriptionID: recipient.Subscription.ID, SensorID: sensorID}
+
+			if battery.LastValue >= recipient.Subscription.Threshold() {
+				_, err = tx.NewDelete().Model(&lowBattery).WherePK().Exec(ctx)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			result, err := tx.NewInsert().Model(&lowBattery).On("CONFLICT DO NOTHING").Exec(ctx)
+			if err != nil {
+				return err
+			}
+			added, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			if added > 0 {
+				err = notify(ctx, tx, recipient, battery)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// ClaimDailyDigests passes the preferences of each user subscribed to the daily digest
+// whose digest hour has come today in their time zone and who hasn't had today's digest
+// yet to queue, then records it as sent for today in the same transaction. Users another
+// instance of the service is sending digests to are skipped
+func ClaimDailyDigests(ctx context.Context, db *bun.DB, now time.Time, queue func(ctx context.Context, tx bun.Tx, preferences NotificationPreferences) error) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var preferences []NotificationPreferences
+		err := tx.NewSelect().
+			Model(&preferences).
+			Where("enabled").
+			Where("user_name IN (SELECT user_name FROM notification_subscriptions WHERE kind = ?)", NotificationKindDailyDigest).
+			OrderExpr("user_name ASC").
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, userPreferences := range preferences {
+			local := now.In(userPreferences.Location())
+			today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+			if local.Hour() < userPreferences.DigestHour {
+				continue
+			}
+			if userPreferences.LastDigestDate != nil && !userPreferences.LastDigestDate.Before(today) {
+				continue
+			}
+
+			err = queue(ctx, tx, userPreferences)
+			if err != nil {
+				return err
+			}
+
+			userPreferences.LastDigestDate = &today
+			_, err = tx.NewUpdate().Model(&userPreferences).Column("last_digest_date").WherePK().Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DigestAlertCounts returns how many alerts fired since the given time and how many
+// alerts are still open
+func DigestAlertCounts(ctx context.Context, db bun.IDB, since time.Time) (int, int, error) {
+	fired, err := db.NewSelect().Model((*Alert)(nil)).Where("fired_at >= ?", since).Count(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	open, err := db.NewSelect().Model((*Alert)(nil)).Where("resolved_at IS NULL").Count(ctx)
+
+	return fired, open, err
+}
+
+// Email is an email to a user, pending until the SMTP server accepts it or every attempt
+// to send it failed
+type Email struct {
+	bun.BaseModel `bun:"table:email_outbox"`
+
+	ID        int64  `bun:"id,pk,autoincrement"`
+	UserName  string `bun:"user_name"`
+	Recipient string `bun:"recipient"`
+	// Kind is the NotificationKind the email was sent for or one of the EmailKind values
+	Kind     string `bun:"kind"`
+	Subject  string `bun:"subject"`
+	TextBody string `bun:"text_body"`
+	HTMLBody string `bun:"html_body"`
+	// Status is one of the EmailStatus values
+	Status        string     `bun:"status"`
+	Attempts      int        `bun:"attempts"`
+	NextAttemptAt time.Time  `bun:"next_attempt_at,nullzero,notnull,default:current_timestamp"`
+	LastAttemptAt *time.Time `bun:"last_attempt_at"`
+	LastError     string     `bun:"last_error,nullzero"`
+	SentAt        *time.Time `bun:"sent_at"`
+	CreatedAt     time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// QueueEmails adds emails to the outbox, db can be the transaction saving what they are about
+func QueueEmails(ctx context.Context, db bun.IDB, emails []Email) error {
+	if len(emails) == 0 {
+		return nil
+	}
+
+	_, err := db.NewInsert().Model(&emails).Returning("*").Exec(ctx)
+
+	return err
+}
+
+// ClaimEmails takes up to limit pending emails due at now and pushes their next attempt
+// back by lease, so other instances of the service leave them alone while they are sent.
+// An email whose attempt is cut short is sent again once the lease ends
+func ClaimEmails(ctx context.Context, db *bun.DB, now time.Time, lease time.Duration, limit int) ([]Email, error) {
+	var emails []Email
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().
+			Model(&emails).
+			Where("status = ?", EmailStatusPending).
+			Where("next_attempt_at <= ?", now).
+			OrderExpr("next_attempt_at ASC, id ASC").
+			Limit(limit).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil || len(emails) == 0 {
+			return err
+		}
+
+		ids := make([]int64, 0, len(emails))
+		for _, email := range emails {
+			ids = append(ids, email.ID)
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*Email)(nil)).
+			Set("next_attempt_at = ?", now.Add(lease)).
+			Where("id IN (?)", bun.In(ids)).
+			Exec(ctx)
+
+		return err
+	})
+
+	return emails, err
+}
+
+// RecordEmailAttempt saves the outcome of an attempt to send an email
+func RecordEmailAttempt(ctx context.Context, db *bun.DB, email *Email) error {
+	_, err := db.NewUpdate().
+		Model(email).
+		Column("status", "attempts", "next_attempt_at", "last_attempt_at", "last_error", "sent_at").
+		WherePK().
+		Exec(ctx)
+
+	return err
+}
+
+// EmailFilter selects emails sent to a user, newest first
+type EmailFilter struct {
+	UserName string
+	// Status is one of the EmailStatus values, empty for any
+	Status string
+	// BeforeID continues the list after the last email of a previous page
+	BeforeID int64
+	Limit    int
+}
+
+// GetEmails returns the emails selected by filter ordered newest first, fetching one more
+// than the limit so callers can tell if there is a next page
+func GetEmails(ctx context.Context, db *bun.DB, filter EmailFilter) ([]Email, error) {
+	query := db.NewSelect().
+		Model((*Email)(nil)).
+		Where("user_name = ?", filter.UserName)
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit + 1)
+	}
+
+	var emails []Email
+	err := query.OrderExpr("id DESC").Scan(ctx, &emails)
+
+	return emails, err
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"nexus-api/logging"
@@ -78,3 +79,14 @@ func NewPostgresClient(config PostgresDatabaseConfig) (PostgresClient, error) {
 func (pg *PostgresClient) HealthCheck() error {
 	return pg.Ping()
 }
+
+// isUniqueViolation returns true if err was caused by a
+// write that would break a unique constraint
+func isUniqueViolation(err error) bool {
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		return pgErr.Field('C') == "23505"
+	}
+
+	return false
+}
@@ -0,0 +1,321 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	// QuarantineReasonOutOfRange is a reading outside its measurement type's min and max
+	QuarantineReasonOutOfRange = "out_of_range"
+	// QuarantineReasonRateOfChange is a reading that changed faster than its measurement
+	// type's max rate per hour since the sensor's previous reading
+	QuarantineReasonRateOfChange = "rate_of_change"
+
+	QuarantineStatusPending   = "pending"
+	QuarantineStatusReleased  = "released"
+	QuarantineStatusDiscarded = "discarded"
+)
+
+var (
+	ErrorNoQuarantinedReading       = errors.New("no quarantined reading found")
+	ErrorQuarantinedReadingReviewed = errors.New("quarantined reading has already been reviewed")
+	ErrorReadingAlreadyStored       = errors.New("a reading is already stored for the sensor, measurement type and date")
+)
+
+// QuarantinedReading is a reading held back on ingest for breaking a quality rule
+type QuarantinedReading struct {
+	ID              int64     `bun:"id,pk,autoincrement"`
+	SensorID        string    `bun:"sensor_id"`
+	MeasurementType string    `bun:"measurement_type"`
+	Date            time.Time `bun:"date"`
+	Value           float64   `bun:"value"`
+	// ReasonCode is one of the QuarantineReason values, Reason explains it
+	ReasonCode string `bun:"reason_code"`
+	Reason     string `bun:"reason"`
+	// Status is one of the QuarantineStatus values
+	Status     string     `bun:"status,default:'pending'"`
+	CreatedAt  time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	ReviewedAt *time.Time `bun:"reviewed_at"`
+	ReviewedBy string     `bun:"reviewed_by,nullzero"`
+	// MeasurementID is the stored reading a released reading became
+	MeasurementID int `bun:"measurement_id,nullzero"`
+}
+
+// qualityViolation is why a reading breaks a quality rule, empty for readings that don't
+type qualityViolation struct {
+	Code   string
+	Reason string
+}
+
+// checkSensorMeasurements applies the valid range and max rate of change rules of the
+// measurement types to the readings, returning the violations lined up with readings.
+// Each series is checked in date order against its last good reading, starting from
+// previous, the newest stored reading before the batch
+func checkSensorMeasurements(readings []SensorMeasurement, measurementTypes map[string]MeasurementType, previous map[string]SensorMeasurement) []qualityViolation {
+	violations := make([]qualityViolation, len(readings))
+
+	order := make([]int, len(readings))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return readings[order[a]].Date.Before(readings[order[b]].Date)
+	})
+
+	lastGood := make(map[string]SensorMeasurement, len(previous))
+	for series, reading := range previous {
+		lastGood[series] = reading
+	}
+
+	for _, i := range order {
+		reading := readings[i]
+		rules := measurementTypes[reading.MeasurementType]
+
+		if rules.MinValue != nil && reading.Value < *rules.MinValue {
+			violations[i] = qualityViolation{QuarantineReasonOutOfRange, fmt.Sprintf("value %g is below the minimum of %g", reading.Value, *rules.MinValue)}
+			continue
+		}
+		if rules.MaxValue != nil && reading.Value > *rules.MaxValue {
+			violations[i] = qualityViolation{QuarantineReasonOutOfRange, fmt.Sprintf("value %g is above the maximum of %g", reading.Value, *rules.MaxValue)}
+			continue
+		}
+
+		series := sensorSeriesID(reading.SensorID, reading.MeasurementType)
+		last, ok := lastGood[series]
+		if rules.MaxRatePerHour != nil && ok && reading.Date.After(last.Date) {
+			rate := math.Abs(reading.Value-last.Value) / reading.Date.Sub(last.Date).Hours()
+			if rate > *rules.MaxRatePerHour {
+				violations[i] = qualityViolation{QuarantineReasonRateOfChange, fmt.Sprintf("changed %g per hour since the reading at %s, more than the maximum of %g", rate, last.Date.UTC().Format(time.RFC3339), *rules.MaxRatePerHour)}
+				continue
+			}
+		}
+
+		lastGood[series] = reading
+	}
+
+	return violations
+}
+
+// getPreviousSensorMeasurements returns the newest stored reading before the earliest
+// of the batch for each series of the readings whose measurement type limits its rate
+// of change
+func getPreviousSensorMeasurements(ctx context.Context, db bun.IDB, readings []SensorMeasurement, measurementTypes map[string]MeasurementType) (map[string]SensorMeasurement, error) {
+	earliest := make(map[string]SensorMeasurement)
+	for _, reading := range readings {
+		if measurementTypes[reading.MeasurementType].MaxRatePerHour == nil {
+			continue
+		}
+
+		series := sensorSeriesID(reading.SensorID, reading.MeasurementType)
+		if first, ok := earliest[series]; !ok || reading.Date.Before(first.Date) {
+			earliest[series] = reading
+		}
+	}
+
+	previous := make(map[string]SensorMeasurement, len(earliest))
+	for series, first := range earliest {
+		var reading SensorMeasurement
+		err := db.NewSelect().
+			Model(&reading).
+			Where("sensor_id = ?", first.SensorID).
+			Where("measurement_type = ?", first.MeasurementType).
+			Where("date < ?", first.Date).
+			OrderExpr("date DESC").
+			Limit(1).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		previous[series] = reading
+	}
+
+	return previous, nil
+}
+
+// quarantineSensorMeasurements moves the rows breaking a quality rule of their measurement
+// type into quarantine, marking their results, and returns the remaining rows to store
+// along with their indexes in results
+func quarantineSensorMeasurements(ctx context.Context, tx bun.Tx, rows []SensorMeasurement, rowIndexes []int, results []SensorMeasurementResult) ([]SensorMeasurement, []int, error) {
+	if len(rows) == 0 {
+		return rows, rowIndexes, nil
+	}
+
+	var types []MeasurementType
+	err := tx.NewSelect().Model(&types).Scan(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	measurementTypes := make(map[string]MeasurementType, len(types))
+	for _, measurementType := range types {
+		measurementTypes[measurementType.ID] = measurementType
+	}
+
+	previous, err := getPreviousSensorMeasurements(ctx, tx, rows, measurementTypes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	violations := checkSensorMeasurements(rows, measurementTypes, previous)
+
+	var accepted []SensorMeasurement
+	var acceptedIndexes []int
+	var quarantined []QuarantinedReading
+	for i, row := range rows {
+		if violations[i].Code == "" {
+			accepted = append(accepted, row)
+			acceptedIndexes = append(acceptedIndexes, rowIndexes[i])
+			continue
+		}
+
+		quarantined = append(quarantined, QuarantinedReading{
+			SensorID:        row.SensorID,
+			MeasurementType: row.MeasurementType,
+			Date:            row.Date,
+			Value:           row.Value,
+			ReasonCode:      violations[i].Code,
+			Reason:          violations[i].Reason,
+			Status:          QuarantineStatusPending,
+		})
+		results[rowIndexes[i]] = SensorMeasurementResult{Status: MeasurementStatusQuarantined, Reason: violations[i].Code + ": " + violations[i].Reason}
+	}
+
+	for start := 0; start < len(quarantined); start += measurementInsertChunkSize {
+		chunk := quarantined[start:min(start+measurementInsertChunkSize, len(quarantined))]
+		_, err = tx.NewInsert().Model(&chunk).ExcludeColumn("id", "created_at").Exec(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return accepted, acceptedIndexes, nil
+}
+
+// QuarantineFilter selects and pages quarantined readings, empty fields don't restrict them
+type QuarantineFilter struct {
+	Status          string
+	SensorID        string
+	MeasurementType string
+	// AfterID resumes a listing after the last reading of the previous page
+	AfterID int64
+	Limit   int
+}
+
+// GetQuarantinedReadings returns the quarantined readings selected by filter ordered by id,
+// fetching one more than the limit so callers can tell if there is a next page
+func GetQuarantinedReadings(ctx context.Context, db *bun.DB, filter QuarantineFilter) ([]QuarantinedReading, error) {
+	query := db.NewSelect().Model((*QuarantinedReading)(nil)).Where("id > ?", filter.AfterID)
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.SensorID != "" {
+		query = query.Where("sensor_id = ?", filter.SensorID)
+	}
+	if filter.MeasurementType != "" {
+		query = query.Where("measurement_type = ?", filter.MeasurementType)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit + 1)
+	}
+
+	var readings []QuarantinedReading
+	err := query.OrderExpr("id ASC").Scan(ctx, &readings)
+
+	return readings, err
+}
+
+// reviewQuarantinedReading locks the pending quarantined reading, lets review act on it
+// and marks it with status, all in one transaction
+func reviewQuarantinedReading(ctx context.Context, db *bun.DB, id int64, reviewedBy string, status string, review func(ctx context.Context, tx bun.Tx, reading *QuarantinedReading) error) (QuarantinedReading, error) {
+	var reading QuarantinedReading
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().Model(&reading).Where("id = ?", id).For("UPDATE").Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrorNoQuarantinedReading
+		}
+		if err != nil {
+			return err
+		}
+
+		if reading.Status != QuarantineStatusPending {
+			return ErrorQuarantinedReadingReviewed
+		}
+
+		if review != nil {
+			err = review(ctx, tx, &reading)
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		reading.Status = status
+		reading.ReviewedAt = &now
+		reading.ReviewedBy = reviewedBy
+		_, err = tx.NewUpdate().
+			Model(&reading).
+			Column("status", "reviewed_at", "reviewed_by", "measurement_id").
+			WherePK().
+			Exec(ctx)
+
+		return err
+	})
+
+	return reading, err
+}
+
+// ReleaseQuarantinedReading stores a pending quarantined reading as a reading of its
+// sensor, returning ErrorReadingAlreadyStored if another reading was stored for the
+// same sensor, measurement type and date in the meantime
+func ReleaseQuarantinedReading(ctx context.Context, db *bun.DB, id int64, reviewedBy string) (QuarantinedReading, error) {
+	return reviewQuarantinedReading(ctx, db, id, reviewedBy, QuarantineStatusReleased, func(ctx context.Context, tx bun.Tx, reading *QuarantinedReading) error {
+		measurement := SensorMeasurement{
+			SensorID:        reading.SensorID,
+			MeasurementType: reading.MeasurementType,
+			Date:            reading.Date,
+			Value:           reading.Value,
+		}
+
+		result, err := tx.NewInsert().
+			Model(&measurement).
+			ExcludeColumn("id").
+			On("CONFLICT (sensor_id, measurement_type, date) DO NOTHING").
+			Returning("id").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if inserted == 0 {
+			return ErrorReadingAlreadyStored
+		}
+
+		reading.MeasurementID = measurement.ID
+
+		return updateSensorLastSeen(ctx, tx, []SensorMeasurement{measurement})
+	})
+}
+
+// DiscardQuarantinedReading marks a pending quarantined reading as discarded, it is kept
+// for the record but never stored as a reading
+func DiscardQuarantinedReading(ctx context.Context, db *bun.DB, id int64, reviewedBy string) (QuarantinedReading, error) {
+	return reviewQuarantinedReading(ctx, db, id, reviewedBy, QuarantineStatusDiscarded, nil)
+}
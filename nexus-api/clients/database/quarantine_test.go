@@ -0,0 +1,62 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2EInsertSensorMeasurementsQuarantinesSensorGarbage(t *testing.T) {
+	// Step 1: a sensor sends a moisture overflow and browns out mid batch
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err := EnsureSensorExists(testCtx, databaseClient.DB, sensorID, "")
+	assert.NoError(t, err)
+	defer DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	baseTime := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	readings := []SensorMeasurement{
+		{SensorID: sensorID, MeasurementType: MeasurementTypeSoilMoisture, Date: baseTime, Value: 31},
+		{SensorID: sensorID, MeasurementType: MeasurementTypeSoilMoisture, Date: baseTime.Add(10 * time.Minute), Value: 6553.5},
+		{SensorID: sensorID, MeasurementType: MeasurementTypeSoilTemperature, Date: baseTime, Value: 18.5},
+		{SensorID: sensorID, MeasurementType: MeasurementTypeSoilTemperature, Date: baseTime.Add(10 * time.Minute), Value: -40},
+		{SensorID: sensorID, MeasurementType: MeasurementTypeSoilTemperature, Date: baseTime.Add(20 * time.Minute), Value: 85},
+		{SensorID: sensorID, MeasurementType: MeasurementTypeSoilTemperature, Date: baseTime.Add(30 * time.Minute), Value: 0},
+		{SensorID: sensorID, MeasurementType: MeasurementTypeSoilTemperature, Date: baseTime.Add(40 * time.Minute), Value: 19},
+	}
+
+	// Step 2: insert the batch as the ingest paths do
+	results, err := InsertSensorMeasurements(testCtx, databaseClient.DB, readings, OnConflictIgnore)
+	assert.NoError(t, err)
+
+	// Step 3: the garbage is held back by the seeded rules, the plausible readings stored
+	expected := []string{
+		MeasurementStatusInserted,
+		MeasurementStatusQuarantined, // above the moisture max of 100
+		MeasurementStatusInserted,
+		MeasurementStatusQuarantined, // below the temperature min of -30
+		MeasurementStatusQuarantined, // above the temperature max of 70
+		MeasurementStatusQuarantined, // fell 18.5 in half an hour
+		MeasurementStatusInserted,
+	}
+	if assert.Len(t, results, len(expected)) {
+		for i, status := range expected {
+			assert.Equal(t, status, results[i].Status, "reading %d", i)
+		}
+	}
+
+	var quarantined []QuarantinedReading
+	err = databaseClient.DB.NewSelect().
+		Model(&quarantined).
+		Where("sensor_id = ?", sensorID).
+		OrderExpr("date ASC, measurement_type ASC").
+		Scan(testCtx)
+	assert.NoError(t, err)
+	if assert.Len(t, quarantined, 4) {
+		assert.Equal(t, QuarantineReasonOutOfRange, quarantined[0].ReasonCode)
+		assert.Equal(t, QuarantineReasonOutOfRange, quarantined[1].ReasonCode)
+		assert.Equal(t, QuarantineReasonOutOfRange, quarantined[2].ReasonCode)
+		assert.Equal(t, QuarantineReasonRateOfChange, quarantined[3].ReasonCode)
+	}
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	SensorMeasurementRollupsHourlyTable = "sensor_measurement_rollups_hourly"
+	SensorMeasurementRollupsDailyTable  = "sensor_measurement_rollups_daily"
+)
+
+// RetentionResult counts the rows changed by applying a measurement type's retention policy
+type RetentionResult struct {
+	RolledUp      int64 // Raw readings rolled up and deleted
+	DeletedHourly int64 // Hourly rollups past their retention
+	DeletedDaily  int64 // Daily rollups past their retention
+}
+
+// RawRetentionCutoff returns the time before which raw readings of the measurement type
+// are rolled up at now, the start of the UTC day so only whole hours and days are
+// rolled up, or the zero time if raw readings are kept forever
+func (mt MeasurementType) RawRetentionCutoff(now time.Time) time.Time {
+	if mt.RawRetentionDays <= 0 {
+		return time.Time{}
+	}
+
+	cutoff := now.UTC().AddDate(0, 0, -mt.RawRetentionDays)
+
+	return time.Date(cutoff.Year(), cutoff.Month(), cutoff.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// rollUpSensorMeasurements adds the readings of measurementType taken before cutoff to the
+// rollups of table, bucketed by field. Rollups are merged with the existing bucket so
+// readings that arrive after their bucket was first rolled up are still counted
+func rollUpSensorMeasurements(ctx context.Context, tx bun.Tx, table string, field string, measurementType string, cutoff time.Time) error {
+	_, err := tx.NewRaw(`
+		INSERT INTO ? AS rollup (sensor_id, measurement_type, bucket_start, min, max, avg, count)
+		SELECT sensor_id, measurement_type, date_trunc(?, date, 'UTC'), MIN(value), MAX(value), AVG(value), COUNT(*)
+		FROM sensor_measurements
+		WHERE measurement_type = ? AND date < ?
+		GROUP BY sensor_id, measurement_type, date_trunc(?, date, 'UTC')
+		ON CONFLICT (sensor_id, measurement_type, bucket_start) DO UPDATE SET
+			min = LEAST(rollup.min, EXCLUDED.min),
+			max = GREATEST(rollup.max, EXCLUDED.max),
+			avg = (rollup.avg * rollup.count + EXCLUDED.avg * EXCLUDED.count) / (rollup.count + EXCLUDED.count),
+			count = rollup.count + EXCLUDED.count`,
+		bun.Ident(table), field, measurementType, cutoff, field).
+		Exec(ctx)
+
+	return err
+}
+
+// ApplyRetentionPolicy enforces the retention policy of the measurement type at now. Raw
+// readings past their retention are rolled up into the hourly and daily rollups and
+// deleted in one transaction, then rollups past their own retention are deleted
+func ApplyRetentionPolicy(ctx context.Context, db *bun.DB, measurementType MeasurementType, now time.Time) (RetentionResult, error) {
+	var result RetentionResult
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if cutoff := measurementType.RawRetentionCutoff(now); !cutoff.IsZero() {
+			err := rollUpSensorMeasurements(ctx, tx, SensorMeasurementRollupsHourlyTable, "hour", measurementType.ID, cutoff)
+			if err != nil {
+				return fmt.Errorf("rolling up hourly: %w", err)
+			}
+
+			err = rollUpSensorMeasurements(ctx, tx, SensorMeasurementRollupsDailyTable, "day", measurementType.ID, cutoff)
+			if err != nil {
+				return fmt.Errorf("rolling up daily: %w", err)
+			}
+
+			deleted, err := tx.NewDelete().
+				Model((*SensorMeasurement)(nil)).
+				Where("measurement_type = ?", measurementType.ID).
+				Where("date < ?", cutoff).
+				Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("deleting raw readings: %w", err)
+			}
+
+			result.RolledUp, err = deleted.RowsAffected()
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, tier := range []struct {
+			table   string
+			days    int
+			deleted *int64
+		}{
+			{SensorMeasurementRollupsHourlyTable, measurementType.HourlyRetentionDays, &result.DeletedHourly},
+			{SensorMeasurementRollupsDailyTable, measurementType.DailyRetentionDays, &result.DeletedDaily},
+		} {
+			if tier.days <= 0 {
+				continue
+			}
+
+			deleted, err := tx.NewDelete().
+				TableExpr("?", bun.Ident(tier.table)).
+				Where("measurement_type = ?", measurementType.ID).
+				Where("bucket_start < ?", now.UTC().AddDate(0, 0, -tier.days)).
+				Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("deleting %s: %w", tier.table, err)
+			}
+
+			*tier.deleted, err = deleted.RowsAffected()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,21 @@
+-- Registry of the kinds of readings sensors report, new probe types are
+-- added here instead of needing their own table
+CREATE TABLE measurement_types (
+    id VARCHAR(64) PRIMARY KEY,                 -- Identifier used in the api, e.g. soil_moisture
+    display_name VARCHAR(255) NOT NULL,
+    unit VARCHAR(32) NOT NULL,
+    min_value DOUBLE PRECISION,                 -- Lowest plausible reading, NULL for no bound
+    max_value DOUBLE PRECISION,                 -- Highest plausible reading, NULL for no bound
+    mqtt_identifier VARCHAR(32) UNIQUE,         -- Measurement or status id sent by the gateway
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (min_value IS NULL OR max_value IS NULL OR min_value <= max_value)
+);
+
+INSERT INTO measurement_types (id, display_name, unit, min_value, max_value, mqtt_identifier) VALUES
+('soil_moisture', 'Soil Moisture', '%', 0, 100, '4103'),
+('soil_temperature', 'Soil Temperature', '°C', -40, 85, '4102'),
+('battery_level', 'Battery Level', '%', 0, 100, '3000'),
+-- MQTT identifiers for the new probes are set through the admin api once they are deployed
+('soil_ec', 'Soil Electrical Conductivity', 'dS/m', 0, 23, NULL),
+('soil_ph', 'Soil pH', 'pH', 0, 14, NULL),
+('air_humidity', 'Air Humidity', '%', 0, 100, NULL);
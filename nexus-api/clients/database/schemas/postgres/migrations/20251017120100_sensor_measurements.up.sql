@@ -0,0 +1,24 @@
+-- Readings of every measurement type, replacing the table per type
+CREATE TABLE sensor_measurements (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id VARCHAR(32) NOT NULL REFERENCES sensors(id),
+    measurement_type VARCHAR(64) NOT NULL REFERENCES measurement_types(id),
+    date TIMESTAMP WITH TIME ZONE NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    UNIQUE (sensor_id, measurement_type, date)
+);
+
+-- Move the existing readings over, oldest first so ids keep their order
+INSERT INTO sensor_measurements (sensor_id, measurement_type, date, value)
+SELECT sensor_id, 'soil_moisture', date, soil_moisture FROM sensor_moisture_data ORDER BY id;
+
+INSERT INTO sensor_measurements (sensor_id, measurement_type, date, value)
+SELECT sensor_id, 'soil_temperature', date, soil_temperature FROM sensor_temperature_data ORDER BY id;
+
+-- Battery dates were stored without a time zone, they were written in UTC
+INSERT INTO sensor_measurements (sensor_id, measurement_type, date, value)
+SELECT sensor_id, 'battery_level', date AT TIME ZONE 'UTC', battery_level FROM sensor_battery_data ORDER BY id;
+
+DROP TABLE sensor_moisture_data;
+DROP TABLE sensor_temperature_data;
+DROP TABLE sensor_battery_data;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    file_name VARCHAR(255) NOT NULL,
+    mapping JSONB NOT NULL,
+    on_conflict VARCHAR(16) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    inserted_readings INTEGER NOT NULL DEFAULT 0,
+    updated_readings INTEGER NOT NULL DEFAULT 0,
+    duplicate_readings INTEGER NOT NULL DEFAULT 0,
+    rejected_rows INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    CHECK (status IN ('pending', 'running', 'completed', 'failed'))
+);
+
+CREATE TABLE IF NOT EXISTS import_job_errors (
+    id BIGSERIAL PRIMARY KEY,
+    job_id UUID NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    message TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS import_job_errors_job_id_row_number_idx ON import_job_errors (job_id, row_number);
@@ -0,0 +1,35 @@
+-- Newest reading of each measurement type per sensor, maintained as readings
+-- are saved so status checks don't scan the readings
+CREATE TABLE IF NOT EXISTS sensor_last_seen (
+    sensor_id VARCHAR(32) NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    measurement_type VARCHAR(64) NOT NULL REFERENCES measurement_types(id),
+    last_date TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_value DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (sensor_id, measurement_type)
+);
+
+INSERT INTO sensor_last_seen (sensor_id, measurement_type, last_date, last_value)
+SELECT DISTINCT ON (sensor_id, measurement_type) sensor_id, measurement_type, date, value
+FROM sensor_measurements
+ORDER BY sensor_id, measurement_type, date DESC, id DESC
+ON CONFLICT DO NOTHING;
+
+-- last_seen_at is the newest reading of any type, online_since the first reading
+-- after the sensor's most recent gap in readings of more than a day
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS online_since TIMESTAMP WITH TIME ZONE;
+
+WITH readings AS (
+    SELECT sensor_id, date, LAG(date) OVER (PARTITION BY sensor_id ORDER BY date) AS previous_date
+    FROM sensor_measurements
+), activity AS (
+    SELECT sensor_id,
+        MAX(date) AS last_seen_at,
+        MAX(date) FILTER (WHERE previous_date IS NULL OR date - previous_date > INTERVAL '24 hours') AS online_since
+    FROM readings
+    GROUP BY sensor_id
+)
+UPDATE sensors
+SET last_seen_at = activity.last_seen_at, online_since = activity.online_since
+FROM activity
+WHERE sensors.id = activity.sensor_id;
@@ -0,0 +1,4 @@
+-- How often a sensor is expected to report and how much longer to wait before it is
+-- considered offline, the defaults add up to the one day threshold used until now
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS reporting_interval_seconds INTEGER NOT NULL DEFAULT 3600 CHECK (reporting_interval_seconds > 0);
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS offline_grace_seconds INTEGER NOT NULL DEFAULT 82800 CHECK (offline_grace_seconds >= 0);
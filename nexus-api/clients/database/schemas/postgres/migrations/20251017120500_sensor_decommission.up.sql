@@ -0,0 +1,5 @@
+-- Decommissioned sensors are hidden from active views and no longer accept live
+-- readings, their history is kept until an admin purges the sensor
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS decommissioned_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS decommission_reason TEXT;
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS decommissioned_by TEXT;
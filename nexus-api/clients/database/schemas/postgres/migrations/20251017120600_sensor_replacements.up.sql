@@ -0,0 +1,4 @@
+-- A sensor installed in place of another links to it so the readings of both can be
+-- read as one history, a sensor can only be replaced once
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS replaces_sensor_id TEXT REFERENCES sensors(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS sensors_replaces_sensor_id_idx ON sensors (replaces_sensor_id);
@@ -0,0 +1,28 @@
+-- How long readings of each measurement type are kept, in days, NULL keeps them forever.
+-- Raw readings past their retention are rolled up into the hourly and daily tables
+-- before they are deleted, so rollups hold only readings that are no longer raw
+ALTER TABLE measurement_types ADD COLUMN IF NOT EXISTS raw_retention_days INTEGER CHECK (raw_retention_days > 0);
+ALTER TABLE measurement_types ADD COLUMN IF NOT EXISTS hourly_retention_days INTEGER CHECK (hourly_retention_days > 0);
+ALTER TABLE measurement_types ADD COLUMN IF NOT EXISTS daily_retention_days INTEGER CHECK (daily_retention_days > 0);
+
+CREATE TABLE IF NOT EXISTS sensor_measurement_rollups_hourly (
+    sensor_id VARCHAR(32) NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    measurement_type VARCHAR(64) NOT NULL REFERENCES measurement_types(id),
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    min DOUBLE PRECISION NOT NULL,
+    max DOUBLE PRECISION NOT NULL,
+    avg DOUBLE PRECISION NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (sensor_id, measurement_type, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS sensor_measurement_rollups_daily (
+    sensor_id VARCHAR(32) NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    measurement_type VARCHAR(64) NOT NULL REFERENCES measurement_types(id),
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    min DOUBLE PRECISION NOT NULL,
+    max DOUBLE PRECISION NOT NULL,
+    avg DOUBLE PRECISION NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (sensor_id, measurement_type, bucket_start)
+);
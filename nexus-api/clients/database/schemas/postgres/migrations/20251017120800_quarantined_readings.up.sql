@@ -0,0 +1,35 @@
+-- Largest plausible change of a reading per hour, NULL for no limit
+ALTER TABLE measurement_types ADD COLUMN IF NOT EXISTS max_rate_per_hour DOUBLE PRECISION CHECK (max_rate_per_hour > 0);
+
+-- Readings held back on ingest for breaking their measurement type's valid range or
+-- rate of change rule, until an admin releases them into sensor_measurements or
+-- discards them
+CREATE TABLE IF NOT EXISTS quarantined_readings (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id VARCHAR(32) NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    measurement_type VARCHAR(64) NOT NULL REFERENCES measurement_types(id),
+    date TIMESTAMP WITH TIME ZONE NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    reason_code VARCHAR(32) NOT NULL,
+    reason TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'released', 'discarded')),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    reviewed_by TEXT,
+    measurement_id BIGINT -- Reading the quarantined reading was released as
+);
+
+CREATE INDEX IF NOT EXISTS quarantined_readings_status_idx ON quarantined_readings (status, id);
+
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS quarantined_readings INTEGER NOT NULL DEFAULT 0;
+
+-- Soil temperature probes read -40 after a brown-out and 85 on a power-on reset, the
+-- ends of the range the built-in type was seeded with, so narrow it to what a field
+-- can reach and limit how fast soil, which warms and cools slowly, may change. Moisture
+-- and humidity rise quickly under irrigation and batteries jump when swapped, so their
+-- ranges are left to catch garbage without a rate limit
+UPDATE measurement_types SET min_value = -30, max_value = 70
+WHERE id = 'soil_temperature' AND min_value = -40 AND max_value = 85;
+
+UPDATE measurement_types SET max_rate_per_hour = 30
+WHERE id = 'soil_temperature' AND max_rate_per_hour IS NULL;
@@ -0,0 +1,15 @@
+-- Calibration profiles convert a sensor's raw readings of a measurement type to calibrated
+-- values from effective_from until the next profile takes over. Readings are stored raw
+-- and calibrated when read, so changing a profile recomputes the history it covers
+CREATE TABLE IF NOT EXISTS sensor_calibrations (
+    id BIGSERIAL PRIMARY KEY,
+    sensor_id VARCHAR(32) NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    measurement_type VARCHAR(64) NOT NULL REFERENCES measurement_types(id),
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- Polynomial coefficients from the constant term up, a linear calibration is {offset, gain}
+    coefficients DOUBLE PRECISION[] NOT NULL CHECK (cardinality(coefficients) > 0),
+    note TEXT,
+    created_by TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (sensor_id, measurement_type, effective_from)
+);
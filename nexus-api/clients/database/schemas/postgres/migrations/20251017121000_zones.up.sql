@@ -0,0 +1,16 @@
+-- Zones are the field blocks irrigation is managed by, bounded by a GeoJSON Polygon
+CREATE TABLE IF NOT EXISTS zones (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    crop_type TEXT,
+    boundary JSONB NOT NULL,
+    created_by TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Sensors are placed in the zone whose boundary holds their coordinates unless
+-- they were assigned to a zone by hand
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS zone_id UUID REFERENCES zones(id) ON DELETE SET NULL;
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS zone_assigned_manually BOOLEAN NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS sensors_zone_id_idx ON sensors (zone_id);
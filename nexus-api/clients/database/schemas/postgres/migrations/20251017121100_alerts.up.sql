@@ -0,0 +1,54 @@
+-- Alert rules watch the readings of a measurement type taken by one sensor, the sensors
+-- of a zone or, when neither is set, every sensor
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    measurement_type VARCHAR(64) NOT NULL REFERENCES measurement_types(id),
+    sensor_id VARCHAR(32) REFERENCES sensors(id) ON DELETE CASCADE,
+    zone_id UUID REFERENCES zones(id) ON DELETE CASCADE,
+    operator VARCHAR(16) NOT NULL CHECK (operator IN ('above', 'below')),
+    threshold DOUBLE PRECISION NOT NULL,
+    -- How long the condition must hold before the alert fires
+    duration_seconds INTEGER NOT NULL DEFAULT 0 CHECK (duration_seconds >= 0),
+    -- How far back past the threshold readings must go before the alert resolves
+    hysteresis DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (hysteresis >= 0),
+    severity VARCHAR(16) NOT NULL DEFAULT 'warning' CHECK (severity IN ('info', 'warning', 'critical')),
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_by TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (sensor_id IS NULL OR zone_id IS NULL)
+);
+
+CREATE INDEX IF NOT EXISTS alert_rules_measurement_type_idx ON alert_rules (measurement_type) WHERE enabled;
+
+-- Alerts fired by a rule for a sensor, open until resolved_at is set. The rule's
+-- condition is copied so the history still reads right after the rule changes
+CREATE TABLE IF NOT EXISTS alerts (
+    id BIGSERIAL PRIMARY KEY,
+    rule_id UUID REFERENCES alert_rules(id) ON DELETE SET NULL,
+    rule_name TEXT NOT NULL,
+    sensor_id VARCHAR(32) NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    measurement_type VARCHAR(64) NOT NULL REFERENCES measurement_types(id),
+    operator VARCHAR(16) NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL,
+    severity VARCHAR(16) NOT NULL,
+    breaching_since TIMESTAMP WITH TIME ZONE NOT NULL, -- Reading the condition started holding at
+    fired_at TIMESTAMP WITH TIME ZONE NOT NULL,        -- Reading the alert fired on
+    fired_value DOUBLE PRECISION NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    resolved_value DOUBLE PRECISION, -- NULL when the rule was disabled or deleted while firing
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS alerts_open_idx ON alerts (rule_id, sensor_id) WHERE resolved_at IS NULL;
+CREATE INDEX IF NOT EXISTS alerts_sensor_id_idx ON alerts (sensor_id, id);
+
+-- Where each rule's evaluation of each sensor's readings got to
+CREATE TABLE IF NOT EXISTS alert_rule_states (
+    rule_id UUID NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    sensor_id VARCHAR(32) NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    last_reading_at TIMESTAMP WITH TIME ZONE, -- Newest reading evaluated
+    breaching_since TIMESTAMP WITH TIME ZONE, -- Start of the current run of readings breaking the condition
+    PRIMARY KEY (rule_id, sensor_id)
+);
@@ -0,0 +1,40 @@
+-- Users told about the alerts a rule fires, and the users told as well once a critical
+-- alert has gone unacknowledged for the escalation delay
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS recipients TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS escalation_recipients TEXT[] NOT NULL DEFAULT '{}';
+
+-- What the people on the farm did about an alert, shared so everyone sees the same state
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS recipients TEXT[] NOT NULL DEFAULT '{}'; -- The rule's recipients when it fired
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledged_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS acknowledged_by TEXT;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS assigned_to TEXT;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS resolved_by TEXT; -- NULL when a reading or a rule change resolved it
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS resolution_note TEXT;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS escalated_to TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS alerts_escalation_idx ON alerts (created_at)
+    WHERE resolved_at IS NULL AND acknowledged_at IS NULL AND escalated_at IS NULL AND severity = 'critical';
+
+-- History of each alert, from firing through what users did about it to resolution
+CREATE TABLE IF NOT EXISTS alert_events (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id BIGINT NOT NULL REFERENCES alerts(id) ON DELETE CASCADE,
+    action VARCHAR(16) NOT NULL CHECK (action IN ('fired', 'acknowledged', 'snoozed', 'assigned', 'escalated', 'resolved')),
+    actor TEXT, -- NULL for events of the service itself
+    note TEXT,
+    snoozed_until TIMESTAMP WITH TIME ZONE,
+    assigned_to TEXT,
+    recipients TEXT[] NOT NULL DEFAULT '{}', -- Users escalated to
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS alert_events_alert_id_idx ON alert_events (alert_id, id);
+
+-- Start the history of the alerts fired before it was kept
+INSERT INTO alert_events (alert_id, action, created_at)
+SELECT id, 'fired', created_at FROM alerts;
+
+INSERT INTO alert_events (alert_id, action, created_at)
+SELECT id, 'resolved', resolved_at FROM alerts WHERE resolved_at IS NOT NULL;
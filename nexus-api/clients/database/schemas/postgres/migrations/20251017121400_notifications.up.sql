@@ -0,0 +1,57 @@
+-- Where and when each user is emailed their notifications
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_name TEXT PRIMARY KEY REFERENCES login_authentications(user_name) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    -- Minutes after local midnight emails are held back from and until, no quiet hours when
+    -- NULL. Quiet hours that end before they start run past midnight
+    quiet_hours_start SMALLINT CHECK (quiet_hours_start BETWEEN 0 AND 1439),
+    quiet_hours_end SMALLINT CHECK (quiet_hours_end BETWEEN 0 AND 1439),
+    digest_hour SMALLINT NOT NULL DEFAULT 7 CHECK (digest_hour BETWEEN 0 AND 23), -- Local hour the daily digest is sent at
+    last_digest_date DATE, -- Local date the last daily digest was queued on
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- What each user is notified about, sensor notifications cover every sensor when sensor_id is NULL
+CREATE TABLE IF NOT EXISTS notification_subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_name TEXT NOT NULL REFERENCES login_authentications(user_name) ON DELETE CASCADE,
+    kind VARCHAR(32) NOT NULL CHECK (kind IN ('sensor_offline', 'battery_low', 'daily_digest')),
+    sensor_id TEXT REFERENCES sensors(id) ON DELETE CASCADE,
+    battery_threshold DOUBLE PRECISION, -- Percent, battery_low only
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS notification_subscriptions_unique_idx ON notification_subscriptions (user_name, kind, COALESCE(sensor_id, ''));
+CREATE INDEX IF NOT EXISTS notification_subscriptions_kind_idx ON notification_subscriptions (kind, sensor_id);
+
+-- Sensors each battery_low subscription was notified about, kept until the sensor's
+-- battery is back above the threshold so a low battery is only reported once
+CREATE TABLE IF NOT EXISTS notification_low_batteries (
+    subscription_id UUID NOT NULL REFERENCES notification_subscriptions(id) ON DELETE CASCADE,
+    sensor_id TEXT NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    notified_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (subscription_id, sensor_id)
+);
+
+-- Emails waiting to be sent, kept afterwards as the log of what each user was sent
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    user_name TEXT NOT NULL REFERENCES login_authentications(user_name) ON DELETE CASCADE,
+    recipient TEXT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Held back to the end of quiet hours
+    last_attempt_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS email_outbox_due_idx ON email_outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS email_outbox_user_name_idx ON email_outbox (user_name, id);
@@ -2,32 +2,28 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 )
 
 var (
-	ErrorNoSensorMoistureData    = errors.New("no sensor moisture data found")
-	ErrorNoSensorTemperatureData = errors.New("no sensor temperature data found")
-	ErrorNoSensorBatteryData     = errors.New("no sensor battery data found")
+	ErrorNoSensorMeasurements = errors.New("no sensor measurements found")
+	ErrorSensorDecommissioned = errors.New("sensor is decommissioned")
 )
 
-type SensorMoistureData struct {
-	ID           int       `bun:"id,pk,autoincrement"`
-	SensorID     string    `bun:"sensor_id"`
-	Date         time.Time `bun:"date,notnull"`
-	SoilMoisture float64   `bun:"soil_moisture"`
-}
-
-type SensorTemperatureData struct {
+// SensorMeasurement is a single reading of one measurement type taken by a sensor
+type SensorMeasurement struct {
 	ID              int       `bun:"id,pk,autoincrement"`
 	SensorID        string    `bun:"sensor_id"`
-	Date            time.Time `bun:"date"`
-	SoilTemperature float64   `bun:"soil_temperature"`
+	MeasurementType string    `bun:"measurement_type"`
+	Date            time.Time `bun:"date,notnull"`
+	Value           float64   `bun:"value"`
 }
 
 type SensorCoordinates struct {
@@ -41,59 +37,367 @@ type Sensor struct {
 	Location         string    `json:"location" bun:"location"`
 	InstallationDate time.Time `json:"installation_date" bun:"installation_date"`
 	SensorCoordinates
+	// LastSeenAt and OnlineSince are maintained as readings are saved
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty" bun:"last_seen_at"`
+	OnlineSince *time.Time `json:"online_since,omitempty" bun:"online_since"`
+	// A sensor is offline once it has gone its reporting interval plus the grace
+	// period without a reading, zero values are stored as the defaults
+	ReportingIntervalSeconds int `json:"reporting_interval_seconds" bun:"reporting_interval_seconds,default:3600"`
+	OfflineGraceSeconds      int `json:"offline_grace_seconds" bun:"offline_grace_seconds,default:82800"`
+	// DecommissionedAt is set once a sensor is taken out of service, its readings are kept
+	DecommissionedAt   *time.Time `json:"decommissioned_at,omitempty" bun:"decommissioned_at"`
+	DecommissionReason string     `json:"decommission_reason,omitempty" bun:"decommission_reason,nullzero"`
+	DecommissionedBy   string     `json:"decommissioned_by,omitempty" bun:"decommissioned_by,nullzero"`
+	// ReplacesSensorID is the sensor this one was installed in place of
+	ReplacesSensorID string `json:"replaces_sensor_id,omitempty" bun:"replaces_sensor_id,nullzero"`
+	// ZoneID is the zone the sensor is in, worked out from its coordinates
+	// unless it was assigned to the zone by hand
+	ZoneID               *uuid.UUID `json:"zone_id,omitempty" bun:"zone_id,type:uuid"`
+	ZoneAssignedManually bool       `json:"zone_assigned_manually" bun:"zone_assigned_manually"`
+	// ReportedOnline is whether the sensor was online when last checked for webhook
+	// events, nil until it is first checked
+	ReportedOnline *bool `json:"-" bun:"reported_online"`
 }
 
-func GetSensorMoistureDataForSensorID(ctx context.Context, db *bun.DB, sensorID string) ([]SensorMoistureData, error) {
-	log.Info().Msgf("[sensor_data.go] Querying moisture data for sensor_id: %s", sensorID)
-	var data []SensorMoistureData
-	err := db.NewSelect().
+// IsDecommissioned returns whether the sensor has been taken out of service
+func (s Sensor) IsDecommissioned() bool {
+	return s.DecommissionedAt != nil
+}
+
+// OnlineThreshold returns the longest the sensor can go without a reading and still be online
+func (s Sensor) OnlineThreshold() time.Duration {
+	if s.ReportingIntervalSeconds <= 0 {
+		return DefaultSensorOnlineThreshold
+	}
+
+	return time.Duration(s.ReportingIntervalSeconds+s.OfflineGraceSeconds) * time.Second
+}
+
+// IsOnlineAt returns whether the sensor had a reading within its online threshold of now
+func (s Sensor) IsOnlineAt(now time.Time) bool {
+	return s.LastSeenAt != nil && now.Sub(*s.LastSeenAt) <= s.OnlineThreshold()
+}
+
+// GetSensorMeasurements returns the page of the sensors' readings of measurementType selected
+// by query, along with the cursor for the next page (nil if this is the last page). The
+// readings of several sensors, such as a chain of replacements, are read as one history
+func GetSensorMeasurements(ctx context.Context, db *bun.DB, sensorIDs []string, measurementType string, query SensorDataQuery) ([]SensorMeasurement, *SensorDataCursor, error) {
+	var data []SensorMeasurement
+	err := query.apply(db.NewSelect().
 		Model(&data).
-		Where("sensor_id = ?", sensorID).
+		Where("sensor_id IN (?)", bun.In(sensorIDs)).
+		Where("measurement_type = ?", measurementType)).
 		Scan(ctx)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if len(data) == 0 {
-		return nil, ErrorNoSensorMoistureData
+		return nil, nil, ErrorNoSensorMeasurements
+	}
+
+	var nextCursor *SensorDataCursor
+	if query.Limit > 0 && len(data) > query.Limit {
+		data = data[:query.Limit]
+		last := data[len(data)-1]
+		nextCursor = &SensorDataCursor{Date: last.Date, ID: last.ID}
 	}
 
-	return data, nil
+	return data, nextCursor, nil
 }
 
-func GetSensorTemperatureDataForSensorID(ctx context.Context, db *bun.DB, sensorID string) ([]SensorTemperatureData, error) {
-	var data []SensorTemperatureData
+// GetLatestSensorMeasurementDate returns the timestamp of the most recent reading of
+// measurementType by any of the sensors, or the zero time if they have none
+func GetLatestSensorMeasurementDate(ctx context.Context, db *bun.DB, sensorIDs []string, measurementType string) (time.Time, error) {
+	var latest sql.NullTime
 	err := db.NewSelect().
-		Model(&data).
-		Where("sensor_id = ?", sensorID).
-		Scan(ctx)
+		Model((*SensorMeasurement)(nil)).
+		ColumnExpr("MAX(date)").
+		Where("sensor_id IN (?)", bun.In(sensorIDs)).
+		Where("measurement_type = ?", measurementType).
+		Scan(ctx, &latest)
 
 	if err != nil {
-		return nil, err
+		return time.Time{}, err
 	}
 
-	if len(data) == 0 {
-		return nil, ErrorNoSensorTemperatureData
+	return latest.Time, nil
+}
+
+// SensorMeasurementFilter selects readings across sensors and measurement types,
+// empty fields do not restrict the readings selected
+type SensorMeasurementFilter struct {
+	SensorIDs        []string
+	MeasurementTypes []string
+	// Start and End bound the reading dates (inclusive)
+	Start time.Time
+	End   time.Time
+}
+
+// apply adds the filter's conditions to a select over sensor_measurements
+func (f SensorMeasurementFilter) apply(query *bun.SelectQuery) *bun.SelectQuery {
+	if len(f.SensorIDs) > 0 {
+		query = query.Where("sensor_id IN (?)", bun.In(f.SensorIDs))
 	}
 
-	return data, nil
+	if len(f.MeasurementTypes) > 0 {
+		query = query.Where("measurement_type IN (?)", bun.In(f.MeasurementTypes))
+	}
+
+	if !f.Start.IsZero() {
+		query = query.Where("date >= ?", f.Start)
+	}
+
+	if !f.End.IsZero() {
+		query = query.Where("date <= ?", f.End)
+	}
+
+	return query
 }
 
-func (d *SensorMoistureData) Save(ctx context.Context, db *bun.DB) error {
-	_, err := db.NewInsert().
-		Model(d).
-		Exec(ctx)
-	return err
+// applyRollup adds the filter's conditions to a select over one of the rollups tables,
+// selecting the rollups whose bucket starts in the date range
+func (f SensorMeasurementFilter) applyRollup(query *bun.SelectQuery) *bun.SelectQuery {
+	if len(f.SensorIDs) > 0 {
+		query = query.Where("sensor_id IN (?)", bun.In(f.SensorIDs))
+	}
+
+	if len(f.MeasurementTypes) > 0 {
+		query = query.Where("measurement_type IN (?)", bun.In(f.MeasurementTypes))
+	}
+
+	if !f.Start.IsZero() {
+		query = query.Where("bucket_start >= ?", f.Start)
+	}
+
+	if !f.End.IsZero() {
+		query = query.Where("bucket_start <= ?", f.End)
+	}
+
+	return query
 }
 
-func (d *SensorTemperatureData) Save(ctx context.Context, db *bun.DB) error {
+// StreamSensorMeasurements calls fn with each reading selected by filter, ordered by
+// sensor, measurement type and date. Rows are read from the database as fn consumes
+// them rather than being loaded into memory, so exports of any size can be streamed.
+// Returning an error from fn stops the stream and is returned
+func StreamSensorMeasurements(ctx context.Context, db *bun.DB, filter SensorMeasurementFilter, fn func(SensorMeasurement) error) error {
+	rows, err := filter.apply(db.NewSelect().Model((*SensorMeasurement)(nil))).
+		OrderExpr("sensor_id ASC, measurement_type ASC, date ASC, id ASC").
+		Rows(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var measurement SensorMeasurement
+		err = db.ScanRow(ctx, rows, &measurement)
+		if err != nil {
+			return err
+		}
+
+		err = fn(measurement)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (d *SensorMeasurement) Save(ctx context.Context, db *bun.DB) error {
 	_, err := db.NewInsert().
 		Model(d).
 		Exec(ctx)
 	return err
 }
 
+const (
+	// OnConflictIgnore keeps the stored reading when a batch repeats a sensor, type and date
+	OnConflictIgnore = "ignore"
+	// OnConflictUpdate replaces the stored reading's value when a batch repeats a sensor, type and date
+	OnConflictUpdate = "update"
+
+	MeasurementStatusInserted  = "inserted"
+	MeasurementStatusUpdated   = "updated"
+	MeasurementStatusDuplicate = "duplicate"
+	MeasurementStatusRejected  = "rejected"
+	// MeasurementStatusQuarantined readings broke a quality rule of their measurement
+	// type and were held back for review instead of stored
+	MeasurementStatusQuarantined = "quarantined"
+
+	// measurementInsertChunkSize keeps each insert statement well under
+	// the postgres limit on the number of bind parameters
+	measurementInsertChunkSize = 1000
+)
+
+var (
+	ErrorInvalidOnConflict = errors.New("invalid on conflict behaviour")
+)
+
+// SensorMeasurementResult is the outcome of saving one reading of a batch
+type SensorMeasurementResult struct {
+	// Status is one of the MeasurementStatus values
+	Status string
+	// ID of the stored row for inserted and updated readings
+	ID int
+	// Reason explains why a reading was rejected or is a duplicate
+	Reason string
+}
+
+// insertedSensorMeasurement is a row returned by the batch insert,
+// xmax is 0 for rows that were newly inserted rather than updated
+type insertedSensorMeasurement struct {
+	ID              int       `bun:"id"`
+	SensorID        string    `bun:"sensor_id"`
+	MeasurementType string    `bun:"measurement_type"`
+	Date            time.Time `bun:"date"`
+	Inserted        bool      `bun:"inserted"`
+}
+
+// sensorSeriesID identifies the readings of one sensor and measurement type
+func sensorSeriesID(sensorID string, measurementType string) string {
+	return sensorID + "/" + measurementType
+}
+
+// sensorMeasurementKey identifies a reading within a batch
+func sensorMeasurementKey(sensorID string, measurementType string, date time.Time) string {
+	return fmt.Sprintf("%s/%s/%d", sensorID, measurementType, date.UnixMicro())
+}
+
+// planSensorMeasurementInsert works out which readings of a batch are sent to the
+// database, filling in results for readings that are rejected or repeat the sensor,
+// measurement type and date of another reading of the same batch. For OnConflictIgnore the first of the repeated readings
+// is kept, for OnConflictUpdate the last. Dates are truncated to the microsecond
+// precision postgres stores them with
+func planSensorMeasurementInsert(measurements []SensorMeasurement, onConflict string) ([]SensorMeasurementResult, map[string]int) {
+	results := make([]SensorMeasurementResult, len(measurements))
+	kept := make(map[string]int)
+
+	for i := range measurements {
+		measurement := &measurements[i]
+		measurement.Date = measurement.Date.Truncate(time.Microsecond)
+
+		switch {
+		case measurement.Date.IsZero():
+			results[i] = SensorMeasurementResult{Status: MeasurementStatusRejected, Reason: "date is required"}
+			continue
+		case math.IsNaN(measurement.Value) || math.IsInf(measurement.Value, 0):
+			results[i] = SensorMeasurementResult{Status: MeasurementStatusRejected, Reason: "value must be a finite number"}
+			continue
+		}
+
+		key := sensorMeasurementKey(measurement.SensorID, measurement.MeasurementType, measurement.Date)
+		previous, repeated := kept[key]
+		if !repeated {
+			kept[key] = i
+			continue
+		}
+
+		if onConflict == OnConflictUpdate {
+			results[previous] = SensorMeasurementResult{Status: MeasurementStatusDuplicate, Reason: fmt.Sprintf("superseded by reading %d of the batch", i)}
+			kept[key] = i
+		} else {
+			results[i] = SensorMeasurementResult{Status: MeasurementStatusDuplicate, Reason: fmt.Sprintf("repeats reading %d of the batch", previous)}
+		}
+	}
+
+	return results, kept
+}
+
+// InsertSensorMeasurements saves a batch of readings in one transaction, so either
+// every reading is stored or none are. Readings that already exist for the same
+// sensor, measurement type and date are left alone (OnConflictIgnore) or have their
+// value replaced (OnConflictUpdate). The returned results line up with measurements
+func InsertSensorMeasurements(ctx context.Context, db *bun.DB, measurements []SensorMeasurement, onConflict string) ([]SensorMeasurementResult, error) {
+	var conflictClause string
+	switch onConflict {
+	case "", OnConflictIgnore:
+		onConflict = OnConflictIgnore
+		conflictClause = "CONFLICT (sensor_id, measurement_type, date) DO NOTHING"
+	case OnConflictUpdate:
+		conflictClause = "CONFLICT (sensor_id, measurement_type, date) DO UPDATE"
+	default:
+		return nil, ErrorInvalidOnConflict
+	}
+
+	// planning truncates dates, so work on a copy rather than the caller's readings
+	measurements = append([]SensorMeasurement(nil), measurements...)
+	results, kept := planSensorMeasurementInsert(measurements, onConflict)
+
+	var rows []SensorMeasurement
+	var rowIndexes []int
+	for i, measurement := range measurements {
+		if kept[sensorMeasurementKey(measurement.SensorID, measurement.MeasurementType, measurement.Date)] == i && results[i].Status == "" {
+			rows = append(rows, measurement)
+			rowIndexes = append(rowIndexes, i)
+		}
+	}
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		rows, rowIndexes, err := quarantineSensorMeasurements(ctx, tx, rows, rowIndexes, results)
+		if err != nil {
+			return err
+		}
+
+		for start := 0; start < len(rows); start += measurementInsertChunkSize {
+			end := min(start+measurementInsertChunkSize, len(rows))
+			chunk := rows[start:end]
+
+			query := tx.NewInsert().
+				Model(&chunk).
+				ExcludeColumn("id").
+				On(conflictClause).
+				Returning("id, sensor_id, measurement_type, date, (xmax = 0) AS inserted")
+			if onConflict == OnConflictUpdate {
+				query = query.Set("value = EXCLUDED.value")
+			}
+
+			var inserted []insertedSensorMeasurement
+			_, err := query.Exec(ctx, &inserted)
+			if err != nil {
+				return err
+			}
+
+			stored := make(map[string]insertedSensorMeasurement, len(inserted))
+			for _, row := range inserted {
+				stored[sensorMeasurementKey(row.SensorID, row.MeasurementType, row.Date)] = row
+			}
+
+			for offset, row := range chunk {
+				index := rowIndexes[start+offset]
+				storedRow, ok := stored[sensorMeasurementKey(row.SensorID, row.MeasurementType, row.Date)]
+
+				switch {
+				case !ok:
+					results[index] = SensorMeasurementResult{Status: MeasurementStatusDuplicate, Reason: "reading already stored"}
+				case storedRow.Inserted:
+					results[index] = SensorMeasurementResult{Status: MeasurementStatusInserted, ID: storedRow.ID}
+				default:
+					results[index] = SensorMeasurementResult{Status: MeasurementStatusUpdated, ID: storedRow.ID}
+				}
+			}
+		}
+
+		var saved []SensorMeasurement
+		for i, index := range rowIndexes {
+			if status := results[index].Status; status == MeasurementStatusInserted || status == MeasurementStatusUpdated {
+				saved = append(saved, rows[i])
+			}
+		}
+
+		return updateSensorLastSeen(ctx, tx, saved)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
 // EnsureSensorExists ensures a sensor exists in the database, creating it if it doesn't exist
 // This function will auto-create sensors without checking if they're online
 func EnsureSensorExists(ctx context.Context, db *bun.DB, sensorID string, deviceID string) error {
@@ -104,8 +408,11 @@ func EnsureSensorExists(ctx context.Context, db *bun.DB, sensorID string, device
 		Where("id = ?", sensorID).
 		Scan(ctx)
 
-	// If sensor exists, we're done
+	// If sensor exists, we're done unless it was taken out of service
 	if err == nil {
+		if existingSensor.IsDecommissioned() {
+			return ErrorSensorDecommissioned
+		}
 		return nil
 	}
 
@@ -132,8 +439,9 @@ func EnsureSensorExists(ctx context.Context, db *bun.DB, sensorID string, device
 // EnsureSensorExistsIfOnline ensures a sensor exists in the database, but only creates it
 // if the provided data timestamp indicates the sensor is online (data is recent).
 // dataTimestamp: The timestamp of the incoming sensor data
-// onlineThresholdHours: How many hours back we consider data to be "recent" (default: 24 hours)
-func EnsureSensorExistsIfOnline(ctx context.Context, db *bun.DB, sensorID string, deviceID string, dataTimestamp time.Time, onlineThresholdHours int) error {
+// onlineThreshold: How far back we consider data to be "recent", sensors that don't exist
+// yet have the default reporting interval so callers pass DefaultSensorOnlineThreshold
+func EnsureSensorExistsIfOnline(ctx context.Context, db *bun.DB, sensorID string, deviceID string, dataTimestamp time.Time, onlineThreshold time.Duration) error {
 	// Check if sensor already exists
 	var existingSensor Sensor
 	err := db.NewSelect().
@@ -141,8 +449,11 @@ func EnsureSensorExistsIfOnline(ctx context.Context, db *bun.DB, sensorID string
 		Where("id = ?", sensorID).
 		Scan(ctx)
 
-	// If sensor exists, we're done
+	// If sensor exists, we're done unless it was taken out of service
 	if err == nil {
+		if existingSensor.IsDecommissioned() {
+			return ErrorSensorDecommissioned
+		}
 		return nil
 	}
 
@@ -153,12 +464,11 @@ func EnsureSensorExistsIfOnline(ctx context.Context, db *bun.DB, sensorID string
 
 	// Check if the data timestamp is recent (sensor is online)
 	now := time.Now()
-	threshold := time.Duration(onlineThresholdHours) * time.Hour
 	timeDiff := now.Sub(dataTimestamp)
 
 	// If data is too old, don't create the sensor (it's offline)
-	if timeDiff > threshold {
-		return fmt.Errorf("sensor %s appears offline: data timestamp (%v) is older than %d hours", sensorID, dataTimestamp, onlineThresholdHours)
+	if timeDiff > onlineThreshold {
+		return fmt.Errorf("sensor %s appears offline: data timestamp (%v) is older than %s", sensorID, dataTimestamp, onlineThreshold)
 	}
 
 	// Data is recent, sensor is online - create new sensor entry
@@ -181,40 +491,35 @@ func (spyd *Sensor) Save(ctx context.Context, db *bun.DB) error {
 	return err
 }
 
-func (c *PostgresClient) GetAllSensors(ctx context.Context, username string) ([]Sensor, error) {
+// Update stores the given columns of the sensor
+func (spyd *Sensor) Update(ctx context.Context, db *bun.DB, columns ...string) error {
+	_, err := db.NewUpdate().Model(spyd).Column(columns...).WherePK().Exec(ctx)
+	return err
+}
+
+// GetAllSensors returns the sensors in service, and the decommissioned
+// sensors as well if includeDecommissioned is true
+func (c *PostgresClient) GetAllSensors(ctx context.Context, username string, includeDecommissioned bool) ([]Sensor, error) {
 	var sensors []Sensor
 	// TODO: When users are associated with sensors, filter by username/userid
-	err := c.DB.NewSelect().Model(&sensors).Scan(ctx)
-	if err != nil {
-		return nil, err
+	query := c.DB.NewSelect().Model(&sensors)
+	if !includeDecommissioned {
+		query = query.Where("decommissioned_at IS NULL")
 	}
-	return sensors, nil
-}
-
-func GetSensorTemperatureData(ctx context.Context, db *bun.DB, sensorID string) ([]SensorTemperatureData, error) {
-	var data []SensorTemperatureData
-	err := db.NewSelect().
-		Model(&data).
-		Where("sensor_id = ?", sensorID).
-		Scan(ctx)
-
+	err := query.Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(data) == 0 {
-		return nil, ErrorNoSensorTemperatureData
-	}
-
-	return data, nil
+	return sensors, nil
 }
 
-func CreateSensor(ctx context.Context, db *bun.DB, eui, name, location string) (Sensor, error) {
+func CreateSensor(ctx context.Context, db *bun.DB, eui, name, location string, coordinates SensorCoordinates) (Sensor, error) {
 	sensor := Sensor{
-		ID:               eui,
-		Name:             name,
-		Location:         location,
-		InstallationDate: time.Now(),
+		ID:                eui,
+		Name:              name,
+		Location:          location,
+		InstallationDate:  time.Now(),
+		SensorCoordinates: coordinates,
 	}
 	_, err := db.NewInsert().Model(&sensor).Exec(ctx)
 	return sensor, err
@@ -226,6 +531,8 @@ func GetSensorByID(ctx context.Context, db *bun.DB, id string) (Sensor, error) {
 	return sensor, err
 }
 
+// DeleteSensor permanently deletes a sensor and all of its readings, sensors
+// that are taken out of service should be decommissioned instead
 func DeleteSensor(ctx context.Context, db *bun.DB, id string) error {
 	// Start a transaction to ensure all deletions succeed or none do
 	tx, err := db.BeginTx(ctx, nil)
@@ -234,26 +541,13 @@ func DeleteSensor(ctx context.Context, db *bun.DB, id string) error {
 	}
 	defer tx.Rollback()
 
-	// Delete all related sensor data first (due to foreign key constraints)
-	// 1. Delete battery data
-	_, err = tx.NewDelete().Model((*SensorBatteryData)(nil)).Where("sensor_id = ?", id).Exec(ctx)
-	if err != nil {
-		return err
-	}
-
-	// 2. Delete moisture data
-	_, err = tx.NewDelete().Model((*SensorMoistureData)(nil)).Where("sensor_id = ?", id).Exec(ctx)
+	// Delete all of the sensor's readings first (due to foreign key constraints)
+	_, err = tx.NewDelete().Model((*SensorMeasurement)(nil)).Where("sensor_id = ?", id).Exec(ctx)
 	if err != nil {
 		return err
 	}
 
-	// 3. Delete temperature data
-	_, err = tx.NewDelete().Model((*SensorTemperatureData)(nil)).Where("sensor_id = ?", id).Exec(ctx)
-	if err != nil {
-		return err
-	}
-
-	// 4. Finally delete the sensor itself
+	// Finally delete the sensor itself
 	_, err = tx.NewDelete().Model((*Sensor)(nil)).Where("id = ?", id).Exec(ctx)
 	if err != nil {
 		return err
@@ -262,38 +556,3 @@ func DeleteSensor(ctx context.Context, db *bun.DB, id string) error {
 	// Commit the transaction
 	return tx.Commit()
 }
-
-type SensorBatteryData struct {
-	ID           int       `bun:"id,pk,autoincrement"`
-	SensorID     string    `bun:"sensor_id"`
-	Date         time.Time `bun:"date"`
-	BatteryLevel float64   `bun:"battery_level"`
-}
-
-func GetSensorBatteryDataForSensorID(ctx context.Context, db *bun.DB, sensorID string, startDate, endDate time.Time) ([]SensorBatteryData, error) {
-	var data []SensorBatteryData
-	query := db.NewSelect().Model(&data).Where("sensor_id = ?", sensorID)
-
-	if !startDate.IsZero() {
-		query.Where("date >= ?", startDate)
-	}
-	if !endDate.IsZero() {
-		query.Where("date <= ?", endDate)
-	}
-
-	err := query.Scan(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(data) == 0 {
-		return nil, ErrorNoSensorBatteryData
-	}
-
-	return data, nil
-}
-
-func (d *SensorBatteryData) Save(ctx context.Context, db *bun.DB) error {
-	_, err := db.NewInsert().Model(d).Exec(ctx)
-	return err
-}
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	SortOrderAscending  = "asc"
+	SortOrderDescending = "desc"
+)
+
+var (
+	ErrorInvalidSensorDataCursor = errors.New("invalid sensor data cursor")
+)
+
+// SensorDataQuery narrows and pages a read of a sensor's readings
+type SensorDataQuery struct {
+	// Start and End bound the reading dates (inclusive), ignored when zero
+	Start time.Time
+	End   time.Time
+	// Limit is the maximum number of rows in a page, 0 for no limit
+	Limit int
+	// Order is SortOrderAscending (the default) or SortOrderDescending by date
+	Order string
+	// After resumes a read from the last row of the previous page
+	After *SensorDataCursor
+}
+
+// SensorDataCursor identifies the last row returned in a page of readings,
+// readings are ordered by date then id so the pair is a stable position
+type SensorDataCursor struct {
+	Date time.Time
+	ID   int
+}
+
+// Encode returns the opaque string form of the cursor that is
+// handed out to api clients
+func (c SensorDataCursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.Date.UnixNano(), c.ID)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeSensorDataCursor parses a cursor previously returned by Encode
+// returning ErrorInvalidSensorDataCursor if it is malformed
+func DecodeSensorDataCursor(encoded string) (SensorDataCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return SensorDataCursor{}, ErrorInvalidSensorDataCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 {
+		return SensorDataCursor{}, ErrorInvalidSensorDataCursor
+	}
+
+	unixNano, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return SensorDataCursor{}, ErrorInvalidSensorDataCursor
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return SensorDataCursor{}, ErrorInvalidSensorDataCursor
+	}
+
+	return SensorDataCursor{Date: time.Unix(0, unixNano).UTC(), ID: id}, nil
+}
+
+// apply adds the filtering, ordering and paging for the query to a select
+// over one of the sensor readings tables, fetching one more row than the
+// limit so callers can tell if there is a next page
+func (q SensorDataQuery) apply(query *bun.SelectQuery) *bun.SelectQuery {
+	if !q.Start.IsZero() {
+		query = query.Where("date >= ?", q.Start)
+	}
+
+	if !q.End.IsZero() {
+		query = query.Where("date <= ?", q.End)
+	}
+
+	if q.Order == SortOrderDescending {
+		if q.After != nil {
+			query = query.Where("(date, id) < (?, ?)", q.After.Date, q.After.ID)
+		}
+		query = query.OrderExpr("date DESC, id DESC")
+	} else {
+		if q.After != nil {
+			query = query.Where("(date, id) > (?, ?)", q.After.Date, q.After.ID)
+		}
+		query = query.OrderExpr("date ASC, id ASC")
+	}
+
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit + 1)
+	}
+
+	return query
+}
+
+const (
+	ResolutionHourly  = "hourly"
+	ResolutionDaily   = "daily"
+	ResolutionWeekly  = "weekly"
+	ResolutionMonthly = "monthly"
+)
+
+var (
+	ErrorInvalidResolution = errors.New("invalid resolution")
+
+	// resolutionToDateTruncField maps api resolutions to postgres date_trunc fields
+	resolutionToDateTruncField = map[string]string{
+		ResolutionHourly:  "hour",
+		ResolutionDaily:   "day",
+		ResolutionWeekly:  "week",
+		ResolutionMonthly: "month",
+	}
+)
+
+// ValidResolution returns true if resolution is one of the supported bucket sizes
+func ValidResolution(resolution string) bool {
+	_, ok := resolutionToDateTruncField[resolution]
+
+	return ok
+}
+
+// SensorDataBucket summarises the readings that fall in one time bucket
+type SensorDataBucket struct {
+	BucketStart time.Time `bun:"bucket_start"`
+	Min         float64   `bun:"min"`
+	Max         float64   `bun:"max"`
+	Avg         float64   `bun:"avg"`
+	Count       int       `bun:"count"`
+}
+
+// rollupTableForField returns the rollups table whose buckets fit in buckets of the date_trunc field
+func rollupTableForField(field string) string {
+	if field == "hour" {
+		return SensorMeasurementRollupsHourlyTable
+	}
+
+	return SensorMeasurementRollupsDailyTable
+}
+
+// tieredBucketsQuery returns a select of the buckets of the readings selected by filter,
+// grouped by groupColumns then bucket_start. Raw readings are combined with the rollups
+// of readings that were deleted once past their retention, a rollup is counted when its
+// own bucket starts in the filter's date range. Hourly buckets are read from the hourly
+// rollups and every other resolution from the daily rollups
+func tieredBucketsQuery(db *bun.DB, field string, filter SensorMeasurementFilter, groupColumns ...string) *bun.SelectQuery {
+	group := strings.Join(append(groupColumns, "bucket_start"), ", ")
+
+	raw := filter.apply(db.NewSelect().
+		Model((*SensorMeasurement)(nil)).
+		Column(groupColumns...).
+		ColumnExpr("date_trunc(?, date, 'UTC') AS bucket_start", field).
+		ColumnExpr("MIN(value) AS min").
+		ColumnExpr("MAX(value) AS max").
+		ColumnExpr("SUM(value) AS total").
+		ColumnExpr("COUNT(*) AS count")).
+		GroupExpr(group)
+
+	rolledUp := filter.applyRollup(db.NewSelect().
+		TableExpr("? AS rollup", bun.Ident(rollupTableForField(field))).
+		Column(groupColumns...).
+		ColumnExpr("date_trunc(?, bucket_start, 'UTC') AS bucket_start", field).
+		ColumnExpr("MIN(min) AS min").
+		ColumnExpr("MAX(max) AS max").
+		ColumnExpr("SUM(avg * count) AS total").
+		ColumnExpr("SUM(count) AS count")).
+		GroupExpr(group)
+
+	return db.NewSelect().
+		TableExpr("(?) AS tiers", raw.UnionAll(rolledUp)).
+		Column(groupColumns...).
+		Column("bucket_start").
+		ColumnExpr("MIN(min) AS min").
+		ColumnExpr("MAX(max) AS max").
+		ColumnExpr("SUM(total) / SUM(count) AS avg").
+		ColumnExpr("SUM(count) AS count").
+		GroupExpr(group)
+}
+
+// GetSensorMeasurementBuckets groups the sensors' readings of measurementType into UTC
+// buckets of the given resolution, computing min, max, average and count in postgres
+// from both the raw readings and the rollups of readings past their retention.
+// Only the date range and order of query are used, weeks start on Monday
+func GetSensorMeasurementBuckets(ctx context.Context, db *bun.DB, sensorIDs []string, measurementType string, resolution string, query SensorDataQuery) ([]SensorDataBucket, error) {
+	field, ok := resolutionToDateTruncField[resolution]
+	if !ok {
+		return nil, ErrorInvalidResolution
+	}
+
+	filter := SensorMeasurementFilter{
+		SensorIDs:        sensorIDs,
+		MeasurementTypes: []string{measurementType},
+		Start:            query.Start,
+		End:              query.End,
+	}
+
+	selectQuery := tieredBucketsQuery(db, field, filter)
+	if query.Order == SortOrderDescending {
+		selectQuery = selectQuery.OrderExpr("bucket_start DESC")
+	} else {
+		selectQuery = selectQuery.OrderExpr("bucket_start ASC")
+	}
+
+	var buckets []SensorDataBucket
+	err := selectQuery.Scan(ctx, &buckets)
+
+	return buckets, err
+}
+
+// SensorSeriesBucket is a bucket of one sensor's readings of one measurement type
+type SensorSeriesBucket struct {
+	SensorID        string `bun:"sensor_id"`
+	MeasurementType string `bun:"measurement_type"`
+	SensorDataBucket
+}
+
+// SensorSeriesLatest is the date of a sensor's most recent reading of a measurement type
+type SensorSeriesLatest struct {
+	SensorID        string    `bun:"sensor_id"`
+	MeasurementType string    `bun:"measurement_type"`
+	Date            time.Time `bun:"date"`
+}
+
+// GetSensorMeasurementSeries returns the readings selected by filter ordered by sensor,
+// measurement type and date. When limit is above 0 at most limit+1 of the earliest
+// readings of each sensor and measurement type are returned, so callers can tell
+// which series were cut short
+func GetSensorMeasurementSeries(ctx context.Context, db *bun.DB, filter SensorMeasurementFilter, limit int) ([]SensorMeasurement, error) {
+	var data []SensorMeasurement
+	if limit <= 0 {
+		err := filter.apply(db.NewSelect().Model(&data)).
+			OrderExpr("sensor_id ASC, measurement_type ASC, date ASC, id ASC").
+			Scan(ctx)
+
+		return data, err
+	}
+
+	numbered := filter.apply(db.NewSelect().
+		Model((*SensorMeasurement)(nil)).
+		Column("id", "sensor_id", "measurement_type", "date", "value").
+		ColumnExpr("ROW_NUMBER() OVER (PARTITION BY sensor_id, measurement_type ORDER BY date ASC, id ASC) AS series_row"))
+
+	err := db.NewSelect().
+		Model(&data).
+		ModelTableExpr("(?) AS sensor_measurement", numbered).
+		Where("series_row <= ?", limit+1).
+		OrderExpr("sensor_id ASC, measurement_type ASC, date ASC, id ASC").
+		Scan(ctx)
+
+	return data, err
+}
+
+// GetSensorMeasurementSeriesBuckets groups the readings selected by filter, raw and rolled
+// up, into UTC buckets of the given resolution for each sensor and measurement type,
+// ordered by sensor, measurement type and bucket
+func GetSensorMeasurementSeriesBuckets(ctx context.Context, db *bun.DB, filter SensorMeasurementFilter, resolution string) ([]SensorSeriesBucket, error) {
+	field, ok := resolutionToDateTruncField[resolution]
+	if !ok {
+		return nil, ErrorInvalidResolution
+	}
+
+	var buckets []SensorSeriesBucket
+	err := tieredBucketsQuery(db, field, filter, "sensor_id", "measurement_type").
+		OrderExpr("sensor_id ASC, measurement_type ASC, bucket_start ASC").
+		Scan(ctx, &buckets)
+
+	return buckets, err
+}
+
+// GetLatestSensorMeasurementDates returns the date of the most recent reading of each
+// sensor and measurement type selected by filter, ignoring the filter's date range
+func GetLatestSensorMeasurementDates(ctx context.Context, db *bun.DB, filter SensorMeasurementFilter) ([]SensorSeriesLatest, error) {
+	filter.Start, filter.End = time.Time{}, time.Time{}
+
+	var latest []SensorSeriesLatest
+	err := filter.apply(db.NewSelect().
+		Model((*SensorMeasurement)(nil)).
+		Column("sensor_id", "measurement_type").
+		ColumnExpr("MAX(date) AS date")).
+		GroupExpr("sensor_id, measurement_type").
+		Scan(ctx, &latest)
+
+	return latest, err
+}
+
+// GetSensorMeasurementsAt returns the newest reading of each sensor and measurement type
+// selected by filter, among the readings in the filter's date range
+func GetSensorMeasurementsAt(ctx context.Context, db *bun.DB, filter SensorMeasurementFilter) ([]SensorMeasurement, error) {
+	var measurements []SensorMeasurement
+	err := filter.apply(db.NewSelect().
+		Model(&measurements).
+		DistinctOn("sensor_id, measurement_type")).
+		OrderExpr("sensor_id, measurement_type, date DESC").
+		Scan(ctx)
+
+	return measurements, err
+}
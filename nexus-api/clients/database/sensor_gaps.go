@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// SensorGapTolerance is how many reporting intervals can pass between two readings
+// of a sensor before the time between them counts as a gap, the half interval on top
+// of the expected one absorbs jitter in when readings arrive
+const SensorGapTolerance = 1.5
+
+// SensorReadingGap is the time between two consecutive readings of a sensor that were
+// further apart than SensorGapTolerance reporting intervals
+type SensorReadingGap struct {
+	SensorID string    `bun:"sensor_id"`
+	Start    time.Time `bun:"gap_start"` // Date of the reading before the gap
+	End      time.Time `bun:"gap_end"`   // Date of the reading that ended the gap
+}
+
+// SensorReadingSpan is the first and last reading of a sensor and how many distinct
+// times it reported at, readings of several types taken together count once
+type SensorReadingSpan struct {
+	SensorID string    `bun:"sensor_id"`
+	First    time.Time `bun:"first_date"`
+	Last     time.Time `bun:"last_date"`
+	Count    int       `bun:"count"`
+}
+
+// distinctReadingDates selects the distinct times each sensor reported at among the
+// readings selected by filter
+func distinctReadingDates(db *bun.DB, filter SensorMeasurementFilter) *bun.SelectQuery {
+	return filter.apply(db.NewSelect().
+		Model((*SensorMeasurement)(nil)).
+		Distinct().
+		Column("sensor_id", "date"))
+}
+
+// GetSensorReadingGaps returns the gaps between consecutive readings selected by filter,
+// judged against each sensor's reporting interval, ordered by sensor and date. Gaps
+// before the first and after the last reading in the filter's range aren't included
+func GetSensorReadingGaps(ctx context.Context, db *bun.DB, filter SensorMeasurementFilter) ([]SensorReadingGap, error) {
+	spaced := db.NewSelect().
+		TableExpr("(?) AS reading_dates", distinctReadingDates(db, filter)).
+		Column("sensor_id").
+		ColumnExpr("LAG(date) OVER (PARTITION BY sensor_id ORDER BY date) AS gap_start").
+		ColumnExpr("date AS gap_end")
+
+	var gaps []SensorReadingGap
+	err := db.NewSelect().
+		TableExpr("(?) AS spaced", spaced).
+		ColumnExpr("spaced.sensor_id, spaced.gap_start, spaced.gap_end").
+		Join("JOIN sensors ON sensors.id = spaced.sensor_id").
+		Where("spaced.gap_start IS NOT NULL").
+		Where("spaced.gap_end - spaced.gap_start > make_interval(secs => sensors.reporting_interval_seconds * ?)", SensorGapTolerance).
+		OrderExpr("spaced.sensor_id ASC, spaced.gap_start ASC").
+		Scan(ctx, &gaps)
+
+	return gaps, err
+}
+
+// GetSensorReadingSpans returns the span of the readings selected by filter for each
+// sensor that has any
+func GetSensorReadingSpans(ctx context.Context, db *bun.DB, filter SensorMeasurementFilter) ([]SensorReadingSpan, error) {
+	var spans []SensorReadingSpan
+	err := db.NewSelect().
+		TableExpr("(?) AS reading_dates", distinctReadingDates(db, filter)).
+		Column("sensor_id").
+		ColumnExpr("MIN(date) AS first_date").
+		ColumnExpr("MAX(date) AS last_date").
+		ColumnExpr("COUNT(*) AS count").
+		GroupExpr("sensor_id").
+		Scan(ctx, &spans)
+
+	return spans, err
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// maxSensorChainLength bounds how far a chain of replacements is followed
+const maxSensorChainLength = 100
+
+var (
+	ErrorSensorAlreadyReplaced = errors.New("sensor has already been replaced")
+	ErrorSensorReplacesAnother = errors.New("replacement sensor already replaces another sensor")
+)
+
+// ReplaceSensor links the sensor newID to the sensor oldID it was installed in place of,
+// creating it if it doesn't exist yet. The new sensor takes the name, location,
+// coordinates, zone and reporting settings of the old one, which is decommissioned by
+// replacedBy if it is still in service. The old sensor not existing is returned as
+// sql.ErrNoRows
+func ReplaceSensor(ctx context.Context, db *bun.DB, oldID string, newID string, replacedBy string) (Sensor, error) {
+	var replacement Sensor
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var old Sensor
+		err := tx.NewSelect().Model(&old).Where("id = ?", oldID).For("UPDATE").Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		// a sensor that already has a successor, old or new, would fork or loop the chain
+		replaced, err := tx.NewSelect().Model((*Sensor)(nil)).Where("replaces_sensor_id IN (?)", bun.In([]string{oldID, newID})).Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if replaced {
+			return ErrorSensorAlreadyReplaced
+		}
+
+		now := time.Now()
+		err = tx.NewSelect().Model(&replacement).Where("id = ?", newID).For("UPDATE").Scan(ctx)
+		exists := err == nil
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if exists {
+			if replacement.IsDecommissioned() {
+				return ErrorSensorDecommissioned
+			}
+			if replacement.ReplacesSensorID != "" {
+				return ErrorSensorReplacesAnother
+			}
+		} else {
+			replacement = Sensor{ID: newID, InstallationDate: now}
+		}
+
+		replacement.Name = old.Name
+		replacement.Location = old.Location
+		replacement.SensorCoordinates = old.SensorCoordinates
+		replacement.ReportingIntervalSeconds = old.ReportingIntervalSeconds
+		replacement.OfflineGraceSeconds = old.OfflineGraceSeconds
+		replacement.ReplacesSensorID = oldID
+		replacement.ZoneID = old.ZoneID
+		replacement.ZoneAssignedManually = old.ZoneAssignedManually
+
+		if exists {
+			_, err = tx.NewUpdate().Model(&replacement).
+				Column("name", "location", "latitude", "longitude", "reporting_interval_seconds", "offline_grace_seconds", "replaces_sensor_id", "zone_id", "zone_assigned_manually").
+				WherePK().
+				Exec(ctx)
+		} else {
+			_, err = tx.NewInsert().Model(&replacement).Exec(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		if old.IsDecommissioned() {
+			return nil
+		}
+
+		old.DecommissionedAt = &now
+		old.DecommissionReason = "replaced by " + newID
+		old.DecommissionedBy = replacedBy
+		_, err = tx.NewUpdate().Model(&old).
+			Column("decommissioned_at", "decommission_reason", "decommissioned_by").
+			WherePK().
+			Exec(ctx)
+
+		return err
+	})
+
+	return replacement, err
+}
+
+// sensorChainLink is a sensor in the chain of replacements of a root sensor,
+// depth counts replacements from the root, negative for the sensors it replaced
+type sensorChainLink struct {
+	Root     string `bun:"root"`
+	SensorID string `bun:"sensor_id"`
+	Depth    int    `bun:"depth"`
+}
+
+// GetSensorChains returns the ids of every sensor in the chain of replacements each of the
+// sensors is part of, oldest first and including the sensor itself. Sensors that don't
+// exist are left out of the map
+func GetSensorChains(ctx context.Context, db *bun.DB, sensorIDs []string) (map[string][]string, error) {
+	chains := make(map[string][]string, len(sensorIDs))
+	if len(sensorIDs) == 0 {
+		return chains, nil
+	}
+
+	var links []sensorChainLink
+	err := db.NewRaw(`
+		WITH RECURSIVE predecessors AS (
+			SELECT id AS root, id AS sensor_id, replaces_sensor_id, 0 AS depth
+			FROM sensors WHERE id IN (?0)
+			UNION ALL
+			SELECT p.root, s.id, s.replaces_sensor_id, p.depth - 1
+			FROM sensors s JOIN predecessors p ON s.id = p.replaces_sensor_id
+			WHERE p.depth > -?1
+		), successors AS (
+			SELECT id AS root, id AS sensor_id, 0 AS depth
+			FROM sensors WHERE id IN (?0)
+			UNION ALL
+			SELECT f.root, s.id, f.depth + 1
+			FROM sensors s JOIN successors f ON s.replaces_sensor_id = f.sensor_id
+			WHERE f.depth < ?1
+		)
+		SELECT root, sensor_id, depth FROM predecessors
+		UNION
+		SELECT root, sensor_id, depth FROM successors
+		ORDER BY root, depth`, bun.In(sensorIDs), maxSensorChainLength).
+		Scan(ctx, &links)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		chains[link.Root] = append(chains[link.Root], link.SensorID)
+	}
+
+	return chains, nil
+}
+
+// GetSensorChain returns the ids of every sensor in the chain of replacements the sensor
+// is part of, oldest first. A sensor that doesn't exist is its own chain
+func GetSensorChain(ctx context.Context, db *bun.DB, sensorID string) ([]string, error) {
+	chains, err := GetSensorChains(ctx, db, []string{sensorID})
+	if err != nil {
+		return nil, err
+	}
+
+	chain, ok := chains[sensorID]
+	if !ok {
+		return []string{sensorID}, nil
+	}
+
+	return chain, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	DefaultSensorReportingInterval = time.Hour
+	DefaultSensorOfflineGrace      = 23 * time.Hour
+	// DefaultSensorOnlineThreshold is the longest a sensor with the default reporting
+	// interval and grace period can go without a reading and still be online
+	DefaultSensorOnlineThreshold = DefaultSensorReportingInterval + DefaultSensorOfflineGrace
+)
+
+// SensorLastSeen is the newest reading of one measurement type taken by a sensor
+type SensorLastSeen struct {
+	bun.BaseModel `bun:"table:sensor_last_seen"`
+
+	SensorID        string    `bun:"sensor_id,pk"`
+	MeasurementType string    `bun:"measurement_type,pk"`
+	LastDate        time.Time `bun:"last_date"`
+	LastValue       float64   `bun:"last_value"`
+}
+
+// updateSensorLastSeen records the newest of the saved readings of each sensor and
+// measurement type, and each sensor's last seen time. A sensor's online_since is
+// moved to its newest reading when that reading ends a gap longer than the sensor's
+// reporting interval plus grace period, which is when an offline sensor comes back online
+func updateSensorLastSeen(ctx context.Context, tx bun.Tx, saved []SensorMeasurement) error {
+	if len(saved) == 0 {
+		return nil
+	}
+
+	type seriesKey struct{ sensorID, measurementType string }
+	newestByType := make(map[seriesKey]SensorLastSeen)
+	newestBySensor := make(map[string]time.Time)
+	for _, measurement := range saved {
+		key := seriesKey{measurement.SensorID, measurement.MeasurementType}
+		if newest, ok := newestByType[key]; !ok || !measurement.Date.Before(newest.LastDate) {
+			newestByType[key] = SensorLastSeen{
+				SensorID:        measurement.SensorID,
+				MeasurementType: measurement.MeasurementType,
+				LastDate:        measurement.Date,
+				LastValue:       measurement.Value,
+			}
+		}
+
+		if measurement.Date.After(newestBySensor[measurement.SensorID]) {
+			newestBySensor[measurement.SensorID] = measurement.Date
+		}
+	}
+
+	lastSeen := make([]SensorLastSeen, 0, len(newestByType))
+	for _, newest := range newestByType {
+		lastSeen = append(lastSeen, newest)
+	}
+
+	_, err := tx.NewInsert().
+		Model(&lastSeen).
+		On("CONFLICT (sensor_id, measurement_type) DO UPDATE").
+		Set("last_date = EXCLUDED.last_date").
+		Set("last_value = EXCLUDED.last_value").
+		Where("sensor_last_seen.last_date <= EXCLUDED.last_date").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	for sensorID, newest := range newestBySensor {
+		_, err = tx.NewUpdate().
+			Model((*Sensor)(nil)).
+			Set("online_since = CASE WHEN last_seen_at IS NULL OR ? > last_seen_at + make_interval(secs => reporting_interval_seconds + offline_grace_seconds) THEN ? ELSE online_since END", newest, newest).
+			Set("last_seen_at = GREATEST(last_seen_at, ?)", newest).
+			Where("id = ?", sensorID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetSensorLastSeen returns the newest reading of every measurement type of every
+// sensor, ordered by sensor and measurement type
+func GetSensorLastSeen(ctx context.Context, db *bun.DB) ([]SensorLastSeen, error) {
+	var lastSeen []SensorLastSeen
+	err := db.NewSelect().Model(&lastSeen).OrderExpr("sensor_id ASC, measurement_type ASC").Scan(ctx)
+
+	return lastSeen, err
+}
+
+// GetSensorOnlineThresholds returns the online threshold of each of the given sensors
+// that exists, callers fall back to DefaultSensorOnlineThreshold for the rest
+func GetSensorOnlineThresholds(ctx context.Context, db *bun.DB, sensorIDs []string) (map[string]time.Duration, error) {
+	thresholds := make(map[string]time.Duration, len(sensorIDs))
+	if len(sensorIDs) == 0 {
+		return thresholds, nil
+	}
+
+	var sensors []Sensor
+	err := db.NewSelect().
+		Model(&sensors).
+		Column("id", "reporting_interval_seconds", "offline_grace_seconds").
+		Where("id IN (?)", bun.In(sensorIDs)).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sensor := range sensors {
+		thresholds[sensor.ID] = sensor.OnlineThreshold()
+	}
+
+	return thresholds, nil
+}
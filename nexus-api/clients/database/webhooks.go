@@ -0,0 +1,366 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Types of the events sent to webhook subscriptions
+const (
+	WebhookEventReadingsCreated    = "readings.created"
+	WebhookEventSensorOffline      = "sensor.offline"
+	WebhookEventSensorOnline       = "sensor.online"
+	WebhookEventSensorCreated      = "sensor.created"
+	WebhookEventSensorDeleted      = "sensor.deleted"
+	WebhookEventDroneImageUploaded = "drone_image.uploaded"
+	WebhookEventUserCreated        = "user.created"
+	// a decommissioned sensor keeps its history and can be recommissioned,
+	// sensor.deleted is only sent when a sensor is purged
+	WebhookEventSensorDecommissioned = "sensor.decommissioned"
+	WebhookEventSensorRecommissioned = "sensor.recommissioned"
+	// a critical alert nobody acknowledged in time, sent along with emails to the
+	// escalation recipients of its rule
+	WebhookEventAlertEscalated = "alert.escalated"
+)
+
+// WebhookEventTypes are the types of event subscriptions can be made to
+var WebhookEventTypes = []string{
+	WebhookEventReadingsCreated,
+	WebhookEventSensorOffline,
+	WebhookEventSensorOnline,
+	WebhookEventSensorCreated,
+	WebhookEventSensorDeleted,
+	WebhookEventSensorDecommissioned,
+	WebhookEventSensorRecommissioned,
+	WebhookEventDroneImageUploaded,
+	WebhookEventUserCreated,
+	WebhookEventAlertEscalated,
+}
+
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusDelivered = "delivered"
+	WebhookDeliveryStatusFailed    = "failed"
+)
+
+var (
+	ErrorNoWebhookSubscription = errors.New("no webhook subscription found")
+)
+
+// WebhookSubscription is an endpoint events of EventTypes are sent to, signed with Secret
+type WebhookSubscription struct {
+	ID          uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	URL         string    `bun:"url"`
+	Secret      string    `bun:"secret"`
+	EventTypes  []string  `bun:"event_types,array"`
+	Description string    `bun:"description,nullzero"`
+	Enabled     bool      `bun:"enabled"`
+	CreatedBy   string    `bun:"created_by,nullzero"`
+	CreatedAt   time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt   time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// Save adds the subscription
+func (s *WebhookSubscription) Save(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewInsert().Model(s).Returning("*").Exec(ctx)
+
+	return err
+}
+
+// Update saves changes to the subscription, deliveries already added keep being sent
+// to it, to its new url if it changed
+func (s *WebhookSubscription) Update(ctx context.Context, db *bun.DB) error {
+	s.UpdatedAt = time.Now()
+
+	result, err := db.NewUpdate().
+		Model(s).
+		Column("url", "secret", "event_types", "description", "enabled", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrorNoWebhookSubscription
+	}
+
+	return nil
+}
+
+// GetWebhookSubscriptions returns every webhook subscription oldest first
+func GetWebhookSubscriptions(ctx context.Context, db *bun.DB) ([]WebhookSubscription, error) {
+	var subscriptions []WebhookSubscription
+	err := db.NewSelect().Model(&subscriptions).OrderExpr("created_at ASC, id ASC").Scan(ctx)
+
+	return subscriptions, err
+}
+
+// GetWebhookSubscription returns the subscription with the given id or
+// ErrorNoWebhookSubscription if there is none
+func GetWebhookSubscription(ctx context.Context, db *bun.DB, id uuid.UUID) (WebhookSubscription, error) {
+	var subscription WebhookSubscription
+	err := db.NewSelect().Model(&subscription).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return WebhookSubscription{}, ErrorNoWebhookSubscription
+		}
+		return WebhookSubscription{}, err
+	}
+
+	return subscription, nil
+}
+
+// DeleteWebhookSubscription deletes a subscription along with its delivery log
+func DeleteWebhookSubscription(ctx context.Context, db *bun.DB, id uuid.UUID) error {
+	result, err := db.NewDelete().Model((*WebhookSubscription)(nil)).Where("id = ?", id).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrorNoWebhookSubscription
+	}
+
+	return nil
+}
+
+// WebhookEvent is an event to send to the subscriptions to its type, Payload is the body
+// of every delivery of it
+type WebhookEvent struct {
+	ID      uuid.UUID
+	Type    string
+	Payload json.RawMessage
+}
+
+// WebhookDelivery is an event sent to a subscription, pending until the subscription's
+// endpoint accepts it or every attempt to send it failed
+type WebhookDelivery struct {
+	ID             int64           `bun:"id,pk,autoincrement"`
+	SubscriptionID uuid.UUID       `bun:"subscription_id,type:uuid"`
+	EventID        uuid.UUID       `bun:"event_id,type:uuid"`
+	EventType      string          `bun:"event_type"`
+	Payload        json.RawMessage `bun:"payload,type:jsonb"`
+	// Status is one of the WebhookDeliveryStatus values
+	Status string `bun:"status"`
+	// Attempts counts the attempts since the delivery was added or last replayed
+	Attempts       int        `bun:"attempts"`
+	Replays        int        `bun:"replays"`
+	NextAttemptAt  time.Time  `bun:"next_attempt_at,nullzero,notnull,default:current_timestamp"`
+	LastAttemptAt  *time.Time `bun:"last_attempt_at"`
+	LastStatusCode int        `bun:"last_status_code,nullzero"` // Zero when the last attempt got no response
+	LastError      string     `bun:"last_error,nullzero"`
+	LastResponse   string     `bun:"last_response,nullzero"`
+	DeliveredAt    *time.Time `bun:"delivered_at"`
+	CreatedAt      time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// AddWebhookDeliveries adds a pending delivery of the event for each enabled subscription
+// to its type, returning how many were added. db can be a transaction so the event is
+// only sent if what it announces is saved
+func AddWebhookDeliveries(ctx context.Context, db bun.IDB, event WebhookEvent) (int, error) {
+	var subscriptionIDs []uuid.UUID
+	err := db.NewSelect().
+		Model((*WebhookSubscription)(nil)).
+		Column("id").
+		Where("enabled").
+		Where("? = ANY(event_types)", event.Type).
+		Scan(ctx, &subscriptionIDs)
+	if err != nil || len(subscriptionIDs) == 0 {
+		return 0, err
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(subscriptionIDs))
+	for _, subscriptionID := range subscriptionIDs {
+		deliveries = append(deliveries, WebhookDelivery{
+			SubscriptionID: subscriptionID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        event.Payload,
+			Status:         WebhookDeliveryStatusPending,
+		})
+	}
+
+	_, err = db.NewInsert().Model(&deliveries).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(deliveries), nil
+}
+
+// ClaimWebhookDeliveries takes up to limit pending deliveries due at now to the enabled
+// subscriptions and pushes their next attempt back by lease, so other instances of the
+// service leave them alone while they are sent. The subscriptions they go to are
+// returned by id. A delivery whose attempt is cut short is sent again once the lease ends
+func ClaimWebhookDeliveries(ctx context.Context, db *bun.DB, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, map[uuid.UUID]WebhookSubscription, error) {
+	var deliveries []WebhookDelivery
+	subscriptions := make(map[uuid.UUID]WebhookSubscription)
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().
+			Model(&deliveries).
+			Where("status = ?", WebhookDeliveryStatusPending).
+			Where("next_attempt_at <= ?", now).
+			Where("subscription_id IN (SELECT id FROM webhook_subscriptions WHERE enabled)").
+			OrderExpr("next_attempt_at ASC, id ASC").
+			Limit(limit).
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]int64, 0, len(deliveries))
+		subscriptionIDs := make([]uuid.UUID, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+			subscriptionIDs = append(subscriptionIDs, delivery.SubscriptionID)
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*WebhookDelivery)(nil)).
+			Set("next_attempt_at = ?", now.Add(lease)).
+			Where("id IN (?)", bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		var claimed []WebhookSubscription
+		err = tx.NewSelect().Model(&claimed).Where("id IN (?)", bun.In(subscriptionIDs)).Scan(ctx)
+		for _, subscription := range claimed {
+			subscriptions[subscription.ID] = subscription
+		}
+
+		return err
+	})
+
+	return deliveries, subscriptions, err
+}
+
+// RecordWebhookAttempt saves the outcome of an attempt to send a delivery
+func RecordWebhookAttempt(ctx context.Context, db *bun.DB, delivery *WebhookDelivery) error {
+	_, err := db.NewUpdate().
+		Model(delivery).
+		Column("status", "attempts", "next_attempt_at", "last_attempt_at", "last_status_code", "last_error", "last_response", "delivered_at").
+		WherePK().
+		Exec(ctx)
+
+	return err
+}
+
+// ReplayWebhookDeliveries puts the failed deliveries to a subscription back in the queue
+// to be sent at now with a fresh set of attempts, only those in ids unless it is empty.
+// It returns how many deliveries were replayed
+func ReplayWebhookDeliveries(ctx context.Context, db *bun.DB, subscriptionID uuid.UUID, ids []int64, now time.Time) (int, error) {
+	query := db.NewUpdate().
+		Model((*WebhookDelivery)(nil)).
+		Set("status = ?", WebhookDeliveryStatusPending).
+		Set("attempts = 0").
+		Set("replays = replays + 1").
+		Set("next_attempt_at = ?", now).
+		Where("subscription_id = ?", subscriptionID).
+		Where("status = ?", WebhookDeliveryStatusFailed)
+	if len(ids) > 0 {
+		query = query.Where("id IN (?)", bun.In(ids))
+	}
+
+	result, err := query.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed, err := result.RowsAffected()
+
+	return int(replayed), err
+}
+
+// WebhookDeliveryFilter selects deliveries from a subscription's delivery log, newest first
+type WebhookDeliveryFilter struct {
+	SubscriptionID uuid.UUID
+	// Status is one of the WebhookDeliveryStatus values, empty for any
+	Status string
+	// BeforeID continues the log after the last delivery of a previous page
+	BeforeID int64
+	Limit    int
+}
+
+// GetWebhookDeliveries returns the deliveries selected by filter ordered newest first,
+// fetching one more than the limit so callers can tell if there is a next page
+func GetWebhookDeliveries(ctx context.Context, db *bun.DB, filter WebhookDeliveryFilter) ([]WebhookDelivery, error) {
+	query := db.NewSelect().
+		Model((*WebhookDelivery)(nil)).
+		Where("subscription_id = ?", filter.SubscriptionID)
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit + 1)
+	}
+
+	var deliveries []WebhookDelivery
+	err := query.OrderExpr("id DESC").Scan(ctx, &deliveries)
+
+	return deliveries, err
+}
+
+// UpdateSensorsReportedOnline checks which sensors in service are online at now, saving
+// each sensor whose state changed since it was last checked and passing it to changed in
+// the same transaction. Sensors checked for the first time are saved without being passed
+// on, so existing sensors aren't all announced at once. Sensors another instance of the
+// service is checking are skipped
+func UpdateSensorsReportedOnline(ctx context.Context, db *bun.DB, now time.Time, changed func(ctx context.Context, tx bun.Tx, sensor Sensor) error) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var sensors []Sensor
+		err := tx.NewSelect().
+			Model(&sensors).
+			Where("decommissioned_at IS NULL").
+			Where("last_seen_at IS NOT NULL").
+			Where("reported_online IS DISTINCT FROM (last_seen_at + make_interval(secs => reporting_interval_seconds + offline_grace_seconds) >= ?)", now).
+			OrderExpr("id ASC").
+			For("UPDATE SKIP LOCKED").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, sensor := range sensors {
+			online := sensor.IsOnlineAt(now)
+			firstCheck := sensor.ReportedOnline == nil
+			sensor.ReportedOnline = &online
+
+			_, err = tx.NewUpdate().Model(&sensor).Column("reported_online").WherePK().Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			if !firstCheck {
+				err = changed(ctx, tx, sensor)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+var (
+	ErrorNoZone        = errors.New("no zone found")
+	ErrorDuplicateZone = errors.New("zone name already in use")
+)
+
+// Zone is a field block bounded by a GeoJSON Polygon that sensors are placed in
+type Zone struct {
+	ID        uuid.UUID       `bun:"id,pk,type:uuid,default:gen_random_uuid()"`
+	Name      string          `bun:"name"`
+	CropType  string          `bun:"crop_type,nullzero"`
+	Boundary  json.RawMessage `bun:"boundary,type:jsonb"`
+	CreatedBy string          `bun:"created_by,nullzero"`
+	CreatedAt time.Time       `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time       `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+}
+
+// Save adds the zone, returning ErrorDuplicateZone if its name is taken
+func (z *Zone) Save(ctx context.Context, db *bun.DB) error {
+	_, err := db.NewInsert().Model(z).Returning("*").Exec(ctx)
+	if isUniqueViolation(err) {
+		return ErrorDuplicateZone
+	}
+
+	return err
+}
+
+// Update saves changes to the name, crop type and boundary of the zone, returning
+// ErrorDuplicateZone if its name is taken
+func (z *Zone) Update(ctx context.Context, db *bun.DB) error {
+	z.UpdatedAt = time.Now()
+	result, err := db.NewUpdate().Model(z).Column("name", "crop_type", "boundary", "updated_at").WherePK().Exec(ctx)
+	if isUniqueViolation(err) {
+		return ErrorDuplicateZone
+	}
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrorNoZone
+	}
+
+	return nil
+}
+
+// GetZones returns every zone ordered by name
+func GetZones(ctx context.Context, db *bun.DB) ([]Zone, error) {
+	var zones []Zone
+	err := db.NewSelect().Model(&zones).OrderExpr("name ASC").Scan(ctx)
+
+	return zones, err
+}
+
+// GetZone returns the zone with the given id or ErrorNoZone if there is none
+func GetZone(ctx context.Context, db *bun.DB, id uuid.UUID) (Zone, error) {
+	var zone Zone
+	err := db.NewSelect().Model(&zone).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Zone{}, ErrorNoZone
+		}
+		return Zone{}, err
+	}
+
+	return zone, nil
+}
+
+// DeleteZone deletes a zone, its sensors are left without a zone until they are
+// placed in another one by their coordinates
+func DeleteZone(ctx context.Context, db *bun.DB, id uuid.UUID) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model((*Sensor)(nil)).
+			Set("zone_id = NULL").
+			Set("zone_assigned_manually = false").
+			Where("zone_id = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.NewDelete().Model((*Zone)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return ErrorNoZone
+		}
+
+		return nil
+	})
+}
+
+// GetZoneSensorIDs returns the ids of the sensors in each of the zones, in service
+// unless includeDecommissioned is true, zones without sensors are left out
+func GetZoneSensorIDs(ctx context.Context, db *bun.DB, zoneIDs []uuid.UUID, includeDecommissioned bool) (map[uuid.UUID][]string, error) {
+	zoneSensorIDs := make(map[uuid.UUID][]string)
+	if len(zoneIDs) == 0 {
+		return zoneSensorIDs, nil
+	}
+
+	var sensors []Sensor
+	query := db.NewSelect().
+		Model(&sensors).
+		Column("id", "zone_id").
+		Where("zone_id IN (?)", bun.In(zoneIDs)).
+		OrderExpr("id ASC")
+	if !includeDecommissioned {
+		query = query.Where("decommissioned_at IS NULL")
+	}
+	err := query.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sensor := range sensors {
+		zoneSensorIDs[*sensor.ZoneID] = append(zoneSensorIDs[*sensor.ZoneID], sensor.ID)
+	}
+
+	return zoneSensorIDs, nil
+}
+
+// SetSensorZone assigns a sensor to a zone by hand, or hands its zone back to
+// being worked out from its coordinates when zoneID is nil
+func SetSensorZone(ctx context.Context, db *bun.DB, sensorID string, zoneID *uuid.UUID) error {
+	_, err := db.NewUpdate().
+		Model((*Sensor)(nil)).
+		Set("zone_id = ?", zoneID).
+		Set("zone_assigned_manually = ?", zoneID != nil).
+		Where("id = ?", sensorID).
+		Exec(ctx)
+
+	return err
+}
+
+// GetAutomaticallyZonedSensors returns the sensors in service whose zone is
+// worked out from their coordinates
+func GetAutomaticallyZonedSensors(ctx context.Context, db *bun.DB) ([]Sensor, error) {
+	var sensors []Sensor
+	err := db.NewSelect().
+		Model(&sensors).
+		Where("decommissioned_at IS NULL").
+		Where("NOT zone_assigned_manually").
+		Scan(ctx)
+
+	return sensors, err
+}
+
+// UpdateAutomaticSensorZones moves sensors to the zones their coordinates were found
+// in, nil for none. Sensors assigned by hand in the meantime are left where they are
+func UpdateAutomaticSensorZones(ctx context.Context, db *bun.DB, zones map[string]*uuid.UUID) error {
+	if len(zones) == 0 {
+		return nil
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for sensorID, zoneID := range zones {
+			_, err := tx.NewUpdate().
+				Model((*Sensor)(nil)).
+				Set("zone_id = ?", zoneID).
+				Where("id = ?", sensorID).
+				Where("NOT zone_assigned_manually").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,263 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrorMissingRecipient = errors.New("email has no recipient")
+	// ErrorUnencryptedAuth is a server that needs credentials but doesn't offer STARTTLS,
+	// credentials are only sent over an encrypted connection or to localhost
+	ErrorUnencryptedAuth = errors.New("smtp server does not offer STARTTLS so the credentials can't be sent, unset the username for servers that don't need authentication")
+)
+
+// Config is how to reach the SMTP server emails are relayed through, Username is left
+// empty for servers that don't need authentication such as MailHog
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// From is the address emails are sent from, optionally with a display name
+	From string
+	// Timeout bounds each conversation with the server
+	Timeout time.Duration
+}
+
+// String formats the config for logging without the password
+func (c Config) String() string {
+	return fmt.Sprintf("{Host:%s Port:%d Username:%s Password:%s From:%s Timeout:%s}", c.Host, c.Port, c.Username, redact(c.Password), c.From, c.Timeout)
+}
+
+// GoString formats the config for %#v without the password
+func (c Config) GoString() string {
+	return fmt.Sprintf("email.Config{Host:%q, Port:%d, Username:%q, Password:%q, From:%q, Timeout:%d}", c.Host, c.Port, c.Username, redact(c.Password), c.From, c.Timeout)
+}
+
+// redact hides a secret, leaving whether it was set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return "[redacted]"
+}
+
+// Message is an email with a plain text body and an equivalent html body
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Client sends emails through an SMTP server
+type Client struct {
+	config Config
+	from   *mail.Address
+}
+
+// NewClient returns a client for the SMTP server in config
+func NewClient(config Config) (*Client, error) {
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("error %s parsing from address %q", err, config.From)
+	}
+	if config.Password != "" && config.Username == "" {
+		return nil, errors.New("smtp password is set without a username")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	return &Client{config: config, from: from}, nil
+}
+
+// IsPermanent returns whether an error sending an email is one retrying won't fix, such
+// as the server refusing the recipient
+func IsPermanent(err error) bool {
+	var protocolErr *textproto.Error
+
+	return errors.Is(err, ErrorMissingRecipient) || errors.Is(err, ErrorUnencryptedAuth) || (errors.As(err, &protocolErr) && protocolErr.Code >= 500)
+}
+
+// Check connects and authenticates to the server without sending anything, so a
+// misconfigured server is reported when the config is loaded rather than on the first
+// notification
+func (c *Client) Check(ctx context.Context) error {
+	client, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Quit()
+}
+
+// connect opens a conversation with the server, upgrading it with STARTTLS when the
+// server offers it and authenticating when the config has credentials
+func (c *Client) connect(ctx context.Context) (*smtp.Client, error) {
+	dialer := net.Dialer{Timeout: c.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.config.Host, strconv.Itoa(c.config.Port)))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(c.config.Timeout))
+
+	client, err := smtp.NewClient(conn, c.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: c.config.Host})
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	if c.config.Username != "" {
+		_, encrypted := client.TLSConnectionState()
+		err = checkAuthTransport(c.config.Host, encrypted)
+		if err == nil {
+			err = client.Auth(smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host))
+		}
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// checkAuthTransport returns whether credentials may be sent to host, smtp.PlainAuth
+// refuses to send them unencrypted to anywhere but localhost
+func checkAuthTransport(host string, encrypted bool) error {
+	if encrypted || host == "localhost" || host == "127.0.0.1" || host == "::1" {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrorUnencryptedAuth, host)
+}
+
+// Send sends an email, upgrading the connection with STARTTLS when the server offers it
+func (c *Client) Send(ctx context.Context, message Message) error {
+	to, err := mail.ParseAddress(message.To)
+	if err != nil || message.To == "" {
+		return ErrorMissingRecipient
+	}
+
+	body, err := buildMessage(c.from, to, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = client.Mail(c.from.Address)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(to.Address)
+	if err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(body)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// buildMessage encodes an email sent at now as a multipart/alternative MIME message
+func buildMessage(from *mail.Address, to *mail.Address, message Message, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", message.Text},
+		{"text/html; charset=UTF-8", message.HTML},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+		_, err = encoder.Write([]byte(part.content))
+		if err != nil {
+			return nil, err
+		}
+		err = encoder.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := parts.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var header bytes.Buffer
+	for _, field := range [][2]string{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("UTF-8", message.Subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", parts.Boundary())},
+	} {
+		fmt.Fprintf(&header, "%s: %s\r\n", field[0], field[1])
+	}
+	header.WriteString("\r\n")
+
+	return append(header.Bytes(), body.Bytes()...), nil
+}
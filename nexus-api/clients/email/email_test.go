@@ -0,0 +1,214 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTPServer accepts a single email the way MailHog would and returns what it received
+func fakeSMTPServer(t *testing.T, rcptReply string) (string, int, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	received := make(chan string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch command := strings.ToUpper(strings.Fields(line + " ")[0]); command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL":
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				text.PrintfLine(rcptReply)
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, _ := io.ReadAll(text.DotReader())
+				received <- string(data)
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				return
+			default:
+				text.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+
+	address := listener.Addr().(*net.TCPAddr)
+
+	return address.IP.String(), address.Port, received
+}
+
+func TestUnitTestSendDeliversMultipartEmail(t *testing.T) {
+	// setup test data
+	host, port, received := fakeSMTPServer(t, "250 OK")
+	client, err := NewClient(Config{Host: host, Port: port, From: "Nexus <nexus@farm.example>", Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+
+	// execute test
+	err = client.Send(context.Background(), Message{
+		To:      "alice@farm.example",
+		Subject: "Battery of sensor North 1 is at 15%",
+		Text:    "plain body",
+		HTML:    "<p>html body</p>",
+	})
+
+	// assert results
+	assert.NoError(t, err)
+
+	message, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(<-received)))
+	assert.NoError(t, err)
+	assert.Equal(t, `"Nexus" <nexus@farm.example>`, message.Header.Get("From"))
+	assert.Equal(t, "<alice@farm.example>", message.Header.Get("To"))
+	assert.Equal(t, "Battery of sensor North 1 is at 15%", message.Header.Get("Subject"))
+	assert.Contains(t, message.Header.Get("Content-Type"), "multipart/alternative")
+	body, _ := io.ReadAll(message.Body)
+	assert.Contains(t, string(body), "plain body")
+	assert.Contains(t, string(body), "<p>html body</p>")
+}
+
+func TestUnitTestSendRefusedRecipientIsPermanent(t *testing.T) {
+	// setup test data
+	host, port, _ := fakeSMTPServer(t, "550 no such mailbox")
+	client, err := NewClient(Config{Host: host, Port: port, From: "nexus@farm.example", Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+
+	// execute test
+	err = client.Send(context.Background(), Message{To: "nobody@farm.example", Subject: "hi"})
+
+	// assert results
+	assert.Error(t, err)
+	assert.True(t, IsPermanent(err))
+	assert.True(t, IsPermanent(client.Send(context.Background(), Message{Subject: "no recipient"})))
+	assert.False(t, IsPermanent(io.ErrUnexpectedEOF))
+}
+
+func TestUnitTestRenderEveryTemplate(t *testing.T) {
+	// setup test data
+	renderer, err := NewRenderer()
+	assert.NoError(t, err)
+
+	nairobi := time.FixedZone("EAT", 3*60*60)
+	seen := time.Date(2025, 6, 1, 9, 30, 0, 0, time.UTC)
+
+	// execute test and assert results
+	message, err := renderer.Render("battery_low", map[string]interface{}{
+		"Username":       "alice",
+		"TimeZone":       nairobi,
+		"SensorID":       "2cf7f1c0",
+		"SensorName":     "North <1>",
+		"SensorLocation": "Field 1",
+		"BatteryLevel":   14.6,
+		"Threshold":      20.0,
+		"ReadAt":         seen,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Battery of sensor North <1> is at 15%", message.Subject)
+	assert.Contains(t, message.Text, "below your threshold of 20%")
+	assert.Contains(t, message.Text, "Sun 1 Jun 2025 12:30 EAT")
+	assert.Contains(t, message.HTML, "North &lt;1&gt;")
+
+	message, err = renderer.Render("daily_digest", map[string]interface{}{
+		"Username":         "alice",
+		"TimeZone":         nairobi,
+		"Since":            seen,
+		"SensorsInService": 3,
+		"OnlineSensors":    2,
+		"OfflineSensors":   []map[string]interface{}{{"ID": "2cf7f1c0", "Name": "North 1", "LastSeenAt": &seen}},
+		"LowBatteries":     []map[string]interface{}{{"ID": "2cf7f1c1", "Name": "North 2", "Level": 12.0}},
+		"AlertsFired":      4,
+		"OpenAlerts":       1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Nexus daily digest: 2 of 3 sensors online, 1 open alerts", message.Subject)
+	assert.Contains(t, message.Text, "- North 1 (2cf7f1c0), last seen Sun 1 Jun 2025 12:30 EAT")
+	assert.Contains(t, message.Text, "- North 2 (2cf7f1c1) at 12%")
+	assert.Contains(t, message.HTML, "<li>North 2 (2cf7f1c1) at 12%</li>")
+
+	for name, data := range map[string]map[string]interface{}{
+		"sensor_offline": {"Username": "alice", "SensorID": "2cf7f1c0", "SensorName": "North 1", "LastSeenAt": seen},
+		"test":           {"Username": "alice", "SentAt": seen},
+		"alert_escalated": {"Username": "alice", "AlertID": 42, "RuleName": "Field dry", "SensorID": "2cf7f1c0", "MeasurementType": "soil_moisture",
+			"Operator": "below", "Threshold": 20.0, "FiredValue": 11.0, "FiredAt": seen, "DelayMinutes": 30},
+	} {
+		message, err = renderer.Render(name, data)
+		assert.NoError(t, err, name)
+		assert.NotEmpty(t, message.Subject, name)
+		assert.Contains(t, message.Text, "Hi alice", name)
+		assert.Contains(t, message.HTML, "Hi alice", name)
+	}
+}
+
+func TestUnitTestConfigFormattingRedactsPassword(t *testing.T) {
+	// setup test data
+	config := Config{Host: "smtp.farm.example", Port: 587, Username: "nexus", Password: "hunter2", From: "nexus@farm.example"}
+
+	// execute test
+	formatted := []string{fmt.Sprint(config), fmt.Sprintf("%+v", config), fmt.Sprintf("%#v", config), fmt.Sprintf("%+v", struct{ SMTP Config }{config})}
+
+	// assert results
+	for _, text := range formatted {
+		assert.NotContains(t, text, "hunter2")
+		assert.Contains(t, text, "[redacted]")
+		assert.Contains(t, text, "smtp.farm.example")
+	}
+	assert.NotContains(t, fmt.Sprint(Config{Host: "mailhog"}), "[redacted]", "an unset password is shown as unset")
+}
+
+func TestUnitTestCheckAuthTransport(t *testing.T) {
+	// execute test and assert results
+	assert.NoError(t, checkAuthTransport("smtp.farm.example", true))
+	assert.NoError(t, checkAuthTransport("localhost", false))
+	assert.NoError(t, checkAuthTransport("127.0.0.1", false))
+
+	err := checkAuthTransport("mailhog", false)
+	assert.ErrorIs(t, err, ErrorUnencryptedAuth)
+	assert.Contains(t, err.Error(), "mailhog")
+	assert.True(t, IsPermanent(err))
+}
+
+func TestUnitTestNewClientRefusesPasswordWithoutUsername(t *testing.T) {
+	// execute test
+	_, err := NewClient(Config{Host: "smtp.farm.example", Port: 587, Password: "hunter2", From: "nexus@farm.example"})
+
+	// assert results
+	assert.Error(t, err)
+}
+
+func TestUnitTestCheckConnectsWithoutSending(t *testing.T) {
+	// setup test data
+	host, port, received := fakeSMTPServer(t, "250 OK")
+	client, err := NewClient(Config{Host: host, Port: port, From: "nexus@farm.example", Timeout: 5 * time.Second})
+	assert.NoError(t, err)
+
+	// execute test
+	err = client.Check(context.Background())
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Empty(t, received)
+}
@@ -0,0 +1,75 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// templateFiles holds the templates of each kind of email: <name>.txt.tmpl defines the
+// "<name>.subject" and "<name>.text" templates and <name>.html.tmpl the "<name>.html"
+// template. layout.html.tmpl holds the parts every html body shares
+//
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// templateFuncs are available to every template
+var templateFuncs = map[string]interface{}{
+	// datetime formats a time in the recipient's time zone
+	"datetime": func(t time.Time, location *time.Location) string {
+		if location == nil {
+			location = time.UTC
+		}
+		return t.In(location).Format("Mon 2 Jan 2006 15:04 MST")
+	},
+}
+
+// Renderer renders emails from the embedded templates
+type Renderer struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// NewRenderer parses the embedded templates
+func NewRenderer() (*Renderer, error) {
+	text, err := texttemplate.New("").Funcs(templateFuncs).ParseFS(templateFiles, "templates/*.txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.New("").Funcs(templateFuncs).ParseFS(templateFiles, "templates/*.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Renderer{text: text, html: html}, nil
+}
+
+// Render renders the email called name for data, the caller sets who it is sent to
+func (r *Renderer) Render(name string, data interface{}) (Message, error) {
+	var message Message
+	var subject, text, html bytes.Buffer
+
+	err := r.text.ExecuteTemplate(&subject, name+".subject", data)
+	if err != nil {
+		return message, err
+	}
+	err = r.text.ExecuteTemplate(&text, name+".text", data)
+	if err != nil {
+		return message, err
+	}
+	err = r.html.ExecuteTemplate(&html, name+".html", data)
+	if err != nil {
+		return message, err
+	}
+
+	// subjects are a single line however the template is laid out
+	message.Subject = strings.Join(strings.Fields(subject.String()), " ")
+	message.Text = strings.TrimSpace(text.String()) + "\n"
+	message.HTML = html.String()
+
+	return message, nil
+}
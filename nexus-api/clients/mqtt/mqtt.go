@@ -47,8 +47,17 @@ type MQTTClient struct {
 	// Track subscriptions for automatic resubscription on reconnect
 	subscriptions     map[string]subscriptionInfo
 	subscriptionMutex sync.RWMutex
+
+	// Cache of the measurement registry keyed by MQTT identifier
+	measurementTypes         map[string]api.MeasurementType
+	measurementTypesLoadedAt time.Time
+	measurementTypesMutex    sync.Mutex
 }
 
+// measurementTypesRefreshInterval limits how often an unknown
+// identifier causes the measurement registry to be reloaded
+const measurementTypesRefreshInterval = 5 * time.Minute
+
 // subscriptionInfo tracks subscription details for resubscription
 type subscriptionInfo struct {
 	topic    string
@@ -392,87 +401,100 @@ func (m *MQTTClient) HandleMessage(client mqtt.Client, msg mqtt.Message) {
 		Time("timestamp", ts).
 		Msg("Received sensor reading")
 
-	// Process based on sensor type
-	switch valueIdentifier {
-	case "3000": // Battery Level
-		batteryDetail := api.BatteryLevelData{
-			Date:         ts,
-			BatteryLevel: reading.Value,
-		}
-		sdkPayload := api.SetBatteryLevelDataResponse{
-			BatteryLevelData: []api.BatteryLevelData{batteryDetail},
-		}
-		err := retryWithRefresh(func() error {
-			return m.sdkClient.SetSensorBatteryData(ctx, sensorID, sdkPayload)
-		})
-		if err != nil {
-			m.logger.Error().Err(err).
-				Str("deviceID", deviceID).
-				Str("sensorID", sensorID).
-				Msg("Failed to set battery data")
-			return
-		}
-		m.logger.Info().
-			Str("deviceID", deviceID).
-			Str("sensorID", sensorID).
-			Float64("batteryLevel", reading.Value).
-			Msg("Successfully processed battery data")
-
-	case "4102": // Temperature
-		tempDetail := api.SensorTemperatureData{
-			SensorID:        sensorID, // Use hex ID directly
-			Date:            ts,
-			SoilTemperature: reading.Value,
-		}
-		sdkPayload := api.SetSensorTemperatureDataResponse{
-			SensorTemperatureData: []api.SensorTemperatureData{tempDetail},
-		}
-		err := retryWithRefresh(func() error {
-			return m.sdkClient.SetSensorTemperatureData(ctx, sensorID, sdkPayload)
-		})
-		if err != nil {
-			m.logger.Error().Err(err).
-				Str("deviceID", deviceID).
-				Str("sensorID", sensorID).
-				Msg("Failed to set temperature data")
-			return
-		}
-		m.logger.Info().
-			Str("deviceID", deviceID).
-			Str("sensorID", sensorID).
-			Float64("temperature", reading.Value).
-			Msg("Successfully processed temperature data")
-
-	case "4103": // Moisture
-		moistureDetail := api.SensorMoistureData{
-			SensorID:     sensorID, // Use hex ID directly
-			Date:         ts,
-			SoilMoisture: reading.Value,
-		}
-		sdkPayload := api.SetSensorMoistureDataResponse{
-			SensorMoistureData: []api.SensorMoistureData{moistureDetail},
-		}
+	// Look up which measurement type gateways publish under this identifier
+	measurementType, ok, err := m.lookupMeasurementType(valueIdentifier, func() ([]api.MeasurementType, error) {
+		var measurementTypes []api.MeasurementType
 		err := retryWithRefresh(func() error {
-			return m.sdkClient.SetSensorMoistureData(ctx, sensorID, sdkPayload)
+			var err error
+			measurementTypes, err = m.sdkClient.GetMeasurementTypes(ctx)
+			return err
 		})
-		if err != nil {
-			m.logger.Error().Err(err).
-				Str("deviceID", deviceID).
-				Str("sensorID", sensorID).
-				Msg("Failed to set moisture data")
-			return
-		}
-		m.logger.Info().
-			Str("deviceID", deviceID).
-			Str("sensorID", sensorID).
-			Float64("moisture", reading.Value).
-			Msg("Successfully processed moisture data")
+		return measurementTypes, err
+	})
+	if err != nil {
+		m.logger.Error().Err(err).
+			Str("valueIdentifier", valueIdentifier).
+			Msg("Failed to load measurement types")
+		return
+	}
 
-	default:
+	if !ok {
 		m.logger.Warn().
 			Str("deviceID", deviceID).
 			Str("sensorID", sensorID).
 			Str("valueIdentifier", valueIdentifier).
 			Msg("Received message with unhandled value identifier")
+		return
+	}
+
+	sdkPayload := api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: ts, Value: reading.Value}},
 	}
+	// Readings are saved with on conflict ignore, so a message redelivered by the
+	// broker or retried after a re-login is reported as a duplicate, not stored twice
+	var result api.SetSensorMeasurementsResponse
+	err = retryWithRefresh(func() error {
+		var err error
+		result, err = m.sdkClient.SetSensorMeasurements(ctx, sensorID, measurementType.ID, sdkPayload)
+		return err
+	})
+	if err != nil {
+		m.logger.Error().Err(err).
+			Str("deviceID", deviceID).
+			Str("sensorID", sensorID).
+			Str("measurementType", measurementType.ID).
+			Msg("Failed to set sensor data")
+		return
+	}
+	m.logger.Info().
+		Str("deviceID", deviceID).
+		Str("sensorID", sensorID).
+		Str("measurementType", measurementType.ID).
+		Float64("value", reading.Value).
+		Int("inserted", result.Inserted).
+		Int("duplicates", result.Duplicates).
+		Int("quarantined", result.Quarantined).
+		Int("rejected", result.Rejected).
+		Msg("Successfully processed sensor data")
+}
+
+// lookupMeasurementType returns the registered measurement type published under the MQTT
+// identifier, using load to refresh the cached registry when the identifier is unknown
+// and the cache is older than measurementTypesRefreshInterval
+func (m *MQTTClient) lookupMeasurementType(identifier string, load func() ([]api.MeasurementType, error)) (api.MeasurementType, bool, error) {
+	m.measurementTypesMutex.Lock()
+	defer m.measurementTypesMutex.Unlock()
+
+	if measurementType, ok := m.measurementTypes[identifier]; ok {
+		return measurementType, true, nil
+	}
+
+	if m.measurementTypes != nil && time.Since(m.measurementTypesLoadedAt) < measurementTypesRefreshInterval {
+		return api.MeasurementType{}, false, nil
+	}
+
+	measurementTypes, err := load()
+	if err != nil {
+		return api.MeasurementType{}, false, err
+	}
+
+	m.measurementTypes = measurementTypesByMQTTIdentifier(measurementTypes)
+	m.measurementTypesLoadedAt = time.Now()
+
+	measurementType, ok := m.measurementTypes[identifier]
+
+	return measurementType, ok, nil
+}
+
+// measurementTypesByMQTTIdentifier indexes the measurement types that
+// have an MQTT identifier by that identifier
+func measurementTypesByMQTTIdentifier(measurementTypes []api.MeasurementType) map[string]api.MeasurementType {
+	byIdentifier := make(map[string]api.MeasurementType)
+	for _, measurementType := range measurementTypes {
+		if measurementType.MQTTIdentifier != "" {
+			byIdentifier[measurementType.MQTTIdentifier] = measurementType
+		}
+	}
+
+	return byIdentifier
 }
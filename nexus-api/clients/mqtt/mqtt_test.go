@@ -2,9 +2,11 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"nexus-api/api"
 	"nexus-api/logging"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -65,3 +67,48 @@ func TestNewMQTTClient(t *testing.T) {
 	client.Disconnect()
 	assert.False(t, client.IsConnected())
 }
+
+func TestLookupMeasurementTypeCachesRegistry(t *testing.T) {
+	client := &MQTTClient{}
+	loads := 0
+	load := func() ([]api.MeasurementType, error) {
+		loads++
+		return []api.MeasurementType{
+			{ID: "soil_moisture", MQTTIdentifier: "4103"},
+			{ID: "soil_ph"},
+		}, nil
+	}
+
+	measurementType, ok, err := client.lookupMeasurementType("4103", load)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "soil_moisture", measurementType.ID)
+
+	// known identifiers and recently missed identifiers are answered from the cache
+	_, ok, err = client.lookupMeasurementType("4103", load)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, ok, err = client.lookupMeasurementType("9999", load)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 1, loads)
+
+	// a stale cache is reloaded when an unknown identifier arrives
+	client.measurementTypesLoadedAt = time.Now().Add(-2 * measurementTypesRefreshInterval)
+	_, ok, err = client.lookupMeasurementType("9999", load)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 2, loads)
+}
+
+func TestLookupMeasurementTypeReturnsLoadErrors(t *testing.T) {
+	client := &MQTTClient{}
+
+	_, ok, err := client.lookupMeasurementType("4103", func() ([]api.MeasurementType, error) {
+		return nil, errors.New("registry unavailable")
+	})
+
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
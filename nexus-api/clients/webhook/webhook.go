@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-Nexus-Event"
+	DeliveryHeader  = "X-Nexus-Delivery"
+	TimestampHeader = "X-Nexus-Timestamp"
+	SignatureHeader = "X-Nexus-Signature"
+)
+
+// signatureScheme prefixes signatures so the scheme can change without breaking receivers
+const signatureScheme = "sha256="
+
+// maxResponseLength bounds how much of a receiver's response is kept
+const maxResponseLength = 1024
+
+var (
+	ErrorUnexpectedStatus = errors.New("unexpected response status")
+	ErrorInvalidSignature = errors.New("invalid webhook signature")
+	ErrorExpiredDelivery  = errors.New("webhook delivery signed too long ago")
+	ErrorMissingSignature = errors.New("missing webhook signature headers")
+	ErrorMissingSecret    = errors.New("webhook secret is empty")
+)
+
+// Sign returns the signature of a body sent at timestamp: the hex encoded HMAC-SHA256,
+// keyed with the subscription's secret, of the timestamp in unix seconds, a dot and the body
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signatureScheme + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a delivery received at now, refusing
+// deliveries signed more than tolerance before or after now so a captured delivery
+// can't be sent again later. Receivers written in Go can use it as is
+func Verify(secret string, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	signature, timestamp := header.Get(SignatureHeader), header.Get(TimestampHeader)
+	if signature == "" || timestamp == "" {
+		return ErrorMissingSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrorInvalidSignature
+	}
+	signedAt := time.Unix(seconds, 0)
+	if now.Sub(signedAt) > tolerance || signedAt.Sub(now) > tolerance {
+		return ErrorExpiredDelivery
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, signedAt, body))) {
+		return ErrorInvalidSignature
+	}
+
+	return nil
+}
+
+// Delivery is an event sent to a subscription's endpoint
+type Delivery struct {
+	ID        int64
+	EventType string
+	URL       string
+	Secret    string
+	Body      []byte
+}
+
+// Result is how the receiver of a delivery responded
+type Result struct {
+	StatusCode int
+	// Body is the start of the response, kept to help debug failing receivers
+	Body string
+}
+
+// Client sends deliveries to the endpoints of subscriptions
+type Client struct {
+	httpClient *http.Client
+	userAgent  string
+}
+
+// NewClient returns a client that gives up on a delivery after timeout
+func NewClient(timeout time.Duration) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: timeout},
+		userAgent:  "nexus-webhooks/1",
+	}
+}
+
+// Send posts a delivery signed at now, returning an error if it couldn't be sent or the
+// receiver didn't answer with a 2xx status. Redirects are followed
+func (c *Client) Send(ctx context.Context, delivery Delivery, now time.Time) (Result, error) {
+	var result Result
+	if delivery.Secret == "" {
+		return result, ErrorMissingSecret
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return result, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", c.userAgent)
+	request.Header.Set(EventHeader, delivery.EventType)
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	request.Header.Set(SignatureHeader, Sign(delivery.Secret, now, delivery.Body))
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return result, err
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseLength))
+	result.StatusCode = response.StatusCode
+	result.Body = strings.ToValidUTF8(string(body), "")
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return result, fmt.Errorf("%w %d", ErrorUnexpectedStatus, response.StatusCode)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestSendSignsDeliveriesReceiversCanVerify(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	var received http.Header
+	var verifyErr error
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = r.Header
+		verifyErr = Verify("shh", r.Header, body, now.Add(time.Minute), 5*time.Minute)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("queued"))
+	}))
+	defer receiver.Close()
+
+	delivery := Delivery{
+		ID:        42,
+		EventType: "sensor.created",
+		URL:       receiver.URL,
+		Secret:    "shh",
+		Body:      []byte(`{"type":"sensor.created"}`),
+	}
+
+	// execute test
+	result, err := NewClient(time.Second).Send(context.Background(), delivery, now)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, result.StatusCode)
+	assert.Equal(t, "queued", result.Body)
+	assert.NoError(t, verifyErr)
+	assert.Equal(t, "sensor.created", received.Get(EventHeader))
+	assert.Equal(t, "42", received.Get(DeliveryHeader))
+	assert.Equal(t, "application/json", received.Get("Content-Type"))
+}
+
+func TestUnitTestSendFailsOnErrorResponses(t *testing.T) {
+	// setup test data
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	// execute test
+	result, err := NewClient(time.Second).Send(context.Background(), Delivery{URL: receiver.URL, Secret: "shh"}, time.Now())
+
+	// assert results
+	assert.ErrorIs(t, err, ErrorUnexpectedStatus)
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+}
+
+func TestUnitTestVerifyRefusesTamperedAndStaleDeliveries(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"user.created"}`)
+	header := http.Header{}
+	header.Set(TimestampHeader, "1748779200") // now
+	header.Set(SignatureHeader, Sign("shh", now, body))
+
+	// execute test and assert results
+	assert.NoError(t, Verify("shh", header, body, now, time.Minute))
+	assert.ErrorIs(t, Verify("other", header, body, now, time.Minute), ErrorInvalidSignature)
+	assert.ErrorIs(t, Verify("shh", header, []byte(`{"type":"user.deleted"}`), now, time.Minute), ErrorInvalidSignature)
+	assert.ErrorIs(t, Verify("shh", header, body, now.Add(time.Hour), time.Minute), ErrorExpiredDelivery)
+	assert.ErrorIs(t, Verify("shh", http.Header{}, body, now, time.Minute), ErrorMissingSignature)
+}
@@ -0,0 +1,242 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxPolygonPositions bounds the size of the polygons accepted by ParsePolygon
+const MaxPolygonPositions = 10000
+
+var ErrorInvalidGeoJSON = errors.New("boundary must be a GeoJSON Polygon")
+
+// Point is a position on earth in degrees
+type Point struct {
+	Longitude float64
+	Latitude  float64
+}
+
+// Ring is a closed line of points, its first and last points are the same
+type Ring []Point
+
+// Polygon is the area inside its first ring, less the holes inside the rest
+type Polygon []Ring
+
+// geoJSONGeometry is a GeoJSON Polygon geometry or a Feature holding one
+type geoJSONGeometry struct {
+	Type        string           `json:"type"`
+	Coordinates [][][]float64    `json:"coordinates,omitempty"`
+	Geometry    *geoJSONGeometry `json:"geometry,omitempty"`
+}
+
+// ParsePolygon reads a GeoJSON Polygon geometry, or a Feature whose geometry is a
+// Polygon, returning an error if it is not a valid area on earth. Altitudes are ignored
+func ParsePolygon(data []byte) (Polygon, error) {
+	var geometry geoJSONGeometry
+	err := json.Unmarshal(data, &geometry)
+	if err != nil {
+		return nil, ErrorInvalidGeoJSON
+	}
+
+	if geometry.Type == "Feature" && geometry.Geometry != nil {
+		geometry = *geometry.Geometry
+	}
+	if geometry.Type != "Polygon" || len(geometry.Coordinates) == 0 {
+		return nil, ErrorInvalidGeoJSON
+	}
+
+	polygon := make(Polygon, 0, len(geometry.Coordinates))
+	positions := 0
+	for i, coordinates := range geometry.Coordinates {
+		positions += len(coordinates)
+		if positions > MaxPolygonPositions {
+			return nil, fmt.Errorf("polygon must have at most %d positions", MaxPolygonPositions)
+		}
+
+		ring, err := parseRing(coordinates)
+		if err != nil {
+			return nil, fmt.Errorf("ring %d: %w", i, err)
+		}
+		polygon = append(polygon, ring)
+	}
+
+	if polygon[0].area() == 0 {
+		return nil, fmt.Errorf("polygon must enclose an area")
+	}
+
+	return polygon, nil
+}
+
+// parseRing reads the positions of a GeoJSON linear ring
+func parseRing(coordinates [][]float64) (Ring, error) {
+	if len(coordinates) < 4 {
+		return nil, fmt.Errorf("a ring must have at least 4 positions")
+	}
+
+	ring := make(Ring, 0, len(coordinates))
+	for _, position := range coordinates {
+		if len(position) < 2 || len(position) > 3 {
+			return nil, fmt.Errorf("positions must be [longitude, latitude]")
+		}
+
+		point := Point{Longitude: position[0], Latitude: position[1]}
+		if !(point.Longitude >= -180 && point.Longitude <= 180) {
+			return nil, fmt.Errorf("longitude must be between -180 and 180")
+		}
+		if !(point.Latitude >= -90 && point.Latitude <= 90) {
+			return nil, fmt.Errorf("latitude must be between -90 and 90")
+		}
+		ring = append(ring, point)
+	}
+
+	if ring[0] != ring[len(ring)-1] {
+		return nil, fmt.Errorf("a ring's first and last positions must be the same")
+	}
+
+	return ring, nil
+}
+
+// area returns the planar area enclosed by the ring in square degrees
+func (r Ring) area() float64 {
+	var sum float64
+	for i := 0; i < len(r)-1; i++ {
+		sum += r[i].Longitude*r[i+1].Latitude - r[i+1].Longitude*r[i].Latitude
+	}
+
+	return math.Abs(sum) / 2
+}
+
+// contains returns whether the ring encloses the point, by counting the ring's
+// edges crossed by a line running east from it
+func (r Ring) contains(point Point) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a.Latitude > point.Latitude) == (b.Latitude > point.Latitude) {
+			continue
+		}
+
+		crossing := a.Longitude + (point.Latitude-a.Latitude)*(b.Longitude-a.Longitude)/(b.Latitude-a.Latitude)
+		if point.Longitude < crossing {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// Contains returns whether the point lies inside the polygon and outside its holes
+func (p Polygon) Contains(point Point) bool {
+	if len(p) == 0 || !p[0].contains(point) {
+		return false
+	}
+
+	for _, hole := range p[1:] {
+		if hole.contains(point) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Area returns the planar area of the polygon in square degrees, which is only
+// meaningful for comparing the size of polygons close to each other
+func (p Polygon) Area() float64 {
+	if len(p) == 0 {
+		return 0
+	}
+
+	area := p[0].area()
+	for _, hole := range p[1:] {
+		area -= hole.area()
+	}
+
+	return area
+}
+
+// MarshalJSON encodes the polygon as a GeoJSON Polygon geometry
+func (p Polygon) MarshalJSON() ([]byte, error) {
+	geometry := geoJSONGeometry{
+		Type:        "Polygon",
+		Coordinates: make([][][]float64, 0, len(p)),
+	}
+	for _, ring := range p {
+		coordinates := make([][]float64, 0, len(ring))
+		for _, point := range ring {
+			coordinates = append(coordinates, []float64{point.Longitude, point.Latitude})
+		}
+		geometry.Coordinates = append(geometry.Coordinates, coordinates)
+	}
+
+	return json.Marshal(geometry)
+}
+
+// EarthRadiusMeters is the mean radius of the earth
+const EarthRadiusMeters = 6371008.8
+
+// Distance returns the great circle distance between two points in metres
+func Distance(a Point, b Point) float64 {
+	latitudeA, latitudeB := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	deltaLatitude := latitudeB - latitudeA
+	deltaLongitude := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(latitudeA)*math.Cos(latitudeB)*math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// BoundingBox is the area between two meridians and two parallels in degrees, a box
+// whose west edge is east of its east edge crosses the antimeridian
+type BoundingBox struct {
+	West  float64
+	South float64
+	East  float64
+	North float64
+}
+
+// ParseBoundingBox reads a bounding box written west,south,east,north as in GeoJSON
+func ParseBoundingBox(value string) (BoundingBox, error) {
+	var box BoundingBox
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return box, fmt.Errorf("bounding box must be west,south,east,north")
+	}
+
+	edges := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		edge, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return box, fmt.Errorf("bounding box must be west,south,east,north")
+		}
+		edges = append(edges, edge)
+	}
+	box = BoundingBox{West: edges[0], South: edges[1], East: edges[2], North: edges[3]}
+
+	if !(box.West >= -180 && box.West <= 180 && box.East >= -180 && box.East <= 180) {
+		return box, fmt.Errorf("bounding box longitudes must be between -180 and 180")
+	}
+	if !(box.South >= -90 && box.North <= 90 && box.South <= box.North) {
+		return box, fmt.Errorf("bounding box latitudes must be between -90 and 90, south first")
+	}
+
+	return box, nil
+}
+
+// Contains returns whether the point lies inside the box or on its edges
+func (b BoundingBox) Contains(point Point) bool {
+	if point.Latitude < b.South || point.Latitude > b.North {
+		return false
+	}
+
+	if b.West <= b.East {
+		return point.Longitude >= b.West && point.Longitude <= b.East
+	}
+
+	return point.Longitude >= b.West || point.Longitude <= b.East
+}
@@ -0,0 +1,111 @@
+package geo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testField is a 2x2 degree square with a 1x1 degree hole in its middle
+const testField = `{"type": "Polygon", "coordinates": [
+	[[10, 50], [12, 50], [12, 52], [10, 52], [10, 50]],
+	[[10.5, 50.5], [11.5, 50.5], [11.5, 51.5], [10.5, 51.5], [10.5, 50.5]]
+]}`
+
+func TestUnitTestParsePolygonAcceptsPolygonsAndFeatures(t *testing.T) {
+	// execute test
+	polygon, err := ParsePolygon([]byte(testField))
+	assert.NoError(t, err)
+
+	feature, err := ParsePolygon([]byte(`{"type": "Feature", "properties": {"name": "north block"}, "geometry": ` + testField + `}`))
+	assert.NoError(t, err)
+
+	// assert results
+	assert.Len(t, polygon, 2)
+	assert.Equal(t, polygon, feature)
+	assert.Equal(t, Point{Longitude: 12, Latitude: 50}, polygon[0][1])
+	assert.InDelta(t, 3, polygon.Area(), 1e-9, "4 square degrees less the 1 square degree hole")
+}
+
+func TestUnitTestParsePolygonRejectsInvalidPolygons(t *testing.T) {
+	for name, geometry := range map[string]string{
+		"not json":                       `{"type": "Polygon"`,
+		"point":                          `{"type": "Point", "coordinates": [10, 50]}`,
+		"no rings":                       `{"type": "Polygon", "coordinates": []}`,
+		"too few positions":              `{"type": "Polygon", "coordinates": [[[10, 50], [12, 50], [10, 50]]]}`,
+		"open ring":                      `{"type": "Polygon", "coordinates": [[[10, 50], [12, 50], [12, 52], [10, 52]]]}`,
+		"latitude and longitude swapped": `{"type": "Polygon", "coordinates": [[[50, 100], [52, 100], [52, 102], [50, 100]]]}`,
+		"missing latitude":               `{"type": "Polygon", "coordinates": [[[10], [12, 50], [12, 52], [10]]]}`,
+		"no area":                        `{"type": "Polygon", "coordinates": [[[10, 50], [11, 50], [12, 50], [10, 50]]]}`,
+		"feature of a point":             `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [10, 50]}}`,
+	} {
+		_, err := ParsePolygon([]byte(geometry))
+
+		assert.Error(t, err, "expected error for %s", name)
+	}
+}
+
+func TestUnitTestPolygonContains(t *testing.T) {
+	// setup test data
+	polygon, err := ParsePolygon([]byte(testField))
+	assert.NoError(t, err)
+
+	// execute test & assert results
+	assert.True(t, polygon.Contains(Point{Longitude: 10.25, Latitude: 51}), "inside the field, west of the hole")
+	assert.True(t, polygon.Contains(Point{Longitude: 11.75, Latitude: 50.25}), "inside the field, south east of the hole")
+	assert.False(t, polygon.Contains(Point{Longitude: 11, Latitude: 51}), "inside the hole")
+	assert.False(t, polygon.Contains(Point{Longitude: 13, Latitude: 51}), "east of the field")
+	assert.False(t, polygon.Contains(Point{Longitude: 11, Latitude: 49}), "south of the field")
+	assert.False(t, Polygon{}.Contains(Point{Longitude: 11, Latitude: 51}))
+}
+
+func TestUnitTestPolygonMarshalsToGeoJSON(t *testing.T) {
+	// setup test data
+	polygon, err := ParsePolygon([]byte(testField))
+	assert.NoError(t, err)
+
+	// execute test
+	data, err := json.Marshal(polygon)
+	assert.NoError(t, err)
+
+	// assert results
+	assert.JSONEq(t, testField, string(data))
+
+	parsed, err := ParsePolygon(data)
+	assert.NoError(t, err)
+	assert.Equal(t, polygon, parsed)
+}
+
+func TestUnitTestDistance(t *testing.T) {
+	london := Point{Longitude: -0.1276, Latitude: 51.5072}
+	paris := Point{Longitude: 2.3522, Latitude: 48.8566}
+
+	assert.InDelta(t, 343500, Distance(london, paris), 1000)
+	assert.InDelta(t, Distance(london, paris), Distance(paris, london), 1e-6)
+	assert.Equal(t, 0.0, Distance(paris, paris))
+	assert.InDelta(t, 111195, Distance(Point{Longitude: 179.5, Latitude: 0}, Point{Longitude: -179.5, Latitude: 0}), 10, "across the antimeridian")
+}
+
+func TestUnitTestBoundingBox(t *testing.T) {
+	// setup test data
+	box, err := ParseBoundingBox("10, 50, 12, 52")
+	assert.NoError(t, err)
+	wrapping, err := ParseBoundingBox("179,-20,-179,-10")
+	assert.NoError(t, err)
+
+	// execute test & assert results
+	assert.True(t, box.Contains(Point{Longitude: 11, Latitude: 51}))
+	assert.True(t, box.Contains(Point{Longitude: 12, Latitude: 50}), "edges are inside")
+	assert.False(t, box.Contains(Point{Longitude: 13, Latitude: 51}))
+	assert.False(t, box.Contains(Point{Longitude: 11, Latitude: 49}))
+	assert.True(t, wrapping.Contains(Point{Longitude: 179.5, Latitude: -15}))
+	assert.True(t, wrapping.Contains(Point{Longitude: -179.5, Latitude: -15}))
+	assert.False(t, wrapping.Contains(Point{Longitude: 0, Latitude: -15}))
+
+	for _, value := range []string{"", "10,50,12", "10,50,12,north", "10,52,12,50", "10,50,200,52", "10,-95,12,52"} {
+		_, err := ParseBoundingBox(value)
+
+		assert.Error(t, err, "expected error for %q", value)
+	}
+}
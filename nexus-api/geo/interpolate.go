@@ -0,0 +1,177 @@
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrorNoSamples = errors.New("no samples to interpolate")
+
+// Sample is a value measured at a point
+type Sample struct {
+	Point
+	Value float64
+}
+
+// Interpolator fits a surface through samples, so methods that need to solve for the
+// whole set of samples, such as kriging, only do so once per surface
+type Interpolator interface {
+	Fit(samples []Sample) (Surface, error)
+}
+
+// Surface estimates values between the samples it was fitted to
+type Surface interface {
+	// At returns the estimated value at the point, false where there is no estimate
+	At(point Point) (float64, bool)
+}
+
+// InverseDistanceWeighting estimates values as the mean of the samples weighted by the
+// inverse of their distance raised to Power. Samples further than MaxDistanceMeters
+// away are ignored unless it is zero, points with no sample in range have no estimate
+type InverseDistanceWeighting struct {
+	Power             float64
+	MaxDistanceMeters float64
+}
+
+// Fit returns the surface through the samples
+func (idw InverseDistanceWeighting) Fit(samples []Sample) (Surface, error) {
+	if len(samples) == 0 {
+		return nil, ErrorNoSamples
+	}
+
+	return idwSurface{idw: idw, samples: samples}, nil
+}
+
+type idwSurface struct {
+	idw     InverseDistanceWeighting
+	samples []Sample
+}
+
+// At returns the weighted mean of the samples in range of the point, or the value of a
+// sample at the point itself
+func (s idwSurface) At(point Point) (float64, bool) {
+	var weightedSum, weights float64
+	for _, sample := range s.samples {
+		distance := Distance(point, sample.Point)
+		if distance < 1e-6 {
+			return sample.Value, true
+		}
+		if s.idw.MaxDistanceMeters > 0 && distance > s.idw.MaxDistanceMeters {
+			continue
+		}
+
+		weight := 1 / math.Pow(distance, s.idw.Power)
+		weightedSum += weight * sample.Value
+		weights += weight
+	}
+
+	if weights == 0 {
+		return math.NaN(), false
+	}
+
+	return weightedSum / weights, true
+}
+
+// Grid is a raster of equally sized cells covering a bounding box, row 0 is the
+// northernmost row and column 0 the westernmost column
+type Grid struct {
+	BoundingBox BoundingBox
+	Columns     int
+	Rows        int
+	// Values of the cells row by row, NaN where there is no estimate
+	Values []float64
+}
+
+// CellCenter returns the point at the center of a cell
+func (g Grid) CellCenter(column int, row int) Point {
+	return Point{
+		Longitude: g.BoundingBox.West + (float64(column)+0.5)*(g.BoundingBox.East-g.BoundingBox.West)/float64(g.Columns),
+		Latitude:  g.BoundingBox.North - (float64(row)+0.5)*(g.BoundingBox.North-g.BoundingBox.South)/float64(g.Rows),
+	}
+}
+
+// Value returns the value of a cell
+func (g Grid) Value(column int, row int) float64 {
+	return g.Values[row*g.Columns+column]
+}
+
+// SampleGrid estimates the value at the center of each cell of a grid of columns by rows
+// covering box, which must not cross the antimeridian. Cells whose center mask rejects
+// are left without a value, a nil mask keeps every cell
+func SampleGrid(surface Surface, box BoundingBox, columns int, rows int, mask func(Point) bool) Grid {
+	grid := Grid{
+		BoundingBox: box,
+		Columns:     columns,
+		Rows:        rows,
+		Values:      make([]float64, columns*rows),
+	}
+
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			center := grid.CellCenter(column, row)
+			value := math.NaN()
+			if mask == nil || mask(center) {
+				if estimate, ok := surface.At(center); ok {
+					value = estimate
+				}
+			}
+			grid.Values[row*columns+column] = value
+		}
+	}
+
+	return grid
+}
+
+// metersPerDegreeLatitude is the length of a degree of latitude
+const metersPerDegreeLatitude = EarthRadiusMeters * math.Pi / 180
+
+// Size returns the width, measured along the box's middle parallel, and height of
+// the box in metres
+func (b BoundingBox) Size() (float64, float64) {
+	middle := (b.North + b.South) / 2 * math.Pi / 180
+	east := b.East
+	if east < b.West {
+		east += 360
+	}
+
+	return (east - b.West) * metersPerDegreeLatitude * math.Cos(middle), (b.North - b.South) * metersPerDegreeLatitude
+}
+
+// Expand returns the box grown by at least meters on every side, capped at the poles
+// and the antimeridian
+func (b BoundingBox) Expand(meters float64) BoundingBox {
+	latitudeMargin := meters / metersPerDegreeLatitude
+	expanded := BoundingBox{
+		South: math.Max(-90, b.South-latitudeMargin),
+		North: math.Min(90, b.North+latitudeMargin),
+	}
+
+	// a degree of longitude is shortest at the parallel furthest from the equator
+	widest := math.Max(math.Abs(expanded.South), math.Abs(expanded.North)) * math.Pi / 180
+	if math.Cos(widest) < 1e-6 {
+		expanded.West, expanded.East = -180, 180
+		return expanded
+	}
+	longitudeMargin := latitudeMargin / math.Cos(widest)
+	expanded.West = math.Max(-180, b.West-longitudeMargin)
+	expanded.East = math.Min(180, b.East+longitudeMargin)
+
+	return expanded
+}
+
+// Bounds returns the smallest bounding box holding the polygon
+func (p Polygon) Bounds() BoundingBox {
+	box := BoundingBox{West: 180, South: 90, East: -180, North: -90}
+	if len(p) == 0 {
+		return BoundingBox{}
+	}
+
+	for _, point := range p[0] {
+		box.West = math.Min(box.West, point.Longitude)
+		box.East = math.Max(box.East, point.Longitude)
+		box.South = math.Min(box.South, point.Latitude)
+		box.North = math.Max(box.North, point.Latitude)
+	}
+
+	return box
+}
@@ -0,0 +1,82 @@
+package geo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestInverseDistanceWeighting(t *testing.T) {
+	// setup test data
+	west := Sample{Point: Point{Longitude: 10, Latitude: 50}, Value: 10}
+	east := Sample{Point: Point{Longitude: 10.002, Latitude: 50}, Value: 30}
+	surface, err := InverseDistanceWeighting{Power: 2, MaxDistanceMeters: 1000}.Fit([]Sample{west, east})
+	assert.NoError(t, err)
+
+	// execute test & assert results
+	value, ok := surface.At(west.Point)
+	assert.True(t, ok)
+	assert.Equal(t, 10.0, value, "points on a sample take its value")
+
+	value, ok = surface.At(Point{Longitude: 10.001, Latitude: 50})
+	assert.True(t, ok)
+	assert.InDelta(t, 20, value, 1e-6, "halfway between the samples")
+
+	value, ok = surface.At(Point{Longitude: 10.0005, Latitude: 50})
+	assert.True(t, ok)
+	assert.InDelta(t, 12, value, 1e-6, "a quarter of the way weighs the nearer sample 9 times as much")
+
+	_, ok = surface.At(Point{Longitude: 11, Latitude: 50})
+	assert.False(t, ok, "no sample in range")
+
+	_, err = InverseDistanceWeighting{Power: 2}.Fit(nil)
+	assert.ErrorIs(t, err, ErrorNoSamples)
+}
+
+func TestUnitTestSampleGrid(t *testing.T) {
+	// setup test data
+	box := BoundingBox{West: 10, South: 50, East: 10.004, North: 50.002}
+	surface, err := InverseDistanceWeighting{Power: 2}.Fit([]Sample{
+		{Point: Point{Longitude: 10.0005, Latitude: 50.0015}, Value: 10},
+		{Point: Point{Longitude: 10.0035, Latitude: 50.0005}, Value: 30},
+	})
+	assert.NoError(t, err)
+	westHalf := func(point Point) bool { return point.Longitude < 10.002 }
+
+	// execute test
+	grid := SampleGrid(surface, box, 4, 2, westHalf)
+
+	// assert results
+	assert.Len(t, grid.Values, 8)
+	assert.Equal(t, Point{Longitude: 10.0005, Latitude: 50.0015}, grid.CellCenter(0, 0), "row 0 is the north")
+	assert.Equal(t, 10.0, grid.Value(0, 0))
+	assert.True(t, grid.Value(1, 1) > 10 && grid.Value(1, 1) < 30)
+	assert.True(t, math.IsNaN(grid.Value(3, 1)), "cells outside the mask have no value")
+	assert.True(t, math.IsNaN(grid.Value(2, 0)))
+}
+
+func TestUnitTestBoundingBoxSizeAndExpand(t *testing.T) {
+	box := BoundingBox{West: 10, South: -0.01, East: 10.01, North: 0.01}
+
+	width, height := box.Size()
+	assert.InDelta(t, 1112, width, 1)
+	assert.InDelta(t, 2224, height, 1)
+
+	expanded := box.Expand(1112)
+	assert.InDelta(t, -0.02, expanded.South, 1e-4)
+	assert.InDelta(t, 0.02, expanded.North, 1e-4)
+	assert.InDelta(t, 9.99, expanded.West, 1e-4)
+
+	polar := BoundingBox{West: 0, South: 89.5, East: 1, North: 90}.Expand(60000)
+	assert.Equal(t, 90.0, polar.North, "capped at the pole")
+	assert.Equal(t, -180.0, polar.West, "every meridian meets at the pole")
+	assert.Equal(t, 180.0, polar.East)
+}
+
+func TestUnitTestPolygonBounds(t *testing.T) {
+	polygon, err := ParsePolygon([]byte(testField))
+	assert.NoError(t, err)
+
+	assert.Equal(t, BoundingBox{West: 10, South: 50, East: 12, North: 52}, polygon.Bounds())
+}
@@ -11,6 +11,8 @@ import (
 
 	"nexus-api/clients/database"
 
+	"nexus-api/clients/email"
+
 	mqttclient "nexus-api/clients/mqtt"
 
 	"nexus-api/logging"
@@ -21,6 +23,8 @@ import (
 
 	"os"
 
+	"strconv"
+
 	"strings"
 
 	"sync"
@@ -69,6 +73,51 @@ func main() {
 
 	serviceLogger.Trace().Msgf("loaded databaseClient config %+v", databaseConfig)
 
+	// how long critical alerts can go unacknowledged before they escalate, the service
+	// default when unset
+
+	var alertEscalationDelay time.Duration
+
+	if value := os.Getenv("ALERT_ESCALATION_DELAY"); value != "" {
+
+		alertEscalationDelay, err = time.ParseDuration(value)
+
+		if err != nil {
+
+			panic(fmt.Errorf("error %s parsing ALERT_ESCALATION_DELAY %s", err, value))
+
+		}
+
+	}
+
+	// parse smtp configuration from the environment, notification emails are only sent
+	// when SMTP_HOST is set
+
+	smtpConfig := email.Config{
+
+		Host: os.Getenv("SMTP_HOST"),
+
+		Port: 587,
+
+		Username: os.Getenv("SMTP_USERNAME"),
+
+		Password: os.Getenv("SMTP_PASSWORD"),
+
+		From: os.Getenv("SMTP_FROM"),
+	}
+
+	if value := os.Getenv("SMTP_PORT"); value != "" {
+
+		smtpConfig.Port, err = strconv.Atoi(value)
+
+		if err != nil {
+
+			panic(fmt.Errorf("error %s parsing SMTP_PORT %s", err, value))
+
+		}
+
+	}
+
 	// --- Initialize API Service (runs migrations synchronously) ---
 
 	apiConfig := service.APIConfig{
@@ -78,6 +127,10 @@ func main() {
 		DatabaseConfig: databaseConfig,
 
 		ServiceLogger: &serviceLogger,
+
+		AlertEscalationDelay: alertEscalationDelay,
+
+		SMTP: smtpConfig,
 	}
 
 	serviceLogger.Debug().Msgf("loaded api config %+v", apiConfig)
@@ -1,19 +1,32 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
 	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"nexus-api/api"
 	"nexus-api/clients/database"
+	"nexus-api/clients/webhook"
 	"nexus-api/logging"
 	"nexus-api/password"
 	"nexus-api/sdk"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
 )
 
 var (
@@ -355,16 +368,16 @@ func TestE2ESetAndGetSensorMoistureData(t *testing.T) {
 
 	// Test payload for setting Moisture data
 	expectedMoistureData := api.SetSensorMoistureDataResponse{SensorMoistureData: []api.SensorMoistureData{
-		{Date: time.Now().Add(1 * time.Second).UTC(), SoilMoisture: 100, SensorID: sensorID},
-		{Date: time.Now().Add(2 * time.Second).UTC(), SoilMoisture: 150, SensorID: sensorID},
+		{Date: time.Now().Add(1 * time.Second).UTC(), SoilMoisture: 45, SensorID: sensorID},
+		{Date: time.Now().Add(2 * time.Second).UTC(), SoilMoisture: 46.5, SensorID: sensorID},
 	}}
 
 	// Step 1: POST (Set) moisture data
-	err = testClient.SetSensorMoistureData(testCtx, sensorID, expectedMoistureData)
+	_, err = testClient.SetSensorMoistureData(testCtx, sensorID, expectedMoistureData)
 	assert.NoError(t, err, "Setting yield data should succeed")
 
 	// change to get sensor moisture data
-	gotMoistureData, err := testClient.GetSensorMoistureData(testCtx, sensorID)
+	gotMoistureData, err := testClient.GetSensorMoistureData(testCtx, sensorID, api.SensorDataQuery{})
 	assert.NoError(t, err, "Retrieving yield data should succeed")
 
 	// Step 3: compare to moisture data - ignoring ID field which is auto-generated
@@ -427,16 +440,16 @@ func TestE2ESetAndGetSensorTemperatureData(t *testing.T) {
 
 	// Test payload for setting Temperature data
 	expectedTemperatureData := api.SetSensorTemperatureDataResponse{SensorTemperatureData: []api.SensorTemperatureData{
-		{Date: time.Now().Add(1 * time.Second).UTC(), SoilTemperature: 100, SensorID: sensorID},
-		{Date: time.Now().Add(2 * time.Second).UTC(), SoilTemperature: 150, SensorID: sensorID},
+		{Date: time.Now().Add(-20 * time.Minute).UTC(), SoilTemperature: 21.5, SensorID: sensorID},
+		{Date: time.Now().Add(-10 * time.Minute).UTC(), SoilTemperature: 22, SensorID: sensorID},
 	}}
 
 	// Step 1: POST (Set) temperature data
-	err = testClient.SetSensorTemperatureData(testCtx, sensorID, expectedTemperatureData)
+	_, err = testClient.SetSensorTemperatureData(testCtx, sensorID, expectedTemperatureData)
 	assert.NoError(t, err, "Setting Temperature data should succeed")
 
 	// change to get sensor temperature data
-	gotTemperatureData, err := testClient.GetSensorTemperatureData(testCtx, sensorID)
+	gotTemperatureData, err := testClient.GetSensorTemperatureData(testCtx, sensorID, api.SensorDataQuery{})
 	assert.NoError(t, err, "Retrieving temperature data should succeed")
 
 	// Step 3: compare to temperature data - ignoring ID field which is auto-generated
@@ -453,6 +466,2095 @@ func TestE2ESetAndGetSensorTemperatureData(t *testing.T) {
 	}
 }
 
+func TestE2EGetSensorMoistureDataWithTimeRangeAndPagination(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	testSensor := database.Sensor{
+		ID:               sensorID,
+		Name:             "Test Paged Sensor",
+		Location:         "Test Location",
+		InstallationDate: time.Now(),
+	}
+	err = testSensor.Save(testCtx, databaseClient.DB)
+	assert.NoError(t, err)
+
+	baseTime := time.Now().Add(-10 * time.Hour).UTC().Truncate(time.Second)
+	var readings []api.SensorMoistureData
+	for i := 0; i < 5; i++ {
+		readings = append(readings, api.SensorMoistureData{
+			Date:         baseTime.Add(time.Duration(i) * time.Hour),
+			SoilMoisture: float64(10 + i),
+			SensorID:     sensorID,
+		})
+	}
+
+	_, err = testClient.SetSensorMoistureData(testCtx, sensorID, api.SetSensorMoistureDataResponse{SensorMoistureData: readings})
+	assert.NoError(t, err)
+
+	// Step 1: page through all readings two at a time
+	var paged []api.SensorMoistureData
+	query := api.SensorDataQuery{Limit: 2}
+	for pages := 0; pages < 5; pages++ {
+		page, err := testClient.GetSensorMoistureData(testCtx, sensorID, query)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page.SensorMoistureData), 2, "page should not exceed limit")
+		paged = append(paged, page.SensorMoistureData...)
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	assert.Equal(t, len(readings), len(paged), "paging should return every reading exactly once")
+	for i, expected := range readings {
+		assert.Equal(t, expected.SoilMoisture, paged[i].SoilMoisture, "readings should be in ascending date order")
+	}
+
+	// Step 2: restrict to a time range in descending order
+	ranged, err := testClient.GetSensorMoistureData(testCtx, sensorID, api.SensorDataQuery{
+		Start: baseTime.Add(1 * time.Hour),
+		End:   baseTime.Add(3 * time.Hour),
+		Order: "desc",
+	})
+	assert.NoError(t, err)
+	if assert.Equal(t, 3, len(ranged.SensorMoistureData)) {
+		assert.Equal(t, float64(13), ranged.SensorMoistureData[0].SoilMoisture)
+		assert.Equal(t, float64(11), ranged.SensorMoistureData[2].SoilMoisture)
+	}
+	assert.Empty(t, ranged.NextCursor, "there should be no next page without a limit")
+
+	// online status should reflect the newest reading even when it is outside the page
+	assert.NotNil(t, ranged.LastDataTimestamp)
+	if ranged.LastDataTimestamp != nil {
+		assert.True(t, ranged.LastDataTimestamp.Equal(readings[4].Date), "last data timestamp should be the newest reading")
+	}
+}
+
+func TestE2EGetSensorTemperatureDataBuckets(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	testSensor := database.Sensor{
+		ID:               sensorID,
+		Name:             "Test Bucketed Sensor",
+		Location:         "Test Location",
+		InstallationDate: time.Now(),
+	}
+	err = testSensor.Save(testCtx, databaseClient.DB)
+	assert.NoError(t, err)
+
+	// two readings in each of two consecutive days
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour).Add(-48 * time.Hour)
+	readings := []api.SensorTemperatureData{
+		{SensorID: sensorID, Date: dayStart.Add(1 * time.Hour), SoilTemperature: 10},
+		{SensorID: sensorID, Date: dayStart.Add(2 * time.Hour), SoilTemperature: 20},
+		{SensorID: sensorID, Date: dayStart.Add(25 * time.Hour), SoilTemperature: 5},
+		{SensorID: sensorID, Date: dayStart.Add(26 * time.Hour), SoilTemperature: 7},
+	}
+
+	_, err = testClient.SetSensorTemperatureData(testCtx, sensorID, api.SetSensorTemperatureDataResponse{SensorTemperatureData: readings})
+	assert.NoError(t, err)
+
+	// Step 1: request daily buckets
+	response, err := testClient.GetSensorTemperatureDataBuckets(testCtx, sensorID, "daily", api.SensorDataQuery{})
+
+	// Step 2: assert each day is summarised
+	assert.NoError(t, err)
+	assert.Equal(t, "daily", response.Resolution)
+	if assert.Equal(t, 2, len(response.Buckets)) {
+		assert.True(t, response.Buckets[0].BucketStart.Equal(dayStart))
+		assert.Equal(t, float64(10), response.Buckets[0].Min)
+		assert.Equal(t, float64(20), response.Buckets[0].Max)
+		assert.Equal(t, float64(15), response.Buckets[0].Avg)
+		assert.Equal(t, 2, response.Buckets[0].Count)
+
+		assert.True(t, response.Buckets[1].BucketStart.Equal(dayStart.Add(24*time.Hour)))
+		assert.Equal(t, float64(6), response.Buckets[1].Avg)
+	}
+
+	// Step 3: the date range narrows the buckets
+	response, err = testClient.GetSensorTemperatureDataBuckets(testCtx, sensorID, "hourly", api.SensorDataQuery{
+		Start: dayStart.Add(24 * time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(response.Buckets))
+
+	// Step 4: unknown resolutions are rejected
+	_, err = testClient.GetSensorTemperatureDataBuckets(testCtx, sensorID, "yearly", api.SensorDataQuery{})
+	assert.Error(t, err)
+}
+
+func TestE2EMeasurementRegistryAndGenericMeasurements(t *testing.T) {
+	// Step 0: prepare test data
+	adminClient, adminUsername := createTestAdminUser(t)
+	defer cleanupTestUser(t, adminUsername)
+
+	userClient, userUsername := createTestRegularUser(t)
+	defer cleanupTestUser(t, userUsername)
+
+	for client, username := range map[*sdk.NexusClient]string{adminClient: adminUsername, userClient: userUsername} {
+		_, err := client.Login(testCtx, api.LoginRequest{
+			Username: username,
+			Password: "password123",
+		})
+		assert.NoError(t, err)
+	}
+
+	minValue, maxValue := float64(0), float64(2000)
+	measurementTypeID := "test_" + uuid.NewString()[:8]
+	mqttIdentifier := uuid.NewString()[:8]
+
+	// Step 1: regular users can not change the registry, admins can
+	_, err := userClient.CreateMeasurementType(testCtx, api.MeasurementType{ID: measurementTypeID, DisplayName: "Test CO2", Unit: "ppm"})
+	assert.Error(t, err)
+
+	created, err := adminClient.CreateMeasurementType(testCtx, api.MeasurementType{
+		ID:             measurementTypeID,
+		DisplayName:    "Test CO2",
+		Unit:           "ppm",
+		MinValue:       &minValue,
+		MaxValue:       &maxValue,
+		MQTTIdentifier: mqttIdentifier,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, measurementTypeID, created.ID)
+
+	_, err = adminClient.CreateMeasurementType(testCtx, api.MeasurementType{ID: measurementTypeID, DisplayName: "Duplicate", Unit: "ppm"})
+	assert.Error(t, err, "duplicate ids should be rejected")
+
+	displayName := "Test Carbon Dioxide"
+	updated, err := adminClient.UpdateMeasurementType(testCtx, measurementTypeID, api.UpdateMeasurementTypeRequest{DisplayName: &displayName})
+	assert.NoError(t, err)
+	assert.Equal(t, displayName, updated.DisplayName)
+	assert.Equal(t, mqttIdentifier, updated.MQTTIdentifier, "unset fields should be left unchanged")
+	assert.Equal(t, minValue, *updated.MinValue)
+
+	updated, err = adminClient.UpdateMeasurementType(testCtx, measurementTypeID, api.UpdateMeasurementTypeRequest{ClearMinValue: true})
+	assert.NoError(t, err)
+	assert.Nil(t, updated.MinValue, "cleared bounds should be removed")
+	assert.Equal(t, maxValue, *updated.MaxValue)
+
+	measurementTypes, err := userClient.GetMeasurementTypes(testCtx)
+	assert.NoError(t, err)
+	registered := make(map[string]api.MeasurementType)
+	for _, measurementType := range measurementTypes {
+		registered[measurementType.ID] = measurementType
+	}
+	assert.Contains(t, registered, measurementTypeID)
+	assert.Equal(t, "4103", registered["soil_moisture"].MQTTIdentifier)
+
+	// Step 2: readings of the new type can be stored and read back
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	baseTime := time.Now().UTC().Truncate(time.Second)
+	_, err = userClient.SetSensorMeasurements(testCtx, sensorID, measurementTypeID, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{
+			{Date: baseTime.Add(-2 * time.Minute), Value: 410},
+			{Date: baseTime.Add(-1 * time.Minute), Value: 420},
+		},
+	})
+	assert.NoError(t, err)
+
+	gotMeasurements, err := userClient.GetSensorMeasurements(testCtx, sensorID, measurementTypeID, api.SensorDataQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, "ppm", gotMeasurements.Unit)
+	if assert.Equal(t, 2, len(gotMeasurements.Measurements)) {
+		assert.Equal(t, float64(410), gotMeasurements.Measurements[0].Value)
+		assert.Equal(t, float64(420), gotMeasurements.Measurements[1].Value)
+	}
+
+	// Step 3: unknown measurement types are rejected
+	_, err = userClient.GetSensorMeasurements(testCtx, sensorID, "not_a_type", api.SensorDataQuery{})
+	assert.Error(t, err)
+
+	// Step 4: legacy routes read and write the same storage
+	_, err = userClient.SetSensorMoistureData(testCtx, sensorID, api.SetSensorMoistureDataResponse{
+		SensorMoistureData: []api.SensorMoistureData{{Date: baseTime, SoilMoisture: 33}},
+	})
+	assert.NoError(t, err)
+
+	gotMoisture, err := userClient.GetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(gotMoisture.Measurements)) {
+		assert.Equal(t, float64(33), gotMoisture.Measurements[0].Value)
+	}
+}
+
+func TestE2ERetentionRollsUpRawReadings(t *testing.T) {
+	// Step 0: prepare test data
+	adminClient, adminUsername := createTestAdminUser(t)
+	defer cleanupTestUser(t, adminUsername)
+
+	_, err := adminClient.Login(testCtx, api.LoginRequest{
+		Username: adminUsername,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	measurementTypeID := "test_" + uuid.NewString()[:8]
+	_, err = adminClient.CreateMeasurementType(testCtx, api.MeasurementType{
+		ID:          measurementTypeID,
+		DisplayName: "Test Leaf Wetness",
+		Unit:        "%",
+	})
+	assert.NoError(t, err)
+
+	// Step 1: policies that would delete rollups before what they summarise are rejected
+	rawDays, hourlyDays := 30, 7
+	_, err = adminClient.UpdateMeasurementType(testCtx, measurementTypeID, api.UpdateMeasurementTypeRequest{
+		RawRetentionDays:    &rawDays,
+		HourlyRetentionDays: &hourlyDays,
+	})
+	assert.Error(t, err)
+
+	updated, err := adminClient.UpdateMeasurementType(testCtx, measurementTypeID, api.UpdateMeasurementTypeRequest{RawRetentionDays: &rawDays})
+	assert.NoError(t, err)
+	assert.Equal(t, 30, updated.RawRetentionDays)
+	assert.Equal(t, 0, updated.HourlyRetentionDays)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	oldHour := time.Now().UTC().AddDate(0, 0, -40).Truncate(time.Hour)
+	recent := time.Now().UTC().Add(-5 * time.Minute).Truncate(time.Second)
+	_, err = adminClient.SetSensorMeasurements(testCtx, sensorID, measurementTypeID, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{
+			{Date: oldHour.Add(10 * time.Minute), Value: 10},
+			{Date: oldHour.Add(20 * time.Minute), Value: 20},
+			{Date: recent, Value: 50},
+		},
+	})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	// Step 2: apply the policy, rolling up and deleting the old raw readings
+	measurementType, err := database.GetMeasurementType(testCtx, databaseClient.DB, measurementTypeID)
+	assert.NoError(t, err)
+
+	result, err := database.ApplyRetentionPolicy(testCtx, databaseClient.DB, measurementType, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.RolledUp)
+
+	// a page of raw readings only has the reading within the raw retention left
+	readings, err := adminClient.GetSensorMeasurements(testCtx, sensorID, measurementTypeID, api.SensorDataQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.NotNil(t, readings.RawRetentionCutoff)
+	if assert.Len(t, readings.Measurements, 1) {
+		assert.Equal(t, 50.0, readings.Measurements[0].Value)
+	}
+
+	// Step 3: a range reaching past the raw retention is read from the rollups
+	queried, err := adminClient.QuerySensors(testCtx, api.QuerySensorsRequest{
+		SensorIDs:        []string{sensorID},
+		MeasurementTypes: []string{measurementTypeID},
+		Start:            time.Now().AddDate(0, 0, -60),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "hourly", queried.Resolution)
+	if assert.Len(t, queried.Series, 1) && assert.Len(t, queried.Series[0].Buckets, 2) {
+		assert.True(t, oldHour.Equal(queried.Series[0].Buckets[0].BucketStart))
+		assert.Equal(t, 15.0, queried.Series[0].Buckets[0].Avg)
+		assert.Equal(t, 2, queried.Series[0].Buckets[0].Count)
+		assert.Equal(t, 50.0, queried.Series[0].Buckets[1].Avg)
+	}
+
+	queried, err = adminClient.QuerySensors(testCtx, api.QuerySensorsRequest{
+		SensorIDs:        []string{sensorID},
+		MeasurementTypes: []string{measurementTypeID},
+		Resolution:       "monthly",
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, queried.Series, 1) {
+		var count int
+		for _, bucket := range queried.Series[0].Buckets {
+			count += bucket.Count
+		}
+		assert.Equal(t, 3, count)
+	}
+
+	// Step 4: applying the policy again rolls nothing up twice
+	result, err = database.ApplyRetentionPolicy(testCtx, databaseClient.DB, measurementType, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result.RolledUp)
+}
+
+func TestE2EReadingsPastRawRetentionKeepTheirShape(t *testing.T) {
+	// Step 0: prepare test data
+	adminClient, adminUsername := createTestAdminUser(t)
+	defer cleanupTestUser(t, adminUsername)
+
+	_, err := adminClient.Login(testCtx, api.LoginRequest{
+		Username: adminUsername,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	measurementTypes, err := adminClient.GetMeasurementTypes(testCtx)
+	assert.NoError(t, err)
+	var originalRawDays int
+	for _, measurementType := range measurementTypes {
+		if measurementType.ID == "soil_moisture" {
+			originalRawDays = measurementType.RawRetentionDays
+		}
+	}
+
+	rawDays := 30
+	_, err = adminClient.UpdateMeasurementType(testCtx, "soil_moisture", api.UpdateMeasurementTypeRequest{RawRetentionDays: &rawDays})
+	assert.NoError(t, err)
+	defer adminClient.UpdateMeasurementType(testCtx, "soil_moisture", api.UpdateMeasurementTypeRequest{RawRetentionDays: &originalRawDays})
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	oldHour := time.Now().UTC().AddDate(0, 0, -40).Truncate(time.Hour)
+	recent := time.Now().UTC().Add(-5 * time.Minute).Truncate(time.Second)
+	_, err = adminClient.SetSensorMoistureData(testCtx, sensorID, api.SetSensorMoistureDataResponse{
+		SensorMoistureData: []api.SensorMoistureData{
+			{Date: oldHour.Add(10 * time.Minute), SoilMoisture: 30},
+			{Date: oldHour.Add(20 * time.Minute), SoilMoisture: 32},
+			{Date: recent, SoilMoisture: 50},
+		},
+	})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	// Step 1: a range reaching past the raw retention is read from the hourly rollups,
+	// returned as readings holding each hour's average
+	old, err := adminClient.GetSensorMoistureData(testCtx, sensorID, api.SensorDataQuery{Start: time.Now().AddDate(0, 0, -60)})
+	assert.NoError(t, err)
+	assert.Equal(t, "hourly", old.Resolution)
+	if assert.Len(t, old.SensorMoistureData, 2) {
+		assert.True(t, oldHour.Equal(old.SensorMoistureData[0].Date))
+		assert.Equal(t, 31.0, old.SensorMoistureData[0].SoilMoisture)
+		assert.True(t, recent.Truncate(time.Hour).Equal(old.SensorMoistureData[1].Date))
+		assert.Equal(t, 50.0, old.SensorMoistureData[1].SoilMoisture)
+	}
+
+	// a range within the raw retention is read as raw readings
+	latest, err := adminClient.GetSensorMoistureData(testCtx, sensorID, api.SensorDataQuery{Start: time.Now().AddDate(0, 0, -1)})
+	assert.NoError(t, err)
+	assert.Empty(t, latest.Resolution)
+	if assert.Len(t, latest.SensorMoistureData, 1) {
+		assert.Equal(t, 50.0, latest.SensorMoistureData[0].SoilMoisture)
+	}
+
+	// an open range reaches back past the raw retention too
+	open, err := adminClient.GetSensorMoistureData(testCtx, sensorID, api.SensorDataQuery{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, open.Resolution)
+	assert.Len(t, open.SensorMoistureData, 2)
+
+	// pages of raw readings refuse starts past the raw retention and say where
+	// the raw readings stop when there is no start
+	_, err = adminClient.GetSensorMoistureData(testCtx, sensorID, api.SensorDataQuery{Start: time.Now().AddDate(0, 0, -60), Limit: 10})
+	assert.Error(t, err)
+
+	paged, err := adminClient.GetSensorMoistureData(testCtx, sensorID, api.SensorDataQuery{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, paged.Resolution)
+	assert.NotNil(t, paged.RawRetentionCutoff)
+
+	// Step 2: exports only hold raw readings, so ranges past the raw retention are refused
+	var export bytes.Buffer
+	err = adminClient.ExportSensorData(testCtx, api.SensorDataExportQuery{
+		SensorIDs:        []string{sensorID},
+		MeasurementTypes: []string{"moisture"},
+		Start:            time.Now().AddDate(0, 0, -60),
+	}, &export)
+	assert.Error(t, err)
+
+	err = adminClient.ExportSensorData(testCtx, api.SensorDataExportQuery{
+		SensorIDs:        []string{sensorID},
+		MeasurementTypes: []string{"moisture"},
+		Start:            time.Now().AddDate(0, 0, -1),
+	}, &export)
+	assert.NoError(t, err)
+}
+
+func TestE2EQuarantineImplausibleReadings(t *testing.T) {
+	// Step 0: prepare test data
+	adminClient, adminUsername := createTestAdminUser(t)
+	defer cleanupTestUser(t, adminUsername)
+
+	_, err := adminClient.Login(testCtx, api.LoginRequest{
+		Username: adminUsername,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	minValue, maxValue, maxRate := float64(0), float64(100), float64(10)
+	measurementTypeID := "test_" + uuid.NewString()[:8]
+	_, err = adminClient.CreateMeasurementType(testCtx, api.MeasurementType{
+		ID:             measurementTypeID,
+		DisplayName:    "Test Soil Moisture",
+		Unit:           "%",
+		MinValue:       &minValue,
+		MaxValue:       &maxValue,
+		MaxRatePerHour: &maxRate,
+	})
+	assert.NoError(t, err)
+
+	// Step 1: readings outside the range or changing too fast are quarantined, not stored
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	baseTime := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	saved, err := adminClient.SetSensorMeasurements(testCtx, sensorID, measurementTypeID, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{
+			{Date: baseTime, Value: 40},
+			{Date: baseTime.Add(10 * time.Minute), Value: 140},  // above the max
+			{Date: baseTime.Add(20 * time.Minute), Value: 80},   // jumped 40 in 20 minutes
+			{Date: baseTime.Add(30 * time.Minute), Value: 44.5}, // within the rate of the last good reading
+		},
+	})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+	assert.Equal(t, 2, saved.Inserted)
+	assert.Equal(t, 2, saved.Quarantined)
+	if assert.Len(t, saved.Results, 4) {
+		assert.Equal(t, "quarantined", saved.Results[1].Status)
+		assert.Equal(t, "quarantined", saved.Results[2].Status)
+	}
+
+	readings, err := adminClient.GetSensorMeasurements(testCtx, sensorID, measurementTypeID, api.SensorDataQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, readings.Measurements, 2)
+
+	// Step 2: the quarantined readings are listed for review with their reasons
+	var quarantined []api.QuarantinedReading
+	page, err := adminClient.GetQuarantinedReadings(testCtx, "", 0, 0)
+	assert.NoError(t, err)
+	for _, reading := range page.Readings {
+		if reading.SensorID == sensorID {
+			quarantined = append(quarantined, reading)
+		}
+	}
+	if !assert.Len(t, quarantined, 2) {
+		return
+	}
+	assert.Equal(t, "out_of_range", quarantined[0].ReasonCode)
+	assert.Equal(t, "rate_of_change", quarantined[1].ReasonCode)
+
+	// Step 3: releasing stores the reading, discarding keeps it out for good
+	released, err := adminClient.ReleaseQuarantinedReading(testCtx, quarantined[1].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "released", released.Status)
+	assert.Equal(t, adminUsername, released.ReviewedBy)
+	assert.NotZero(t, released.MeasurementID)
+
+	discarded, err := adminClient.DiscardQuarantinedReading(testCtx, quarantined[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "discarded", discarded.Status)
+
+	_, err = adminClient.ReleaseQuarantinedReading(testCtx, quarantined[0].ID)
+	assert.Error(t, err)
+
+	readings, err = adminClient.GetSensorMeasurements(testCtx, sensorID, measurementTypeID, api.SensorDataQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, readings.Measurements, 3)
+}
+
+func TestE2ESensorCalibrationsApplyOnRead(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	baseTime := time.Now().UTC().Add(-3 * time.Hour).Truncate(time.Hour)
+	_, err = testClient.SetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{
+			{Date: baseTime.Add(10 * time.Minute), Value: 20},
+			{Date: baseTime.Add(70 * time.Minute), Value: 20},
+			{Date: baseTime.Add(130 * time.Minute), Value: 20},
+		},
+	})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	// Step 1: a linear calibration from the second hour and a polynomial one from the third
+	gain, offset := 1.5, 2.0
+	linear, err := testClient.CreateSensorCalibration(testCtx, sensorID, api.CreateSensorCalibrationRequest{
+		MeasurementType: database.MeasurementTypeSoilMoisture,
+		EffectiveFrom:   baseTime.Add(time.Hour),
+		Gain:            &gain,
+		Offset:          &offset,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{2, 1.5}, linear.Coefficients)
+
+	polynomial, err := testClient.CreateSensorCalibration(testCtx, sensorID, api.CreateSensorCalibrationRequest{
+		MeasurementType: database.MeasurementTypeSoilMoisture,
+		EffectiveFrom:   baseTime.Add(2 * time.Hour),
+		Coefficients:    []float64{1, 0, 0.1},
+		Note:            "clay loam",
+	})
+	assert.NoError(t, err)
+
+	_, err = testClient.CreateSensorCalibration(testCtx, sensorID, api.CreateSensorCalibrationRequest{
+		MeasurementType: database.MeasurementTypeSoilMoisture,
+		EffectiveFrom:   baseTime.Add(time.Hour),
+	})
+	assert.Error(t, err, "a second calibration effective from the same date is rejected")
+
+	calibrations, err := testClient.GetSensorCalibrations(testCtx, sensorID, "")
+	assert.NoError(t, err)
+	assert.Len(t, calibrations.Calibrations, 2)
+
+	// Step 2: readings are calibrated by the calibration in effect when they were taken
+	readings, err := testClient.GetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilMoisture, api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.Len(t, readings.Measurements, 3) {
+		assert.Equal(t, 20.0, readings.Measurements[0].Value)
+		assert.Equal(t, 32.0, readings.Measurements[1].Value)
+		assert.InDelta(t, 41.0, readings.Measurements[2].Value, 1e-9)
+	}
+
+	buckets, err := testClient.GetSensorMeasurementBuckets(testCtx, sensorID, database.MeasurementTypeSoilMoisture, "hourly", api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.Len(t, buckets.Buckets, 3) {
+		assert.Equal(t, 32.0, buckets.Buckets[1].Avg)
+	}
+
+	// Step 3: raw readings are still available and unchanged
+	raw, err := testClient.GetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilMoisture, api.SensorDataQuery{Raw: true})
+	assert.NoError(t, err)
+	for _, reading := range raw.Measurements {
+		assert.Equal(t, 20.0, reading.Value)
+	}
+
+	// Step 4: removing a calibration recomputes the history it covered
+	err = testClient.DeleteSensorCalibration(testCtx, sensorID, polynomial.ID)
+	assert.NoError(t, err)
+
+	readings, err = testClient.GetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilMoisture, api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.Len(t, readings.Measurements, 3) {
+		assert.Equal(t, 32.0, readings.Measurements[2].Value)
+	}
+}
+
+func TestE2EConvertUnitsOnRead(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	readingTime := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Second)
+	_, err = testClient.SetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilTemperature, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: readingTime, Value: 25}},
+	})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	_, err = testClient.SetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: readingTime, Value: 32}},
+	})
+	assert.NoError(t, err)
+
+	// Step 1: readings are returned in their canonical unit by default
+	temperature, err := testClient.GetSensorTemperatureData(testCtx, sensorID, api.SensorDataQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, api.UnitCelsius, temperature.Unit)
+	if assert.Len(t, temperature.SensorTemperatureData, 1) {
+		assert.Equal(t, 25.0, temperature.SensorTemperatureData[0].SoilTemperature)
+	}
+
+	// Step 2: and converted to the units asked for, stating the unit of the values
+	temperature, err = testClient.GetSensorTemperatureData(testCtx, sensorID, api.SensorDataQuery{Units: []string{"fahrenheit"}})
+	assert.NoError(t, err)
+	assert.Equal(t, api.UnitFahrenheit, temperature.Unit)
+	if assert.Len(t, temperature.SensorTemperatureData, 1) {
+		assert.InDelta(t, 77.0, temperature.SensorTemperatureData[0].SoilTemperature, 1e-9)
+	}
+
+	moisture, err := testClient.GetSensorMoistureData(testCtx, sensorID, api.SensorDataQuery{Units: []string{"fraction"}})
+	assert.NoError(t, err)
+	assert.Equal(t, api.UnitFraction, moisture.Unit)
+	if assert.Len(t, moisture.SensorMoistureData, 1) {
+		assert.InDelta(t, 0.32, moisture.SensorMoistureData[0].SoilMoisture, 1e-9)
+	}
+
+	queried, err := testClient.QuerySensors(testCtx, api.QuerySensorsRequest{
+		SensorIDs:        []string{sensorID},
+		MeasurementTypes: []string{database.MeasurementTypeSoilTemperature},
+		Resolution:       "hourly",
+		Units:            []string{"fahrenheit"},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, queried.Series, 1) && assert.Len(t, queried.Series[0].Buckets, 1) {
+		assert.Equal(t, api.UnitFahrenheit, queried.Series[0].Unit)
+		assert.InDelta(t, 77.0, queried.Series[0].Buckets[0].Avg, 1e-9)
+	}
+
+	// Step 3: unknown units are rejected
+	_, err = testClient.GetSensorTemperatureData(testCtx, sensorID, api.SensorDataQuery{Units: []string{"kelvin"}})
+	assert.Error(t, err)
+}
+
+func TestE2ESensorCoverageReportsGaps(t *testing.T) {
+	// Step 0: prepare test data
+	adminClient, adminUsername := createTestAdminUser(t)
+	defer cleanupTestUser(t, adminUsername)
+
+	_, err := adminClient.Login(testCtx, api.LoginRequest{
+		Username: adminUsername,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	sensor, err := database.CreateSensor(testCtx, databaseClient.DB, sensorID, "Coverage Test Sensor", "Field 1", database.SensorCoordinates{})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	end := time.Now().UTC().Truncate(time.Hour)
+	start := end.Add(-12 * time.Hour)
+	sensor.InstallationDate = start.Add(-24 * time.Hour)
+	err = sensor.Update(testCtx, databaseClient.DB, "installation_date")
+	assert.NoError(t, err)
+
+	// hourly readings with six hours of silence in the middle
+	var measurements []api.SensorMeasurement
+	for hour := 0; hour < 12; hour++ {
+		if hour >= 3 && hour < 9 {
+			continue
+		}
+		measurements = append(measurements, api.SensorMeasurement{Date: start.Add(time.Duration(hour)*time.Hour + 30*time.Minute), Value: 30})
+	}
+	_, err = adminClient.SetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{Measurements: measurements})
+	assert.NoError(t, err)
+
+	// Step 1: the sensor's report lists the silence
+	coverage, err := adminClient.GetSensorCoverage(testCtx, sensorID, start, end, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 12, coverage.ExpectedReadings)
+	assert.Equal(t, 6, coverage.ReceivedReadings)
+	if assert.Len(t, coverage.Gaps, 1) {
+		assert.True(t, start.Add(2*time.Hour+30*time.Minute).Equal(coverage.Gaps[0].Start))
+		assert.Equal(t, 6, coverage.Gaps[0].MissedReadings)
+	}
+	assert.Less(t, coverage.CoveragePercent, 60.0)
+
+	// Step 2: the fleet ranking places it among the sensors with least coverage
+	ranking, err := adminClient.GetSensorsCoverage(testCtx, start, end, "")
+	assert.NoError(t, err)
+	var found bool
+	for i, ranked := range ranking.Sensors {
+		if i > 0 {
+			assert.GreaterOrEqual(t, ranked.CoveragePercent, ranking.Sensors[i-1].CoveragePercent)
+		}
+		if ranked.SensorID == sensorID {
+			found = true
+			assert.Equal(t, coverage.CoveragePercent, ranked.CoveragePercent)
+			assert.Equal(t, 1, ranked.GapCount)
+			assert.Empty(t, ranked.Gaps)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestE2EZonesAggregateTheirSensors(t *testing.T) {
+	// Step 0: prepare test data
+	userClient, username := createTestRegularUser(t)
+	defer cleanupTestUser(t, username)
+
+	_, err := userClient.Login(testCtx, api.LoginRequest{
+		Username: username,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	zone, err := userClient.CreateZone(testCtx, api.CreateZoneRequest{
+		Name:     "Zone " + uuid.New().String()[:8],
+		CropType: "maize",
+		Boundary: json.RawMessage(`{"type": "Polygon", "coordinates": [[[30.1, -10.2], [30.2, -10.2], [30.2, -10.1], [30.1, -10.1], [30.1, -10.2]]]}`),
+	})
+	assert.NoError(t, err)
+	zoneID, err := uuid.Parse(zone.ID)
+	assert.NoError(t, err)
+	defer database.DeleteZone(testCtx, databaseClient.DB, zoneID)
+
+	inside := &api.SensorCoordinates{Latitude: -10.15, Longitude: 30.15}
+	outside := &api.SensorCoordinates{Latitude: -10.5, Longitude: 30.5}
+	var sensorIDs []string
+	for _, coordinates := range []*api.SensorCoordinates{inside, inside, outside} {
+		sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+		err = userClient.AddSensor(testCtx, sensorID, "Zone Test Sensor", "Field 1", coordinates)
+		assert.NoError(t, err)
+		defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+		sensorIDs = append(sensorIDs, sensorID)
+	}
+
+	// Step 1: sensors inside the boundary are placed in the zone, others can be assigned by hand
+	assigned, err := userClient.AssignSensorZone(testCtx, sensorIDs[2], zone.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, zone.ID, assigned.ZoneID)
+	assert.True(t, assigned.ZoneAssignedManually)
+
+	zone, err = userClient.GetZone(testCtx, zone.ID)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, sensorIDs, zone.SensorIDs)
+
+	// Step 2: the zone's series aggregates the readings of its sensors
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+	for i, value := range []float64{20, 40, 30} {
+		_, err = userClient.SetSensorMeasurements(testCtx, sensorIDs[i], database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{
+			Measurements: []api.SensorMeasurement{{Date: hour.Add(10 * time.Minute), Value: value}, {Date: hour.Add(40 * time.Minute), Value: value}},
+		})
+		assert.NoError(t, err)
+	}
+
+	series, err := userClient.GetZoneSeries(testCtx, zone.ID, api.ZoneSeriesQuery{MeasurementTypes: []string{"moisture"}})
+	assert.NoError(t, err)
+	assert.Equal(t, database.ResolutionHourly, series.Resolution)
+	if assert.Len(t, series.Series, 1) && assert.Len(t, series.Series[0].Buckets, 1) {
+		bucket := series.Series[0].Buckets[0]
+		assert.True(t, hour.Equal(bucket.BucketStart))
+		assert.Equal(t, 30.0, bucket.Mean)
+		assert.Equal(t, 20.0, bucket.Min)
+		assert.Equal(t, 40.0, bucket.Max)
+		assert.Equal(t, 3, bucket.SensorCount)
+		assert.Equal(t, 6, bucket.Count)
+	}
+
+	// Step 3: sensors that move out of the boundary leave the zone, unless assigned by hand
+	moved, err := userClient.UpdateSensor(testCtx, sensorIDs[1], api.UpdateSensorRequest{Latitude: &outside.Latitude, Longitude: &outside.Longitude})
+	assert.NoError(t, err)
+	assert.Empty(t, moved.ZoneID)
+
+	unassigned, err := userClient.AssignSensorZone(testCtx, sensorIDs[2], "")
+	assert.NoError(t, err)
+	assert.Empty(t, unassigned.ZoneID)
+	assert.False(t, unassigned.ZoneAssignedManually)
+
+	zone, err = userClient.GetZone(testCtx, zone.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{sensorIDs[0]}, zone.SensorIDs)
+
+	// Step 4: deleting the zone leaves its sensors without a zone
+	err = userClient.DeleteZone(testCtx, zone.ID)
+	assert.NoError(t, err)
+
+	sensor, err := database.GetSensorByID(testCtx, databaseClient.DB, sensorIDs[0])
+	assert.NoError(t, err)
+	assert.Nil(t, sensor.ZoneID)
+
+	_, err = userClient.GetZone(testCtx, zone.ID)
+	assert.Error(t, err)
+}
+
+func TestE2EFindSensorsByLocationAsGeoJSON(t *testing.T) {
+	// Step 0: prepare test data
+	userClient, username := createTestRegularUser(t)
+	defer cleanupTestUser(t, username)
+
+	_, err := userClient.Login(testCtx, api.LoginRequest{
+		Username: username,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	nearID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = userClient.AddSensor(testCtx, nearID, "Map Test Sensor", "Field 1", &api.SensorCoordinates{Latitude: -33.9001, Longitude: 151.2001})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, nearID)
+
+	farID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = userClient.AddSensor(testCtx, farID, "Map Test Sensor", "Field 2", &api.SensorCoordinates{Latitude: -33.95, Longitude: 151.25})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, farID)
+
+	_, err = userClient.SetSensorMeasurements(testCtx, nearID, database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: time.Now().UTC().Add(-time.Minute), Value: 27.5}},
+	})
+	assert.NoError(t, err)
+
+	sensorIDs := func(sensors []api.Sensor) []string {
+		var ids []string
+		for _, sensor := range sensors {
+			ids = append(ids, sensor.ID)
+		}
+		return ids
+	}
+
+	// Step 1: the bounding box and radius filters select sensors by their coordinates
+	inBox, err := userClient.FindSensors(testCtx, api.SensorsQuery{BoundingBox: []float64{151.19, -33.91, 151.21, -33.89}})
+	assert.NoError(t, err)
+	assert.Contains(t, sensorIDs(inBox), nearID)
+	assert.NotContains(t, sensorIDs(inBox), farID)
+
+	nearby, err := userClient.FindSensors(testCtx, api.SensorsQuery{Near: &api.SensorCoordinates{Latitude: -33.9, Longitude: 151.2}, RadiusMeters: 10000})
+	assert.NoError(t, err)
+	ids := sensorIDs(nearby)
+	assert.Contains(t, ids, nearID)
+	assert.Contains(t, ids, farID)
+	if assert.GreaterOrEqual(t, len(ids), 2) {
+		assert.Equal(t, nearID, ids[0], "nearest first")
+	}
+
+	// Step 2: the geojson format returns features holding the sensor's state and newest readings
+	collection, err := userClient.GetSensorsGeoJSON(testCtx, api.SensorsQuery{Near: &api.SensorCoordinates{Latitude: -33.9, Longitude: 151.2}, RadiusMeters: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	if assert.Len(t, collection.Features, 1) {
+		feature := collection.Features[0]
+		assert.Equal(t, nearID, feature.ID)
+		if assert.NotNil(t, feature.Geometry) {
+			assert.Equal(t, "Point", feature.Geometry.Type)
+			assert.InDeltaSlice(t, []float64{151.2001, -33.9001}, feature.Geometry.Coordinates, 1e-9)
+		}
+		assert.Equal(t, true, feature.Properties["is_online"])
+		assert.Equal(t, 27.5, feature.Properties[database.MeasurementTypeSoilMoisture])
+	}
+}
+
+func TestE2EHeatmapInterpolatesBetweenSensors(t *testing.T) {
+	// Step 0: prepare test data
+	userClient, username := createTestRegularUser(t)
+	defer cleanupTestUser(t, username)
+
+	_, err := userClient.Login(testCtx, api.LoginRequest{
+		Username: username,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	dryID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = userClient.AddSensor(testCtx, dryID, "Heatmap Test Sensor", "Field 1", &api.SensorCoordinates{Latitude: -41.2001, Longitude: 174.7001})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, dryID)
+
+	wetID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = userClient.AddSensor(testCtx, wetID, "Heatmap Test Sensor", "Field 1", &api.SensorCoordinates{Latitude: -41.2009, Longitude: 174.7009})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, wetID)
+
+	now := time.Now().UTC()
+	_, err = userClient.SetSensorMeasurements(testCtx, dryID, database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: now.Add(-time.Minute), Value: 10}},
+	})
+	assert.NoError(t, err)
+	_, err = userClient.SetSensorMeasurements(testCtx, wetID, database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: now.Add(-time.Minute), Value: 40}},
+	})
+	assert.NoError(t, err)
+
+	query := api.HeatmapQuery{
+		BoundingBox:    []float64{174.7, -41.201, 174.701, -41.2},
+		CellSizeMeters: 10,
+		RadiusMeters:   1000,
+	}
+
+	// Step 1: the grid runs from the dry sensor's reading in the north west to the wet one's in the south east
+	heatmap, err := userClient.GetHeatmap(testCtx, query)
+	assert.NoError(t, err)
+	assert.Equal(t, database.MeasurementTypeSoilMoisture, heatmap.MeasurementType)
+	assert.Equal(t, "idw", heatmap.Method)
+	assert.Equal(t, 9, heatmap.Columns)
+	assert.Equal(t, 12, heatmap.Rows)
+	assert.Len(t, heatmap.Values, heatmap.Rows)
+
+	sampled := make(map[string]float64)
+	for _, sample := range heatmap.Samples {
+		sampled[sample.SensorID] = sample.Value
+	}
+	assert.Equal(t, 10.0, sampled[dryID])
+	assert.Equal(t, 40.0, sampled[wetID])
+
+	if assert.NotNil(t, heatmap.Values[0][0]) && assert.NotNil(t, heatmap.Values[heatmap.Rows-1][heatmap.Columns-1]) {
+		assert.Less(t, *heatmap.Values[0][0], 25.0)
+		assert.Greater(t, *heatmap.Values[heatmap.Rows-1][heatmap.Columns-1], 25.0)
+	}
+	if assert.NotNil(t, heatmap.Min) && assert.NotNil(t, heatmap.Max) {
+		assert.GreaterOrEqual(t, *heatmap.Min, 10.0)
+		assert.LessOrEqual(t, *heatmap.Max, 40.0)
+	}
+
+	// Step 2: the same grid can be fetched as an image with one pixel per cell
+	var overlay bytes.Buffer
+	bounds, err := userClient.GetHeatmapPNG(testCtx, query, &overlay)
+	assert.NoError(t, err)
+	assert.Equal(t, query.BoundingBox, bounds)
+	decoded, err := png.Decode(&overlay)
+	assert.NoError(t, err)
+	if err == nil {
+		assert.Equal(t, heatmap.Columns, decoded.Bounds().Dx())
+		assert.Equal(t, heatmap.Rows, decoded.Bounds().Dy())
+	}
+
+	// Step 3: readings taken after the time asked for are left out
+	query.At = now.Add(-time.Hour)
+	before, err := userClient.GetHeatmap(testCtx, query)
+	assert.NoError(t, err)
+	for _, sample := range before.Samples {
+		assert.NotEqual(t, dryID, sample.SensorID)
+		assert.NotEqual(t, wetID, sample.SensorID)
+	}
+}
+
+func TestE2EAlertRulesFireAndResolveOnIngest(t *testing.T) {
+	// Step 0: prepare test data
+	userClient, username := createTestRegularUser(t)
+	defer cleanupTestUser(t, username)
+
+	_, err := userClient.Login(testCtx, api.LoginRequest{
+		Username: username,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = userClient.AddSensor(testCtx, sensorID, "Alert Test Sensor", "Field 1", nil)
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	rule, err := userClient.CreateAlertRule(testCtx, api.CreateAlertRuleRequest{
+		Name:            "Alert test field dry",
+		MeasurementType: "moisture",
+		SensorID:        sensorID,
+		Operator:        "below",
+		Threshold:       20,
+		DurationSeconds: 60,
+		Hysteresis:      5,
+		Severity:        "critical",
+	})
+	assert.NoError(t, err)
+	defer userClient.DeleteAlertRule(testCtx, rule.ID)
+	assert.Equal(t, database.MeasurementTypeSoilMoisture, rule.MeasurementType)
+	assert.True(t, rule.Enabled)
+
+	_, err = userClient.CreateAlertRule(testCtx, api.CreateAlertRuleRequest{
+		Name:            "Alert test invalid",
+		MeasurementType: "moisture",
+		Operator:        "equals",
+		Threshold:       20,
+	})
+	assert.Error(t, err, "unknown operators are refused")
+
+	baseTime := time.Now().UTC().Truncate(time.Second).Add(-10 * time.Minute)
+	save := func(offset time.Duration, value float64) {
+		_, err := userClient.SetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{
+			Measurements: []api.SensorMeasurement{{Date: baseTime.Add(offset), Value: value}},
+		})
+		assert.NoError(t, err)
+	}
+
+	// Step 1: the alert only fires once the readings stay below the threshold for the duration
+	save(0, 18)
+	alerts, err := userClient.GetAlerts(testCtx, api.AlertsQuery{SensorID: sensorID})
+	assert.NoError(t, err)
+	assert.Empty(t, alerts.Alerts)
+
+	save(time.Minute, 17)
+	alerts, err = userClient.GetAlerts(testCtx, api.AlertsQuery{SensorID: sensorID, State: "firing"})
+	assert.NoError(t, err)
+	if assert.Len(t, alerts.Alerts, 1) {
+		alert := alerts.Alerts[0]
+		assert.Equal(t, rule.ID, alert.RuleID)
+		assert.Equal(t, "critical", alert.Severity)
+		assert.Equal(t, 17.0, alert.FiredValue)
+		assert.True(t, baseTime.Equal(alert.BreachingSince))
+	}
+
+	// Step 2: readings within the hysteresis leave it firing, readings past it resolve it
+	save(2*time.Minute, 22)
+	alerts, err = userClient.GetAlerts(testCtx, api.AlertsQuery{SensorID: sensorID, State: "firing"})
+	assert.NoError(t, err)
+	assert.Len(t, alerts.Alerts, 1)
+
+	save(3*time.Minute, 26)
+	alerts, err = userClient.GetAlerts(testCtx, api.AlertsQuery{SensorID: sensorID})
+	assert.NoError(t, err)
+	if assert.Len(t, alerts.Alerts, 1) {
+		alert, err := userClient.GetAlert(testCtx, alerts.Alerts[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "resolved", alert.State)
+		if assert.NotNil(t, alert.ResolvedValue) {
+			assert.Equal(t, 26.0, *alert.ResolvedValue)
+		}
+	}
+
+	// Step 3: disabling the rule stops it firing
+	enabled := false
+	_, err = userClient.UpdateAlertRule(testCtx, rule.ID, api.UpdateAlertRuleRequest{Enabled: &enabled})
+	assert.NoError(t, err)
+	save(4*time.Minute, 5)
+	save(6*time.Minute, 5)
+	alerts, err = userClient.GetAlerts(testCtx, api.AlertsQuery{RuleID: rule.ID, State: "firing"})
+	assert.NoError(t, err)
+	assert.Empty(t, alerts.Alerts)
+}
+
+func TestE2EAlertLifecycleIsSharedBetweenUsers(t *testing.T) {
+	// Step 0: prepare test data
+	ownerClient, owner := createTestRegularUser(t)
+	defer cleanupTestUser(t, owner)
+	managerClient, manager := createTestRegularUser(t)
+	defer cleanupTestUser(t, manager)
+
+	for client, username := range map[*sdk.NexusClient]string{ownerClient: owner, managerClient: manager} {
+		_, err := client.Login(testCtx, api.LoginRequest{
+			Username: username,
+			Password: "password123",
+		})
+		assert.NoError(t, err)
+	}
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err := ownerClient.AddSensor(testCtx, sensorID, "Alert Lifecycle Test Sensor", "Field 1", nil)
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	_, err = ownerClient.CreateAlertRule(testCtx, api.CreateAlertRuleRequest{
+		Name:                 "Alert lifecycle test unknown recipient",
+		MeasurementType:      "moisture",
+		Operator:             "below",
+		Threshold:            20,
+		EscalationRecipients: []string{"no-such-user-" + uuid.New().String()},
+	})
+	assert.Error(t, err, "recipients must be users")
+
+	rule, err := ownerClient.CreateAlertRule(testCtx, api.CreateAlertRuleRequest{
+		Name:                 "Alert lifecycle test field dry",
+		MeasurementType:      "moisture",
+		SensorID:             sensorID,
+		Operator:             "below",
+		Threshold:            20,
+		Severity:             "critical",
+		Recipients:           []string{owner},
+		EscalationRecipients: []string{manager},
+	})
+	assert.NoError(t, err)
+	defer ownerClient.DeleteAlertRule(testCtx, rule.ID)
+	assert.Equal(t, []string{manager}, rule.EscalationRecipients)
+
+	baseTime := time.Now().UTC().Truncate(time.Second).Add(-10 * time.Minute)
+	_, err = ownerClient.SetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: baseTime, Value: 12}},
+	})
+	assert.NoError(t, err)
+
+	alerts, err := managerClient.GetAlerts(testCtx, api.AlertsQuery{RuleID: rule.ID, State: "firing"})
+	assert.NoError(t, err)
+	if !assert.Len(t, alerts.Alerts, 1) {
+		return
+	}
+	alertID := alerts.Alerts[0].ID
+	assert.Equal(t, []string{owner}, alerts.Alerts[0].Recipients)
+
+	// Step 1: an alert is acknowledged once and everyone sees who acknowledged it
+	_, err = ownerClient.AcknowledgeAlert(testCtx, alertID, api.AcknowledgeAlertRequest{Note: "checking the valve"})
+	assert.NoError(t, err)
+	_, err = managerClient.AcknowledgeAlert(testCtx, alertID, api.AcknowledgeAlertRequest{})
+	assert.Error(t, err, "acknowledged alerts can't be acknowledged again")
+
+	alert, err := managerClient.GetAlert(testCtx, alertID)
+	assert.NoError(t, err)
+	assert.Equal(t, "acknowledged", alert.State)
+	assert.Equal(t, owner, alert.AcknowledgedBy)
+
+	// Step 2: snoozing and assigning are shared the same way
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	_, err = managerClient.SnoozeAlert(testCtx, alertID, api.SnoozeAlertRequest{Until: until})
+	assert.NoError(t, err)
+	alerts, err = ownerClient.GetAlerts(testCtx, api.AlertsQuery{RuleID: rule.ID, State: "snoozed"})
+	assert.NoError(t, err)
+	if assert.Len(t, alerts.Alerts, 1) {
+		assert.True(t, until.Equal(*alerts.Alerts[0].SnoozedUntil))
+	}
+
+	_, err = ownerClient.AssignAlert(testCtx, alertID, api.AssignAlertRequest{Username: "no-such-user-" + uuid.New().String()})
+	assert.Error(t, err, "alerts can only be assigned to users")
+	alert, err = ownerClient.AssignAlert(testCtx, alertID, api.AssignAlertRequest{Username: manager})
+	assert.NoError(t, err)
+	assert.Equal(t, manager, alert.AssignedTo)
+
+	// Step 3: resolving by hand needs a note, after which the alert is closed to actions
+	_, err = managerClient.ResolveAlert(testCtx, alertID, api.ResolveAlertRequest{})
+	assert.Error(t, err, "resolving by hand needs a note")
+	alert, err = managerClient.ResolveAlert(testCtx, alertID, api.ResolveAlertRequest{Note: "valve replaced"})
+	assert.NoError(t, err)
+	assert.Equal(t, "resolved", alert.State)
+	assert.Equal(t, manager, alert.ResolvedBy)
+	assert.Equal(t, "valve replaced", alert.ResolutionNote)
+	_, err = ownerClient.AssignAlert(testCtx, alertID, api.AssignAlertRequest{Username: owner})
+	assert.Error(t, err, "resolved alerts can't be acted on")
+
+	events, err := ownerClient.GetAlertEvents(testCtx, alertID)
+	assert.NoError(t, err)
+	var actions []string
+	for _, event := range events.Events {
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []string{"fired", "acknowledged", "snoozed", "assigned", "resolved"}, actions)
+	if assert.Len(t, events.Events, 5) {
+		assert.Empty(t, events.Events[0].Actor)
+		assert.Equal(t, owner, events.Events[1].Actor)
+		assert.Equal(t, "checking the valve", events.Events[1].Note)
+		assert.Equal(t, manager, events.Events[3].AssignedTo)
+	}
+
+	// Step 4: the rule fires again on the next dry reading, and nobody acknowledging
+	// the alert escalates it to the escalation recipients
+	_, err = ownerClient.SetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeSoilMoisture, api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: baseTime.Add(time.Minute), Value: 11}},
+	})
+	assert.NoError(t, err)
+	alerts, err = ownerClient.GetAlerts(testCtx, api.AlertsQuery{RuleID: rule.ID, State: "firing"})
+	assert.NoError(t, err)
+	if !assert.Len(t, alerts.Alerts, 1) {
+		return
+	}
+	refiredID := alerts.Alerts[0].ID
+	assert.NotEqual(t, alertID, refiredID)
+
+	notified := make(map[int64][]string)
+	escalated, err := database.EscalateAlerts(testCtx, databaseClient.DB, time.Now().Add(time.Hour), 30*time.Minute, func(ctx context.Context, tx bun.Tx, alert database.Alert) error {
+		notified[alert.ID] = alert.EscalatedTo
+		return nil
+	})
+	assert.NoError(t, err)
+	var escalatedIDs []int64
+	for _, alert := range escalated {
+		escalatedIDs = append(escalatedIDs, alert.ID)
+	}
+	assert.Contains(t, escalatedIDs, refiredID)
+	assert.Equal(t, []string{manager}, notified[refiredID], "each escalation is passed on to be delivered")
+
+	alert, err = ownerClient.GetAlert(testCtx, refiredID)
+	assert.NoError(t, err)
+	assert.NotNil(t, alert.EscalatedAt)
+	assert.Equal(t, []string{manager}, alert.EscalatedTo)
+
+	events, err = ownerClient.GetAlertEvents(testCtx, refiredID)
+	assert.NoError(t, err)
+	if assert.Len(t, events.Events, 2) {
+		assert.Equal(t, "escalated", events.Events[1].Action)
+		assert.Equal(t, []string{manager}, events.Events[1].Recipients)
+	}
+}
+
+func TestE2EWebhooksDeliverSignedEventsAndReplayFailures(t *testing.T) {
+	// Step 0: prepare test data
+	adminClient, adminUsername := createTestAdminUser(t)
+	defer cleanupTestUser(t, adminUsername)
+
+	_, err := adminClient.Login(testCtx, api.LoginRequest{
+		Username: adminUsername,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	// the stand-in receiver listens on every interface so the service container can reach
+	// it, verifies each delivery and refuses them while refusing is set
+	secret := "e2e-webhook-" + uuid.New().String()
+	var refusing atomic.Bool
+	received := make(chan api.WebhookEvent, 100)
+
+	listener, err := net.Listen("tcp", ":0")
+	assert.NoError(t, err)
+	receiver := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if webhook.Verify(secret, r.Header, body, time.Now(), 5*time.Minute) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if refusing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var event api.WebhookEvent
+		if json.Unmarshal(body, &event) == nil {
+			select {
+			case received <- event:
+			default:
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	receiver.Listener.Close()
+	receiver.Listener = listener
+	receiver.Start()
+	defer receiver.Close()
+
+	receiverHost := os.Getenv("TEST_WEBHOOK_RECEIVER_HOST")
+	if receiverHost == "" {
+		receiverHost = "localhost"
+	}
+	receiverURL := fmt.Sprintf("http://%s:%d/hooks/nexus", receiverHost, listener.Addr().(*net.TCPAddr).Port)
+
+	waitForSensorEvent := func(eventType string, sensorID string) (api.WebhookSensorEvent, bool) {
+		timeout := time.After(30 * time.Second)
+		for {
+			select {
+			case event := <-received:
+				var data api.WebhookSensorEvent
+				if event.Type == eventType && json.Unmarshal(event.Data, &data) == nil && data.Sensor.ID == sensorID {
+					return data, true
+				}
+			case <-timeout:
+				return api.WebhookSensorEvent{}, false
+			}
+		}
+	}
+
+	// Step 1: subscriptions need an http url and known event types
+	_, err = adminClient.CreateWebhookSubscription(testCtx, api.CreateWebhookSubscriptionRequest{URL: "ftp://example.com", EventTypes: []string{"sensor.created"}})
+	assert.Error(t, err)
+	_, err = adminClient.CreateWebhookSubscription(testCtx, api.CreateWebhookSubscriptionRequest{URL: receiverURL, EventTypes: []string{"sensor.renamed"}})
+	assert.Error(t, err)
+
+	subscription, err := adminClient.CreateWebhookSubscription(testCtx, api.CreateWebhookSubscriptionRequest{
+		URL:         receiverURL,
+		EventTypes:  []string{"sensor.created", "sensor.decommissioned", "sensor.recommissioned"},
+		Secret:      secret,
+		Description: "E2E stand-in receiver",
+	})
+	assert.NoError(t, err)
+	defer adminClient.DeleteWebhookSubscription(testCtx, subscription.ID)
+	assert.Equal(t, secret, subscription.Secret)
+	assert.True(t, subscription.Enabled)
+	assert.Equal(t, adminUsername, subscription.CreatedBy)
+
+	// the secret is only shown when the subscription is created
+	fetched, err := adminClient.GetWebhookSubscription(testCtx, subscription.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, fetched.Secret)
+	assert.Equal(t, []string{"sensor.created", "sensor.decommissioned", "sensor.recommissioned"}, fetched.EventTypes)
+
+	// Step 2: creating a sensor is delivered signed to the receiver and logged
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = adminClient.AddSensor(testCtx, sensorID, "Webhook Test Sensor", "Field 1", nil)
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	created, ok := waitForSensorEvent("sensor.created", sensorID)
+	assert.True(t, ok, "sensor.created was not delivered")
+	assert.Equal(t, "Webhook Test Sensor", created.Sensor.Name)
+
+	log, err := adminClient.GetWebhookDeliveries(testCtx, subscription.ID, api.WebhookDeliveriesQuery{Status: "delivered"})
+	assert.NoError(t, err)
+	if assert.Len(t, log.Deliveries, 1) {
+		assert.Equal(t, "sensor.created", log.Deliveries[0].EventType)
+		assert.Equal(t, 1, log.Deliveries[0].Attempts)
+		assert.Equal(t, http.StatusNoContent, log.Deliveries[0].LastStatusCode)
+		assert.NotNil(t, log.Deliveries[0].DeliveredAt)
+	}
+
+	// Step 3: a refused delivery stays pending and is retried later
+	refusing.Store(true)
+	err = adminClient.DecommissionSensor(testCtx, sensorID, "webhook test")
+	assert.NoError(t, err)
+
+	var refused api.WebhookDelivery
+	for i := 0; i < 100 && refused.Attempts == 0; i++ {
+		time.Sleep(300 * time.Millisecond)
+		log, err = adminClient.GetWebhookDeliveries(testCtx, subscription.ID, api.WebhookDeliveriesQuery{Status: "pending"})
+		assert.NoError(t, err)
+		if len(log.Deliveries) > 0 {
+			refused = log.Deliveries[0]
+		}
+	}
+	assert.Equal(t, "sensor.decommissioned", refused.EventType)
+	assert.Equal(t, 1, refused.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, refused.LastStatusCode)
+	assert.NotEmpty(t, refused.LastError)
+	if assert.NotNil(t, refused.NextAttemptAt) {
+		assert.True(t, refused.NextAttemptAt.After(*refused.LastAttemptAt))
+	}
+
+	// Step 4: once it has failed every attempt it can be replayed
+	_, err = databaseClient.DB.NewUpdate().
+		Model((*database.WebhookDelivery)(nil)).
+		Set("status = ?", database.WebhookDeliveryStatusFailed).
+		Where("id = ?", refused.ID).
+		Exec(testCtx)
+	assert.NoError(t, err)
+	refusing.Store(false)
+
+	replayed, err := adminClient.ReplayWebhookDeliveries(testCtx, subscription.ID, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, replayed.Replayed)
+
+	decommissioned, ok := waitForSensorEvent("sensor.decommissioned", sensorID)
+	assert.True(t, ok, "replayed sensor.decommissioned was not delivered")
+	assert.NotNil(t, decommissioned.Sensor.DecommissionedAt)
+	assert.False(t, decommissioned.Purged)
+
+	log, err = adminClient.GetWebhookDeliveries(testCtx, subscription.ID, api.WebhookDeliveriesQuery{})
+	assert.NoError(t, err)
+	if assert.Len(t, log.Deliveries, 2) {
+		assert.Equal(t, refused.ID, log.Deliveries[0].ID)
+		assert.Equal(t, "delivered", log.Deliveries[0].Status)
+		assert.Equal(t, 1, log.Deliveries[0].Replays)
+		assert.Equal(t, 1, log.Deliveries[0].Attempts)
+	}
+
+	// nothing is left to replay
+	replayed, err = adminClient.ReplayWebhookDeliveries(testCtx, subscription.ID, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, replayed.Replayed)
+
+	// Step 5: putting the sensor back in service is announced too
+	_, err = adminClient.RecommissionSensor(testCtx, sensorID)
+	assert.NoError(t, err)
+
+	recommissioned, ok := waitForSensorEvent("sensor.recommissioned", sensorID)
+	assert.True(t, ok, "sensor.recommissioned was not delivered")
+	assert.Nil(t, recommissioned.Sensor.DecommissionedAt)
+}
+
+func TestE2EEmailNotificationsRespectPreferencesAndQuietHours(t *testing.T) {
+	// Step 0: prepare test data
+	userClient, username := createTestRegularUser(t)
+	defer cleanupTestUser(t, username)
+
+	_, err := userClient.Login(testCtx, api.LoginRequest{
+		Username: username,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	address := username + "@nexus.test"
+	waitForEmail := func(kind string, status string) (api.Email, bool) {
+		for i := 0; i < 100; i++ {
+			emails, err := userClient.GetEmails(testCtx, api.EmailsQuery{Status: status})
+			assert.NoError(t, err)
+			for _, sent := range emails.Emails {
+				if sent.Kind == kind {
+					return sent, true
+				}
+			}
+			time.Sleep(300 * time.Millisecond)
+		}
+		return api.Email{}, false
+	}
+
+	// Step 1: users start with notifications off until they save their preferences
+	preferences, err := userClient.GetNotificationPreferences(testCtx)
+	assert.NoError(t, err)
+	assert.False(t, preferences.Enabled)
+	assert.Equal(t, "UTC", preferences.TimeZone)
+	assert.Nil(t, preferences.UpdatedAt)
+
+	_, err = userClient.SendTestEmail(testCtx)
+	assert.Error(t, err, "test emails need an address")
+	_, err = userClient.UpdateNotificationPreferences(testCtx, api.UpdateNotificationPreferencesRequest{Email: "not an address"})
+	assert.Error(t, err)
+	_, err = userClient.UpdateNotificationPreferences(testCtx, api.UpdateNotificationPreferencesRequest{Email: address, TimeZone: "Mars/Olympus"})
+	assert.Error(t, err)
+
+	preferences, err = userClient.UpdateNotificationPreferences(testCtx, api.UpdateNotificationPreferencesRequest{Email: address, TimeZone: "Africa/Nairobi"})
+	assert.NoError(t, err)
+	assert.True(t, preferences.Enabled)
+	assert.Equal(t, address, preferences.Email)
+	assert.NotNil(t, preferences.UpdatedAt)
+
+	// Step 2: a test email goes out through the smtp server
+	queued, err := userClient.SendTestEmail(testCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, "pending", queued.Status)
+	assert.Equal(t, address, queued.Recipient)
+
+	sent, ok := waitForEmail("test", "sent")
+	assert.True(t, ok, "test email was not sent")
+	assert.Equal(t, queued.ID, sent.ID)
+	assert.Equal(t, 1, sent.Attempts)
+	assert.NotNil(t, sent.SentAt)
+
+	if mailhogURL := os.Getenv("TEST_MAILHOG_API_URL"); mailhogURL != "" {
+		response, err := http.Get(fmt.Sprintf("%s/api/v2/search?kind=to&query=%s", mailhogURL, address))
+		if assert.NoError(t, err) {
+			defer response.Body.Close()
+			var caught struct {
+				Items []struct {
+					Content struct {
+						Headers map[string][]string
+					}
+				}
+			}
+			assert.NoError(t, json.NewDecoder(response.Body).Decode(&caught))
+			if assert.Len(t, caught.Items, 1) {
+				assert.Equal(t, []string{sent.Subject}, caught.Items[0].Content.Headers["Subject"])
+			}
+		}
+	}
+
+	// Step 3: subscriptions are validated and can't be repeated
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = userClient.AddSensor(testCtx, sensorID, "Notification Test Sensor", "Field 1", nil)
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	_, err = userClient.CreateNotificationSubscription(testCtx, api.CreateNotificationSubscriptionRequest{Kind: "daily_digest", SensorID: sensorID})
+	assert.Error(t, err)
+	_, err = userClient.CreateNotificationSubscription(testCtx, api.CreateNotificationSubscriptionRequest{Kind: "battery_low", SensorID: "no-such-sensor"})
+	assert.Error(t, err)
+
+	subscription, err := userClient.CreateNotificationSubscription(testCtx, api.CreateNotificationSubscriptionRequest{Kind: "battery_low", SensorID: sensorID})
+	assert.NoError(t, err)
+	assert.Equal(t, sensorID, subscription.SensorID)
+	_, err = userClient.CreateNotificationSubscription(testCtx, api.CreateNotificationSubscriptionRequest{Kind: "battery_low", SensorID: sensorID})
+	assert.Error(t, err, "duplicate subscriptions are refused")
+
+	subscriptions, err := userClient.GetNotificationSubscriptions(testCtx)
+	assert.NoError(t, err)
+	assert.Len(t, subscriptions.Subscriptions, 1)
+
+	// Step 4: a low battery during quiet hours is emailed once they end
+	nairobi, err := time.LoadLocation("Africa/Nairobi")
+	assert.NoError(t, err)
+	localNow := time.Now().In(nairobi)
+	quietStart, quietEnd := localNow.Add(-time.Hour).Format("15:04"), localNow.Add(time.Hour).Format("15:04")
+	_, err = userClient.UpdateNotificationPreferences(testCtx, api.UpdateNotificationPreferencesRequest{
+		Email:           address,
+		TimeZone:        "Africa/Nairobi",
+		QuietHoursStart: quietStart,
+		QuietHoursEnd:   quietEnd,
+	})
+	assert.NoError(t, err)
+
+	saveBattery := func(value float64) {
+		_, err := userClient.SetSensorMeasurements(testCtx, sensorID, database.MeasurementTypeBatteryLevel, api.SetSensorMeasurementsRequest{
+			Measurements: []api.SensorMeasurement{{Date: time.Now().UTC().Truncate(time.Second), Value: value}},
+		})
+		assert.NoError(t, err)
+		time.Sleep(time.Second)
+	}
+
+	saveBattery(15)
+	held, ok := waitForEmail("battery_low", "pending")
+	assert.True(t, ok, "low battery email was not queued")
+	assert.Contains(t, held.Subject, "15%")
+	if assert.NotNil(t, held.NextAttemptAt) {
+		assert.Equal(t, quietEnd, held.NextAttemptAt.In(nairobi).Format("15:04"))
+	}
+
+	// the battery staying low isn't emailed again
+	saveBattery(14)
+	emails, err := userClient.GetEmails(testCtx, api.EmailsQuery{})
+	assert.NoError(t, err)
+	batteryEmails := 0
+	for _, outgoing := range emails.Emails {
+		if outgoing.Kind == "battery_low" {
+			batteryEmails++
+		}
+	}
+	assert.Equal(t, 1, batteryEmails)
+
+	// Step 5: unsubscribing stops the notifications
+	err = userClient.DeleteNotificationSubscription(testCtx, subscription.ID)
+	assert.NoError(t, err)
+	err = userClient.DeleteNotificationSubscription(testCtx, subscription.ID)
+	assert.Error(t, err)
+}
+
+func TestE2EBatteryForecastIgnoresSwapsAndListsSensorsDueForASwap(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	dischargingSensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	depletedSensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	defer database.DeleteSensor(testCtx, databaseClient.DB, dischargingSensorID)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, depletedSensorID)
+
+	// the old battery ran down 5 a day until it was swapped 10 days ago,
+	// the new one has fallen 2 a day since
+	lastReadingAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	var readings []api.BatteryLevelData
+	for day, level := range []float64{30, 25, 20, 15} {
+		readings = append(readings, api.BatteryLevelData{Date: lastReadingAt.AddDate(0, 0, day-14), BatteryLevel: level})
+	}
+	for day := 10; day >= 0; day-- {
+		readings = append(readings, api.BatteryLevelData{Date: lastReadingAt.AddDate(0, 0, -day), BatteryLevel: 95 - 2*float64(10-day)})
+	}
+	_, err = testClient.SetSensorBatteryData(testCtx, dischargingSensorID, api.SetBatteryLevelDataResponse{BatteryLevelData: readings})
+	assert.NoError(t, err)
+
+	_, err = testClient.SetSensorBatteryData(testCtx, depletedSensorID, api.SetBatteryLevelDataResponse{
+		BatteryLevelData: []api.BatteryLevelData{{Date: lastReadingAt, BatteryLevel: 5}},
+	})
+	assert.NoError(t, err)
+
+	// Step 1: the forecast is fitted to the new battery's readings only
+	forecast, err := testClient.GetSensorBatteryForecast(testCtx, dischargingSensorID, api.BatteryForecastQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, "forecast", forecast.Status)
+	assert.Equal(t, 10.0, forecast.Cutoff)
+	assert.Equal(t, 11, forecast.ReadingsUsed)
+	assert.True(t, lastReadingAt.AddDate(0, 0, -10).Equal(*forecast.LastSwapAt))
+	assert.Equal(t, 2.0, *forecast.DischargeRatePerDay)
+	assert.True(t, lastReadingAt.Add(32*24*time.Hour+12*time.Hour).Equal(*forecast.PredictedAt))
+	assert.False(t, forecast.EarliestAt.After(*forecast.PredictedAt))
+	assert.False(t, forecast.LatestAt.Before(*forecast.PredictedAt))
+
+	// a higher cutoff is reached sooner
+	forecast, err = testClient.GetSensorBatteryForecast(testCtx, dischargingSensorID, api.BatteryForecastQuery{Cutoff: 55})
+	assert.NoError(t, err)
+	assert.True(t, lastReadingAt.Add(10*24*time.Hour).Equal(*forecast.PredictedAt))
+
+	forecast, err = testClient.GetSensorBatteryForecast(testCtx, depletedSensorID, api.BatteryForecastQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, "below_cutoff", forecast.Status)
+
+	_, err = testClient.GetSensorBatteryForecast(testCtx, "no-such-sensor", api.BatteryForecastQuery{})
+	assert.Error(t, err)
+
+	// Step 2: the fleet forecast lists the sensors due for a swap within the days asked for
+	dueSensorIDs := func(withinDays int) []string {
+		response, err := testClient.GetBatteryForecasts(testCtx, api.BatteryForecastQuery{WithinDays: withinDays})
+		assert.NoError(t, err)
+		assert.Equal(t, withinDays, response.WithinDays)
+
+		var sensorIDs []string
+		for _, sensor := range response.Sensors {
+			if sensor.SensorID == dischargingSensorID || sensor.SensorID == depletedSensorID {
+				sensorIDs = append(sensorIDs, sensor.SensorID)
+			}
+		}
+		return sensorIDs
+	}
+	assert.Equal(t, []string{depletedSensorID}, dueSensorIDs(7))
+	assert.Equal(t, []string{depletedSensorID, dischargingSensorID}, dueSensorIDs(45))
+}
+
+func TestE2ESetSensorMeasurementsIsIdempotent(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	baseTime := time.Now().UTC().Truncate(time.Second)
+	batch := api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{
+			{Date: baseTime.Add(-30 * time.Minute), Value: 20},
+			{Date: baseTime.Add(-20 * time.Minute), Value: 21},
+			{Date: baseTime.Add(-20 * time.Minute), Value: 22}, // repeats the reading before it
+			{Value: 23}, // has no date
+		},
+	}
+
+	// Step 1: the first send inserts the distinct readings
+	result, err := testClient.SetSensorMeasurements(testCtx, sensorID, "soil_temperature", batch)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Inserted)
+	assert.Equal(t, 1, result.Duplicates)
+	assert.Equal(t, 1, result.Rejected)
+	if assert.Equal(t, 4, len(result.Results)) {
+		assert.Equal(t, "inserted", result.Results[0].Status)
+		assert.NotZero(t, result.Results[0].ID)
+		assert.Equal(t, "inserted", result.Results[1].Status)
+		assert.Equal(t, "duplicate", result.Results[2].Status)
+		assert.Equal(t, "rejected", result.Results[3].Status)
+	}
+
+	// Step 2: re-sending the batch, as a gateway does on timeout, stores nothing new
+	result, err = testClient.SetSensorMeasurements(testCtx, sensorID, "soil_temperature", batch)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Inserted)
+	assert.Equal(t, 3, result.Duplicates)
+
+	gotMeasurements, err := testClient.GetSensorMeasurements(testCtx, sensorID, "soil_temperature", api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(gotMeasurements.Measurements)) {
+		assert.Equal(t, float64(21), gotMeasurements.Measurements[1].Value, "the first of the repeated readings should be kept")
+	}
+
+	// Step 3: on conflict update replaces stored values
+	batch.OnConflict = "update"
+	result, err = testClient.SetSensorMeasurements(testCtx, sensorID, "soil_temperature", batch)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Updated)
+
+	gotMeasurements, err = testClient.GetSensorMeasurements(testCtx, sensorID, "soil_temperature", api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(gotMeasurements.Measurements)) {
+		assert.Equal(t, float64(22), gotMeasurements.Measurements[1].Value, "the last of the repeated readings should be kept")
+	}
+
+	// Step 4: the legacy routes report the same results
+	moistureResult, err := testClient.SetSensorMoistureData(testCtx, sensorID, api.SetSensorMoistureDataResponse{
+		SensorMoistureData: []api.SensorMoistureData{{Date: baseTime, SoilMoisture: 30}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moistureResult.Inserted)
+
+	moistureResult, err = testClient.SetSensorMoistureData(testCtx, sensorID, api.SetSensorMoistureDataResponse{
+		SensorMoistureData: []api.SensorMoistureData{{Date: baseTime, SoilMoisture: 30}},
+	})
+	assert.NoError(t, err, "duplicate readings should no longer fail the request")
+	assert.Equal(t, 1, moistureResult.Duplicates)
+}
+
+func TestE2EExportSensorData(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorIDs := []string{uuid.New().String()[:8] + uuid.New().String()[:8], uuid.New().String()[:8] + uuid.New().String()[:8]}
+	baseTime := time.Now().UTC().Truncate(time.Second)
+	for _, sensorID := range sensorIDs {
+		_, err = testClient.SetSensorMoistureData(testCtx, sensorID, api.SetSensorMoistureDataResponse{
+			SensorMoistureData: []api.SensorMoistureData{
+				{Date: baseTime.Add(-2 * time.Minute), SoilMoisture: 40},
+				{Date: baseTime.Add(-1 * time.Minute), SoilMoisture: 41},
+			},
+		})
+		assert.NoError(t, err)
+
+		_, err = testClient.SetSensorBatteryData(testCtx, sensorID, api.SetBatteryLevelDataResponse{
+			BatteryLevelData: []api.BatteryLevelData{{Date: baseTime, BatteryLevel: 90}},
+		})
+		assert.NoError(t, err)
+	}
+
+	// Step 1: export the moisture readings as csv
+	var csvExport bytes.Buffer
+	err = testClient.ExportSensorData(testCtx, api.SensorDataExportQuery{
+		SensorIDs:        sensorIDs,
+		MeasurementTypes: []string{"moisture"},
+	}, &csvExport)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(csvExport.String()), "\n")
+	if assert.Equal(t, 5, len(lines), "expected a header and two readings per sensor") {
+		assert.Equal(t, "sensor_id,measurement_type,date,value,unit", lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], sensorIDs[0]+",soil_moisture,") || strings.HasPrefix(lines[1], sensorIDs[1]+",soil_moisture,"))
+	}
+
+	// Step 2: export every type for one sensor as ndjson
+	var ndjsonExport bytes.Buffer
+	err = testClient.ExportSensorData(testCtx, api.SensorDataExportQuery{
+		SensorIDs: sensorIDs[:1],
+		Start:     baseTime.Add(-90 * time.Second),
+		Format:    "ndjson",
+	}, &ndjsonExport)
+	assert.NoError(t, err)
+
+	var rows []api.SensorDataExportRow
+	decoder := json.NewDecoder(&ndjsonExport)
+	for decoder.More() {
+		var row api.SensorDataExportRow
+		assert.NoError(t, decoder.Decode(&row))
+		rows = append(rows, row)
+	}
+	if assert.Equal(t, 2, len(rows)) {
+		assert.Equal(t, "battery_level", rows[0].MeasurementType)
+		assert.Equal(t, float64(90), rows[0].Value)
+		assert.Equal(t, "soil_moisture", rows[1].MeasurementType)
+		assert.Equal(t, float64(41), rows[1].Value)
+	}
+
+	// Step 3: invalid requests are rejected before streaming starts
+	err = testClient.ExportSensorData(testCtx, api.SensorDataExportQuery{Format: "xlsx"}, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestE2EImportSensorDataFromCSV(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = testClient.AddSensor(testCtx, sensorID, "Imported Sensor", "Field 3", nil)
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	unregisteredSensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	file := strings.Join([]string{
+		"device,logged_at,moisture,temperature",
+		sensorID + ",2024-06-01 00:00:00,35.5,18.25",
+		sensorID + ",2024-06-01 01:00:00,35.0,",
+		sensorID + ",2024-06-01 02:00:00,dry,17.5",
+		unregisteredSensorID + ",2024-06-01 02:00:00,30,17",
+		sensorID + ",June 1st,34,17",
+	}, "\n")
+
+	// Step 1: import a wide file of readings from an sd card
+	job, err := testClient.ImportSensorData(testCtx, "sd_card.csv", strings.NewReader(file), api.SensorDataImportMapping{
+		SensorIDColumn: "device",
+		DateColumn:     "logged_at",
+		DateFormat:     "2006-01-02 15:04:05",
+		ValueColumns:   map[string]string{"moisture": "moisture", "temperature": "soil_temperature"},
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, job.TotalRows)
+	assert.Equal(t, testUserName, job.CreatedBy)
+
+	// Step 2: poll until the job finishes
+	for i := 0; i < 50 && job.Status != "completed" && job.Status != "failed"; i++ {
+		time.Sleep(100 * time.Millisecond)
+		job, err = testClient.GetImportJob(testCtx, job.ID)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "completed", job.Status)
+	assert.Equal(t, 5, job.ProcessedRows)
+	assert.Equal(t, 3, job.InsertedReadings)
+	assert.Equal(t, 3, job.RejectedRows)
+
+	// Step 3: the rows that weren't saved are listed with their reasons
+	jobErrors, err := testClient.GetImportJobErrors(testCtx, job.ID, 0, 2)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(jobErrors.Errors)) && assert.NotNil(t, jobErrors.NextAfterRow) {
+		assert.Equal(t, 4, jobErrors.Errors[0].RowNumber)
+		assert.Contains(t, jobErrors.Errors[0].Message, "soil_moisture value \"dry\"")
+		assert.Equal(t, 5, jobErrors.Errors[1].RowNumber)
+		assert.Contains(t, jobErrors.Errors[1].Message, "is not registered")
+
+		jobErrors, err = testClient.GetImportJobErrors(testCtx, job.ID, *jobErrors.NextAfterRow, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(jobErrors.Errors))
+		assert.Nil(t, jobErrors.NextAfterRow)
+	}
+
+	// Step 4: the valid rows were saved as readings
+	moisture, err := testClient.GetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(moisture.Measurements)) {
+		assert.True(t, moisture.Measurements[0].Date.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(t, 35.5, moisture.Measurements[0].Value)
+	}
+
+	// Step 5: mappings that don't match the file are rejected up front
+	_, err = testClient.ImportSensorData(testCtx, "sd_card.csv", strings.NewReader(file), api.SensorDataImportMapping{
+		SensorID:     sensorID,
+		DateColumn:   "timestamp",
+		ValueColumns: map[string]string{"moisture": "moisture"},
+	}, "")
+	assert.Error(t, err)
+}
+
+func TestE2EQuerySensors(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorIDs := []string{uuid.New().String()[:8] + uuid.New().String()[:8], uuid.New().String()[:8] + uuid.New().String()[:8]}
+	baseTime := time.Now().UTC().Truncate(time.Hour)
+	for i, sensorID := range sensorIDs {
+		_, err = testClient.SetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SetSensorMeasurementsRequest{
+			Measurements: []api.SensorMeasurement{
+				{Date: baseTime.Add(-2 * time.Hour), Value: float64(30 + i)},
+				{Date: baseTime.Add(-90 * time.Minute), Value: float64(40 + i)},
+				{Date: baseTime.Add(-1 * time.Hour), Value: float64(50 + i)},
+			},
+		})
+		assert.NoError(t, err)
+	}
+	_, err = testClient.SetSensorMeasurements(testCtx, sensorIDs[0], "battery_level", api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: baseTime.Add(-1 * time.Hour), Value: 88}},
+	})
+	assert.NoError(t, err)
+
+	// Step 1: query raw readings of both sensors in one request
+	result, err := testClient.QuerySensors(testCtx, api.QuerySensorsRequest{
+		SensorIDs:        sensorIDs,
+		MeasurementTypes: []string{"moisture", "battery"},
+		Start:            baseTime.Add(-100 * time.Minute),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "raw", result.Resolution)
+	if assert.Equal(t, 4, len(result.Series)) {
+		assert.Equal(t, sensorIDs[0], result.Series[0].SensorID)
+		assert.Equal(t, "soil_moisture", result.Series[0].MeasurementType)
+		assert.Equal(t, "%", result.Series[0].Unit)
+		if assert.Equal(t, 2, len(result.Series[0].Measurements)) {
+			assert.Equal(t, float64(40), result.Series[0].Measurements[0].Value)
+		}
+		assert.NotNil(t, result.Series[0].IsOnline)
+
+		assert.Equal(t, "battery_level", result.Series[1].MeasurementType)
+		assert.Equal(t, 1, len(result.Series[1].Measurements))
+
+		assert.Equal(t, sensorIDs[1], result.Series[2].SensorID)
+		assert.Equal(t, 2, len(result.Series[2].Measurements))
+
+		// the second sensor has no battery readings but its series is still returned
+		assert.Equal(t, "battery_level", result.Series[3].MeasurementType)
+		assert.Empty(t, result.Series[3].Measurements)
+		assert.Nil(t, result.Series[3].IsOnline)
+	}
+
+	// Step 2: query hourly buckets
+	result, err = testClient.QuerySensors(testCtx, api.QuerySensorsRequest{
+		SensorIDs:        sensorIDs[1:],
+		MeasurementTypes: []string{"soil_moisture"},
+		Resolution:       "hourly",
+	})
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(result.Series)) && assert.Equal(t, 2, len(result.Series[0].Buckets)) {
+		assert.Equal(t, 2, result.Series[0].Buckets[0].Count)
+		assert.Equal(t, float64(36), result.Series[0].Buckets[0].Avg)
+		assert.Equal(t, float64(51), result.Series[0].Buckets[1].Max)
+	}
+
+	// Step 3: invalid queries are rejected
+	_, err = testClient.QuerySensors(testCtx, api.QuerySensorsRequest{MeasurementTypes: []string{"moisture"}})
+	assert.Error(t, err)
+}
+
+func TestE2EGetSensorsStatus(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	onlineSensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	offlineSensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	neverSeenSensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	now := time.Now().UTC().Truncate(time.Second)
+
+	err = testClient.AddSensor(testCtx, neverSeenSensorID, "Never Seen", "Shed", nil)
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, neverSeenSensorID)
+
+	// the online sensor came back after a gap of two days
+	_, err = testClient.SetSensorMeasurements(testCtx, onlineSensorID, "soil_moisture", api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{
+			{Date: now.Add(-72 * time.Hour), Value: 30},
+			{Date: now.Add(-3 * time.Hour), Value: 31},
+		},
+	})
+	assert.NoError(t, err)
+	_, err = testClient.SetSensorMeasurements(testCtx, onlineSensorID, "soil_moisture", api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: now.Add(-1 * time.Hour), Value: 32}},
+	})
+	assert.NoError(t, err)
+	_, err = testClient.SetSensorBatteryData(testCtx, onlineSensorID, api.SetBatteryLevelDataResponse{
+		BatteryLevelData: []api.BatteryLevelData{{Date: now.Add(-2 * time.Hour), BatteryLevel: 76}},
+	})
+	assert.NoError(t, err)
+
+	_, err = testClient.SetSensorMeasurements(testCtx, offlineSensorID, "soil_temperature", api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: now.Add(-48 * time.Hour), Value: 12}},
+	})
+	assert.NoError(t, err)
+
+	// Step 1: get the status of every sensor
+	status, err := testClient.GetSensorsStatus(testCtx, "")
+	assert.NoError(t, err)
+
+	statuses := make(map[string]api.SensorStatus)
+	for _, sensorStatus := range status.Sensors {
+		statuses[sensorStatus.SensorID] = sensorStatus
+	}
+
+	online := statuses[onlineSensorID]
+	assert.Equal(t, "online", online.State)
+	assert.True(t, online.IsOnline)
+	if assert.NotNil(t, online.LastSeen) && assert.NotNil(t, online.StateSince) {
+		assert.True(t, online.LastSeen.Equal(now.Add(-1*time.Hour)))
+		assert.True(t, online.StateSince.Equal(now.Add(-3*time.Hour)))
+	}
+	if assert.NotNil(t, online.BatteryLevel) {
+		assert.Equal(t, float64(76), *online.BatteryLevel)
+	}
+	assert.Equal(t, 2, len(online.LastReadings))
+
+	offline := statuses[offlineSensorID]
+	assert.Equal(t, "offline", offline.State)
+	if assert.NotNil(t, offline.StateSince) {
+		assert.True(t, offline.StateSince.Equal(now.Add(-24*time.Hour)))
+	}
+	assert.InDelta(t, 24*60*60, offline.StateDurationSeconds, 60)
+	assert.Nil(t, offline.BatteryLevel)
+
+	neverSeen := statuses[neverSeenSensorID]
+	assert.Equal(t, "never_seen", neverSeen.State)
+	assert.Empty(t, neverSeen.LastReadings)
+
+	// Step 2: filter on state
+	status, err = testClient.GetSensorsStatus(testCtx, "offline")
+	assert.NoError(t, err)
+	for _, sensorStatus := range status.Sensors {
+		assert.Equal(t, "offline", sensorStatus.State)
+	}
+
+	_, err = testClient.GetSensorsStatus(testCtx, "sleeping")
+	assert.Error(t, err)
+}
+
+func TestE2EUpdateSensorReportingInterval(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = testClient.AddSensor(testCtx, sensorID, "Fast Probe", "Bed 2", nil)
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	_, err = testClient.SetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: time.Now().Add(-30 * time.Minute), Value: 25}},
+	})
+	assert.NoError(t, err)
+
+	// Step 1: with the default interval a reading from half an hour ago is online
+	readings, err := testClient.GetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, readings.IsOnline) {
+		assert.True(t, *readings.IsOnline)
+	}
+
+	// Step 2: the probe reports every 10 minutes with 5 minutes grace
+	interval, grace := 600, 300
+	sensor, err := testClient.UpdateSensor(testCtx, sensorID, api.UpdateSensorRequest{
+		ReportingIntervalSeconds: &interval,
+		OfflineGraceSeconds:      &grace,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 600, sensor.ReportingIntervalSeconds)
+	assert.Equal(t, 300, sensor.OfflineGraceSeconds)
+	assert.Equal(t, "Fast Probe", sensor.Name)
+
+	// Step 3: the same reading is now too old
+	readings, err = testClient.GetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, readings.IsOnline) {
+		assert.False(t, *readings.IsOnline)
+	}
+
+	status, err := testClient.GetSensorsStatus(testCtx, "offline")
+	assert.NoError(t, err)
+	var found bool
+	for _, sensorStatus := range status.Sensors {
+		found = found || sensorStatus.SensorID == sensorID
+	}
+	assert.True(t, found, "expected sensor to be offline")
+
+	// Step 4: invalid settings and unknown sensors are rejected
+	invalid := 0
+	_, err = testClient.UpdateSensor(testCtx, sensorID, api.UpdateSensorRequest{ReportingIntervalSeconds: &invalid})
+	assert.Error(t, err)
+
+	_, err = testClient.UpdateSensor(testCtx, uuid.New().String()[:16], api.UpdateSensorRequest{ReportingIntervalSeconds: &interval})
+	assert.Error(t, err)
+}
+
+func TestE2EUpdateSensorMetadata(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = testClient.AddSensor(testCtx, sensorID, "Plot Probe", "Plot 1", &api.SensorCoordinates{Latitude: 47.6, Longitude: -122.3})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	sensor, err := database.GetSensorByID(testCtx, databaseClient.DB, sensorID)
+	assert.NoError(t, err)
+	assert.Equal(t, 47.6, sensor.Latitude)
+	assert.Equal(t, -122.3, sensor.Longitude)
+
+	// Step 1: rename and move the sensor
+	name, location := "Plot Probe North", "Plot 4"
+	latitude, longitude := -33.9, 18.4
+	installationDate := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	updated, err := testClient.UpdateSensor(testCtx, sensorID, api.UpdateSensorRequest{
+		Name:             &name,
+		Location:         &location,
+		Latitude:         &latitude,
+		Longitude:        &longitude,
+		InstallationDate: &installationDate,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, name, updated.Name)
+	assert.Equal(t, location, updated.Location)
+	assert.Equal(t, latitude, updated.Latitude)
+	assert.Equal(t, longitude, updated.Longitude)
+	assert.True(t, installationDate.Equal(updated.InstallationDate))
+	assert.Equal(t, 3600, updated.ReportingIntervalSeconds)
+
+	// Step 2: invalid metadata is rejected and leaves the sensor unchanged
+	empty, badLatitude, badLongitude := " ", 91.0, -181.0
+	_, err = testClient.UpdateSensor(testCtx, sensorID, api.UpdateSensorRequest{Name: &empty})
+	assert.Error(t, err)
+	_, err = testClient.UpdateSensor(testCtx, sensorID, api.UpdateSensorRequest{Latitude: &badLatitude})
+	assert.Error(t, err)
+	_, err = testClient.UpdateSensor(testCtx, sensorID, api.UpdateSensorRequest{Longitude: &badLongitude})
+	assert.Error(t, err)
+
+	err = testClient.AddSensor(testCtx, uuid.New().String()[:16], "Lost Probe", "Nowhere", &api.SensorCoordinates{Latitude: 120})
+	assert.Error(t, err)
+
+	sensor, err = database.GetSensorByID(testCtx, databaseClient.DB, sensorID)
+	assert.NoError(t, err)
+	assert.Equal(t, name, sensor.Name)
+	assert.Equal(t, latitude, sensor.Latitude)
+}
+
 func TestE2EGetAllSensors(t *testing.T) {
 	// Step: 0 prepare test data
 	testClient := nexusClientGenerator()
@@ -570,7 +2672,7 @@ func TestE2EAddAndDeleteSensor(t *testing.T) {
 	testName := "Test Sensor " + testEUI
 	testLocation := "Test Location"
 
-	err = testClient.AddSensor(testCtx, testEUI, testName, testLocation)
+	err = testClient.AddSensor(testCtx, testEUI, testName, testLocation, nil)
 	assert.NoError(t, err, "Adding sensor should succeed")
 
 	// Step 2: Verify sensor was added by getting all sensors
@@ -603,6 +2705,186 @@ func TestE2EAddAndDeleteSensor(t *testing.T) {
 	}
 }
 
+func TestE2EDecommissionSensorKeepsHistory(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+	adminClient, adminUserName := createTestAdminUser(t)
+	defer cleanupTestUser(t, adminUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+	_, err = adminClient.Login(testCtx, api.LoginRequest{
+		Username: adminUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	sensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = testClient.AddSensor(testCtx, sensorID, "Broken Probe", "Bed 7", nil)
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
+
+	readingDate := time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Second)
+	_, err = testClient.SetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: readingDate, Value: 31}},
+	})
+	assert.NoError(t, err)
+
+	// Step 1: sensors in service can't be purged
+	err = adminClient.PurgeSensor(testCtx, sensorID)
+	assert.Error(t, err)
+
+	// Step 2: decommission the sensor
+	err = testClient.DecommissionSensor(testCtx, sensorID, "probe cracked")
+	assert.NoError(t, err)
+
+	err = testClient.DecommissionSensor(testCtx, sensorID, "probe cracked")
+	assert.Error(t, err, "a sensor can only be decommissioned once")
+
+	// Step 3: it is hidden from the sensor list unless asked for
+	sensors, err := testClient.GetAllSensors(testCtx)
+	assert.NoError(t, err)
+	for _, sensor := range sensors {
+		assert.NotEqual(t, sensorID, sensor.ID)
+	}
+
+	sensors, err = testClient.GetSensors(testCtx, true)
+	assert.NoError(t, err)
+	var decommissioned *api.Sensor
+	for i := range sensors {
+		if sensors[i].ID == sensorID {
+			decommissioned = &sensors[i]
+		}
+	}
+	if assert.NotNil(t, decommissioned) {
+		assert.NotNil(t, decommissioned.DecommissionedAt)
+		assert.Equal(t, "probe cracked", decommissioned.DecommissionReason)
+		assert.Equal(t, testUserName, decommissioned.DecommissionedBy)
+	}
+
+	// Step 4: its history is still queryable but live readings are refused
+	readings, err := testClient.GetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SensorDataQuery{})
+	assert.NoError(t, err)
+	if assert.Len(t, readings.Measurements, 1) {
+		assert.Equal(t, 31.0, readings.Measurements[0].Value)
+	}
+
+	_, err = testClient.SetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: time.Now(), Value: 32}},
+	})
+	assert.Error(t, err)
+
+	sensor, err := database.GetSensorByID(testCtx, databaseClient.DB, sensorID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Broken Probe", sensor.Name, "sensor should not be recreated")
+
+	// Step 5: it can be put back in service
+	recommissioned, err := testClient.RecommissionSensor(testCtx, sensorID)
+	assert.NoError(t, err)
+	assert.Nil(t, recommissioned.DecommissionedAt)
+	assert.Empty(t, recommissioned.DecommissionReason)
+
+	// Step 6: purging is admin only and removes the sensor and its history
+	err = testClient.DecommissionSensor(testCtx, sensorID, "replaced")
+	assert.NoError(t, err)
+
+	err = testClient.PurgeSensor(testCtx, sensorID)
+	assert.Error(t, err, "regular users can't purge sensors")
+
+	err = adminClient.PurgeSensor(testCtx, sensorID)
+	assert.NoError(t, err)
+
+	_, err = database.GetSensorByID(testCtx, databaseClient.DB, sensorID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestE2EReplaceSensorCarriesHistory(t *testing.T) {
+	// Step 0: prepare test data
+	testClient, testUserName := createTestRegularUser(t)
+	defer cleanupTestUser(t, testUserName)
+
+	_, err := testClient.Login(testCtx, api.LoginRequest{
+		Username: testUserName,
+		Password: "password123",
+	})
+	assert.NoError(t, err)
+
+	oldSensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	newSensorID := uuid.New().String()[:8] + uuid.New().String()[:8]
+	err = testClient.AddSensor(testCtx, oldSensorID, "Hole 12", "North field", &api.SensorCoordinates{Latitude: 40.1, Longitude: -88.2})
+	assert.NoError(t, err)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, oldSensorID)
+	defer database.DeleteSensor(testCtx, databaseClient.DB, newSensorID)
+
+	oldReadingDate := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+	_, err = testClient.SetSensorMeasurements(testCtx, oldSensorID, "soil_moisture", api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: oldReadingDate, Value: 20}},
+	})
+	assert.NoError(t, err)
+
+	// Step 1: the new probe reports before anyone records the replacement, auto-creating it
+	newReadingDate := time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Second)
+	_, err = testClient.SetSensorMeasurements(testCtx, newSensorID, "soil_moisture", api.SetSensorMeasurementsRequest{
+		Measurements: []api.SensorMeasurement{{Date: newReadingDate, Value: 22}},
+	})
+	assert.NoError(t, err)
+
+	// Step 2: replace the old sensor with the new one
+	replacement, err := testClient.ReplaceSensor(testCtx, oldSensorID, newSensorID)
+	assert.NoError(t, err)
+	assert.Equal(t, newSensorID, replacement.ID)
+	assert.Equal(t, oldSensorID, replacement.ReplacesSensorID)
+	assert.Equal(t, "Hole 12", replacement.Name)
+	assert.Equal(t, "North field", replacement.Location)
+	assert.Equal(t, 40.1, replacement.Latitude)
+	assert.Equal(t, -88.2, replacement.Longitude)
+
+	oldSensor, err := database.GetSensorByID(testCtx, databaseClient.DB, oldSensorID)
+	assert.NoError(t, err)
+	assert.True(t, oldSensor.IsDecommissioned())
+	assert.Equal(t, "replaced by "+newSensorID, oldSensor.DecommissionReason)
+
+	// Step 3: a sensor can only be replaced once
+	_, err = testClient.ReplaceSensor(testCtx, oldSensorID, uuid.New().String()[:16])
+	assert.Error(t, err)
+
+	// Step 4: each sensor's own history is unchanged by default
+	readings, err := testClient.GetSensorMeasurements(testCtx, newSensorID, "soil_moisture", api.SensorDataQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, readings.Measurements, 1)
+
+	// Step 5: the combined history is read from either end of the chain
+	for _, sensorID := range []string{newSensorID, oldSensorID} {
+		readings, err = testClient.GetSensorMeasurements(testCtx, sensorID, "soil_moisture", api.SensorDataQuery{IncludeReplaced: true})
+		assert.NoError(t, err)
+		if assert.Len(t, readings.Measurements, 2) {
+			assert.Equal(t, oldSensorID, readings.Measurements[0].SensorID)
+			assert.Equal(t, 20.0, readings.Measurements[0].Value)
+			assert.Equal(t, newSensorID, readings.Measurements[1].SensorID)
+			assert.Equal(t, 22.0, readings.Measurements[1].Value)
+		}
+	}
+
+	queried, err := testClient.QuerySensors(testCtx, api.QuerySensorsRequest{
+		SensorIDs:        []string{newSensorID},
+		MeasurementTypes: []string{"soil_moisture"},
+		Resolution:       "daily",
+		IncludeReplaced:  true,
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, queried.Series, 1) {
+		var count int
+		for _, bucket := range queried.Series[0].Buckets {
+			count += bucket.Count
+		}
+		assert.Equal(t, 2, count)
+	}
+}
+
 func TestE2ESetAndGetSensorBatteryData(t *testing.T) {
 	// Step: 0 prepare test data
 	testClient := nexusClientGenerator()
@@ -663,13 +2945,15 @@ func TestE2ESetAndGetSensorBatteryData(t *testing.T) {
 	}
 
 	// Step 1: POST (Set) battery data
-	err = testClient.SetSensorBatteryData(testCtx, sensorID, expectedBatteryData)
+	_, err = testClient.SetSensorBatteryData(testCtx, sensorID, expectedBatteryData)
 	assert.NoError(t, err, "Setting battery data should succeed")
 
 	// Step 2: GET battery data
-	startDate := time.Now().AddDate(0, 0, -1).Format("2006-01-02") // Yesterday
-	endDate := time.Now().AddDate(0, 0, 1).Format("2006-01-02")    // Tomorrow
-	gotBatteryData, err := testClient.GetSensorBatteryData(testCtx, sensorID, startDate, endDate)
+	batteryQuery := api.SensorDataQuery{
+		Start: time.Now().AddDate(0, 0, -1), // Yesterday
+		End:   time.Now().AddDate(0, 0, 1),  // Tomorrow
+	}
+	gotBatteryData, err := testClient.GetSensorBatteryData(testCtx, sensorID, batteryQuery)
 	assert.NoError(t, err, "Retrieving battery data should succeed")
 
 	// Step 3: Compare battery data
@@ -689,6 +2973,24 @@ func TestE2ESetAndGetSensorBatteryData(t *testing.T) {
 				"Date should be within 1 second of expected")
 		}
 	}
+
+	// Step 4: page through the battery data newest first
+	batteryQuery.Limit = 1
+	batteryQuery.Order = "desc"
+	firstPage, err := testClient.GetSensorBatteryData(testCtx, sensorID, batteryQuery)
+	assert.NoError(t, err)
+	if assert.Len(t, firstPage.BatteryLevelData, 1) {
+		assert.Equal(t, 90.0, firstPage.BatteryLevelData[0].BatteryLevel)
+	}
+	assert.NotEmpty(t, firstPage.NextCursor)
+
+	batteryQuery.Cursor = firstPage.NextCursor
+	secondPage, err := testClient.GetSensorBatteryData(testCtx, sensorID, batteryQuery)
+	assert.NoError(t, err)
+	if assert.Len(t, secondPage.BatteryLevelData, 1) {
+		assert.Equal(t, 85.5, secondPage.BatteryLevelData[0].BatteryLevel)
+	}
+	assert.Empty(t, secondPage.NextCursor)
 }
 
 func TestE2EAutoCreateSensorOnlyWhenOnline(t *testing.T) {
@@ -734,15 +3036,15 @@ func TestE2EAutoCreateSensorOnlyWhenOnline(t *testing.T) {
 	}
 
 	// Verify sensor doesn't exist before
-	_, err = testClient.GetSensorMoistureData(testCtx, recentSensorID)
+	_, err = testClient.GetSensorMoistureData(testCtx, recentSensorID, api.SensorDataQuery{})
 	assert.Error(t, err, "Sensor should not exist before auto-creation")
 
 	// Set data with recent timestamp - should auto-create sensor
-	err = testClient.SetSensorMoistureData(testCtx, recentSensorID, recentData)
+	_, err = testClient.SetSensorMoistureData(testCtx, recentSensorID, recentData)
 	assert.NoError(t, err, "Setting recent sensor data should succeed and auto-create sensor")
 
 	// Verify sensor was auto-created by trying to get it
-	gotData, err := testClient.GetSensorMoistureData(testCtx, recentSensorID)
+	gotData, err := testClient.GetSensorMoistureData(testCtx, recentSensorID, api.SensorDataQuery{})
 	assert.NoError(t, err, "Sensor should exist after auto-creation with recent data")
 	assert.NotNil(t, gotData, "Sensor data should be retrievable")
 
@@ -772,18 +3074,18 @@ func TestE2EAutoCreateSensorOnlyWhenOnline(t *testing.T) {
 	}
 
 	// Verify sensor doesn't exist before
-	_, err = testClient.GetSensorMoistureData(testCtx, oldSensorID)
+	_, err = testClient.GetSensorMoistureData(testCtx, oldSensorID, api.SensorDataQuery{})
 	assert.Error(t, err, "Sensor should not exist before attempting to set old data")
 
 	// Set data with old timestamp - should log warning but fallback to creating sensor
 	// (Based on current implementation, it falls back to EnsureSensorExists)
-	err = testClient.SetSensorMoistureData(testCtx, oldSensorID, oldData)
+	_, err = testClient.SetSensorMoistureData(testCtx, oldSensorID, oldData)
 	// The current implementation falls back to EnsureSensorExists, so it will succeed
 	// but log a warning. In a stricter implementation, this could fail.
 	assert.NoError(t, err, "Setting old sensor data should succeed (with fallback)")
 
 	// Verify sensor was created (due to fallback behavior)
-	gotOldData, err := testClient.GetSensorMoistureData(testCtx, oldSensorID)
+	gotOldData, err := testClient.GetSensorMoistureData(testCtx, oldSensorID, api.SensorDataQuery{})
 	assert.NoError(t, err, "Sensor should exist after fallback creation")
 	assert.NotNil(t, gotOldData, "Sensor data should be retrievable")
 
@@ -800,15 +3102,15 @@ func TestE2EAutoCreateSensorOnlyWhenOnline(t *testing.T) {
 	}
 
 	// Verify sensor doesn't exist before
-	_, err = testClient.GetSensorMoistureData(testCtx, veryRecentSensorID)
+	_, err = testClient.GetSensorMoistureData(testCtx, veryRecentSensorID, api.SensorDataQuery{})
 	assert.Error(t, err, "Sensor should not exist before auto-creation")
 
 	// Set data with very recent timestamp - should auto-create sensor
-	err = testClient.SetSensorMoistureData(testCtx, veryRecentSensorID, veryRecentData)
+	_, err = testClient.SetSensorMoistureData(testCtx, veryRecentSensorID, veryRecentData)
 	assert.NoError(t, err, "Setting very recent sensor data should succeed and auto-create sensor")
 
 	// Verify sensor was auto-created
-	gotVeryRecentData, err := testClient.GetSensorMoistureData(testCtx, veryRecentSensorID)
+	gotVeryRecentData, err := testClient.GetSensorMoistureData(testCtx, veryRecentSensorID, api.SensorDataQuery{})
 	assert.NoError(t, err, "Sensor should exist after auto-creation with very recent data")
 	assert.NotNil(t, gotVeryRecentData, "Sensor data should be retrievable")
 
@@ -825,15 +3127,15 @@ func TestE2EAutoCreateSensorOnlyWhenOnline(t *testing.T) {
 	}
 
 	// Verify sensor doesn't exist before
-	_, err = testClient.GetSensorTemperatureData(testCtx, recentTempSensorID)
+	_, err = testClient.GetSensorTemperatureData(testCtx, recentTempSensorID, api.SensorDataQuery{})
 	assert.Error(t, err, "Temperature sensor should not exist before auto-creation")
 
 	// Set temperature data with recent timestamp - should auto-create sensor
-	err = testClient.SetSensorTemperatureData(testCtx, recentTempSensorID, recentTempData)
+	_, err = testClient.SetSensorTemperatureData(testCtx, recentTempSensorID, recentTempData)
 	assert.NoError(t, err, "Setting recent temperature data should succeed and auto-create sensor")
 
 	// Verify sensor was auto-created
-	gotTempData, err := testClient.GetSensorTemperatureData(testCtx, recentTempSensorID)
+	gotTempData, err := testClient.GetSensorTemperatureData(testCtx, recentTempSensorID, api.SensorDataQuery{})
 	assert.NoError(t, err, "Temperature sensor should exist after auto-creation")
 	assert.NotNil(t, gotTempData, "Temperature sensor data should be retrievable")
 
@@ -849,17 +3151,19 @@ func TestE2EAutoCreateSensorOnlyWhenOnline(t *testing.T) {
 	}
 
 	// Verify sensor doesn't exist before
-	startDate := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
-	endDate := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
-	_, err = testClient.GetSensorBatteryData(testCtx, recentBatterySensorID, startDate, endDate)
+	batteryQuery := api.SensorDataQuery{
+		Start: time.Now().AddDate(0, 0, -1),
+		End:   time.Now().AddDate(0, 0, 1),
+	}
+	_, err = testClient.GetSensorBatteryData(testCtx, recentBatterySensorID, batteryQuery)
 	assert.Error(t, err, "Battery sensor should not exist before auto-creation")
 
 	// Set battery data with recent timestamp - should auto-create sensor
-	err = testClient.SetSensorBatteryData(testCtx, recentBatterySensorID, recentBatteryData)
+	_, err = testClient.SetSensorBatteryData(testCtx, recentBatterySensorID, recentBatteryData)
 	assert.NoError(t, err, "Setting recent battery data should succeed and auto-create sensor")
 
 	// Verify sensor was auto-created by checking battery data
-	gotBatteryData, err := testClient.GetSensorBatteryData(testCtx, recentBatterySensorID, startDate, endDate)
+	gotBatteryData, err := testClient.GetSensorBatteryData(testCtx, recentBatterySensorID, batteryQuery)
 	assert.NoError(t, err, "Battery sensor should exist after auto-creation")
 	assert.NotNil(t, gotBatteryData, "Battery sensor data should be retrievable")
 
@@ -873,7 +3177,7 @@ func TestE2EAutoCreateSensorOnlyWhenOnline(t *testing.T) {
 	}
 
 	for _, sensorID := range cleanupSensors {
-		err = testClient.DeleteSensor(testCtx, sensorID)
+		err = database.DeleteSensor(testCtx, databaseClient.DB, sensorID)
 		if err != nil {
 			// Log but don't fail test if cleanup fails
 			t.Logf("Warning: Failed to cleanup test sensor %s: %v", sensorID, err)
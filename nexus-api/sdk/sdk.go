@@ -10,6 +10,8 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -279,9 +281,10 @@ func (nc *NexusClient) SetPanelYieldData(ctx context.Context, panelID int, yield
 	return nil
 }
 
-// GetSensorMoistureData retrieves moisture data for a specific sensor
-func (nc *NexusClient) GetSensorMoistureData(ctx context.Context, sensorID string) (api.GetSensorMoistureDataResponse, error) {
-	endpoint := fmt.Sprintf("%s/sensors/%s/moisture_data", nc.Config.NexusAPIEndpoint, sensorID)
+// GetSensorMoistureData retrieves a page of moisture data for a specific sensor,
+// pass the returned NextCursor in query.Cursor to fetch the following page
+func (nc *NexusClient) GetSensorMoistureData(ctx context.Context, sensorID string, query api.SensorDataQuery) (api.GetSensorMoistureDataResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/moisture_data%s", nc.Config.NexusAPIEndpoint, sensorID, encodeSensorDataQuery(query))
 
 	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
@@ -313,41 +316,19 @@ func (nc *NexusClient) GetSensorMoistureData(ctx context.Context, sensorID strin
 }
 
 // SetSensorMoistureData saves moisture data for a specific sensor
-func (nc *NexusClient) SetSensorMoistureData(ctx context.Context, sensorID string, moistureData api.SetSensorMoistureDataResponse) error {
+func (nc *NexusClient) SetSensorMoistureData(ctx context.Context, sensorID string, moistureData api.SetSensorMoistureDataResponse) (api.SetSensorMeasurementsResponse, error) {
 	endpoint := fmt.Sprintf("%s/sensors/%s/moisture_data", nc.Config.NexusAPIEndpoint, sensorID)
 
-	body, err := json.Marshal(moistureData)
-	if err != nil {
-		return err
-	}
-
-	httpRequest, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(body))
-	if err != nil {
-		return err
-	}
-
-	httpRequest.Header.Set("Content-Type", "application/json")
-	err = SetAuthHeaders(httpRequest, nc.Cookie)
-	if err != nil {
-		return err
-	}
-
-	response, err := nc.http.Do(httpRequest)
-	if err != nil {
-		return err
-	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
-	}
+	var result api.SetSensorMeasurementsResponse
+	err := nc.doJSONRequest(ctx, "POST", endpoint, moistureData, &result)
 
-	return nil
+	return result, err
 }
 
-// GetSensorTemperatureData retrieves temperature data for a specific sensor
-func (nc *NexusClient) GetSensorTemperatureData(ctx context.Context, sensorID string) (api.GetSensorTemperatureDataResponse, error) {
-	endpoint := fmt.Sprintf("%s/sensors/%s/temperature_data", nc.Config.NexusAPIEndpoint, sensorID)
+// GetSensorTemperatureData retrieves a page of temperature data for a specific sensor,
+// pass the returned NextCursor in query.Cursor to fetch the following page
+func (nc *NexusClient) GetSensorTemperatureData(ctx context.Context, sensorID string, query api.SensorDataQuery) (api.GetSensorTemperatureDataResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/temperature_data%s", nc.Config.NexusAPIEndpoint, sensorID, encodeSensorDataQuery(query))
 
 	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
@@ -379,36 +360,13 @@ func (nc *NexusClient) GetSensorTemperatureData(ctx context.Context, sensorID st
 }
 
 // SetSensorTemperatureData saves temperature data for a specific sensor
-func (nc *NexusClient) SetSensorTemperatureData(ctx context.Context, sensorID string, temperatureData api.SetSensorTemperatureDataResponse) error {
+func (nc *NexusClient) SetSensorTemperatureData(ctx context.Context, sensorID string, temperatureData api.SetSensorTemperatureDataResponse) (api.SetSensorMeasurementsResponse, error) {
 	endpoint := fmt.Sprintf("%s/sensors/%s/temperature_data", nc.Config.NexusAPIEndpoint, sensorID)
 
-	body, err := json.Marshal(temperatureData)
-	if err != nil {
-		return err
-	}
-
-	httpRequest, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(body))
-	if err != nil {
-		return err
-	}
-
-	httpRequest.Header.Set("Content-Type", "application/json")
-	err = SetAuthHeaders(httpRequest, nc.Cookie)
-	if err != nil {
-		return err
-	}
-
-	response, err := nc.http.Do(httpRequest)
-	if err != nil {
-		return err
-	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
-	}
+	var result api.SetSensorMeasurementsResponse
+	err := nc.doJSONRequest(ctx, "POST", endpoint, temperatureData, &result)
 
-	return nil
+	return result, err
 }
 
 // GetPanelConsumptionData retrieves consumption data for a specific panel
@@ -477,9 +435,18 @@ func (nc *NexusClient) SetPanelConsumptionData(ctx context.Context, panelID int,
 	return nil
 }
 
-// GetAllSensors retrieves all sensors from the API
+// GetAllSensors retrieves all sensors in service from the API
 func (nc *NexusClient) GetAllSensors(ctx context.Context) ([]api.Sensor, error) {
+	return nc.GetSensors(ctx, false)
+}
+
+// GetSensors retrieves the sensors in service, and the decommissioned
+// sensors as well if includeDecommissioned is true
+func (nc *NexusClient) GetSensors(ctx context.Context, includeDecommissioned bool) ([]api.Sensor, error) {
 	endpoint := fmt.Sprintf("%s/sensors", nc.Config.NexusAPIEndpoint)
+	if includeDecommissioned {
+		endpoint += "?include_decommissioned=true"
+	}
 
 	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
@@ -510,14 +477,64 @@ func (nc *NexusClient) GetAllSensors(ctx context.Context) ([]api.Sensor, error)
 	return sensors, nil
 }
 
-// AddSensor creates a new sensor with the given EUI, name, and location
-func (nc *NexusClient) AddSensor(ctx context.Context, eui, name, location string) error {
+// sensorsQueryValues returns the query parameters selecting the sensors listed, zero values are left out
+func sensorsQueryValues(query api.SensorsQuery) url.Values {
+	params := url.Values{}
+	if query.IncludeDecommissioned {
+		params.Set("include_decommissioned", "true")
+	}
+	if len(query.BoundingBox) > 0 {
+		edges := make([]string, 0, len(query.BoundingBox))
+		for _, edge := range query.BoundingBox {
+			edges = append(edges, strconv.FormatFloat(edge, 'f', -1, 64))
+		}
+		params.Set("bbox", strings.Join(edges, ","))
+	}
+	if query.Near != nil {
+		params.Set("lat", strconv.FormatFloat(query.Near.Latitude, 'f', -1, 64))
+		params.Set("lon", strconv.FormatFloat(query.Near.Longitude, 'f', -1, 64))
+		params.Set("radius", strconv.FormatFloat(query.RadiusMeters, 'f', -1, 64))
+	}
+
+	return params
+}
+
+// FindSensors retrieves the sensors inside a bounding box and/or within a radius of a point
+func (nc *NexusClient) FindSensors(ctx context.Context, query api.SensorsQuery) ([]api.Sensor, error) {
+	endpoint := fmt.Sprintf("%s/sensors?%s", nc.Config.NexusAPIEndpoint, sensorsQueryValues(query).Encode())
+
+	var sensors []api.Sensor
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &sensors)
+
+	return sensors, err
+}
+
+// GetSensorsGeoJSON retrieves the sensors selected by the query as a GeoJSON FeatureCollection
+// holding each sensor's state and newest readings
+func (nc *NexusClient) GetSensorsGeoJSON(ctx context.Context, query api.SensorsQuery) (api.GeoJSONFeatureCollection, error) {
+	params := sensorsQueryValues(query)
+	params.Set("format", "geojson")
+	endpoint := fmt.Sprintf("%s/sensors?%s", nc.Config.NexusAPIEndpoint, params.Encode())
+
+	var collection api.GeoJSONFeatureCollection
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &collection)
+
+	return collection, err
+}
+
+// AddSensor creates a new sensor with the given EUI, name, and location,
+// placed at the given coordinates unless they are nil
+func (nc *NexusClient) AddSensor(ctx context.Context, eui, name, location string, coordinates *api.SensorCoordinates) error {
 	endpoint := fmt.Sprintf("%s/sensors", nc.Config.NexusAPIEndpoint)
 
-	requestBody := map[string]string{
-		"eui":      eui,
-		"name":     name,
-		"location": location,
+	requestBody := api.AddSensorRequest{
+		EUI:      eui,
+		Name:     name,
+		Location: location,
+	}
+	if coordinates != nil {
+		requestBody.Latitude = &coordinates.Latitude
+		requestBody.Longitude = &coordinates.Longitude
 	}
 
 	body, err := json.Marshal(requestBody)
@@ -550,31 +567,440 @@ func (nc *NexusClient) AddSensor(ctx context.Context, eui, name, location string
 	return nil
 }
 
-// DeleteSensor removes a sensor with the given ID
+// DeleteSensor decommissions the sensor with the given ID without giving a reason,
+// its history is kept until an admin purges it with PurgeSensor
 func (nc *NexusClient) DeleteSensor(ctx context.Context, sensorID string) error {
-	endpoint := fmt.Sprintf("%s/sensors/%s", nc.Config.NexusAPIEndpoint, sensorID)
+	return nc.DecommissionSensor(ctx, sensorID, "")
+}
 
-	request, err := http.NewRequestWithContext(ctx, "DELETE", endpoint, nil)
+// DecommissionSensor takes a sensor out of service, hiding it from the sensor list
+// and refusing its live readings while keeping its history
+func (nc *NexusClient) DecommissionSensor(ctx context.Context, sensorID string, reason string) error {
+	endpoint := fmt.Sprintf("%s/sensors/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID))
+
+	return nc.doJSONRequest(ctx, http.MethodDelete, endpoint, api.DecommissionSensorRequest{Reason: reason}, nil)
+}
+
+// RecommissionSensor puts a decommissioned sensor back in service
+func (nc *NexusClient) RecommissionSensor(ctx context.Context, sensorID string) (api.Sensor, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/recommission", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID))
+
+	var sensor api.Sensor
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, nil, &sensor)
+
+	return sensor, err
+}
+
+// ReplaceSensor links the sensor newSensorID to the sensor it was installed in place of,
+// copying the old sensor's name, location and coordinates and decommissioning it
+func (nc *NexusClient) ReplaceSensor(ctx context.Context, sensorID string, newSensorID string) (api.Sensor, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/replace", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID))
+
+	var sensor api.Sensor
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, api.ReplaceSensorRequest{NewSensorID: newSensorID}, &sensor)
+
+	return sensor, err
+}
+
+// GetSensorCalibrations lists a sensor's calibrations, of every measurement type when measurementTypeID is empty
+func (nc *NexusClient) GetSensorCalibrations(ctx context.Context, sensorID string, measurementTypeID string) (api.GetSensorCalibrationsResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/calibrations", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID))
+	if measurementTypeID != "" {
+		endpoint += "?measurement_type=" + url.QueryEscape(measurementTypeID)
+	}
+
+	var result api.GetSensorCalibrationsResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// CreateSensorCalibration adds a calibration applied to the sensor's readings taken from its effective date
+func (nc *NexusClient) CreateSensorCalibration(ctx context.Context, sensorID string, calibration api.CreateSensorCalibrationRequest) (api.SensorCalibration, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/calibrations", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID))
+
+	var result api.SensorCalibration
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, calibration, &result)
+
+	return result, err
+}
+
+// DeleteSensorCalibration removes a calibration of the sensor
+func (nc *NexusClient) DeleteSensorCalibration(ctx context.Context, sensorID string, calibrationID int64) error {
+	endpoint := fmt.Sprintf("%s/sensors/%s/calibrations/%d", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID), calibrationID)
+
+	return nc.doJSONRequest(ctx, http.MethodDelete, endpoint, nil, nil)
+}
+
+// coverageQueryValues returns the query parameters of a coverage report, zero values are left out
+func coverageQueryValues(start time.Time, end time.Time, measurementTypeID string) url.Values {
+	params := url.Values{}
+	if !start.IsZero() {
+		params.Set("start", start.Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		params.Set("end", end.Format(time.RFC3339))
+	}
+	if measurementTypeID != "" {
+		params.Set("measurement_type", measurementTypeID)
+	}
+
+	return params
+}
+
+// GetSensorCoverage reports the gaps in a sensor's readings between start and end, the week
+// before now when they are zero, counting readings of every type unless measurementTypeID is set
+func (nc *NexusClient) GetSensorCoverage(ctx context.Context, sensorID string, start time.Time, end time.Time, measurementTypeID string) (api.SensorCoverage, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/coverage?%s", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID), coverageQueryValues(start, end, measurementTypeID).Encode())
+
+	var result api.SensorCoverage
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetSensorsCoverage ranks every sensor in service by how completely it reported between
+// start and end, least complete first (admin only)
+func (nc *NexusClient) GetSensorsCoverage(ctx context.Context, start time.Time, end time.Time, measurementTypeID string) (api.GetSensorsCoverageResponse, error) {
+	endpoint := fmt.Sprintf("%s/admin/sensors/coverage?%s", nc.Config.NexusAPIEndpoint, coverageQueryValues(start, end, measurementTypeID).Encode())
+
+	var result api.GetSensorsCoverageResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetZones lists every zone and the sensors in service in it
+func (nc *NexusClient) GetZones(ctx context.Context) (api.GetZonesResponse, error) {
+	endpoint := fmt.Sprintf("%s/zones", nc.Config.NexusAPIEndpoint)
+
+	var result api.GetZonesResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetZone returns a zone and the sensors in service in it
+func (nc *NexusClient) GetZone(ctx context.Context, zoneID string) (api.Zone, error) {
+	endpoint := fmt.Sprintf("%s/zones/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(zoneID))
+
+	var result api.Zone
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// CreateZone adds a zone, sensors whose coordinates are inside its boundary are moved to it
+func (nc *NexusClient) CreateZone(ctx context.Context, zone api.CreateZoneRequest) (api.Zone, error) {
+	endpoint := fmt.Sprintf("%s/zones", nc.Config.NexusAPIEndpoint)
+
+	var result api.Zone
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, zone, &result)
+
+	return result, err
+}
+
+// UpdateZone changes the fields of a zone that are set in the request
+func (nc *NexusClient) UpdateZone(ctx context.Context, zoneID string, update api.UpdateZoneRequest) (api.Zone, error) {
+	endpoint := fmt.Sprintf("%s/zones/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(zoneID))
+
+	var result api.Zone
+	err := nc.doJSONRequest(ctx, http.MethodPatch, endpoint, update, &result)
+
+	return result, err
+}
+
+// DeleteZone deletes a zone, its sensors are moved to any other zone their coordinates are in
+func (nc *NexusClient) DeleteZone(ctx context.Context, zoneID string) error {
+	endpoint := fmt.Sprintf("%s/zones/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(zoneID))
+
+	return nc.doJSONRequest(ctx, http.MethodDelete, endpoint, nil, nil)
+}
+
+// AssignSensorZone assigns a sensor to a zone by hand, an empty zoneID places
+// the sensor in the zone its coordinates are in again
+func (nc *NexusClient) AssignSensorZone(ctx context.Context, sensorID string, zoneID string) (api.Sensor, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/zone", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID))
+
+	var request api.AssignSensorZoneRequest
+	if zoneID != "" {
+		request.ZoneID = &zoneID
+	}
+
+	var sensor api.Sensor
+	err := nc.doJSONRequest(ctx, http.MethodPut, endpoint, request, &sensor)
+
+	return sensor, err
+}
+
+// GetZoneSeries aggregates the readings of the sensors of a zone into buckets holding
+// the mean, min and max across the sensors
+func (nc *NexusClient) GetZoneSeries(ctx context.Context, zoneID string, query api.ZoneSeriesQuery) (api.GetZoneSeriesResponse, error) {
+	params := url.Values{}
+	if len(query.MeasurementTypes) > 0 {
+		params.Set("types", strings.Join(query.MeasurementTypes, ","))
+	}
+	if query.Resolution != "" {
+		params.Set("resolution", query.Resolution)
+	}
+	if !query.Start.IsZero() {
+		params.Set("start", query.Start.Format(time.RFC3339))
+	}
+	if !query.End.IsZero() {
+		params.Set("end", query.End.Format(time.RFC3339))
+	}
+	if query.Raw {
+		params.Set("raw", "true")
+	}
+	if len(query.Units) > 0 {
+		params.Set("units", strings.Join(query.Units, ","))
+	}
+
+	endpoint := fmt.Sprintf("%s/zones/%s/series?%s", nc.Config.NexusAPIEndpoint, url.PathEscape(zoneID), params.Encode())
+
+	var result api.GetZoneSeriesResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetAlertRules retrieves every alert rule ordered by name
+func (nc *NexusClient) GetAlertRules(ctx context.Context) (api.GetAlertRulesResponse, error) {
+	endpoint := fmt.Sprintf("%s/alert_rules", nc.Config.NexusAPIEndpoint)
+
+	var result api.GetAlertRulesResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetAlertRule retrieves an alert rule
+func (nc *NexusClient) GetAlertRule(ctx context.Context, ruleID string) (api.AlertRule, error) {
+	endpoint := fmt.Sprintf("%s/alert_rules/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(ruleID))
+
+	var result api.AlertRule
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// CreateAlertRule adds an alert rule, returning it as saved
+func (nc *NexusClient) CreateAlertRule(ctx context.Context, request api.CreateAlertRuleRequest) (api.AlertRule, error) {
+	endpoint := fmt.Sprintf("%s/alert_rules", nc.Config.NexusAPIEndpoint)
+
+	var result api.AlertRule
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, request, &result)
+
+	return result, err
+}
+
+// UpdateAlertRule changes the fields of an alert rule set in request
+func (nc *NexusClient) UpdateAlertRule(ctx context.Context, ruleID string, request api.UpdateAlertRuleRequest) (api.AlertRule, error) {
+	endpoint := fmt.Sprintf("%s/alert_rules/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(ruleID))
+
+	var result api.AlertRule
+	err := nc.doJSONRequest(ctx, http.MethodPatch, endpoint, request, &result)
+
+	return result, err
+}
+
+// DeleteAlertRule deletes an alert rule, keeping the alerts it fired
+func (nc *NexusClient) DeleteAlertRule(ctx context.Context, ruleID string) error {
+	endpoint := fmt.Sprintf("%s/alert_rules/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(ruleID))
+
+	return nc.doJSONRequest(ctx, http.MethodDelete, endpoint, nil, nil)
+}
+
+// GetAlerts retrieves a page of the alert history selected by query, newest first
+func (nc *NexusClient) GetAlerts(ctx context.Context, query api.AlertsQuery) (api.GetAlertsResponse, error) {
+	params := url.Values{}
+	if query.RuleID != "" {
+		params.Set("rule_id", query.RuleID)
+	}
+	if query.SensorID != "" {
+		params.Set("sensor_id", query.SensorID)
+	}
+	if query.State != "" {
+		params.Set("state", query.State)
+	}
+	if !query.Start.IsZero() {
+		params.Set("start", query.Start.Format(time.RFC3339))
+	}
+	if !query.End.IsZero() {
+		params.Set("end", query.End.Format(time.RFC3339))
+	}
+	if query.BeforeID > 0 {
+		params.Set("before_id", strconv.FormatInt(query.BeforeID, 10))
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	endpoint := fmt.Sprintf("%s/alerts?%s", nc.Config.NexusAPIEndpoint, params.Encode())
+
+	var result api.GetAlertsResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetAlert retrieves an alert
+func (nc *NexusClient) GetAlert(ctx context.Context, alertID int64) (api.Alert, error) {
+	endpoint := fmt.Sprintf("%s/alerts/%d", nc.Config.NexusAPIEndpoint, alertID)
+
+	var result api.Alert
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetAlertEvents retrieves the history of an alert oldest first
+func (nc *NexusClient) GetAlertEvents(ctx context.Context, alertID int64) (api.GetAlertEventsResponse, error) {
+	endpoint := fmt.Sprintf("%s/alerts/%d/events", nc.Config.NexusAPIEndpoint, alertID)
+
+	var result api.GetAlertEventsResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// AcknowledgeAlert acknowledges an open alert, which stops it escalating
+func (nc *NexusClient) AcknowledgeAlert(ctx context.Context, alertID int64, request api.AcknowledgeAlertRequest) (api.Alert, error) {
+	endpoint := fmt.Sprintf("%s/alerts/%d/acknowledge", nc.Config.NexusAPIEndpoint, alertID)
+
+	var result api.Alert
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, request, &result)
+
+	return result, err
+}
+
+// SnoozeAlert snoozes an open alert until request.Until
+func (nc *NexusClient) SnoozeAlert(ctx context.Context, alertID int64, request api.SnoozeAlertRequest) (api.Alert, error) {
+	endpoint := fmt.Sprintf("%s/alerts/%d/snooze", nc.Config.NexusAPIEndpoint, alertID)
+
+	var result api.Alert
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, request, &result)
+
+	return result, err
+}
+
+// AssignAlert assigns an open alert to a user, or unassigns it if request.Username is empty
+func (nc *NexusClient) AssignAlert(ctx context.Context, alertID int64, request api.AssignAlertRequest) (api.Alert, error) {
+	endpoint := fmt.Sprintf("%s/alerts/%d/assign", nc.Config.NexusAPIEndpoint, alertID)
+
+	var result api.Alert
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, request, &result)
+
+	return result, err
+}
+
+// ResolveAlert resolves an open alert by hand with a note saying why
+func (nc *NexusClient) ResolveAlert(ctx context.Context, alertID int64, request api.ResolveAlertRequest) (api.Alert, error) {
+	endpoint := fmt.Sprintf("%s/alerts/%d/resolve", nc.Config.NexusAPIEndpoint, alertID)
+
+	var result api.Alert
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, request, &result)
+
+	return result, err
+}
+
+// heatmapQueryValues encodes a heatmap query as the query parameters of GET /heatmap
+func heatmapQueryValues(query api.HeatmapQuery) url.Values {
+	params := url.Values{}
+	if len(query.BoundingBox) > 0 {
+		edges := make([]string, 0, len(query.BoundingBox))
+		for _, edge := range query.BoundingBox {
+			edges = append(edges, strconv.FormatFloat(edge, 'f', -1, 64))
+		}
+		params.Set("bbox", strings.Join(edges, ","))
+	}
+	if query.ZoneID != "" {
+		params.Set("zone_id", query.ZoneID)
+	}
+	if !query.At.IsZero() {
+		params.Set("at", query.At.Format(time.RFC3339))
+	}
+	if query.CellSizeMeters != 0 {
+		params.Set("cell_size", strconv.FormatFloat(query.CellSizeMeters, 'f', -1, 64))
+	}
+	if query.MeasurementType != "" {
+		params.Set("measurement_type", query.MeasurementType)
+	}
+	if query.Method != "" {
+		params.Set("method", query.Method)
+	}
+	if query.Power != 0 {
+		params.Set("power", strconv.FormatFloat(query.Power, 'f', -1, 64))
+	}
+	if query.RadiusMeters != 0 {
+		params.Set("radius", strconv.FormatFloat(query.RadiusMeters, 'f', -1, 64))
+	}
+	if query.Raw {
+		params.Set("raw", "true")
+	}
+	if len(query.Units) > 0 {
+		params.Set("units", strings.Join(query.Units, ","))
+	}
+
+	return params
+}
+
+// GetHeatmap retrieves a grid of values interpolated across the area selected by query
+func (nc *NexusClient) GetHeatmap(ctx context.Context, query api.HeatmapQuery) (api.Heatmap, error) {
+	endpoint := fmt.Sprintf("%s/heatmap?%s", nc.Config.NexusAPIEndpoint, heatmapQueryValues(query).Encode())
+
+	var result api.Heatmap
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetHeatmapPNG writes the heatmap selected by query to w as a PNG image with one pixel
+// per cell, returning the bounding box the image covers as west, south, east, north
+func (nc *NexusClient) GetHeatmapPNG(ctx context.Context, query api.HeatmapQuery, w io.Writer) ([]float64, error) {
+	params := heatmapQueryValues(query)
+	params.Set("format", "png")
+	endpoint := fmt.Sprintf("%s/heatmap?%s", nc.Config.NexusAPIEndpoint, params.Encode())
+
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = SetAuthHeaders(request, nc.Cookie)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	response, err := nc.http.Do(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer response.Body.Close()
 
 	if !(response.StatusCode >= 200 && response.StatusCode <= 299) {
-		return fmt.Errorf("non 200-level status code: %d", response.StatusCode)
+		return nil, fmt.Errorf("non 200-level status code: %d", response.StatusCode)
 	}
 
-	return nil
+	var bounds []float64
+	for _, edge := range strings.Split(response.Header.Get("X-Heatmap-Bounds"), ",") {
+		value, err := strconv.ParseFloat(edge, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid X-Heatmap-Bounds header: %w", err)
+		}
+		bounds = append(bounds, value)
+	}
+
+	_, err = io.Copy(w, response.Body)
+
+	return bounds, err
+}
+
+// PurgeSensor permanently deletes a decommissioned sensor and all of its readings, admin only
+func (nc *NexusClient) PurgeSensor(ctx context.Context, sensorID string) error {
+	endpoint := fmt.Sprintf("%s/admin/sensors/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID))
+
+	return nc.doJSONRequest(ctx, http.MethodDelete, endpoint, nil, nil)
 }
 
 // GetDroneImages retrieves drone images within a date range
@@ -751,97 +1177,158 @@ func (nc *NexusClient) DeleteDroneImage(ctx context.Context, imageID string) err
 	return nil
 }
 
-// SetAuthHeaders sets the headers needed to authenticate requests
-// to the Nexus API, returning error (if any)
-func SetAuthHeaders(request *http.Request, cookie *http.Cookie) error {
-	if cookie == nil || cookie.Value == "" {
-		return fmt.Errorf("invalid auth cookie (nil or empty)")
+// encodeSensorDataQuery returns the query string (including the leading ?)
+// for the set fields of query, or an empty string if none are set
+func encodeSensorDataQuery(query api.SensorDataQuery) string {
+	params := sensorDataQueryValues(query)
+
+	if len(params) == 0 {
+		return ""
 	}
-	request.AddCookie(cookie)
-	fmt.Printf("[TRACE] SetAuthHeaders adding cookie: Name=%s, Value=%s\n", cookie.Name, cookie.Value)
-	return nil
+
+	return "?" + params.Encode()
 }
 
-// NewClient creates a new client using the provided configuration
-// returning the client and error (if any)
-// GetSensorBatteryData retrieves battery data for a specific sensor
-func (nc *NexusClient) GetSensorBatteryData(ctx context.Context, sensorID string, startDate, endDate string) (api.GetBatteryLevelDataResponse, error) {
-	endpoint := fmt.Sprintf("%s/sensors/%s/battery_data", nc.Config.NexusAPIEndpoint, sensorID)
+// sensorDataQueryValues returns the query parameters for the set fields of query
+func sensorDataQueryValues(query api.SensorDataQuery) url.Values {
+	params := url.Values{}
 
-	// Add query parameters for date range if provided
-	if startDate != "" || endDate != "" {
-		params := make([]string, 0, 2)
-		if startDate != "" {
-			params = append(params, fmt.Sprintf("start_date=%s", startDate))
-		}
-		if endDate != "" {
-			params = append(params, fmt.Sprintf("end_date=%s", endDate))
-		}
-		if len(params) > 0 {
-			endpoint = fmt.Sprintf("%s?%s", endpoint, strings.Join(params, "&"))
-		}
+	if !query.Start.IsZero() {
+		params.Set("start", query.Start.Format(time.RFC3339))
 	}
-
-	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
-	if err != nil {
-		return api.GetBatteryLevelDataResponse{}, err
+	if !query.End.IsZero() {
+		params.Set("end", query.End.Format(time.RFC3339))
 	}
-
-	err = SetAuthHeaders(request, nc.Cookie)
-	if err != nil {
-		return api.GetBatteryLevelDataResponse{}, err
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
 	}
-
-	response, err := nc.http.Do(request)
-	if err != nil {
-		return api.GetBatteryLevelDataResponse{}, err
+	if query.Order != "" {
+		params.Set("order", query.Order)
 	}
-	defer response.Body.Close()
+	if query.Cursor != "" {
+		params.Set("cursor", query.Cursor)
+	}
+	if query.IncludeReplaced {
+		params.Set("include_replaced", "true")
+	}
+	if query.Raw {
+		params.Set("raw", "true")
+	}
+	if len(query.Units) > 0 {
+		params.Set("units", strings.Join(query.Units, ","))
+	}
+
+	return params
+}
+
+// GetSensorMoistureDataBuckets gets the min, max, average and count of a sensor's
+// moisture readings per bucket of resolution (hourly, daily, weekly or monthly)
+func (nc *NexusClient) GetSensorMoistureDataBuckets(ctx context.Context, sensorID string, resolution string, query api.SensorDataQuery) (api.GetSensorDataBucketsResponse, error) {
+	return nc.getSensorDataBuckets(ctx, sensorID, "moisture_data", resolution, query)
+}
+
+// GetSensorTemperatureDataBuckets gets the min, max, average and count of a sensor's
+// temperature readings per bucket of resolution (hourly, daily, weekly or monthly)
+func (nc *NexusClient) GetSensorTemperatureDataBuckets(ctx context.Context, sensorID string, resolution string, query api.SensorDataQuery) (api.GetSensorDataBucketsResponse, error) {
+	return nc.getSensorDataBuckets(ctx, sensorID, "temperature_data", resolution, query)
+}
+
+// GetSensorBatteryDataBuckets gets the min, max, average and count of a sensor's
+// battery readings per bucket of resolution (hourly, daily, weekly or monthly)
+func (nc *NexusClient) GetSensorBatteryDataBuckets(ctx context.Context, sensorID string, resolution string, query api.SensorDataQuery) (api.GetSensorDataBucketsResponse, error) {
+	return nc.getSensorDataBuckets(ctx, sensorID, "battery_data", resolution, query)
+}
+
+func (nc *NexusClient) getSensorDataBuckets(ctx context.Context, sensorID string, dataPath string, resolution string, query api.SensorDataQuery) (api.GetSensorDataBucketsResponse, error) {
+	params := sensorDataQueryValues(query)
+	params.Set("resolution", resolution)
+
+	endpoint := fmt.Sprintf("%s/sensors/%s/%s?%s", nc.Config.NexusAPIEndpoint, sensorID, dataPath, params.Encode())
+
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return api.GetSensorDataBucketsResponse{}, err
+	}
+
+	err = SetAuthHeaders(request, nc.Cookie)
+	if err != nil {
+		return api.GetSensorDataBucketsResponse{}, err
+	}
+
+	response, err := nc.http.Do(request)
+	if err != nil {
+		return api.GetSensorDataBucketsResponse{}, err
+	}
+	defer response.Body.Close()
 
 	if !(response.StatusCode >= 200 && response.StatusCode <= 299) {
-		return api.GetBatteryLevelDataResponse{}, fmt.Errorf("non 200-level status code: %d", response.StatusCode)
+		return api.GetSensorDataBucketsResponse{}, fmt.Errorf("non 200-level status code: %d", response.StatusCode)
 	}
 
-	var result api.GetBatteryLevelDataResponse
+	var result api.GetSensorDataBucketsResponse
 	err = json.NewDecoder(response.Body).Decode(&result)
 	if err != nil {
-		return api.GetBatteryLevelDataResponse{}, err
+		return api.GetSensorDataBucketsResponse{}, err
 	}
 
 	return result, nil
 }
 
-// SetSensorBatteryData saves battery data for a specific sensor
-func (nc *NexusClient) SetSensorBatteryData(ctx context.Context, sensorID string, batteryData api.SetBatteryLevelDataResponse) error {
-	endpoint := fmt.Sprintf("%s/sensors/%s/battery_data", nc.Config.NexusAPIEndpoint, sensorID)
-
-	body, err := json.Marshal(batteryData)
-	if err != nil {
-		return err
+// SetAuthHeaders sets the headers needed to authenticate requests
+// to the Nexus API, returning error (if any)
+func SetAuthHeaders(request *http.Request, cookie *http.Cookie) error {
+	if cookie == nil || cookie.Value == "" {
+		return fmt.Errorf("invalid auth cookie (nil or empty)")
 	}
+	request.AddCookie(cookie)
+	fmt.Printf("[TRACE] SetAuthHeaders adding cookie: Name=%s, Value=%s\n", cookie.Name, cookie.Value)
+	return nil
+}
 
-	request, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(body))
+// NewClient creates a new client using the provided configuration
+// returning the client and error (if any)
+// GetSensorBatteryData retrieves a page of battery data for a specific sensor,
+// pass the returned NextCursor in query.Cursor to fetch the following page
+func (nc *NexusClient) GetSensorBatteryData(ctx context.Context, sensorID string, query api.SensorDataQuery) (api.GetBatteryLevelDataResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/battery_data%s", nc.Config.NexusAPIEndpoint, sensorID, encodeSensorDataQuery(query))
+
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
-		return err
+		return api.GetBatteryLevelDataResponse{}, err
 	}
 
-	request.Header.Set("Content-Type", "application/json")
 	err = SetAuthHeaders(request, nc.Cookie)
 	if err != nil {
-		return err
+		return api.GetBatteryLevelDataResponse{}, err
 	}
 
 	response, err := nc.http.Do(request)
 	if err != nil {
-		return err
+		return api.GetBatteryLevelDataResponse{}, err
 	}
 	defer response.Body.Close()
 
 	if !(response.StatusCode >= 200 && response.StatusCode <= 299) {
-		return fmt.Errorf("non 200-level status code: %d", response.StatusCode)
+		return api.GetBatteryLevelDataResponse{}, fmt.Errorf("non 200-level status code: %d", response.StatusCode)
 	}
 
-	return nil
+	var result api.GetBatteryLevelDataResponse
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		return api.GetBatteryLevelDataResponse{}, err
+	}
+
+	return result, nil
+}
+
+// SetSensorBatteryData saves battery data for a specific sensor
+func (nc *NexusClient) SetSensorBatteryData(ctx context.Context, sensorID string, batteryData api.SetBatteryLevelDataResponse) (api.SetSensorMeasurementsResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/battery_data", nc.Config.NexusAPIEndpoint, sensorID)
+
+	var result api.SetSensorMeasurementsResponse
+	err := nc.doJSONRequest(ctx, "POST", endpoint, batteryData, &result)
+
+	return result, err
 }
 
 // Admin-related methods
@@ -950,3 +1437,521 @@ func NewClient(config SDKConfig) (*NexusClient, error) {
 
 	return &client, nil
 }
+
+// doJSONRequest sends requestBody (if not nil) as json to the endpoint and decodes
+// a 200-level json response into result (if not nil), returning error (if any)
+func (nc *NexusClient) doJSONRequest(ctx context.Context, method string, endpoint string, requestBody interface{}, result interface{}) error {
+	var body io.Reader
+	if requestBody != nil {
+		encoded, err := json.Marshal(requestBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(encoded)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+
+	if requestBody != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	err = SetAuthHeaders(request, nc.Cookie)
+	if err != nil {
+		return err
+	}
+
+	response, err := nc.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if !(response.StatusCode >= 200 && response.StatusCode <= 299) {
+		return fmt.Errorf("non 200-level status code: %d", response.StatusCode)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// GetMeasurementTypes lists the registered measurement types
+func (nc *NexusClient) GetMeasurementTypes(ctx context.Context) ([]api.MeasurementType, error) {
+	endpoint := fmt.Sprintf("%s/measurement_types", nc.Config.NexusAPIEndpoint)
+
+	var result api.GetMeasurementTypesResponse
+	err := nc.doJSONRequest(ctx, "GET", endpoint, nil, &result)
+
+	return result.MeasurementTypes, err
+}
+
+// CreateMeasurementType adds a measurement type to the registry (admin only)
+func (nc *NexusClient) CreateMeasurementType(ctx context.Context, measurementType api.MeasurementType) (api.MeasurementType, error) {
+	endpoint := fmt.Sprintf("%s/admin/measurement_types", nc.Config.NexusAPIEndpoint)
+
+	var result api.MeasurementType
+	err := nc.doJSONRequest(ctx, "POST", endpoint, measurementType, &result)
+
+	return result, err
+}
+
+// UpdateMeasurementType changes the set fields of a registered measurement type (admin only)
+func (nc *NexusClient) UpdateMeasurementType(ctx context.Context, measurementTypeID string, update api.UpdateMeasurementTypeRequest) (api.MeasurementType, error) {
+	endpoint := fmt.Sprintf("%s/admin/measurement_types/%s", nc.Config.NexusAPIEndpoint, measurementTypeID)
+
+	var result api.MeasurementType
+	err := nc.doJSONRequest(ctx, "PATCH", endpoint, update, &result)
+
+	return result, err
+}
+
+// GetSensorMeasurements retrieves a page of a sensor's readings of any registered measurement type,
+// pass the returned NextCursor in query.Cursor to fetch the following page
+func (nc *NexusClient) GetSensorMeasurements(ctx context.Context, sensorID string, measurementTypeID string, query api.SensorDataQuery) (api.GetSensorMeasurementsResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/measurements/%s%s", nc.Config.NexusAPIEndpoint, sensorID, measurementTypeID, encodeSensorDataQuery(query))
+
+	var result api.GetSensorMeasurementsResponse
+	err := nc.doJSONRequest(ctx, "GET", endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetSensorMeasurementBuckets gets the min, max, average and count of a sensor's readings of any
+// registered measurement type per bucket of resolution (hourly, daily, weekly or monthly)
+func (nc *NexusClient) GetSensorMeasurementBuckets(ctx context.Context, sensorID string, measurementTypeID string, resolution string, query api.SensorDataQuery) (api.GetSensorDataBucketsResponse, error) {
+	return nc.getSensorDataBuckets(ctx, sensorID, "measurements/"+measurementTypeID, resolution, query)
+}
+
+// SetSensorMeasurements saves a sensor's readings of any registered measurement type
+func (nc *NexusClient) SetSensorMeasurements(ctx context.Context, sensorID string, measurementTypeID string, measurements api.SetSensorMeasurementsRequest) (api.SetSensorMeasurementsResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/measurements/%s", nc.Config.NexusAPIEndpoint, sensorID, measurementTypeID)
+
+	var result api.SetSensorMeasurementsResponse
+	err := nc.doJSONRequest(ctx, "POST", endpoint, measurements, &result)
+
+	return result, err
+}
+
+// ExportSensorData streams the readings selected by query to w in the requested format,
+// without holding the export in memory, returning error (if any)
+func (nc *NexusClient) ExportSensorData(ctx context.Context, query api.SensorDataExportQuery, w io.Writer) error {
+	params := url.Values{}
+	if len(query.SensorIDs) > 0 {
+		params.Set("ids", strings.Join(query.SensorIDs, ","))
+	}
+	if len(query.MeasurementTypes) > 0 {
+		params.Set("types", strings.Join(query.MeasurementTypes, ","))
+	}
+	if !query.Start.IsZero() {
+		params.Set("start", query.Start.Format(time.RFC3339))
+	}
+	if !query.End.IsZero() {
+		params.Set("end", query.End.Format(time.RFC3339))
+	}
+	if query.Format != "" {
+		params.Set("format", query.Format)
+	}
+	if query.Raw {
+		params.Set("raw", "true")
+	}
+	if len(query.Units) > 0 {
+		params.Set("units", strings.Join(query.Units, ","))
+	}
+
+	endpoint := fmt.Sprintf("%s/exports/sensors?%s", nc.Config.NexusAPIEndpoint, params.Encode())
+
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	err = SetAuthHeaders(request, nc.Cookie)
+	if err != nil {
+		return err
+	}
+
+	response, err := nc.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if !(response.StatusCode >= 200 && response.StatusCode <= 299) {
+		return fmt.Errorf("non 200-level status code: %d", response.StatusCode)
+	}
+
+	_, err = io.Copy(w, response.Body)
+
+	return err
+}
+
+// ImportSensorData uploads a csv file of historical readings, returning the
+// import job that saves its rows in the background. onConflict may be empty
+func (nc *NexusClient) ImportSensorData(ctx context.Context, fileName string, file io.Reader, mapping api.SensorDataImportMapping, onConflict string) (api.ImportJob, error) {
+	endpoint := fmt.Sprintf("%s/imports/sensor_data", nc.Config.NexusAPIEndpoint)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return api.ImportJob{}, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return api.ImportJob{}, err
+	}
+
+	mappingJSON, err := json.Marshal(mapping)
+	if err != nil {
+		return api.ImportJob{}, err
+	}
+	if err := writer.WriteField("mapping", string(mappingJSON)); err != nil {
+		return api.ImportJob{}, err
+	}
+
+	if onConflict != "" {
+		if err := writer.WriteField("on_conflict", onConflict); err != nil {
+			return api.ImportJob{}, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return api.ImportJob{}, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", endpoint, body)
+	if err != nil {
+		return api.ImportJob{}, err
+	}
+
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	err = SetAuthHeaders(request, nc.Cookie)
+	if err != nil {
+		return api.ImportJob{}, err
+	}
+
+	response, err := nc.http.Do(request)
+	if err != nil {
+		return api.ImportJob{}, err
+	}
+	defer response.Body.Close()
+
+	if !(response.StatusCode >= 200 && response.StatusCode <= 299) {
+		return api.ImportJob{}, fmt.Errorf("non 200-level status code: %d", response.StatusCode)
+	}
+
+	var result api.ImportJob
+	err = json.NewDecoder(response.Body).Decode(&result)
+
+	return result, err
+}
+
+// GetImportJob returns the progress of an import job
+func (nc *NexusClient) GetImportJob(ctx context.Context, jobID string) (api.ImportJob, error) {
+	endpoint := fmt.Sprintf("%s/imports/sensor_data/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(jobID))
+
+	var result api.ImportJob
+	err := nc.doJSONRequest(ctx, "GET", endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetImportJobErrors returns a page of the rows of an import job that were not saved,
+// starting after row afterRow. A limit of 0 uses the server's default page size
+func (nc *NexusClient) GetImportJobErrors(ctx context.Context, jobID string, afterRow int, limit int) (api.GetImportJobErrorsResponse, error) {
+	params := url.Values{}
+	if afterRow > 0 {
+		params.Set("after_row", strconv.Itoa(afterRow))
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	endpoint := fmt.Sprintf("%s/imports/sensor_data/%s/errors?%s", nc.Config.NexusAPIEndpoint, url.PathEscape(jobID), params.Encode())
+
+	var result api.GetImportJobErrorsResponse
+	err := nc.doJSONRequest(ctx, "GET", endpoint, nil, &result)
+
+	return result, err
+}
+
+// QuerySensors returns the series of several sensors and measurement types in one request
+func (nc *NexusClient) QuerySensors(ctx context.Context, query api.QuerySensorsRequest) (api.QuerySensorsResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/query", nc.Config.NexusAPIEndpoint)
+
+	var result api.QuerySensorsResponse
+	err := nc.doJSONRequest(ctx, "POST", endpoint, query, &result)
+
+	return result, err
+}
+
+// GetSensorsStatus returns the state of every sensor, pass an empty state for all sensors
+// or one of online, offline or never_seen for only the sensors in that state
+func (nc *NexusClient) GetSensorsStatus(ctx context.Context, state string) (api.GetSensorsStatusResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/status", nc.Config.NexusAPIEndpoint)
+	if state != "" {
+		endpoint += "?state=" + url.QueryEscape(state)
+	}
+
+	var result api.GetSensorsStatusResponse
+	err := nc.doJSONRequest(ctx, "GET", endpoint, nil, &result)
+
+	return result, err
+}
+
+// UpdateSensor changes the settings of a sensor that are set in update, returning the updated sensor
+func (nc *NexusClient) UpdateSensor(ctx context.Context, sensorID string, update api.UpdateSensorRequest) (api.Sensor, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID))
+
+	var result api.Sensor
+	err := nc.doJSONRequest(ctx, "PATCH", endpoint, update, &result)
+
+	return result, err
+}
+
+// GetQuarantinedReadings lists a page of the readings held back on ingest with the given
+// status, pending when empty or all for every status (admin only). Pass the returned
+// NextAfterID as afterID to fetch the following page
+func (nc *NexusClient) GetQuarantinedReadings(ctx context.Context, status string, afterID int64, limit int) (api.GetQuarantinedReadingsResponse, error) {
+	params := url.Values{}
+	if status != "" {
+		params.Set("status", status)
+	}
+	if afterID > 0 {
+		params.Set("after_id", strconv.FormatInt(afterID, 10))
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	endpoint := fmt.Sprintf("%s/admin/quarantine?%s", nc.Config.NexusAPIEndpoint, params.Encode())
+
+	var result api.GetQuarantinedReadingsResponse
+	err := nc.doJSONRequest(ctx, "GET", endpoint, nil, &result)
+
+	return result, err
+}
+
+// ReleaseQuarantinedReading stores a quarantined reading as a reading of its sensor (admin only)
+func (nc *NexusClient) ReleaseQuarantinedReading(ctx context.Context, readingID int64) (api.QuarantinedReading, error) {
+	endpoint := fmt.Sprintf("%s/admin/quarantine/%d/release", nc.Config.NexusAPIEndpoint, readingID)
+
+	var result api.QuarantinedReading
+	err := nc.doJSONRequest(ctx, "POST", endpoint, nil, &result)
+
+	return result, err
+}
+
+// DiscardQuarantinedReading marks a quarantined reading as discarded so it is never stored (admin only)
+func (nc *NexusClient) DiscardQuarantinedReading(ctx context.Context, readingID int64) (api.QuarantinedReading, error) {
+	endpoint := fmt.Sprintf("%s/admin/quarantine/%d/discard", nc.Config.NexusAPIEndpoint, readingID)
+
+	var result api.QuarantinedReading
+	err := nc.doJSONRequest(ctx, "POST", endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetWebhookSubscriptions lists every webhook subscription, without their secrets (admin only)
+func (nc *NexusClient) GetWebhookSubscriptions(ctx context.Context) (api.GetWebhookSubscriptionsResponse, error) {
+	endpoint := fmt.Sprintf("%s/admin/webhooks", nc.Config.NexusAPIEndpoint)
+
+	var result api.GetWebhookSubscriptionsResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetWebhookSubscription retrieves a webhook subscription, without its secret (admin only)
+func (nc *NexusClient) GetWebhookSubscription(ctx context.Context, subscriptionID string) (api.WebhookSubscription, error) {
+	endpoint := fmt.Sprintf("%s/admin/webhooks/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(subscriptionID))
+
+	var result api.WebhookSubscription
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// CreateWebhookSubscription registers an endpoint to be sent events, returning the
+// subscription with the secret its deliveries are signed with (admin only)
+func (nc *NexusClient) CreateWebhookSubscription(ctx context.Context, request api.CreateWebhookSubscriptionRequest) (api.WebhookSubscription, error) {
+	endpoint := fmt.Sprintf("%s/admin/webhooks", nc.Config.NexusAPIEndpoint)
+
+	var result api.WebhookSubscription
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, request, &result)
+
+	return result, err
+}
+
+// UpdateWebhookSubscription changes the fields of a webhook subscription that are set in
+// the request (admin only)
+func (nc *NexusClient) UpdateWebhookSubscription(ctx context.Context, subscriptionID string, update api.UpdateWebhookSubscriptionRequest) (api.WebhookSubscription, error) {
+	endpoint := fmt.Sprintf("%s/admin/webhooks/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(subscriptionID))
+
+	var result api.WebhookSubscription
+	err := nc.doJSONRequest(ctx, http.MethodPatch, endpoint, update, &result)
+
+	return result, err
+}
+
+// DeleteWebhookSubscription deletes a webhook subscription and its delivery log (admin only)
+func (nc *NexusClient) DeleteWebhookSubscription(ctx context.Context, subscriptionID string) error {
+	endpoint := fmt.Sprintf("%s/admin/webhooks/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(subscriptionID))
+
+	return nc.doJSONRequest(ctx, http.MethodDelete, endpoint, nil, nil)
+}
+
+// GetWebhookDeliveries lists a page of the delivery log of a webhook subscription newest
+// first (admin only). Pass the returned NextBeforeID as query.BeforeID to fetch the following page
+func (nc *NexusClient) GetWebhookDeliveries(ctx context.Context, subscriptionID string, query api.WebhookDeliveriesQuery) (api.GetWebhookDeliveriesResponse, error) {
+	params := url.Values{}
+	if query.Status != "" {
+		params.Set("status", query.Status)
+	}
+	if query.BeforeID > 0 {
+		params.Set("before_id", strconv.FormatInt(query.BeforeID, 10))
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	endpoint := fmt.Sprintf("%s/admin/webhooks/%s/deliveries?%s", nc.Config.NexusAPIEndpoint, url.PathEscape(subscriptionID), params.Encode())
+
+	var result api.GetWebhookDeliveriesResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// ReplayWebhookDeliveries queues failed deliveries of a webhook subscription to be sent
+// again, every failed delivery when deliveryIDs is empty (admin only)
+func (nc *NexusClient) ReplayWebhookDeliveries(ctx context.Context, subscriptionID string, deliveryIDs []int64) (api.ReplayWebhookDeliveriesResponse, error) {
+	endpoint := fmt.Sprintf("%s/admin/webhooks/%s/deliveries/replay", nc.Config.NexusAPIEndpoint, url.PathEscape(subscriptionID))
+
+	var result api.ReplayWebhookDeliveriesResponse
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, api.ReplayWebhookDeliveriesRequest{DeliveryIDs: deliveryIDs}, &result)
+
+	return result, err
+}
+
+// GetNotificationPreferences retrieves where and when the logged in user is emailed notifications
+func (nc *NexusClient) GetNotificationPreferences(ctx context.Context) (api.NotificationPreferences, error) {
+	endpoint := fmt.Sprintf("%s/notifications/preferences", nc.Config.NexusAPIEndpoint)
+
+	var result api.NotificationPreferences
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// UpdateNotificationPreferences replaces the logged in user's notification preferences
+func (nc *NexusClient) UpdateNotificationPreferences(ctx context.Context, request api.UpdateNotificationPreferencesRequest) (api.NotificationPreferences, error) {
+	endpoint := fmt.Sprintf("%s/notifications/preferences", nc.Config.NexusAPIEndpoint)
+
+	var result api.NotificationPreferences
+	err := nc.doJSONRequest(ctx, http.MethodPut, endpoint, request, &result)
+
+	return result, err
+}
+
+// GetNotificationSubscriptions lists the logged in user's notification subscriptions
+func (nc *NexusClient) GetNotificationSubscriptions(ctx context.Context) (api.GetNotificationSubscriptionsResponse, error) {
+	endpoint := fmt.Sprintf("%s/notifications/subscriptions", nc.Config.NexusAPIEndpoint)
+
+	var result api.GetNotificationSubscriptionsResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// CreateNotificationSubscription subscribes the logged in user to a kind of notification
+func (nc *NexusClient) CreateNotificationSubscription(ctx context.Context, request api.CreateNotificationSubscriptionRequest) (api.NotificationSubscription, error) {
+	endpoint := fmt.Sprintf("%s/notifications/subscriptions", nc.Config.NexusAPIEndpoint)
+
+	var result api.NotificationSubscription
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, request, &result)
+
+	return result, err
+}
+
+// DeleteNotificationSubscription unsubscribes the logged in user from one of their notification subscriptions
+func (nc *NexusClient) DeleteNotificationSubscription(ctx context.Context, subscriptionID string) error {
+	endpoint := fmt.Sprintf("%s/notifications/subscriptions/%s", nc.Config.NexusAPIEndpoint, url.PathEscape(subscriptionID))
+
+	return nc.doJSONRequest(ctx, http.MethodDelete, endpoint, nil, nil)
+}
+
+// GetEmails lists a page of the emails sent or waiting to be sent to the logged in user
+// newest first. Pass the returned NextBeforeID as query.BeforeID to fetch the following page
+func (nc *NexusClient) GetEmails(ctx context.Context, query api.EmailsQuery) (api.GetEmailsResponse, error) {
+	params := url.Values{}
+	if query.Status != "" {
+		params.Set("status", query.Status)
+	}
+	if query.BeforeID > 0 {
+		params.Set("before_id", strconv.FormatInt(query.BeforeID, 10))
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	endpoint := fmt.Sprintf("%s/notifications/emails?%s", nc.Config.NexusAPIEndpoint, params.Encode())
+
+	var result api.GetEmailsResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// SendTestEmail queues a test email to the address in the logged in user's notification preferences
+func (nc *NexusClient) SendTestEmail(ctx context.Context) (api.Email, error) {
+	endpoint := fmt.Sprintf("%s/notifications/test", nc.Config.NexusAPIEndpoint)
+
+	var result api.Email
+	err := nc.doJSONRequest(ctx, http.MethodPost, endpoint, nil, &result)
+
+	return result, err
+}
+
+// batteryForecastQueryValues encodes the set fields of a battery forecast query
+func batteryForecastQueryValues(query api.BatteryForecastQuery) url.Values {
+	params := url.Values{}
+	if query.Cutoff > 0 {
+		params.Set("cutoff", strconv.FormatFloat(query.Cutoff, 'f', -1, 64))
+	}
+	if query.WindowDays > 0 {
+		params.Set("window_days", strconv.Itoa(query.WindowDays))
+	}
+	if query.WithinDays > 0 {
+		params.Set("within_days", strconv.Itoa(query.WithinDays))
+	}
+
+	return params
+}
+
+// GetSensorBatteryForecast predicts when a sensor's battery falls to the query's cutoff
+// from the trend of its battery readings since the battery was last swapped
+func (nc *NexusClient) GetSensorBatteryForecast(ctx context.Context, sensorID string, query api.BatteryForecastQuery) (api.BatteryForecast, error) {
+	endpoint := fmt.Sprintf("%s/sensors/%s/battery_forecast?%s", nc.Config.NexusAPIEndpoint, url.PathEscape(sensorID), batteryForecastQueryValues(query).Encode())
+
+	var result api.BatteryForecast
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
+
+// GetBatteryForecasts lists the sensors in service whose battery is below the query's
+// cutoff or predicted to reach it within query.WithinDays, soonest first
+func (nc *NexusClient) GetBatteryForecasts(ctx context.Context, query api.BatteryForecastQuery) (api.GetBatteryForecastsResponse, error) {
+	endpoint := fmt.Sprintf("%s/sensors/battery_forecast?%s", nc.Config.NexusAPIEndpoint, batteryForecastQueryValues(query).Encode())
+
+	var result api.GetBatteryForecastsResponse
+	err := nc.doJSONRequest(ctx, http.MethodGet, endpoint, nil, &result)
+
+	return result, err
+}
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/clients/email"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// maxAlertNoteLength bounds the note left with an action on an alert
+	maxAlertNoteLength = 1000
+	// maxAlertSnooze bounds how far ahead an alert can be snoozed
+	maxAlertSnooze = 7 * 24 * time.Hour
+)
+
+// acknowledgeAlert marks an open alert as acknowledged by username, which stops it
+// escalating. An alert is only acknowledged once, so everyone sees who took it on
+func acknowledgeAlert(alert *database.Alert, username string, note string, now time.Time) (database.AlertEvent, error) {
+	if alert.AcknowledgedAt != nil {
+		return database.AlertEvent{}, database.ErrorAlertAcknowledged
+	}
+
+	alert.AcknowledgedAt, alert.AcknowledgedBy = &now, username
+
+	return database.AlertEvent{Action: database.AlertActionAcknowledged, Actor: username, Note: note}, nil
+}
+
+// snoozeAlert snoozes an open alert until the given time, replacing any earlier snooze
+func snoozeAlert(alert *database.Alert, username string, until time.Time, note string) database.AlertEvent {
+	alert.SnoozedUntil = &until
+
+	return database.AlertEvent{Action: database.AlertActionSnoozed, Actor: username, Note: note, SnoozedUntil: &until}
+}
+
+// assignAlert assigns an open alert to assignee, an empty assignee unassigns it
+func assignAlert(alert *database.Alert, username string, assignee string, note string) database.AlertEvent {
+	alert.AssignedTo = assignee
+
+	return database.AlertEvent{Action: database.AlertActionAssigned, Actor: username, Note: note, AssignedTo: assignee}
+}
+
+// resolveAlert resolves an open alert by hand, keeping the note as the reason
+func resolveAlert(alert *database.Alert, username string, note string, now time.Time) database.AlertEvent {
+	alert.ResolvedAt, alert.ResolvedBy, alert.ResolutionNote = &now, username, note
+
+	return database.AlertEvent{Action: database.AlertActionResolved, Actor: username, Note: note}
+}
+
+// alertEventToAPI converts an alert event to its api representation
+func alertEventToAPI(event database.AlertEvent) api.AlertEvent {
+	return api.AlertEvent{
+		ID:           event.ID,
+		AlertID:      event.AlertID,
+		Action:       event.Action,
+		Actor:        event.Actor,
+		Note:         event.Note,
+		SnoozedUntil: event.SnoozedUntil,
+		AssignedTo:   event.AssignedTo,
+		Recipients:   event.Recipients,
+		CreatedAt:    event.CreatedAt,
+	}
+}
+
+// checkAlertNote makes sure the note left with an action on an alert isn't too long,
+// answering the request directly and returning false if it is
+func checkAlertNote(w http.ResponseWriter, note string) bool {
+	if len(note) > maxAlertNoteLength {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("note must be at most %d characters", maxAlertNoteLength)})
+		return false
+	}
+
+	return true
+}
+
+// actOnAlert applies act to the open alert in the request path and responds with the
+// alert as changed, action names what act does in the log
+func actOnAlert(apiService *APIService, w http.ResponseWriter, r *http.Request, action string, act func(alert *database.Alert) (database.AlertEvent, error)) {
+	username, _ := r.Context().Value(UsernameContextKey).(string)
+
+	alertID, err := strconv.ParseInt(mux.Vars(r)["alert_id"], 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert not found"})
+		return
+	}
+
+	alert, _, err := database.ActOnAlert(r.Context(), apiService.DatabaseClient.DB, alertID, act)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case errors.Is(err, database.ErrorNoAlert):
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert not found"})
+		case errors.Is(err, database.ErrorAlertResolved):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert has already been resolved"})
+		case errors.Is(err, database.ErrorAlertAcknowledged):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert has already been acknowledged"})
+		default:
+			apiService.Error().Msgf("Error updating alert %d: %s", alertID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		}
+		return
+	}
+
+	apiService.Info().Msgf("Alert %d of rule %s for sensor_id: %s %s by %s", alert.ID, alert.RuleName, alert.SensorID, action, username)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(alertToAPI(alert, time.Now()))
+}
+
+// CreateAcknowledgeAlertHandler returns a handler that acknowledges an open alert, which
+// stops it escalating
+func CreateAcknowledgeAlertHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		// the body can be left out, acknowledging without a note
+		var request api.AcknowledgeAlertRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil && !errors.Is(err, io.EOF) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		note := strings.TrimSpace(request.Note)
+		if !checkAlertNote(w, note) {
+			return
+		}
+
+		actOnAlert(apiService, w, r, "acknowledged", func(alert *database.Alert) (database.AlertEvent, error) {
+			return acknowledgeAlert(alert, username, note, time.Now())
+		})
+	}
+}
+
+// CreateSnoozeAlertHandler returns a handler that snoozes an open alert until a time up to
+// maxAlertSnooze ahead
+func CreateSnoozeAlertHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.SnoozeAlertRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		now := time.Now()
+		if !request.Until.After(now) || request.Until.Sub(now) > maxAlertSnooze {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("until must be in the future and at most %d days ahead", int(maxAlertSnooze.Hours()/24))})
+			return
+		}
+
+		note := strings.TrimSpace(request.Note)
+		if !checkAlertNote(w, note) {
+			return
+		}
+
+		actOnAlert(apiService, w, r, fmt.Sprintf("snoozed until %s", request.Until.Format(time.RFC3339)), func(alert *database.Alert) (database.AlertEvent, error) {
+			return snoozeAlert(alert, username, request.Until, note), nil
+		})
+	}
+}
+
+// CreateAssignAlertHandler returns a handler that assigns an open alert to a user, or
+// unassigns it when no username is given
+func CreateAssignAlertHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.AssignAlertRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		note := strings.TrimSpace(request.Note)
+		if !checkAlertNote(w, note) {
+			return
+		}
+
+		assignee := strings.TrimSpace(request.Username)
+		if assignee != "" {
+			_, err = database.GetLoginAuthenticationByUserName(r.Context(), apiService.DatabaseClient.DB, assignee)
+			if errors.Is(err, database.ErrorNoLoginAuthenticationForUsername) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown user %q", assignee)})
+				return
+			}
+			if err != nil {
+				apiService.Error().Msgf("Error looking up user %s to assign an alert to: %s", assignee, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+		}
+
+		action := "unassigned"
+		if assignee != "" {
+			action = fmt.Sprintf("assigned to %s", assignee)
+		}
+
+		actOnAlert(apiService, w, r, action, func(alert *database.Alert) (database.AlertEvent, error) {
+			return assignAlert(alert, username, assignee, note), nil
+		})
+	}
+}
+
+// CreateResolveAlertHandler returns a handler that resolves an open alert by hand with a
+// note saying why. Its rule fires again if the sensor's readings keep breaking its
+// condition for its duration
+func CreateResolveAlertHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.ResolveAlertRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		note := strings.TrimSpace(request.Note)
+		if note == "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "note is required"})
+			return
+		}
+		if !checkAlertNote(w, note) {
+			return
+		}
+
+		actOnAlert(apiService, w, r, "resolved", func(alert *database.Alert) (database.AlertEvent, error) {
+			return resolveAlert(alert, username, note, time.Now()), nil
+		})
+	}
+}
+
+// CreateGetAlertEventsHandler returns a handler that returns the history of an alert
+// oldest first
+func CreateGetAlertEventsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		alert, ok := getAlert(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		events, err := database.GetAlertEvents(r.Context(), apiService.DatabaseClient.DB, alert.ID)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving the history of alert %d: %s", alert.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetAlertEventsResponse{
+			Events: make([]api.AlertEvent, 0, len(events)),
+		}
+		for _, event := range events {
+			response.Events = append(response.Events, alertEventToAPI(event))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// escalateAlerts escalates the critical alerts nobody acknowledged within the configured
+// escalation delay, announcing each as alert.escalated and emailing the users it was
+// escalated to in the transaction escalating it
+func (as *APIService) escalateAlerts(ctx context.Context, now time.Time) {
+	delay := as.Config.AlertEscalationDelay
+	if delay <= 0 {
+		delay = DefaultAlertEscalationDelay
+	}
+
+	alerts, err := database.EscalateAlerts(ctx, as.DatabaseClient.DB, now, delay, func(ctx context.Context, tx bun.Tx, alert database.Alert) error {
+		if as.emailClient != nil {
+			err := as.queueAlertEscalatedEmails(ctx, tx, alert, delay, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		return addWebhookEvent(ctx, tx, database.WebhookEventAlertEscalated, api.WebhookAlertEvent{Alert: alertToAPI(alert, now)}, now)
+	})
+	if err != nil {
+		as.Error().Msgf("error %s escalating unacknowledged alerts", err)
+		return
+	}
+
+	for _, alert := range alerts {
+		as.Warn().Msgf("critical alert %d of rule %s for sensor_id: %s unacknowledged after %s, escalated to %s",
+			alert.ID, alert.RuleName, alert.SensorID, delay, strings.Join(alert.EscalatedTo, ", "))
+	}
+}
+
+// queueAlertEscalatedEmails queues emails to the users the alert was escalated to who have
+// notifications enabled, tx is the transaction escalating it
+func (as *APIService) queueAlertEscalatedEmails(ctx context.Context, tx bun.IDB, alert database.Alert, delay time.Duration, now time.Time) error {
+	recipients, err := database.GetEnabledNotificationPreferences(ctx, tx, alert.EscalatedTo)
+	if err != nil {
+		return err
+	}
+
+	var emails []database.Email
+	for _, preferences := range recipients {
+		outgoing, err := newAlertEscalatedEmail(as.emailRenderer, preferences, alert, delay, now)
+		if err != nil {
+			return err
+		}
+		emails = append(emails, outgoing)
+	}
+
+	return database.QueueEmails(ctx, tx, emails)
+}
+
+// newAlertEscalatedEmail renders the email telling a user an alert was escalated to them.
+// Escalations are urgent, so they are due at now even during the user's quiet hours
+func newAlertEscalatedEmail(renderer *email.Renderer, preferences database.NotificationPreferences, alert database.Alert, delay time.Duration, now time.Time) (database.Email, error) {
+	outgoing, err := newNotificationEmail(renderer, preferences, database.EmailKindAlertEscalated, alertEscalatedEmail{
+		emailRecipient:  emailRecipient{Username: preferences.UserName, TimeZone: preferences.Location()},
+		AlertID:         alert.ID,
+		RuleName:        alert.RuleName,
+		SensorID:        alert.SensorID,
+		MeasurementType: alert.MeasurementType,
+		Operator:        alert.Operator,
+		Threshold:       alert.Threshold,
+		FiredValue:      alert.FiredValue,
+		FiredAt:         alert.FiredAt,
+		DelayMinutes:    int(delay.Minutes()),
+	}, now)
+	outgoing.NextAttemptAt = now
+
+	return outgoing, err
+}
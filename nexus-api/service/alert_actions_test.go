@@ -0,0 +1,98 @@
+package service
+
+import (
+	"nexus-api/clients/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestAlertActionsStepThroughStates(t *testing.T) {
+	// setup test data
+	firedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	alert := database.Alert{ID: 7, Severity: database.AlertSeverityCritical, CreatedAt: firedAt}
+
+	// execute test and assert results
+	assert.Equal(t, database.AlertStateFiring, alert.State(firedAt))
+
+	acknowledgedAt := firedAt.Add(5 * time.Minute)
+	event, err := acknowledgeAlert(&alert, "alice", "on my way", acknowledgedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, database.AlertEvent{Action: database.AlertActionAcknowledged, Actor: "alice", Note: "on my way"}, event)
+	assert.Equal(t, "alice", alert.AcknowledgedBy)
+	assert.Equal(t, database.AlertStateAcknowledged, alert.State(acknowledgedAt))
+
+	_, err = acknowledgeAlert(&alert, "bob", "", acknowledgedAt.Add(time.Minute))
+	assert.ErrorIs(t, err, database.ErrorAlertAcknowledged)
+	assert.Equal(t, "alice", alert.AcknowledgedBy)
+
+	until := firedAt.Add(2 * time.Hour)
+	event = snoozeAlert(&alert, "alice", until, "")
+	assert.Equal(t, database.AlertActionSnoozed, event.Action)
+	assert.Equal(t, until, *event.SnoozedUntil)
+	assert.Equal(t, database.AlertStateSnoozed, alert.State(firedAt.Add(time.Hour)))
+	// back to acknowledged once the snooze ends
+	assert.Equal(t, database.AlertStateAcknowledged, alert.State(until))
+
+	event = assignAlert(&alert, "alice", "bob", "")
+	assert.Equal(t, database.AlertEvent{Action: database.AlertActionAssigned, Actor: "alice", AssignedTo: "bob"}, event)
+	assert.Equal(t, "bob", alert.AssignedTo)
+
+	resolvedAt := until.Add(time.Hour)
+	event = resolveAlert(&alert, "bob", "valve replaced", resolvedAt)
+	assert.Equal(t, database.AlertEvent{Action: database.AlertActionResolved, Actor: "bob", Note: "valve replaced"}, event)
+	assert.Equal(t, resolvedAt, *alert.ResolvedAt)
+	assert.Nil(t, alert.ResolvedValue)
+	assert.Equal(t, "valve replaced", alert.ResolutionNote)
+	assert.Equal(t, database.AlertStateResolved, alert.State(firedAt))
+}
+
+func TestUnitTestAlertEscalationDue(t *testing.T) {
+	// setup test data
+	firedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	delay := 30 * time.Minute
+	critical := database.Alert{Severity: database.AlertSeverityCritical, CreatedAt: firedAt}
+
+	acknowledged := critical
+	acknowledged.AcknowledgedAt = &firedAt
+
+	snoozedUntil := firedAt.Add(time.Hour)
+	snoozed := critical
+	snoozed.SnoozedUntil = &snoozedUntil
+
+	escalated := critical
+	escalated.EscalatedAt = &firedAt
+
+	resolved := critical
+	resolved.ResolvedAt = &firedAt
+
+	warning := critical
+	warning.Severity = database.AlertSeverityWarning
+
+	// execute test and assert results
+	assert.False(t, critical.EscalationDue(delay, firedAt.Add(29*time.Minute)))
+	assert.True(t, critical.EscalationDue(delay, firedAt.Add(30*time.Minute)))
+
+	// the delay starts over when a snooze ends
+	assert.Equal(t, snoozedUntil.Add(delay), snoozed.EscalatesAt(delay))
+	assert.False(t, snoozed.EscalationDue(delay, firedAt.Add(time.Hour)))
+	assert.True(t, snoozed.EscalationDue(delay, firedAt.Add(90*time.Minute)))
+
+	later := firedAt.Add(24 * time.Hour)
+	assert.False(t, acknowledged.EscalationDue(delay, later))
+	assert.False(t, escalated.EscalationDue(delay, later))
+	assert.False(t, resolved.EscalationDue(delay, later))
+	assert.False(t, warning.EscalationDue(delay, later))
+}
+
+func TestUnitTestParseAlertRecipients(t *testing.T) {
+	// execute test
+	recipients := parseAlertRecipients([]string{" alice", "bob", "", "alice ", "carol"})
+	none := parseAlertRecipients(nil)
+
+	// assert results
+	assert.Equal(t, []string{"alice", "bob", "carol"}, recipients)
+	assert.NotNil(t, none)
+	assert.Empty(t, none)
+}
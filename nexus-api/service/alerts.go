@@ -0,0 +1,709 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultAlertsPageSize = 100
+	maxAlertsPageSize     = 1000
+	// maxAlertRuleNameLength bounds the name of an alert rule
+	maxAlertRuleNameLength = 255
+	// maxAlertRuleDuration bounds how long a rule's condition can be made to hold
+	maxAlertRuleDuration = 30 * 24 * time.Hour
+	// maxAlertRecipients bounds each group of users told about a rule's alerts
+	maxAlertRecipients = 50
+)
+
+// alertRuleToAPI converts an alert rule to its api representation
+func alertRuleToAPI(rule database.AlertRule) api.AlertRule {
+	apiRule := api.AlertRule{
+		ID:              rule.ID.String(),
+		Name:            rule.Name,
+		MeasurementType: rule.MeasurementType,
+		SensorID:        rule.SensorID,
+		Operator:        rule.Operator,
+		Threshold:       rule.Threshold,
+		DurationSeconds: rule.DurationSeconds,
+		Hysteresis:      rule.Hysteresis,
+		Severity:        rule.Severity,
+		Enabled:         rule.Enabled,
+		// copied so rules without recipients list none rather than null
+		Recipients:           append([]string{}, rule.Recipients...),
+		EscalationRecipients: append([]string{}, rule.EscalationRecipients...),
+		CreatedBy:            rule.CreatedBy,
+		CreatedAt:            rule.CreatedAt,
+		UpdatedAt:            rule.UpdatedAt,
+	}
+	if rule.ZoneID != nil {
+		apiRule.ZoneID = rule.ZoneID.String()
+	}
+
+	return apiRule
+}
+
+// alertToAPI converts an alert to its api representation in its state at now
+func alertToAPI(alert database.Alert, now time.Time) api.Alert {
+	apiAlert := api.Alert{
+		ID:              alert.ID,
+		RuleName:        alert.RuleName,
+		SensorID:        alert.SensorID,
+		MeasurementType: alert.MeasurementType,
+		Operator:        alert.Operator,
+		Threshold:       alert.Threshold,
+		Severity:        alert.Severity,
+		State:           alert.State(now),
+		BreachingSince:  alert.BreachingSince,
+		FiredAt:         alert.FiredAt,
+		FiredValue:      alert.FiredValue,
+		Recipients:      append([]string{}, alert.Recipients...),
+		AcknowledgedAt:  alert.AcknowledgedAt,
+		AcknowledgedBy:  alert.AcknowledgedBy,
+		SnoozedUntil:    alert.SnoozedUntil,
+		AssignedTo:      alert.AssignedTo,
+		EscalatedAt:     alert.EscalatedAt,
+		EscalatedTo:     alert.EscalatedTo,
+		ResolvedAt:      alert.ResolvedAt,
+		ResolvedValue:   alert.ResolvedValue,
+		ResolvedBy:      alert.ResolvedBy,
+		ResolutionNote:  alert.ResolutionNote,
+	}
+	if alert.RuleID != nil {
+		apiAlert.RuleID = alert.RuleID.String()
+	}
+
+	return apiAlert
+}
+
+// validateAlertRule checks the fields of a rule that don't refer to other records
+func validateAlertRule(rule database.AlertRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(rule.Name) > maxAlertRuleNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxAlertRuleNameLength)
+	}
+	if rule.MeasurementType == "" {
+		return fmt.Errorf("measurement_type is required")
+	}
+	if rule.SensorID != "" && rule.ZoneID != nil {
+		return fmt.Errorf("a rule can watch a sensor or a zone, not both")
+	}
+
+	switch rule.Operator {
+	case database.AlertOperatorAbove, database.AlertOperatorBelow:
+	default:
+		return fmt.Errorf("operator must be %q or %q", database.AlertOperatorAbove, database.AlertOperatorBelow)
+	}
+
+	if math.IsNaN(rule.Threshold) || math.IsInf(rule.Threshold, 0) {
+		return fmt.Errorf("threshold must be a number")
+	}
+	if rule.DurationSeconds < 0 || rule.Duration() > maxAlertRuleDuration {
+		return fmt.Errorf("duration_seconds must be between 0 and %d", int(maxAlertRuleDuration.Seconds()))
+	}
+	if !(rule.Hysteresis >= 0) || math.IsInf(rule.Hysteresis, 0) {
+		return fmt.Errorf("hysteresis must be a number of at least 0")
+	}
+
+	switch rule.Severity {
+	case database.AlertSeverityInfo, database.AlertSeverityWarning, database.AlertSeverityCritical:
+	default:
+		return fmt.Errorf("severity must be %q, %q or %q", database.AlertSeverityInfo, database.AlertSeverityWarning, database.AlertSeverityCritical)
+	}
+
+	if len(rule.Recipients) > maxAlertRecipients || len(rule.EscalationRecipients) > maxAlertRecipients {
+		return fmt.Errorf("recipients and escalation_recipients must each list at most %d users", maxAlertRecipients)
+	}
+
+	return nil
+}
+
+// parseAlertRecipients trims the usernames of a group of recipients, dropping blanks and
+// repeats. It never returns nil, so the group is saved as empty rather than null
+func parseAlertRecipients(usernames []string) []string {
+	recipients := make([]string, 0, len(usernames))
+	seen := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		username = strings.TrimSpace(username)
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		recipients = append(recipients, username)
+	}
+
+	return recipients
+}
+
+// parseAlertRuleZoneID reads the zone id of a rule, empty for none
+func parseAlertRuleZoneID(value string) (*uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	zoneID, err := uuid.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("zone_id must be the id of a zone")
+	}
+
+	return &zoneID, nil
+}
+
+// checkAlertRuleReferences makes sure the measurement type, sensor, zone and recipients a
+// rule refers to exist, answering the request directly and returning false if one doesn't
+func checkAlertRuleReferences(apiService *APIService, w http.ResponseWriter, r *http.Request, rule database.AlertRule) bool {
+	_, err := database.GetMeasurementType(r.Context(), apiService.DatabaseClient.DB, rule.MeasurementType)
+	if errors.Is(err, database.ErrorNoMeasurementType) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown measurement type %q", rule.MeasurementType)})
+		return false
+	}
+
+	if err == nil && rule.SensorID != "" {
+		_, err = database.GetSensorByID(r.Context(), apiService.DatabaseClient.DB, rule.SensorID)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown sensor %q", rule.SensorID)})
+			return false
+		}
+	}
+
+	if err == nil && rule.ZoneID != nil {
+		_, err = database.GetZone(r.Context(), apiService.DatabaseClient.DB, *rule.ZoneID)
+		if errors.Is(err, database.ErrorNoZone) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Unknown zone"})
+			return false
+		}
+	}
+
+	recipients := append(append([]string{}, rule.Recipients...), rule.EscalationRecipients...)
+	for i := 0; err == nil && i < len(recipients); i++ {
+		_, err = database.GetLoginAuthenticationByUserName(r.Context(), apiService.DatabaseClient.DB, recipients[i])
+		if errors.Is(err, database.ErrorNoLoginAuthenticationForUsername) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown user %q", recipients[i])})
+			return false
+		}
+	}
+
+	if err != nil {
+		apiService.Error().Msgf("Error checking the references of alert rule %s: %s", rule.Name, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return false
+	}
+
+	return true
+}
+
+// alertRuleRestarts returns whether changing a rule from before to after restarts its
+// evaluation, which it does when it is disabled or what it watches for changes
+func alertRuleRestarts(before database.AlertRule, after database.AlertRule) bool {
+	if !after.Enabled {
+		return before.Enabled
+	}
+
+	return before.MeasurementType != after.MeasurementType ||
+		before.SensorID != after.SensorID ||
+		(before.ZoneID == nil) != (after.ZoneID == nil) ||
+		(before.ZoneID != nil && *before.ZoneID != *after.ZoneID) ||
+		before.Operator != after.Operator ||
+		before.Threshold != after.Threshold ||
+		before.DurationSeconds != after.DurationSeconds
+}
+
+// evaluateAlertRule steps a rule's state for a sensor through the sensor's readings in
+// date order, returning the new state and the alerts fired or resolved on the way. open
+// is the alert the rule has open for the sensor, nil for none. Readings no newer than the
+// last one evaluated are skipped, so readings arriving late can't fire alerts out of order
+func evaluateAlertRule(rule database.AlertRule, state database.AlertRuleState, open *database.Alert, readings []database.SensorMeasurement) (database.AlertRuleState, []*database.Alert) {
+	var changed []*database.Alert
+
+	for _, reading := range readings {
+		if state.LastReadingAt != nil && !reading.Date.After(*state.LastReadingAt) {
+			continue
+		}
+		date, value := reading.Date, reading.Value
+		state.LastReadingAt = &date
+
+		if open != nil {
+			if rule.Recovered(value) {
+				open.ResolvedAt, open.ResolvedValue = &date, &value
+				// alerts fired by these readings are already in changed
+				if open.ID != 0 {
+					changed = append(changed, open)
+				}
+				open = nil
+			}
+			continue
+		}
+
+		if !rule.Breached(value) {
+			state.BreachingSince = nil
+			continue
+		}
+		if state.BreachingSince == nil {
+			state.BreachingSince = &date
+		}
+		if date.Sub(*state.BreachingSince) < rule.Duration() {
+			continue
+		}
+
+		ruleID := rule.ID
+		open = &database.Alert{
+			RuleID:          &ruleID,
+			RuleName:        rule.Name,
+			SensorID:        state.SensorID,
+			MeasurementType: rule.MeasurementType,
+			Operator:        rule.Operator,
+			Threshold:       rule.Threshold,
+			Severity:        rule.Severity,
+			BreachingSince:  *state.BreachingSince,
+			FiredAt:         date,
+			FiredValue:      value,
+			Recipients:      append([]string{}, rule.Recipients...),
+		}
+		state.BreachingSince = nil
+		changed = append(changed, open)
+	}
+
+	return state, changed
+}
+
+// evaluateSensorAlertRules checks readings a sensor just stored against the enabled
+// rules watching them, returning the alerts fired or resolved. Readings are calibrated
+// first, so rules compare the values users see
+func evaluateSensorAlertRules(ctx context.Context, db *bun.DB, sensorID string, readings []database.SensorMeasurement) ([]database.Alert, error) {
+	sensor, err := database.GetSensorByID(ctx, db, sensorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var measurementTypes []string
+	byType := make(map[string][]database.SensorMeasurement)
+	for _, reading := range readings {
+		if _, ok := byType[reading.MeasurementType]; !ok {
+			measurementTypes = append(measurementTypes, reading.MeasurementType)
+		}
+		byType[reading.MeasurementType] = append(byType[reading.MeasurementType], reading)
+	}
+
+	rules, err := database.GetSensorAlertRules(ctx, db, sensorID, sensor.ZoneID, measurementTypes)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	calibrations, err := database.GetSensorCalibrations(ctx, db, []string{sensorID}, measurementTypes)
+	if err != nil {
+		return nil, err
+	}
+	for measurementType, series := range byType {
+		calibrated := make([]database.SensorMeasurement, 0, len(series))
+		for _, reading := range series {
+			reading.Value = calibrations.Calibrate(sensorID, measurementType, reading.Date, reading.Value)
+			calibrated = append(calibrated, reading)
+		}
+		sort.SliceStable(calibrated, func(i, j int) bool {
+			return calibrated[i].Date.Before(calibrated[j].Date)
+		})
+		byType[measurementType] = calibrated
+	}
+
+	var alerts []database.Alert
+	for _, rule := range rules {
+		changed, err := database.EvaluateAlertRule(ctx, db, rule.ID, sensorID, func(state database.AlertRuleState, open *database.Alert) (database.AlertRuleState, []*database.Alert) {
+			return evaluateAlertRule(rule, state, open, byType[rule.MeasurementType])
+		})
+		if err != nil {
+			return alerts, fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		alerts = append(alerts, changed...)
+	}
+
+	return alerts, nil
+}
+
+// getAlertRule looks up the alert rule named in the request path, answering the request
+// directly and returning false if it can't be found
+func getAlertRule(apiService *APIService, w http.ResponseWriter, r *http.Request) (database.AlertRule, bool) {
+	ruleID, err := uuid.Parse(mux.Vars(r)["rule_id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert rule not found"})
+		return database.AlertRule{}, false
+	}
+
+	rule, err := database.GetAlertRule(r.Context(), apiService.DatabaseClient.DB, ruleID)
+	if err != nil {
+		if errors.Is(err, database.ErrorNoAlertRule) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert rule not found"})
+			return database.AlertRule{}, false
+		}
+
+		apiService.Error().Msgf("Error retrieving alert rule %s: %s", ruleID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return database.AlertRule{}, false
+	}
+
+	return rule, true
+}
+
+// CreateGetAlertRulesHandler returns a handler that lists the alert rules by name
+func CreateGetAlertRulesHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := database.GetAlertRules(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving alert rules: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetAlertRulesResponse{
+			Rules: make([]api.AlertRule, 0, len(rules)),
+		}
+		for _, rule := range rules {
+			response.Rules = append(response.Rules, alertRuleToAPI(rule))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateGetAlertRuleHandler returns a handler that returns an alert rule
+func CreateGetAlertRuleHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := getAlertRule(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(alertRuleToAPI(rule))
+	}
+}
+
+// CreateCreateAlertRuleHandler returns a handler that adds an alert rule, it is evaluated
+// against the readings saved from then on
+func CreateCreateAlertRuleHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.CreateAlertRuleRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		rule := database.AlertRule{
+			Name:            strings.TrimSpace(request.Name),
+			MeasurementType: request.MeasurementType,
+			SensorID:        request.SensorID,
+			Operator:        request.Operator,
+			Threshold:       request.Threshold,
+			DurationSeconds: request.DurationSeconds,
+			Hysteresis:      request.Hysteresis,
+			Severity:        request.Severity,
+			Enabled:         request.Enabled == nil || *request.Enabled,
+			// recipients are saved as empty rather than null when there are none
+			Recipients:           parseAlertRecipients(request.Recipients),
+			EscalationRecipients: parseAlertRecipients(request.EscalationRecipients),
+			CreatedBy:            username,
+		}
+		if alias, ok := measurementTypeAliases[rule.MeasurementType]; ok {
+			rule.MeasurementType = alias
+		}
+		if rule.Severity == "" {
+			rule.Severity = database.AlertSeverityWarning
+		}
+
+		rule.ZoneID, err = parseAlertRuleZoneID(request.ZoneID)
+		if err == nil {
+			err = validateAlertRule(rule)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		if !checkAlertRuleReferences(apiService, w, r, rule) {
+			return
+		}
+
+		err = rule.Save(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error saving alert rule %s: %s", rule.Name, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Alert rule %s (%s) added by %s", rule.Name, rule.ID, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(alertRuleToAPI(rule))
+	}
+}
+
+// CreateUpdateAlertRuleHandler returns a handler that changes an alert rule, leaving
+// fields that are not in the request unchanged. Disabling the rule or changing what it
+// watches for resolves the alerts it has open and starts its evaluation over
+func CreateUpdateAlertRuleHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.UpdateAlertRuleRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		before, ok := getAlertRule(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		rule := before
+		if request.Name != nil {
+			rule.Name = strings.TrimSpace(*request.Name)
+		}
+		if request.MeasurementType != nil {
+			rule.MeasurementType = *request.MeasurementType
+			if alias, ok := measurementTypeAliases[rule.MeasurementType]; ok {
+				rule.MeasurementType = alias
+			}
+		}
+		if request.SensorID != nil {
+			rule.SensorID = *request.SensorID
+		}
+		if request.ZoneID != nil {
+			rule.ZoneID, err = parseAlertRuleZoneID(*request.ZoneID)
+		}
+		if request.Operator != nil {
+			rule.Operator = *request.Operator
+		}
+		if request.Threshold != nil {
+			rule.Threshold = *request.Threshold
+		}
+		if request.DurationSeconds != nil {
+			rule.DurationSeconds = *request.DurationSeconds
+		}
+		if request.Hysteresis != nil {
+			rule.Hysteresis = *request.Hysteresis
+		}
+		if request.Severity != nil {
+			rule.Severity = *request.Severity
+		}
+		if request.Enabled != nil {
+			rule.Enabled = *request.Enabled
+		}
+		if request.Recipients != nil {
+			rule.Recipients = *request.Recipients
+		}
+		if request.EscalationRecipients != nil {
+			rule.EscalationRecipients = *request.EscalationRecipients
+		}
+		// recipients are saved as empty rather than null when there are none
+		rule.Recipients = parseAlertRecipients(rule.Recipients)
+		rule.EscalationRecipients = parseAlertRecipients(rule.EscalationRecipients)
+
+		if err == nil {
+			err = validateAlertRule(rule)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		if !checkAlertRuleReferences(apiService, w, r, rule) {
+			return
+		}
+
+		err = rule.Update(r.Context(), apiService.DatabaseClient.DB, alertRuleRestarts(before, rule))
+		if err != nil {
+			if errors.Is(err, database.ErrorNoAlertRule) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert rule not found"})
+				return
+			}
+
+			apiService.Error().Msgf("Error updating alert rule %s: %s", rule.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Alert rule %s (%s) updated by %s", rule.Name, rule.ID, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(alertRuleToAPI(rule))
+	}
+}
+
+// CreateDeleteAlertRuleHandler returns a handler that deletes an alert rule, the alerts it
+// has open are resolved and its alert history is kept
+func CreateDeleteAlertRuleHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		rule, ok := getAlertRule(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		err := database.DeleteAlertRule(r.Context(), apiService.DatabaseClient.DB, rule.ID)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoAlertRule) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert rule not found"})
+				return
+			}
+
+			apiService.Error().Msgf("Error deleting alert rule %s: %s", rule.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Alert rule %s (%s) deleted by %s", rule.Name, rule.ID, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Alert rule deleted successfully"})
+	}
+}
+
+// CreateGetAlertsHandler returns a handler that lists the alert history newest first,
+// optionally only the alerts of a rule or sensor, in a state, or fired in a range
+func CreateGetAlertsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseAlertFilter(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		alerts, err := database.GetAlerts(r.Context(), apiService.DatabaseClient.DB, filter)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving alerts: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetAlertsResponse{
+			Alerts: make([]api.Alert, 0, len(alerts)),
+		}
+
+		if len(alerts) > filter.Limit {
+			alerts = alerts[:filter.Limit]
+			nextBeforeID := alerts[filter.Limit-1].ID
+			response.NextBeforeID = &nextBeforeID
+		}
+
+		now := time.Now()
+		for _, alert := range alerts {
+			response.Alerts = append(response.Alerts, alertToAPI(alert, now))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// getAlert looks up the alert named in the request path, answering the request
+// directly and returning false if it can't be found
+func getAlert(apiService *APIService, w http.ResponseWriter, r *http.Request) (database.Alert, bool) {
+	alertID, err := strconv.ParseInt(mux.Vars(r)["alert_id"], 10, 64)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert not found"})
+		return database.Alert{}, false
+	}
+
+	alert, err := database.GetAlert(r.Context(), apiService.DatabaseClient.DB, alertID)
+	if err != nil {
+		if errors.Is(err, database.ErrorNoAlert) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Alert not found"})
+			return database.Alert{}, false
+		}
+
+		apiService.Error().Msgf("Error retrieving alert %d: %s", alertID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return database.Alert{}, false
+	}
+
+	return alert, true
+}
+
+// CreateGetAlertHandler returns a handler that returns an alert
+func CreateGetAlertHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		alert, ok := getAlert(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(alertToAPI(alert, time.Now()))
+	}
+}
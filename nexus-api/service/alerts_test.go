@@ -0,0 +1,169 @@
+package service
+
+import (
+	"nexus-api/clients/database"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// testAlertReadings returns readings of value taken a minute apart from start
+func testAlertReadings(start time.Time, values ...float64) []database.SensorMeasurement {
+	readings := make([]database.SensorMeasurement, 0, len(values))
+	for i, value := range values {
+		readings = append(readings, database.SensorMeasurement{
+			SensorID:        "abc",
+			MeasurementType: database.MeasurementTypeSoilMoisture,
+			Date:            start.Add(time.Duration(i) * time.Minute),
+			Value:           value,
+		})
+	}
+
+	return readings
+}
+
+func TestUnitTestEvaluateAlertRuleFiresAfterDurationAndResolvesPastHysteresis(t *testing.T) {
+	// setup test data
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	rule := database.AlertRule{
+		ID:              uuid.New(),
+		Name:            "Field dry",
+		MeasurementType: database.MeasurementTypeSoilMoisture,
+		Operator:        database.AlertOperatorBelow,
+		Threshold:       20,
+		DurationSeconds: 120,
+		Hysteresis:      5,
+		Severity:        database.AlertSeverityCritical,
+	}
+	state := database.AlertRuleState{RuleID: rule.ID, SensorID: "abc"}
+	// dips below, recovers, then stays below for two minutes, hovers just above the
+	// threshold and finally recovers past the hysteresis
+	readings := testAlertReadings(start, 19, 21, 18, 17, 16, 22, 24, 26)
+
+	// execute test
+	state, changed := evaluateAlertRule(rule, state, nil, readings)
+
+	// assert results
+	if assert.Len(t, changed, 1) {
+		alert := changed[0]
+		assert.Equal(t, rule.ID, *alert.RuleID)
+		assert.Equal(t, "abc", alert.SensorID)
+		assert.Equal(t, database.AlertSeverityCritical, alert.Severity)
+		assert.Equal(t, start.Add(2*time.Minute), alert.BreachingSince)
+		assert.Equal(t, start.Add(4*time.Minute), alert.FiredAt)
+		assert.Equal(t, 16.0, alert.FiredValue)
+		if assert.NotNil(t, alert.ResolvedAt) {
+			assert.Equal(t, start.Add(7*time.Minute), *alert.ResolvedAt)
+			assert.Equal(t, 26.0, *alert.ResolvedValue)
+		}
+	}
+	assert.Equal(t, start.Add(7*time.Minute), *state.LastReadingAt)
+	assert.Nil(t, state.BreachingSince)
+}
+
+func TestUnitTestEvaluateAlertRuleCarriesStateAcrossBatches(t *testing.T) {
+	// setup test data
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	rule := database.AlertRule{
+		ID:              uuid.New(),
+		Operator:        database.AlertOperatorAbove,
+		Threshold:       30,
+		DurationSeconds: 60,
+	}
+	state := database.AlertRuleState{RuleID: rule.ID, SensorID: "abc"}
+
+	// execute test
+	state, first := evaluateAlertRule(rule, state, nil, testAlertReadings(start, 31))
+	state, second := evaluateAlertRule(rule, state, nil, testAlertReadings(start.Add(time.Minute), 32))
+	open := second[0]
+	open.ID = 7
+	// a late reading from before the last one evaluated is skipped
+	state, late := evaluateAlertRule(rule, state, open, testAlertReadings(start, 10))
+	_, resolved := evaluateAlertRule(rule, state, open, testAlertReadings(start.Add(2*time.Minute), 30))
+
+	// assert results
+	assert.Empty(t, first, "the condition has not held for the duration yet")
+	assert.Len(t, second, 1)
+	assert.Equal(t, start, open.BreachingSince)
+	assert.Empty(t, late)
+	if assert.Len(t, resolved, 1) {
+		assert.Equal(t, int64(7), resolved[0].ID)
+		assert.Equal(t, 30.0, *resolved[0].ResolvedValue)
+	}
+}
+
+func TestUnitTestEvaluateAlertRuleRefiresWithinABatch(t *testing.T) {
+	// setup test data
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	rule := database.AlertRule{ID: uuid.New(), Operator: database.AlertOperatorAbove, Threshold: 30}
+	state := database.AlertRuleState{RuleID: rule.ID, SensorID: "abc"}
+
+	// execute test
+	_, changed := evaluateAlertRule(rule, state, nil, testAlertReadings(start, 31, 29, 35))
+
+	// assert results
+	if assert.Len(t, changed, 2) {
+		assert.NotNil(t, changed[0].ResolvedAt)
+		assert.Equal(t, 35.0, changed[1].FiredValue)
+		assert.Nil(t, changed[1].ResolvedAt)
+	}
+}
+
+func TestUnitTestValidateAlertRule(t *testing.T) {
+	// setup test data
+	zoneID := uuid.New()
+	valid := database.AlertRule{
+		Name:            "Battery low",
+		MeasurementType: database.MeasurementTypeBatteryLevel,
+		Operator:        database.AlertOperatorBelow,
+		Threshold:       20,
+		Severity:        database.AlertSeverityWarning,
+	}
+
+	// execute & assert results
+	assert.NoError(t, validateAlertRule(valid))
+
+	for name, change := range map[string]func(rule *database.AlertRule){
+		"no name":             func(rule *database.AlertRule) { rule.Name = "" },
+		"no type":             func(rule *database.AlertRule) { rule.MeasurementType = "" },
+		"sensor and zone":     func(rule *database.AlertRule) { rule.SensorID, rule.ZoneID = "abc", &zoneID },
+		"unknown operator":    func(rule *database.AlertRule) { rule.Operator = "equals" },
+		"negative duration":   func(rule *database.AlertRule) { rule.DurationSeconds = -1 },
+		"long duration":       func(rule *database.AlertRule) { rule.DurationSeconds = 31 * 24 * 3600 },
+		"negative hysteresis": func(rule *database.AlertRule) { rule.Hysteresis = -1 },
+		"unknown severity":    func(rule *database.AlertRule) { rule.Severity = "urgent" },
+	} {
+		rule := valid
+		change(&rule)
+
+		assert.Error(t, validateAlertRule(rule), name)
+	}
+}
+
+func TestUnitTestAlertRuleRestarts(t *testing.T) {
+	// setup test data
+	rule := database.AlertRule{
+		Name:            "Field dry",
+		MeasurementType: database.MeasurementTypeSoilMoisture,
+		Operator:        database.AlertOperatorBelow,
+		Threshold:       20,
+		Severity:        database.AlertSeverityWarning,
+		Enabled:         true,
+	}
+
+	renamed, raised, disabled, zoned := rule, rule, rule, rule
+	renamed.Name, renamed.Severity, renamed.Hysteresis = "Block A dry", database.AlertSeverityCritical, 2
+	raised.Threshold = 25
+	disabled.Enabled = false
+	zoneID := uuid.New()
+	zoned.ZoneID = &zoneID
+
+	// execute & assert results
+	assert.False(t, alertRuleRestarts(rule, renamed))
+	assert.True(t, alertRuleRestarts(rule, raised))
+	assert.True(t, alertRuleRestarts(rule, disabled))
+	assert.False(t, alertRuleRestarts(disabled, disabled))
+	assert.True(t, alertRuleRestarts(rule, zoned))
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"sort"
+	"time"
+)
+
+const (
+	BatteryForecastStatusForecast         = "forecast"
+	BatteryForecastStatusBelowCutoff      = "below_cutoff"
+	BatteryForecastStatusNotDischarging   = "not_discharging"
+	BatteryForecastStatusInsufficientData = "insufficient_data"
+
+	defaultBatteryCutoff         = 10.0
+	defaultBatteryForecastWindow = 30 * 24 * time.Hour
+	maxBatteryForecastWindow     = 365 * 24 * time.Hour
+	defaultBatterySwapWithinDays = 14
+	maxBatterySwapWithinDays     = 365
+
+	// batterySwapRise is the smallest rise in level between two readings taken to
+	// be a battery swap rather than noise in the readings
+	batterySwapRise = 10.0
+	// a line is only fitted to enough readings spread over long enough for the
+	// discharge to show through the noise
+	minBatteryForecastReadings = 3
+	minBatteryForecastSpan     = 24 * time.Hour
+	// maxBatteryForecastDays bounds predictions, batteries lasting longer are
+	// treated as not discharging
+	maxBatteryForecastDays = 3650.0
+)
+
+// batteryForecastQuery is the cutoff, the window of readings fitted and, for the
+// fleet forecast, the days ahead sensors due for a swap are listed for
+type batteryForecastQuery struct {
+	Cutoff     float64
+	Window     time.Duration
+	WithinDays int
+}
+
+// batteryLine is a straight line fitted to battery readings by least squares. Times
+// are in days before the last reading, so Level is the fitted level at the last reading
+type batteryLine struct {
+	Level float64
+	Slope float64
+	// Count, MeanDays, MeanLevel, SumSquaresDays and ResidualError describe the
+	// spread of the readings, used to work out the confidence range
+	Count          int
+	MeanDays       float64
+	MeanLevel      float64
+	SumSquaresDays float64
+	ResidualError  float64
+}
+
+// fitBatteryLine fits a line to the readings, which are ordered by date
+func fitBatteryLine(readings []database.SensorMeasurement) batteryLine {
+	last := readings[len(readings)-1].Date
+	line := batteryLine{Count: len(readings)}
+
+	days := make([]float64, len(readings))
+	for i, reading := range readings {
+		days[i] = reading.Date.Sub(last).Hours() / 24
+		line.MeanDays += days[i]
+		line.MeanLevel += reading.Value
+	}
+	line.MeanDays /= float64(line.Count)
+	line.MeanLevel /= float64(line.Count)
+
+	var sumProducts float64
+	for i, reading := range readings {
+		line.SumSquaresDays += (days[i] - line.MeanDays) * (days[i] - line.MeanDays)
+		sumProducts += (days[i] - line.MeanDays) * (reading.Value - line.MeanLevel)
+	}
+	line.Slope = sumProducts / line.SumSquaresDays
+	line.Level = line.MeanLevel - line.Slope*line.MeanDays
+
+	if line.Count > 2 {
+		var sumResiduals float64
+		for i, reading := range readings {
+			residual := reading.Value - (line.Level + line.Slope*days[i])
+			sumResiduals += residual * residual
+		}
+		line.ResidualError = math.Sqrt(sumResiduals / float64(line.Count-2))
+	}
+
+	return line
+}
+
+// crossingRange returns the days after the last reading that the 95% confidence band
+// of the line crosses level, the Fieller interval for the crossing. ok is false when
+// the slope is too uncertain for the range to be bounded
+func (l batteryLine) crossingRange(level float64) (earliest float64, latest float64, ok bool) {
+	spread := studentT95(l.Count-2) * l.ResidualError
+	// solve (MeanLevel - level + Slope*u)^2 = spread^2 * (1/Count + u^2/SumSquaresDays)
+	// for u, the days from the mean reading time
+	a := l.Slope*l.Slope - spread*spread/l.SumSquaresDays
+	if a <= 0 {
+		return 0, 0, false
+	}
+	offset := l.MeanLevel - level
+	b := l.Slope * offset
+	c := offset*offset - spread*spread/float64(l.Count)
+	root := math.Sqrt(math.Max(0, b*b-a*c))
+
+	first, second := (-b-root)/a+l.MeanDays, (-b+root)/a+l.MeanDays
+
+	return math.Min(first, second), math.Max(first, second), true
+}
+
+// studentT95 returns the two sided 95% critical value of Student's t distribution
+// with degreesOfFreedom, the normal value is close enough past 30
+func studentT95(degreesOfFreedom int) float64 {
+	critical := []float64{
+		12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+	}
+	if degreesOfFreedom < 1 {
+		return math.Inf(1)
+	}
+	if degreesOfFreedom > len(critical) {
+		return 1.96
+	}
+
+	return critical[degreesOfFreedom-1]
+}
+
+// forecastBattery predicts when a sensor's battery falls to cutoff from its battery
+// readings ordered by date. Readings before the last rise of batterySwapRise or more
+// are from a battery that has since been swapped and are left out of the fit
+func forecastBattery(sensor database.Sensor, readings []database.SensorMeasurement, cutoff float64, now time.Time) api.BatteryForecast {
+	forecast := api.BatteryForecast{
+		SensorID: sensor.ID,
+		Name:     sensor.Name,
+		Status:   BatteryForecastStatusInsufficientData,
+		Cutoff:   cutoff,
+	}
+	if len(readings) == 0 {
+		return forecast
+	}
+
+	swap := 0
+	for i := 1; i < len(readings); i++ {
+		if readings[i].Value-readings[i-1].Value >= batterySwapRise {
+			swap = i
+		}
+	}
+	if swap > 0 {
+		swappedAt := readings[swap].Date.UTC()
+		forecast.LastSwapAt = &swappedAt
+	}
+	readings = readings[swap:]
+
+	first, last := readings[0], readings[len(readings)-1]
+	fitStart, lastReadingAt, level := first.Date.UTC(), last.Date.UTC(), last.Value
+	forecast.FitStart = &fitStart
+	forecast.LastReadingAt = &lastReadingAt
+	forecast.CurrentLevel = &level
+	forecast.ReadingsUsed = len(readings)
+
+	if level <= cutoff {
+		forecast.Status = BatteryForecastStatusBelowCutoff
+		forecast.DaysRemaining = new(float64)
+		return forecast
+	}
+	if len(readings) < minBatteryForecastReadings || last.Date.Sub(first.Date) < minBatteryForecastSpan {
+		return forecast
+	}
+
+	line := fitBatteryLine(readings)
+	rate := math.Round(-line.Slope*1000) / 1000
+	forecast.DischargeRatePerDay = &rate
+
+	// the fitted line may already be below the cutoff when the last reading is not
+	days := math.Max(0, (cutoff-line.Level)/line.Slope)
+	if line.Slope >= 0 || days > maxBatteryForecastDays {
+		forecast.Status = BatteryForecastStatusNotDischarging
+		return forecast
+	}
+
+	forecast.Status = BatteryForecastStatusForecast
+	daysAfter := func(days float64) *time.Time {
+		at := lastReadingAt.Add(time.Duration(days * 24 * float64(time.Hour))).Round(time.Second)
+		return &at
+	}
+	forecast.PredictedAt = daysAfter(days)
+	remaining := math.Round(math.Max(0, forecast.PredictedAt.Sub(now).Hours()/24)*10) / 10
+	forecast.DaysRemaining = &remaining
+
+	if earliest, latest, ok := line.crossingRange(cutoff); ok {
+		forecast.EarliestAt = daysAfter(math.Min(math.Max(0, earliest), days))
+		if latest <= maxBatteryForecastDays {
+			forecast.LatestAt = daysAfter(math.Max(latest, days))
+		}
+	}
+
+	return forecast
+}
+
+// getBatteryForecasts forecasts the battery of each of the sensors from their
+// battery readings in the query's window before now
+func getBatteryForecasts(apiService *APIService, r *http.Request, sensors []database.Sensor, query batteryForecastQuery, now time.Time) ([]api.BatteryForecast, error) {
+	filter := database.SensorMeasurementFilter{
+		MeasurementTypes: []string{database.MeasurementTypeBatteryLevel},
+		Start:            now.Add(-query.Window),
+		End:              now,
+	}
+	for _, sensor := range sensors {
+		filter.SensorIDs = append(filter.SensorIDs, sensor.ID)
+	}
+
+	readings := make(map[string][]database.SensorMeasurement)
+	if len(filter.SensorIDs) > 0 {
+		err := database.StreamSensorMeasurements(r.Context(), apiService.DatabaseClient.DB, filter, func(reading database.SensorMeasurement) error {
+			readings[reading.SensorID] = append(readings[reading.SensorID], reading)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	forecasts := make([]api.BatteryForecast, 0, len(sensors))
+	for _, sensor := range sensors {
+		forecasts = append(forecasts, forecastBattery(sensor, readings[sensor.ID], query.Cutoff, now))
+	}
+
+	return forecasts, nil
+}
+
+// readBatteryForecastQuery reads the query parameters of a battery forecast, answering
+// invalid requests directly in which case false is returned
+func readBatteryForecastQuery(w http.ResponseWriter, r *http.Request) (batteryForecastQuery, bool) {
+	query, err := parseBatteryForecastQuery(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+		return query, false
+	}
+
+	return query, true
+}
+
+// CreateGetSensorBatteryForecastHandler returns a handler that predicts when a sensor's
+// battery falls to the cutoff from the trend of its recent battery readings
+func CreateGetSensorBatteryForecastHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		query, ok := readBatteryForecastQuery(w, r)
+		if !ok {
+			return
+		}
+
+		forecasts, err := getBatteryForecasts(apiService, r, []database.Sensor{sensor}, query, time.Now())
+		if err != nil {
+			apiService.Error().Msgf("Error forecasting battery of sensor %s: %s", sensor.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(forecasts[0])
+	}
+}
+
+// CreateGetBatteryForecastsHandler returns a handler that lists the sensors in service
+// whose battery is below the cutoff or predicted to reach it within within_days,
+// for planning battery swaps
+func CreateGetBatteryForecastsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		query, ok := readBatteryForecastQuery(w, r)
+		if !ok {
+			return
+		}
+
+		sensors, err := apiService.DatabaseClient.GetAllSensors(r.Context(), username, false)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving sensors: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		now := time.Now()
+		forecasts, err := getBatteryForecasts(apiService, r, sensors, query, now)
+		if err != nil {
+			apiService.Error().Msgf("Error forecasting sensor batteries: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		until := now.AddDate(0, 0, query.WithinDays)
+		response := api.GetBatteryForecastsResponse{
+			Cutoff:     query.Cutoff,
+			WithinDays: query.WithinDays,
+			Until:      until.UTC(),
+			Sensors:    dueBatteryForecasts(forecasts, until),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// dueBatteryForecasts returns the forecasts of batteries below the cutoff, ordered by
+// sensor, followed by those predicted to reach it by until, soonest first
+func dueBatteryForecasts(forecasts []api.BatteryForecast, until time.Time) []api.BatteryForecast {
+	due := []api.BatteryForecast{}
+	for _, forecast := range forecasts {
+		switch {
+		case forecast.Status == BatteryForecastStatusBelowCutoff:
+		case forecast.Status == BatteryForecastStatusForecast && !forecast.PredictedAt.After(until):
+		default:
+			continue
+		}
+		due = append(due, forecast)
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		if (due[i].PredictedAt == nil) != (due[j].PredictedAt == nil) {
+			return due[i].PredictedAt == nil
+		}
+		if due[i].PredictedAt == nil || due[i].PredictedAt.Equal(*due[j].PredictedAt) {
+			return due[i].SensorID < due[j].SensorID
+		}
+		return due[i].PredictedAt.Before(*due[j].PredictedAt)
+	})
+
+	return due
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"math"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dailyBatteryReadings returns a reading of each level a day apart, the last taken at last
+func dailyBatteryReadings(last time.Time, levels ...float64) []database.SensorMeasurement {
+	readings := make([]database.SensorMeasurement, len(levels))
+	for i, level := range levels {
+		readings[i] = database.SensorMeasurement{
+			MeasurementType: database.MeasurementTypeBatteryLevel,
+			Date:            last.AddDate(0, 0, i-len(levels)+1),
+			Value:           level,
+		}
+	}
+
+	return readings
+}
+
+func TestUnitTestForecastBatteryIgnoresReadingsBeforeASwap(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	sensor := database.Sensor{ID: "a", Name: "North 1"}
+	// a battery falling 5 a day until it was swapped for a full one that falls 2 a day
+	readings := dailyBatteryReadings(now, 35, 30, 25, 20, 98, 96, 94, 92, 90, 88, 86)
+
+	// execute test
+	forecast := forecastBattery(sensor, readings, 10, now)
+
+	// assert results
+	assert.Equal(t, BatteryForecastStatusForecast, forecast.Status)
+	assert.Equal(t, "North 1", forecast.Name)
+	assert.Equal(t, now.AddDate(0, 0, -6), *forecast.LastSwapAt)
+	assert.Equal(t, now.AddDate(0, 0, -6), *forecast.FitStart)
+	assert.Equal(t, 7, forecast.ReadingsUsed)
+	assert.Equal(t, 86.0, *forecast.CurrentLevel)
+	assert.Equal(t, 2.0, *forecast.DischargeRatePerDay)
+	assert.Equal(t, now.AddDate(0, 0, 38), *forecast.PredictedAt)
+	assert.Equal(t, 38.0, *forecast.DaysRemaining)
+	// readings exactly on the line leave no doubt about the crossing
+	assert.Equal(t, *forecast.PredictedAt, *forecast.EarliestAt)
+	assert.Equal(t, *forecast.PredictedAt, *forecast.LatestAt)
+}
+
+func TestUnitTestForecastBatteryConfidenceRangeWidensWithNoise(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	readings := dailyBatteryReadings(now, 80, 76, 77, 71, 73, 66, 68, 61, 63, 56)
+
+	// execute test
+	forecast := forecastBattery(database.Sensor{ID: "a"}, readings, 10, now)
+
+	// assert results
+	assert.Equal(t, BatteryForecastStatusForecast, forecast.Status)
+	assert.Nil(t, forecast.LastSwapAt)
+	assert.Equal(t, 2.442, *forecast.DischargeRatePerDay)
+	assert.True(t, forecast.EarliestAt.Before(*forecast.PredictedAt))
+	assert.True(t, forecast.LatestAt.After(*forecast.PredictedAt))
+	assert.True(t, forecast.EarliestAt.After(now))
+}
+
+func TestUnitTestForecastBatteryStatuses(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	sensor := database.Sensor{ID: "a"}
+
+	// execute test and assert results
+	forecast := forecastBattery(sensor, nil, 10, now)
+	assert.Equal(t, BatteryForecastStatusInsufficientData, forecast.Status)
+	assert.Nil(t, forecast.CurrentLevel)
+
+	forecast = forecastBattery(sensor, dailyBatteryReadings(now, 60, 58), 10, now)
+	assert.Equal(t, BatteryForecastStatusInsufficientData, forecast.Status)
+	assert.Equal(t, 58.0, *forecast.CurrentLevel)
+
+	hourly := []database.SensorMeasurement{
+		{Date: now.Add(-2 * time.Hour), Value: 60},
+		{Date: now.Add(-time.Hour), Value: 59},
+		{Date: now, Value: 58},
+	}
+	assert.Equal(t, BatteryForecastStatusInsufficientData, forecastBattery(sensor, hourly, 10, now).Status)
+
+	forecast = forecastBattery(sensor, dailyBatteryReadings(now, 14, 12, 9), 10, now)
+	assert.Equal(t, BatteryForecastStatusBelowCutoff, forecast.Status)
+	assert.Equal(t, 0.0, *forecast.DaysRemaining)
+	assert.Nil(t, forecast.PredictedAt)
+
+	forecast = forecastBattery(sensor, dailyBatteryReadings(now, 70, 71, 70, 72), 10, now)
+	assert.Equal(t, BatteryForecastStatusNotDischarging, forecast.Status)
+	assert.True(t, *forecast.DischargeRatePerDay < 0)
+	assert.Nil(t, forecast.PredictedAt)
+
+	// a drop too slow to reach the cutoff within the forecast limit
+	forecast = forecastBattery(sensor, dailyBatteryReadings(now, 90.002, 90.001, 90), 10, now)
+	assert.Equal(t, BatteryForecastStatusNotDischarging, forecast.Status)
+}
+
+func TestUnitTestBatteryLineCrossingRangeIsUnboundedForAFlatTrend(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	line := fitBatteryLine(dailyBatteryReadings(now, 70, 75, 65, 72, 68, 69))
+
+	// execute test
+	_, _, ok := line.crossingRange(10)
+
+	// assert results
+	assert.False(t, ok)
+	assert.True(t, math.IsInf(studentT95(0), 1))
+	assert.Equal(t, 1.96, studentT95(120))
+}
+
+func TestUnitTestDueBatteryForecasts(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	soon, later, tooLate := now.AddDate(0, 0, 3), now.AddDate(0, 0, 9), now.AddDate(0, 0, 30)
+	forecasts := []api.BatteryForecast{
+		{SensorID: "e", Status: BatteryForecastStatusForecast, PredictedAt: &later},
+		{SensorID: "d", Status: BatteryForecastStatusForecast, PredictedAt: &tooLate},
+		{SensorID: "c", Status: BatteryForecastStatusBelowCutoff},
+		{SensorID: "b", Status: BatteryForecastStatusForecast, PredictedAt: &soon},
+		{SensorID: "a", Status: BatteryForecastStatusBelowCutoff},
+		{SensorID: "f", Status: BatteryForecastStatusNotDischarging},
+		{SensorID: "g", Status: BatteryForecastStatusInsufficientData},
+	}
+
+	// execute test
+	due := dueBatteryForecasts(forecasts, now.AddDate(0, 0, 14))
+
+	// assert results
+	var sensorIDs []string
+	for _, forecast := range due {
+		sensorIDs = append(sensorIDs, forecast.SensorID)
+	}
+	assert.Equal(t, []string{"a", "c", "b", "e"}, sensorIDs)
+	assert.NotNil(t, dueBatteryForecasts(nil, now))
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxCalibrationCoefficients bounds the degree of calibration polynomials to 5,
+	// higher degrees overfit the handful of reference points a probe is calibrated on
+	maxCalibrationCoefficients = 6
+	maxCalibrationNoteLength   = 1000
+)
+
+// calibrationCoefficients returns the polynomial coefficients of a requested calibration,
+// from the constant term up, or an error describing why the request is invalid
+func calibrationCoefficients(request api.CreateSensorCalibrationRequest) ([]float64, error) {
+	coefficients := request.Coefficients
+	if len(coefficients) > 0 {
+		if request.Gain != nil || request.Offset != nil {
+			return nil, fmt.Errorf("coefficients can't be combined with gain and offset")
+		}
+		if len(coefficients) > maxCalibrationCoefficients {
+			return nil, fmt.Errorf("coefficients must have at most %d terms", maxCalibrationCoefficients)
+		}
+	} else {
+		offset, gain := 0.0, 1.0
+		if request.Offset != nil {
+			offset = *request.Offset
+		}
+		if request.Gain != nil {
+			gain = *request.Gain
+		}
+		coefficients = []float64{offset, gain}
+	}
+
+	for _, coefficient := range coefficients {
+		if math.IsNaN(coefficient) || math.IsInf(coefficient, 0) {
+			return nil, fmt.Errorf("calibration coefficients must be finite numbers")
+		}
+	}
+
+	// a calibration that maps every reading to the same value is a mistake
+	constant := true
+	for _, coefficient := range coefficients[1:] {
+		if coefficient != 0 {
+			constant = false
+		}
+	}
+	if constant {
+		return nil, fmt.Errorf("calibration must depend on the raw reading")
+	}
+
+	return coefficients, nil
+}
+
+// sensorCalibrationToAPI converts a calibration to its api representation
+func sensorCalibrationToAPI(calibration database.SensorCalibration) api.SensorCalibration {
+	return api.SensorCalibration{
+		ID:              calibration.ID,
+		SensorID:        calibration.SensorID,
+		MeasurementType: calibration.MeasurementType,
+		EffectiveFrom:   calibration.EffectiveFrom.UTC(),
+		Coefficients:    calibration.Coefficients,
+		Note:            calibration.Note,
+		CreatedBy:       calibration.CreatedBy,
+		CreatedAt:       calibration.CreatedAt,
+	}
+}
+
+// CreateGetSensorCalibrationsHandler returns a handler that lists a sensor's calibrations,
+// of one measurement type when the measurement_type query parameter is set
+func CreateGetSensorCalibrationsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		calibrations, err := database.ListSensorCalibrations(r.Context(), apiService.DatabaseClient.DB, sensor.ID, r.URL.Query().Get("measurement_type"))
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving calibrations of sensor %s: %s", sensor.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetSensorCalibrationsResponse{
+			SensorID:     sensor.ID,
+			Calibrations: make([]api.SensorCalibration, 0, len(calibrations)),
+		}
+		for _, calibration := range calibrations {
+			response.Calibrations = append(response.Calibrations, sensorCalibrationToAPI(calibration))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateCreateSensorCalibrationHandler returns a handler that adds a calibration to a
+// sensor. Stored readings are left as they are, the calibration is applied when readings
+// taken from its effective date are read
+func CreateCreateSensorCalibrationHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.CreateSensorCalibrationRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		coefficients, err := calibrationCoefficients(request)
+		if err == nil && request.EffectiveFrom.IsZero() {
+			err = fmt.Errorf("effective_from is required")
+		}
+		if err == nil && len(request.Note) > maxCalibrationNoteLength {
+			err = fmt.Errorf("note must be at most %d characters", maxCalibrationNoteLength)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		_, err = database.GetMeasurementType(r.Context(), apiService.DatabaseClient.DB, request.MeasurementType)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoMeasurementType) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown measurement type %q", request.MeasurementType)})
+				return
+			}
+
+			apiService.Error().Msgf("Error retrieving measurement type %s: %s", request.MeasurementType, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		calibration := database.SensorCalibration{
+			SensorID:        sensor.ID,
+			MeasurementType: request.MeasurementType,
+			EffectiveFrom:   request.EffectiveFrom.Truncate(time.Microsecond),
+			Coefficients:    coefficients,
+			Note:            request.Note,
+			CreatedBy:       username,
+		}
+		err = calibration.Save(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			if errors.Is(err, database.ErrorDuplicateSensorCalibration) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Sensor already has a calibration of this measurement type effective from that date"})
+				return
+			}
+
+			apiService.Error().Msgf("Error saving calibration of sensor %s: %s", sensor.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Calibration %d of sensor %s %s effective from %s added by %s", calibration.ID, sensor.ID, calibration.MeasurementType, calibration.EffectiveFrom, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(sensorCalibrationToAPI(calibration))
+	}
+}
+
+// CreateDeleteSensorCalibrationHandler returns a handler that removes a calibration of a
+// sensor, the readings it covered are calibrated by the calibration before it again
+func CreateDeleteSensorCalibrationHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		calibrationID, err := strconv.ParseInt(mux.Vars(r)["calibration_id"], 10, 64)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid calibration id"})
+			return
+		}
+
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		err = database.DeleteSensorCalibration(r.Context(), apiService.DatabaseClient.DB, sensor.ID, calibrationID)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoSensorCalibration) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Calibration not found"})
+				return
+			}
+
+			apiService.Error().Msgf("Error deleting calibration %d of sensor %s: %s", calibrationID, sensor.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Calibration %d of sensor %s deleted by %s", calibrationID, sensor.ID, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Calibration deleted successfully"})
+	}
+}
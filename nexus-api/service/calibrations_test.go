@@ -0,0 +1,41 @@
+package service
+
+import (
+	"nexus-api/api"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestCalibrationCoefficientsDefaultsLinearTerms(t *testing.T) {
+	// setup test data
+	gain, offset := 1.08, -2.5
+
+	// execute test
+	linear, linearErr := calibrationCoefficients(api.CreateSensorCalibrationRequest{Gain: &gain, Offset: &offset})
+	offsetOnly, offsetOnlyErr := calibrationCoefficients(api.CreateSensorCalibrationRequest{Offset: &offset})
+	polynomial, polynomialErr := calibrationCoefficients(api.CreateSensorCalibrationRequest{Coefficients: []float64{0.5, 0.9, 0.002}})
+
+	// assert results
+	assert.NoError(t, linearErr)
+	assert.Equal(t, []float64{-2.5, 1.08}, linear)
+	assert.NoError(t, offsetOnlyErr)
+	assert.Equal(t, []float64{-2.5, 1}, offsetOnly)
+	assert.NoError(t, polynomialErr)
+	assert.Equal(t, []float64{0.5, 0.9, 0.002}, polynomial)
+}
+
+func TestUnitTestCalibrationCoefficientsRejectsInvalidRequests(t *testing.T) {
+	zero, gain := float64(0), 1.1
+
+	for name, request := range map[string]api.CreateSensorCalibrationRequest{
+		"coefficients and gain": {Coefficients: []float64{0, 1}, Gain: &gain},
+		"too many terms":        {Coefficients: []float64{0, 1, 0, 0, 0, 0, 1}},
+		"constant polynomial":   {Coefficients: []float64{4}},
+		"zero gain":             {Gain: &zero},
+	} {
+		_, err := calibrationCoefficients(request)
+
+		assert.Error(t, err, "expected error for %s", name)
+	}
+}
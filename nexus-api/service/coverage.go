@@ -0,0 +1,251 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"sort"
+	"time"
+)
+
+const (
+	defaultCoverageRange = 7 * 24 * time.Hour
+	maxCoverageRange     = 366 * 24 * time.Hour
+)
+
+// sensorCoverage works out how completely a sensor reported between start and end from
+// the span of its readings in the range, nil if it has none, and the gaps between them.
+// The range is narrowed to when the sensor was installed and in service. Each gap loses
+// its length less the one reporting interval the sensor had to send its next reading
+func sensorCoverage(sensor database.Sensor, span *database.SensorReadingSpan, gaps []database.SensorReadingGap, start time.Time, end time.Time) api.SensorCoverage {
+	interval := time.Duration(sensor.ReportingIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = database.DefaultSensorReportingInterval
+	}
+
+	if sensor.InstallationDate.After(start) {
+		start = sensor.InstallationDate
+	}
+	if sensor.DecommissionedAt != nil && sensor.DecommissionedAt.Before(end) {
+		end = *sensor.DecommissionedAt
+	}
+
+	coverage := api.SensorCoverage{
+		SensorID:                 sensor.ID,
+		Name:                     sensor.Name,
+		Start:                    start.UTC(),
+		End:                      end.UTC(),
+		ReportingIntervalSeconds: int(interval / time.Second),
+		CoveragePercent:          100,
+		Gaps:                     []api.SensorDataGap{},
+	}
+	if !end.After(start) {
+		coverage.End = coverage.Start
+		return coverage
+	}
+
+	total := end.Sub(start)
+	coverage.ExpectedReadings = int(total / interval)
+	tolerance := time.Duration(float64(interval) * database.SensorGapTolerance)
+
+	var missing time.Duration
+	addGap := func(gapStart time.Time, gapEnd time.Time, lost time.Duration, missed int) {
+		duration := gapEnd.Sub(gapStart)
+		missing += lost
+		coverage.Gaps = append(coverage.Gaps, api.SensorDataGap{
+			Start:           gapStart.UTC(),
+			End:             gapEnd.UTC(),
+			DurationSeconds: int64(duration / time.Second),
+			MissedReadings:  missed,
+		})
+		coverage.LongestGapSeconds = max(coverage.LongestGapSeconds, int64(duration/time.Second))
+	}
+	// addReadingGap adds the gap between two times if it is long enough to have
+	// missed a reading, clipped to the range
+	addReadingGap := func(gapStart time.Time, gapEnd time.Time) {
+		if gapStart.Before(start) {
+			gapStart = start
+		}
+		if gapEnd.After(end) {
+			gapEnd = end
+		}
+
+		duration := gapEnd.Sub(gapStart)
+		if duration <= tolerance {
+			return
+		}
+
+		missed := max(1, int(math.Round(float64(duration)/float64(interval)))-1)
+		addGap(gapStart, gapEnd, duration-interval, missed)
+	}
+
+	if span == nil || span.Count == 0 {
+		addGap(start, end, total, coverage.ExpectedReadings)
+	} else {
+		coverage.ReceivedReadings = span.Count
+		addReadingGap(start, span.First)
+		for _, gap := range gaps {
+			addReadingGap(gap.Start, gap.End)
+		}
+		addReadingGap(span.Last, end)
+	}
+
+	coverage.GapCount = len(coverage.Gaps)
+	coverage.MissingSeconds = int64(missing / time.Second)
+	coverage.CoveragePercent = math.Round(math.Max(0, 1-float64(missing)/float64(total))*10000) / 100
+
+	return coverage
+}
+
+// readCoverageFilter reads the range and measurement_type query parameters of a coverage
+// report, answering invalid requests directly in which case false is returned
+func readCoverageFilter(apiService *APIService, w http.ResponseWriter, r *http.Request) (database.SensorMeasurementFilter, bool) {
+	var filter database.SensorMeasurementFilter
+
+	start, end, err := parseCoverageRange(r, time.Now())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+		return filter, false
+	}
+	filter.Start, filter.End = start, end
+
+	// readings of every type count towards coverage unless one type is asked for
+	if measurementTypeID := r.URL.Query().Get("measurement_type"); measurementTypeID != "" {
+		_, err := database.GetMeasurementType(r.Context(), apiService.DatabaseClient.DB, measurementTypeID)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			if errors.Is(err, database.ErrorNoMeasurementType) {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown measurement type %q", measurementTypeID)})
+				return filter, false
+			}
+
+			apiService.Error().Msgf("Error retrieving measurement type %s: %s", measurementTypeID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return filter, false
+		}
+		filter.MeasurementTypes = []string{measurementTypeID}
+	}
+
+	return filter, true
+}
+
+// getSensorsCoverage works out the coverage of each of the sensors over the filter's range
+func getSensorsCoverage(apiService *APIService, r *http.Request, sensors []database.Sensor, filter database.SensorMeasurementFilter) ([]api.SensorCoverage, error) {
+	spans, err := database.GetSensorReadingSpans(r.Context(), apiService.DatabaseClient.DB, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	gaps, err := database.GetSensorReadingGaps(r.Context(), apiService.DatabaseClient.DB, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	spansBySensor := make(map[string]*database.SensorReadingSpan, len(spans))
+	for i := range spans {
+		spansBySensor[spans[i].SensorID] = &spans[i]
+	}
+
+	gapsBySensor := make(map[string][]database.SensorReadingGap)
+	for _, gap := range gaps {
+		gapsBySensor[gap.SensorID] = append(gapsBySensor[gap.SensorID], gap)
+	}
+
+	coverages := make([]api.SensorCoverage, 0, len(sensors))
+	for _, sensor := range sensors {
+		coverages = append(coverages, sensorCoverage(sensor, spansBySensor[sensor.ID], gapsBySensor[sensor.ID], filter.Start, filter.End))
+	}
+
+	return coverages, nil
+}
+
+// CreateGetSensorCoverageHandler returns a handler that reports the gaps in a sensor's
+// readings over a range, a week before now by default, and the share of the range
+// it reported for
+func CreateGetSensorCoverageHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		filter, ok := readCoverageFilter(apiService, w, r)
+		if !ok {
+			return
+		}
+		filter.SensorIDs = []string{sensor.ID}
+
+		coverages, err := getSensorsCoverage(apiService, r, []database.Sensor{sensor}, filter)
+		if err != nil {
+			apiService.Error().Msgf("Error working out coverage of sensor %s: %s", sensor.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(coverages[0])
+	}
+}
+
+// CreateGetSensorsCoverageHandler returns a handler that ranks every sensor in service by
+// how completely it reported over a range, least complete first (admin only)
+func CreateGetSensorsCoverageHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		filter, ok := readCoverageFilter(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		sensors, err := apiService.DatabaseClient.GetAllSensors(r.Context(), username, false)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving sensors: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		coverages, err := getSensorsCoverage(apiService, r, sensors, filter)
+		if err != nil {
+			apiService.Error().Msgf("Error working out sensor coverage: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		sort.SliceStable(coverages, func(i, j int) bool {
+			if coverages[i].CoveragePercent == coverages[j].CoveragePercent {
+				return coverages[i].SensorID < coverages[j].SensorID
+			}
+			return coverages[i].CoveragePercent < coverages[j].CoveragePercent
+		})
+
+		response := api.GetSensorsCoverageResponse{
+			Start:   filter.Start.UTC(),
+			End:     filter.End.UTC(),
+			Sensors: coverages,
+		}
+		// rankings list the size of each sensor's gaps, not the gaps themselves
+		for i := range response.Sensors {
+			response.Sensors[i].Gaps = nil
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"nexus-api/clients/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestSensorCoverageFindsGaps(t *testing.T) {
+	// setup test data
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	sensor := database.Sensor{
+		ID:                       "sensor-1",
+		InstallationDate:         start.AddDate(-1, 0, 0),
+		ReportingIntervalSeconds: 3600,
+	}
+	span := &database.SensorReadingSpan{
+		SensorID: sensor.ID,
+		First:    start.Add(30 * time.Minute),
+		Last:     start.Add(20*time.Hour + 30*time.Minute),
+		Count:    15,
+	}
+	// silent for six hours overnight
+	gaps := []database.SensorReadingGap{{SensorID: sensor.ID, Start: start.Add(2*time.Hour + 30*time.Minute), End: start.Add(8*time.Hour + 30*time.Minute)}}
+
+	// execute test
+	coverage := sensorCoverage(sensor, span, gaps, start, end)
+
+	// assert results
+	assert.Equal(t, 24, coverage.ExpectedReadings)
+	assert.Equal(t, 15, coverage.ReceivedReadings)
+	if assert.Len(t, coverage.Gaps, 2) {
+		assert.Equal(t, gaps[0].Start, coverage.Gaps[0].Start)
+		assert.Equal(t, int64(6*3600), coverage.Gaps[0].DurationSeconds)
+		assert.Equal(t, 5, coverage.Gaps[0].MissedReadings)
+		// the sensor stopped reporting three and a half hours before the end of the range
+		assert.Equal(t, span.Last, coverage.Gaps[1].Start)
+		assert.Equal(t, end, coverage.Gaps[1].End)
+		assert.Equal(t, 3, coverage.Gaps[1].MissedReadings)
+	}
+	assert.Equal(t, int64(5*3600+int(2.5*3600)), coverage.MissingSeconds)
+	assert.Equal(t, 68.75, coverage.CoveragePercent)
+	assert.Equal(t, int64(6*3600), coverage.LongestGapSeconds)
+}
+
+func TestUnitTestSensorCoverageNarrowsRangeToService(t *testing.T) {
+	// setup test data
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	decommissionedAt := start.Add(10 * time.Hour)
+	sensor := database.Sensor{
+		ID:                       "sensor-1",
+		InstallationDate:         start.Add(4 * time.Hour),
+		ReportingIntervalSeconds: 3600,
+		DecommissionedAt:         &decommissionedAt,
+	}
+
+	// execute test
+	silent := sensorCoverage(sensor, nil, nil, start, start.Add(24*time.Hour))
+	notInstalled := sensorCoverage(sensor, nil, nil, start, start.Add(2*time.Hour))
+
+	// assert results
+	assert.Equal(t, sensor.InstallationDate, silent.Start)
+	assert.Equal(t, decommissionedAt, silent.End)
+	assert.Equal(t, 6, silent.ExpectedReadings)
+	assert.Equal(t, 0.0, silent.CoveragePercent)
+	if assert.Len(t, silent.Gaps, 1) {
+		assert.Equal(t, 6, silent.Gaps[0].MissedReadings)
+	}
+
+	assert.Equal(t, 100.0, notInstalled.CoveragePercent)
+	assert.Empty(t, notInstalled.Gaps)
+}
+
+func TestUnitTestSensorCoverageToleratesJitter(t *testing.T) {
+	// setup test data
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	sensor := database.Sensor{ID: "sensor-1", InstallationDate: start, ReportingIntervalSeconds: 900}
+	span := &database.SensorReadingSpan{SensorID: sensor.ID, First: start.Add(20 * time.Minute), Last: start.Add(50 * time.Minute), Count: 3}
+
+	// execute test
+	coverage := sensorCoverage(sensor, span, nil, start, start.Add(time.Hour))
+
+	// assert results
+	assert.Empty(t, coverage.Gaps)
+	assert.Equal(t, 100.0, coverage.CoveragePercent)
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// retryDelay returns how long to wait before retrying something that was refused attempts
+// times, doubling from base with each attempt up to max. Webhook deliveries and emails back
+// off this way with their own delays
+func retryDelay(attempts int, base time.Duration, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	return min(delay, max)
+}
+
+// sendInBatches calls sendBatch until it claims fewer than batchSize items or ctx is done.
+// sendBatch claims up to batchSize items due to be sent, such as webhook deliveries or
+// emails, sends each and records how it went, returning how many it claimed. An error
+// claiming a batch stops the sending and is returned
+func sendInBatches(ctx context.Context, batchSize int, sendBatch func(ctx context.Context) (int, error)) error {
+	for ctx.Err() == nil {
+		claimed, err := sendBatch(ctx)
+		if err != nil {
+			return err
+		}
+
+		if claimed < batchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestRetryDelayDoublesUpToTheCap(t *testing.T) {
+	// execute test and assert results
+	assert.Equal(t, 30*time.Second, retryDelay(1, webhookRetryBaseDelay, maxWebhookRetryDelay))
+	assert.Equal(t, time.Minute, retryDelay(2, webhookRetryBaseDelay, maxWebhookRetryDelay))
+	assert.Equal(t, 32*time.Minute, retryDelay(7, webhookRetryBaseDelay, maxWebhookRetryDelay))
+	assert.Equal(t, maxWebhookRetryDelay, retryDelay(8, webhookRetryBaseDelay, maxWebhookRetryDelay))
+	assert.Equal(t, maxWebhookRetryDelay, retryDelay(50, webhookRetryBaseDelay, maxWebhookRetryDelay))
+
+	assert.Equal(t, time.Minute, retryDelay(1, emailRetryBaseDelay, maxEmailRetryDelay))
+	assert.Equal(t, 2*time.Minute, retryDelay(2, emailRetryBaseDelay, maxEmailRetryDelay))
+	assert.Equal(t, 32*time.Minute, retryDelay(6, emailRetryBaseDelay, maxEmailRetryDelay))
+	assert.Equal(t, maxEmailRetryDelay, retryDelay(7, emailRetryBaseDelay, maxEmailRetryDelay))
+	assert.Equal(t, maxEmailRetryDelay, retryDelay(50, emailRetryBaseDelay, maxEmailRetryDelay))
+}
+
+func TestUnitTestSendInBatchesStopsAtAShortBatch(t *testing.T) {
+	// setup test data
+	due := 45
+	var batches []int
+	sendBatch := func(ctx context.Context) (int, error) {
+		claimed := min(due, 20)
+		due -= claimed
+		batches = append(batches, claimed)
+		return claimed, nil
+	}
+
+	// execute test
+	err := sendInBatches(context.Background(), 20, sendBatch)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, []int{20, 20, 5}, batches)
+
+	claimErr := errors.New("connection refused")
+	assert.Equal(t, claimErr, sendInBatches(context.Background(), 20, func(ctx context.Context) (int, error) {
+		return 0, claimErr
+	}))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, sendInBatches(cancelled, 20, func(ctx context.Context) (int, error) {
+		t.Fatal("nothing is claimed once the context is done")
+		return 0, nil
+	}))
+}
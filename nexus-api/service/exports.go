@@ -0,0 +1,274 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+
+	// exportFlushInterval is how many rows are written between
+	// flushes of the export to the client
+	exportFlushInterval = 500
+
+	// RawRetentionCutoffsHeader lists, for exports without a start, the time before which
+	// the raw readings of each exported measurement type have been rolled up
+	RawRetentionCutoffsHeader = "X-Raw-Retention-Cutoffs"
+)
+
+// sensorDataExportWriter writes exported readings in one of the export formats
+type sensorDataExportWriter interface {
+	// WriteHeader writes anything that comes before the first row
+	WriteHeader() error
+	WriteRow(row api.SensorDataExportRow) error
+	// Flush writes any buffered rows to the underlying writer
+	Flush() error
+}
+
+// newSensorDataExportWriter returns a writer for format and the content type of its output
+func newSensorDataExportWriter(format string, w io.Writer) (sensorDataExportWriter, string, error) {
+	switch format {
+	case "", ExportFormatCSV:
+		return &csvExportWriter{writer: csv.NewWriter(w)}, "text/csv", nil
+	case ExportFormatNDJSON:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}, "application/x-ndjson", nil
+	default:
+		return nil, "", fmt.Errorf("format must be %q or %q", ExportFormatCSV, ExportFormatNDJSON)
+	}
+}
+
+// csvExportWriter writes one header line then one line per reading
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvExportWriter) WriteHeader() error {
+	return c.writer.Write([]string{"sensor_id", "measurement_type", "date", "value", "unit"})
+}
+
+func (c *csvExportWriter) WriteRow(row api.SensorDataExportRow) error {
+	return c.writer.Write([]string{
+		row.SensorID,
+		row.MeasurementType,
+		row.Date.UTC().Format(time.RFC3339Nano),
+		strconv.FormatFloat(row.Value, 'f', -1, 64),
+		row.Unit,
+	})
+}
+
+func (c *csvExportWriter) Flush() error {
+	c.writer.Flush()
+
+	return c.writer.Error()
+}
+
+// ndjsonExportWriter writes one json object per line per reading
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonExportWriter) WriteHeader() error {
+	return nil
+}
+
+func (n *ndjsonExportWriter) WriteRow(row api.SensorDataExportRow) error {
+	row.Date = row.Date.UTC()
+
+	return n.encoder.Encode(row)
+}
+
+func (n *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+// exportRetentionCutoffs returns the raw retention cutoff at now of each measurement type
+// the filter selects whose raw readings are rolled up after a while, keyed by type
+func exportRetentionCutoffs(measurementTypes []database.MeasurementType, filter database.SensorMeasurementFilter, now time.Time) map[string]time.Time {
+	selected := make(map[string]bool, len(filter.MeasurementTypes))
+	for _, measurementType := range filter.MeasurementTypes {
+		selected[measurementType] = true
+	}
+
+	cutoffs := make(map[string]time.Time)
+	for _, measurementType := range measurementTypes {
+		if len(selected) > 0 && !selected[measurementType.ID] {
+			continue
+		}
+		if cutoff := measurementType.RawRetentionCutoff(now); !cutoff.IsZero() {
+			cutoffs[measurementType.ID] = cutoff
+		}
+	}
+
+	return cutoffs
+}
+
+// checkExportRetention returns an error if start is before the cutoff of any of the
+// measurement types, as only the rollups of their readings before it are kept
+func checkExportRetention(cutoffs map[string]time.Time, start time.Time) error {
+	if start.IsZero() {
+		return nil
+	}
+
+	for _, measurementType := range sortedCutoffTypes(cutoffs) {
+		if start.Before(cutoffs[measurementType]) {
+			return fmt.Errorf("start is before %s, when raw %s readings start being rolled up, query older readings by resolution through /sensors/query instead",
+				cutoffs[measurementType].Format(time.RFC3339), measurementType)
+		}
+	}
+
+	return nil
+}
+
+// sortedCutoffTypes returns the measurement types of the cutoffs in order
+func sortedCutoffTypes(cutoffs map[string]time.Time) []string {
+	measurementTypes := make([]string, 0, len(cutoffs))
+	for measurementType := range cutoffs {
+		measurementTypes = append(measurementTypes, measurementType)
+	}
+	sort.Strings(measurementTypes)
+
+	return measurementTypes
+}
+
+// formatRetentionCutoffs formats the cutoffs for the RawRetentionCutoffsHeader as
+// comma separated measurement_type=RFC3339 pairs ordered by measurement type
+func formatRetentionCutoffs(cutoffs map[string]time.Time) string {
+	pairs := make([]string, 0, len(cutoffs))
+	for _, measurementType := range sortedCutoffTypes(cutoffs) {
+		pairs = append(pairs, measurementType+"="+cutoffs[measurementType].Format(time.RFC3339))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// CreateExportSensorDataHandler returns a handler that streams the selected readings of
+// the selected sensors as csv or ndjson. Rows are written as they are read from the
+// database so memory use does not grow with the size of the export. Only raw readings
+// are exported, so ranges starting before the raw readings of a type are rolled up are
+// rejected and exports without a start list the cutoffs in RawRetentionCutoffsHeader
+func CreateExportSensorDataHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		measurementTypes, err := database.GetMeasurementTypes(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving measurement types: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		units := make(map[string]string, len(measurementTypes))
+		for _, measurementType := range measurementTypes {
+			units[measurementType.ID] = measurementType.Unit
+		}
+
+		filter, err := parseSensorMeasurementFilter(r, units)
+		var converter unitConverter
+		if err == nil {
+			converter, err = parseUnitsParameter(r.URL.Query().Get("units"))
+		}
+		var cutoffs map[string]time.Time
+		if err == nil {
+			cutoffs = exportRetentionCutoffs(measurementTypes, filter, time.Now())
+			err = checkExportRetention(cutoffs, filter.Start)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		// readings are exported calibrated unless raw=true asks for them as stored
+		var calibrations database.SensorCalibrations
+		if r.URL.Query().Get("raw") != "true" {
+			calibrations, err = database.GetSensorCalibrations(r.Context(), apiService.DatabaseClient.DB, filter.SensorIDs, filter.MeasurementTypes)
+			if err != nil {
+				apiService.Error().Msgf("Error retrieving calibrations for sensor data export: %s", err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+		}
+
+		format := r.URL.Query().Get("format")
+		exportWriter, contentType, err := newSensorDataExportWriter(format, w)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if format == "" {
+			format = ExportFormatCSV
+		}
+
+		flusher, _ := w.(http.Flusher)
+		fileName := fmt.Sprintf("sensor_data_%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if filter.Start.IsZero() && len(cutoffs) > 0 {
+			w.Header().Set(RawRetentionCutoffsHeader, formatRetentionCutoffs(cutoffs))
+		}
+		w.WriteHeader(http.StatusOK)
+
+		err = exportWriter.WriteHeader()
+		if err != nil {
+			apiService.Warn().Msgf("Error writing sensor data export header: %s", err)
+			return
+		}
+
+		var rowCount int
+		err = database.StreamSensorMeasurements(r.Context(), apiService.DatabaseClient.DB, filter, func(measurement database.SensorMeasurement) error {
+			err := exportWriter.WriteRow(api.SensorDataExportRow{
+				SensorID:        measurement.SensorID,
+				MeasurementType: measurement.MeasurementType,
+				Date:            measurement.Date,
+				Value:           converter.Value(units[measurement.MeasurementType], calibrations.Calibrate(measurement.SensorID, measurement.MeasurementType, measurement.Date, measurement.Value)),
+				Unit:            converter.Unit(units[measurement.MeasurementType]),
+			})
+			if err != nil {
+				return err
+			}
+
+			rowCount++
+			if rowCount%exportFlushInterval == 0 {
+				err = exportWriter.Flush()
+				if err != nil {
+					return err
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+
+			return nil
+		})
+
+		if err == nil {
+			err = exportWriter.Flush()
+		}
+
+		if err != nil {
+			// The status has already been sent, abort the response so the
+			// client sees a broken stream instead of a silently truncated export
+			apiService.Error().Msgf("Error streaming sensor data export after %d rows: %s", rowCount, err)
+			panic(http.ErrAbortHandler)
+		}
+
+		apiService.Debug().Msgf("Exported %d sensor readings as %s", rowCount, format)
+	}
+}
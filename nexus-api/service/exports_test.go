@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testExportRows = []api.SensorDataExportRow{
+	{SensorID: "2CF7F1C0649007B3", MeasurementType: "soil_moisture", Date: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), Value: 31.5, Unit: "%"},
+	{SensorID: "2CF7F1C0649007B3", MeasurementType: "soil_temperature", Date: time.Date(2025, 3, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*60*60)), Value: -2, Unit: "°C"},
+}
+
+func TestUnitTestCSVExportWriter(t *testing.T) {
+	// setup test data
+	var buffer bytes.Buffer
+	writer, contentType, err := newSensorDataExportWriter("csv", &buffer)
+	assert.NoError(t, err)
+
+	// execute test
+	assert.NoError(t, writer.WriteHeader())
+	for _, row := range testExportRows {
+		assert.NoError(t, writer.WriteRow(row))
+	}
+	assert.NoError(t, writer.Flush())
+
+	// assert results
+	assert.Equal(t, "text/csv", contentType)
+	assert.Equal(t, strings.Join([]string{
+		"sensor_id,measurement_type,date,value,unit",
+		"2CF7F1C0649007B3,soil_moisture,2025-03-01T12:00:00Z,31.5,%",
+		"2CF7F1C0649007B3,soil_temperature,2025-03-01T17:00:00Z,-2,°C",
+		"",
+	}, "\n"), buffer.String())
+}
+
+func TestUnitTestNDJSONExportWriter(t *testing.T) {
+	// setup test data
+	var buffer bytes.Buffer
+	writer, contentType, err := newSensorDataExportWriter("ndjson", &buffer)
+	assert.NoError(t, err)
+
+	// execute test
+	assert.NoError(t, writer.WriteHeader())
+	for _, row := range testExportRows {
+		assert.NoError(t, writer.WriteRow(row))
+	}
+	assert.NoError(t, writer.Flush())
+
+	// assert results
+	assert.Equal(t, "application/x-ndjson", contentType)
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if assert.Equal(t, len(testExportRows), len(lines)) {
+		var row api.SensorDataExportRow
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &row))
+		assert.Equal(t, "soil_temperature", row.MeasurementType)
+		assert.True(t, row.Date.Equal(testExportRows[1].Date))
+		assert.Equal(t, float64(-2), row.Value)
+	}
+}
+
+func TestUnitTestExportWriterRejectsUnknownFormat(t *testing.T) {
+	_, _, err := newSensorDataExportWriter("xlsx", &bytes.Buffer{})
+
+	assert.Error(t, err)
+}
+
+func TestUnitTestExportRetentionCutoffs(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 10, 15, 30, 0, 0, time.UTC)
+	measurementTypes := []database.MeasurementType{
+		{ID: database.MeasurementTypeSoilMoisture, RawRetentionDays: 30},
+		{ID: database.MeasurementTypeSoilTemperature, RawRetentionDays: 90},
+		{ID: database.MeasurementTypeBatteryLevel},
+	}
+
+	// execute test
+	cutoffs := exportRetentionCutoffs(measurementTypes, database.SensorMeasurementFilter{}, now)
+	moistureOnly := exportRetentionCutoffs(measurementTypes, database.SensorMeasurementFilter{MeasurementTypes: []string{database.MeasurementTypeSoilMoisture}}, now)
+
+	// assert results
+	assert.Equal(t, map[string]time.Time{
+		database.MeasurementTypeSoilMoisture:    time.Date(2025, 5, 11, 0, 0, 0, 0, time.UTC),
+		database.MeasurementTypeSoilTemperature: time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC),
+	}, cutoffs)
+	assert.Len(t, moistureOnly, 1)
+	assert.Equal(t, "soil_moisture=2025-05-11T00:00:00Z,soil_temperature=2025-03-12T00:00:00Z", formatRetentionCutoffs(cutoffs))
+
+	assert.NoError(t, checkExportRetention(cutoffs, time.Time{}))
+	assert.NoError(t, checkExportRetention(cutoffs, time.Date(2025, 5, 11, 0, 0, 0, 0, time.UTC)))
+	assert.NoError(t, checkExportRetention(moistureOnly, time.Date(2025, 5, 11, 0, 0, 0, 0, time.UTC)))
+	assert.Error(t, checkExportRetention(cutoffs, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Error(t, checkExportRetention(moistureOnly, time.Date(2025, 5, 10, 23, 0, 0, 0, time.UTC)))
+}
@@ -549,57 +549,29 @@ func CreateGetSensorMoistureDataHandler(apiService *APIService) http.HandlerFunc
 		sensorID := vars["sensor_id"]
 		log.Info().Msgf("[handlers.go] Received request for moisture data for sensor_id: %s", sensorID)
 
-		// Retrieve data for the sensorID
-		data, err := database.GetSensorMoistureDataForSensorID(r.Context(), apiService.DatabaseClient.DB, sensorID)
-		if err != nil {
-			if errors.Is(err, database.ErrorNoSensorMoistureData) {
-				apiService.Debug().Msgf("No data found for sensor_id: %s", sensorID)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "No data found"})
-				return
-			}
-
-			apiService.Error().Msgf("Error retrieving data for sensor_id: %s, error: %s", sensorID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		page, ok := readSensorMeasurements(apiService, w, r, sensorID, database.MeasurementTypeSoilMoisture)
+		if !ok {
 			return
 		}
 
-		// Convert database data to GetSensorMoistureDataResponse
-		var response api.GetSensorMoistureDataResponse
-		var mostRecentTimestamp time.Time
-		for _, d := range data {
+		// Convert measurements to GetSensorMoistureDataResponse
+		response := api.GetSensorMoistureDataResponse{
+			Unit:               page.Unit,
+			IsOnline:           page.IsOnline,
+			LastDataTimestamp:  page.LastDataTimestamp,
+			NextCursor:         page.NextCursor,
+			Resolution:         page.Resolution,
+			RawRetentionCutoff: page.RawRetentionCutoff,
+		}
+		for _, d := range page.Measurements {
 			response.SensorMoistureData = append(response.SensorMoistureData, api.SensorMoistureData{
 				ID:           d.ID,
 				SensorID:     d.SensorID,
 				Date:         d.Date,
-				SoilMoisture: d.SoilMoisture,
+				SoilMoisture: d.Value,
 			})
-			// Track the most recent timestamp
-			if d.Date.After(mostRecentTimestamp) {
-				mostRecentTimestamp = d.Date
-			}
 		}
 
-		// Check if sensor is online based on most recent data timestamp
-		if len(data) > 0 {
-			now := time.Now()
-			threshold := 24 * time.Hour // 24 hours threshold
-			timeDiff := now.Sub(mostRecentTimestamp)
-			isOnline := timeDiff <= threshold
-
-			response.IsOnline = &isOnline
-			response.LastDataTimestamp = &mostRecentTimestamp
-
-			if !isOnline {
-				apiService.Warn().Msgf("Sensor %s appears offline: last data timestamp (%v) is older than 24 hours", sensorID, mostRecentTimestamp)
-			}
-		}
-
-		apiService.Debug().Msgf("Sending back %d moisture data records for sensor_id: %s", len(response.SensorMoistureData), sensorID)
-
 		// Send the response
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -621,69 +593,25 @@ func CreateSetSensorMoistureDataHandler(apiService *APIService) http.HandlerFunc
 			return
 		}
 
-		// Check if we have any data points to determine sensor online status
-		var mostRecentTimestamp time.Time
-		if len(request.SensorMoistureData) > 0 {
-			// Find the most recent timestamp in the batch
-			mostRecentTimestamp = request.SensorMoistureData[0].Date
-			for _, data := range request.SensorMoistureData {
-				if data.Date.After(mostRecentTimestamp) {
-					mostRecentTimestamp = data.Date
-				}
-			}
-		} else {
-			// No data in request, use current time (sensor is sending data, so it's online)
-			mostRecentTimestamp = time.Now()
-		}
-
-		// Ensure sensor exists before saving data, but only if it's online (data is recent)
-		// Use 24 hours as the threshold - if data is older than 24 hours, sensor is considered offline
-		err = database.EnsureSensorExistsIfOnline(r.Context(), apiService.DatabaseClient.DB, sensorID, sensorID, mostRecentTimestamp, 24)
-		if err != nil {
-			// If sensor is offline, log warning but don't fail - we might want to process the data anyway
-			// or we could return an error to prevent processing offline sensor data
-			apiService.Warn().Msgf("Sensor %s appears offline or error ensuring exists: %s", sensorID, err)
-			// Option 1: Skip processing offline sensor data (uncomment to enable)
-			// w.Header().Set("Content-Type", "application/json")
-			// w.WriteHeader(http.StatusBadRequest)
-			// json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Sensor appears offline: %v", err)})
-			// return
-
-			// Option 2: Continue processing but log warning (current behavior)
-			// Try to ensure sensor exists without online check as fallback
-			err = database.EnsureSensorExists(r.Context(), apiService.DatabaseClient.DB, sensorID, sensorID)
-			if err != nil {
-				apiService.Error().Msgf("Failed to ensure sensor exists for sensor_id: %s, error: %s", sensorID, err)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to ensure sensor exists"})
-				return
-			}
-		}
-
-		// Iterate over each SensorMoistureData item and save to the database
+		var measurements []database.SensorMeasurement
 		for _, sensorMoistureData := range request.SensorMoistureData {
-			moistureData := database.SensorMoistureData{
-				SensorID:     sensorID,
-				Date:         sensorMoistureData.Date,
-				SoilMoisture: sensorMoistureData.SoilMoisture,
-			}
+			measurements = append(measurements, database.SensorMeasurement{
+				MeasurementType: database.MeasurementTypeSoilMoisture,
+				Date:            sensorMoistureData.Date,
+				Value:           sensorMoistureData.SoilMoisture,
+			})
+		}
 
-			err = moistureData.Save(r.Context(), apiService.DatabaseClient.DB)
-			if err != nil {
-				apiService.Error().Msgf("Failed to save moisture data for sensor_id: %s, data: %+v, error: %s", sensorID, moistureData, err)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to save moisture data"})
-				return
-			}
+		response, ok := saveSensorMeasurements(apiService, w, r, sensorID, measurements, r.URL.Query().Get("on_conflict"))
+		if !ok {
+			return
 		}
 
 		// Send success response
-		apiService.Trace().Msgf("Successfully saved moisture data for sensor_id: %s", sensorID)
+		response.Message = "Moisture data saved successfully"
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Moisture data saved successfully"})
+		json.NewEncoder(w).Encode(response)
 	}
 }
 
@@ -692,53 +620,27 @@ func CreateGetSensorTemperatureDataHandler(apiService *APIService) http.HandlerF
 		vars := mux.Vars(r)
 		sensorID := vars["sensor_id"]
 
-		// Retrieve data for the sensorID
-		data, err := database.GetSensorTemperatureDataForSensorID(r.Context(), apiService.DatabaseClient.DB, sensorID)
-		if err != nil {
-			if errors.Is(err, database.ErrorNoSensorTemperatureData) {
-				apiService.Debug().Msgf("No data found for sensor_id: %s", sensorID)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "No data found"})
-				return
-			}
-
-			apiService.Error().Msgf("Error retrieving data for sensor_id: %s, error: %s", sensorID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		page, ok := readSensorMeasurements(apiService, w, r, sensorID, database.MeasurementTypeSoilTemperature)
+		if !ok {
 			return
 		}
 
-		// Convert database data to GetSensorTemperatureDataResponse
-		var response api.GetSensorTemperatureDataResponse
-		var mostRecentTimestamp time.Time
-		for _, d := range data {
+		// Convert measurements to GetSensorTemperatureDataResponse
+		response := api.GetSensorTemperatureDataResponse{
+			Unit:               page.Unit,
+			IsOnline:           page.IsOnline,
+			LastDataTimestamp:  page.LastDataTimestamp,
+			NextCursor:         page.NextCursor,
+			Resolution:         page.Resolution,
+			RawRetentionCutoff: page.RawRetentionCutoff,
+		}
+		for _, d := range page.Measurements {
 			response.SensorTemperatureData = append(response.SensorTemperatureData, api.SensorTemperatureData{
 				ID:              d.ID,
 				SensorID:        d.SensorID,
 				Date:            d.Date,
-				SoilTemperature: d.SoilTemperature,
+				SoilTemperature: d.Value,
 			})
-			// Track the most recent timestamp
-			if d.Date.After(mostRecentTimestamp) {
-				mostRecentTimestamp = d.Date
-			}
-		}
-
-		// Check if sensor is online based on most recent data timestamp
-		if len(data) > 0 {
-			now := time.Now()
-			threshold := 24 * time.Hour // 24 hours threshold
-			timeDiff := now.Sub(mostRecentTimestamp)
-			isOnline := timeDiff <= threshold
-
-			response.IsOnline = &isOnline
-			response.LastDataTimestamp = &mostRecentTimestamp
-
-			if !isOnline {
-				apiService.Warn().Msgf("Sensor %s appears offline: last data timestamp (%v) is older than 24 hours", sensorID, mostRecentTimestamp)
-			}
 		}
 
 		// Send the response
@@ -762,72 +664,31 @@ func CreateSetSensorTemperatureDataHandler(apiService *APIService) http.HandlerF
 			return
 		}
 
-		// Check if we have any data points to determine sensor online status
-		var mostRecentTimestamp time.Time
-		if len(request.SensorTemperatureData) > 0 {
-			// Find the most recent timestamp in the batch
-			mostRecentTimestamp = request.SensorTemperatureData[0].Date
-			for _, data := range request.SensorTemperatureData {
-				if data.Date.After(mostRecentTimestamp) {
-					mostRecentTimestamp = data.Date
-				}
-			}
-		} else {
-			// No data in request, use current time (sensor is sending data, so it's online)
-			mostRecentTimestamp = time.Now()
-		}
-
-		// Ensure sensor exists before saving data, but only if it's online (data is recent)
-		// Use 24 hours as the threshold - if data is older than 24 hours, sensor is considered offline
-		err = database.EnsureSensorExistsIfOnline(r.Context(), apiService.DatabaseClient.DB, sensorID, sensorID, mostRecentTimestamp, 24)
-		if err != nil {
-			// If sensor is offline, log warning but don't fail - we might want to process the data anyway
-			// or we could return an error to prevent processing offline sensor data
-			apiService.Warn().Msgf("Sensor %s appears offline or error ensuring exists: %s", sensorID, err)
-			// Option 1: Skip processing offline sensor data (uncomment to enable)
-			// w.Header().Set("Content-Type", "application/json")
-			// w.WriteHeader(http.StatusBadRequest)
-			// json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Sensor appears offline: %v", err)})
-			// return
-
-			// Option 2: Continue processing but log warning (current behavior)
-			// Try to ensure sensor exists without online check as fallback
-			err = database.EnsureSensorExists(r.Context(), apiService.DatabaseClient.DB, sensorID, sensorID)
-			if err != nil {
-				apiService.Error().Msgf("Failed to ensure sensor exists for sensor_id: %s, error: %s", sensorID, err)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to ensure sensor exists"})
-				return
-			}
-		}
-
-		// Iterate over each SensorTemperatureData item and save to the database
+		var measurements []database.SensorMeasurement
 		for _, sensorTemperatureData := range request.SensorTemperatureData {
-			temperatureData := database.SensorTemperatureData{
-				SensorID:        sensorID,
+			measurements = append(measurements, database.SensorMeasurement{
+				MeasurementType: database.MeasurementTypeSoilTemperature,
 				Date:            sensorTemperatureData.Date,
-				SoilTemperature: sensorTemperatureData.SoilTemperature,
-			}
+				Value:           sensorTemperatureData.SoilTemperature,
+			})
+		}
 
-			err = temperatureData.Save(r.Context(), apiService.DatabaseClient.DB)
-			if err != nil {
-				apiService.Error().Msgf("Failed to save temperature data for sensor_id: %s, data: %+v, error: %s", sensorID, temperatureData, err)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to save temperature data"})
-				return
-			}
+		response, ok := saveSensorMeasurements(apiService, w, r, sensorID, measurements, r.URL.Query().Get("on_conflict"))
+		if !ok {
+			return
 		}
 
 		// Send success response
-		apiService.Trace().Msgf("Successfully saved temperature data for sensor_id: %s", sensorID)
+		response.Message = "Temperature data saved successfully"
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Temperature data saved successfully"})
+		json.NewEncoder(w).Encode(response)
 	}
 }
 
+// CreateGetAllSensorsHandler returns a handler that lists the sensors, optionally only
+// those inside the bbox or within radius metres of lat,lon. With format=geojson the
+// sensors are returned as a FeatureCollection along with their state and newest readings
 func CreateGetAllSensorsHandler(apiService *APIService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -837,13 +698,66 @@ func CreateGetAllSensorsHandler(apiService *APIService) http.HandlerFunc {
 			return
 		}
 
-		sensors, err := apiService.DatabaseClient.GetAllSensors(ctx, username)
+		// decommissioned sensors are only listed when asked for
+		includeDecommissioned := r.URL.Query().Get("include_decommissioned") == "true"
+
+		format := r.URL.Query().Get("format")
+		locationFilter, err := parseSensorLocationFilter(r)
+		if err == nil && format != "" && format != SensorsFormatJSON && format != SensorsFormatGeoJSON {
+			err = fmt.Errorf("format must be %q or %q", SensorsFormatJSON, SensorsFormatGeoJSON)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		sensors, err := apiService.DatabaseClient.GetAllSensors(ctx, username, includeDecommissioned)
 		if err != nil {
 			log.Ctx(ctx).Error().Err(err).Msg("failed to get all sensors from database")
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		sensors, distances := filterSensorsByLocation(sensors, locationFilter)
+
+		if format == SensorsFormatGeoJSON {
+			lastSeen, err := database.GetSensorLastSeen(ctx, apiService.DatabaseClient.DB)
+			if err != nil {
+				apiService.Error().Msgf("Error retrieving sensor last seen times: %s", err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+
+			measurementTypes, err := database.GetMeasurementTypes(ctx, apiService.DatabaseClient.DB)
+			if err != nil {
+				apiService.Error().Msgf("Error retrieving measurement types: %s", err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+
+			units := make(map[string]string, len(measurementTypes))
+			for _, measurementType := range measurementTypes {
+				units[measurementType.ID] = measurementType.Unit
+			}
+
+			lastReadings := make(map[string][]database.SensorLastSeen)
+			for _, reading := range lastSeen {
+				lastReadings[reading.SensorID] = append(lastReadings[reading.SensorID], reading)
+			}
+
+			log.Ctx(ctx).Debug().Int("count", len(sensors)).Msg("sending back sensors as geojson")
+			w.Header().Set("Content-Type", "application/geo+json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(sensorsToGeoJSON(sensors, lastReadings, units, distances, time.Now()))
+			return
+		}
+
 		if len(sensors) == 0 {
 			log.Ctx(ctx).Debug().Msg("no sensors found in database")
 			w.Header().Set("Content-Type", "application/json")
@@ -872,11 +786,7 @@ func CreateAddSensorHandler(apiService *APIService) http.HandlerFunc {
 		}
 
 		// Parse request body
-		var request struct {
-			EUI      string `json:"eui"`
-			Name     string `json:"name"`
-			Location string `json:"location"`
-		}
+		var request api.AddSensorRequest
 
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 			log.Ctx(ctx).Error().Err(err).Msg("failed to decode request body")
@@ -902,8 +812,26 @@ func CreateAddSensorHandler(apiService *APIService) http.HandlerFunc {
 			request.Location = "Unknown Location"
 		}
 
+		// Coordinates are optional but a sensor can't be placed with only one of them
+		var coordinates database.SensorCoordinates
+		if (request.Latitude == nil) != (request.Longitude == nil) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "latitude and longitude must be set together"})
+			return
+		}
+		if request.Latitude != nil {
+			coordinates = database.SensorCoordinates{Latitude: *request.Latitude, Longitude: *request.Longitude}
+			if err := validateSensorCoordinates(coordinates); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+				return
+			}
+		}
+
 		// Create sensor in database
-		sensor, err := database.CreateSensor(ctx, apiService.DatabaseClient.DB, request.EUI, request.Name, request.Location)
+		sensor, err := database.CreateSensor(ctx, apiService.DatabaseClient.DB, request.EUI, request.Name, request.Location, coordinates)
 		if err != nil {
 			log.Ctx(ctx).Error().Err(err).Msg("failed to create sensor in database")
 			w.Header().Set("Content-Type", "application/json")
@@ -913,55 +841,16 @@ func CreateAddSensorHandler(apiService *APIService) http.HandlerFunc {
 		}
 
 		log.Ctx(ctx).Info().Str("sensor_id", sensor.ID).Str("username", username).Msg("sensor created successfully")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Sensor created successfully"})
-	}
-}
-
-func CreateDeleteSensorHandler(apiService *APIService) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		username, ok := ctx.Value(UsernameContextKey).(string)
-		if !ok {
-			http.Error(w, "failed to get username from context", http.StatusUnauthorized)
-			return
-		}
-
-		// Get sensor ID from URL path
-		vars := mux.Vars(r)
-		sensorID := vars["sensor_id"]
-		if sensorID == "" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Sensor ID is required"})
-			return
-		}
 
-		// Check if sensor exists first
-		_, err := database.GetSensorByID(ctx, apiService.DatabaseClient.DB, sensorID)
-		if err != nil {
-			log.Ctx(ctx).Error().Err(err).Msgf("sensor not found: %s", sensorID)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Sensor not found"})
-			return
+		if request.Latitude != nil {
+			placeSensorInZone(apiService, r, &sensor)
 		}
 
-		// Delete sensor from database
-		err = database.DeleteSensor(ctx, apiService.DatabaseClient.DB, sensorID)
-		if err != nil {
-			log.Ctx(ctx).Error().Err(err).Msgf("failed to delete sensor: %s", sensorID)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to delete sensor"})
-			return
-		}
+		apiService.publishWebhookEvent(ctx, database.WebhookEventSensorCreated, api.WebhookSensorEvent{Sensor: sensorToAPI(sensor)})
 
-		log.Ctx(ctx).Info().Str("sensor_id", sensorID).Str("username", username).Msg("sensor deleted successfully")
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Sensor deleted successfully"})
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Sensor created successfully"})
 	}
 }
 
@@ -1134,6 +1023,8 @@ func CreateUploadDroneImagesHandler(apiService *APIService) http.HandlerFunc {
 			}
 
 			uploadedImages = append(uploadedImages, apiImage)
+
+			apiService.publishWebhookEvent(r.Context(), database.WebhookEventDroneImageUploaded, apiImage)
 		}
 
 		if len(uploadedImages) == 0 {
@@ -1260,82 +1151,26 @@ func CreateGetSensorBatteryDataHandler(apiService *APIService) http.HandlerFunc
 		vars := mux.Vars(r)
 		sensorID := vars["sensor_id"]
 
-		// Get query parameters for date range
-		startDate := r.URL.Query().Get("start_date")
-		endDate := r.URL.Query().Get("end_date")
-
-		var start, end time.Time
-		var err error
-
-		if startDate != "" {
-			start, err = time.Parse("2006-01-02", startDate)
-			if err != nil {
-				apiService.Error().Msgf("Invalid start_date format: %s", startDate)
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid start_date format. Use YYYY-MM-DD"})
-				return
-			}
-		}
-
-		if endDate != "" {
-			end, err = time.Parse("2006-01-02", endDate)
-			if err != nil {
-				apiService.Error().Msgf("Invalid end_date format: %s", endDate)
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid end_date format. Use YYYY-MM-DD"})
-				return
-			}
-			// Set end date to end of day (23:59:59) to include all data for that day
-			end = end.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-		}
-
-		// Retrieve data for the sensorID
-		data, err := database.GetSensorBatteryDataForSensorID(r.Context(), apiService.DatabaseClient.DB, sensorID, start, end)
-		if err != nil {
-			if errors.Is(err, database.ErrorNoSensorBatteryData) {
-				apiService.Debug().Msgf("No battery data found for sensor_id: %s", sensorID)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusNotFound)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "No data found"})
-				return
-			}
-
-			apiService.Error().Msgf("Error retrieving battery data for sensor_id: %s, error: %s", sensorID, err)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		page, ok := readSensorMeasurements(apiService, w, r, sensorID, database.MeasurementTypeBatteryLevel)
+		if !ok {
 			return
 		}
 
-		// Convert database data to GetBatteryLevelDataResponse
+		// Convert measurements to GetBatteryLevelDataResponse
 		response := api.GetBatteryLevelDataResponse{
-			BatteryLevelData: make([]api.BatteryLevelData, 0),
-		}
-		var mostRecentTimestamp time.Time
-		for _, d := range data {
+			Unit:               page.Unit,
+			IsOnline:           page.IsOnline,
+			LastDataTimestamp:  page.LastDataTimestamp,
+			BatteryLevelData:   make([]api.BatteryLevelData, 0),
+			NextCursor:         page.NextCursor,
+			Resolution:         page.Resolution,
+			RawRetentionCutoff: page.RawRetentionCutoff,
+		}
+		for _, d := range page.Measurements {
 			response.BatteryLevelData = append(response.BatteryLevelData, api.BatteryLevelData{
 				Date:         d.Date,
-				BatteryLevel: d.BatteryLevel,
+				BatteryLevel: d.Value,
 			})
-			// Track the most recent timestamp
-			if d.Date.After(mostRecentTimestamp) {
-				mostRecentTimestamp = d.Date
-			}
-		}
-
-		// Check if sensor is online based on most recent data timestamp
-		if len(data) > 0 {
-			now := time.Now()
-			threshold := 24 * time.Hour // 24 hours threshold
-			timeDiff := now.Sub(mostRecentTimestamp)
-			isOnline := timeDiff <= threshold
-
-			response.IsOnline = &isOnline
-			response.LastDataTimestamp = &mostRecentTimestamp
-
-			if !isOnline {
-				apiService.Warn().Msgf("Sensor %s appears offline: last battery data timestamp (%v) is older than 24 hours", sensorID, mostRecentTimestamp)
-			}
 		}
 
 		// Send the response
@@ -1359,69 +1194,25 @@ func CreateSetSensorBatteryDataHandler(apiService *APIService) http.HandlerFunc
 			return
 		}
 
-		// Check if we have any data points to determine sensor online status
-		var mostRecentTimestamp time.Time
-		if len(request.BatteryLevelData) > 0 {
-			// Find the most recent timestamp in the batch
-			mostRecentTimestamp = request.BatteryLevelData[0].Date
-			for _, data := range request.BatteryLevelData {
-				if data.Date.After(mostRecentTimestamp) {
-					mostRecentTimestamp = data.Date
-				}
-			}
-		} else {
-			// No data in request, use current time (sensor is sending data, so it's online)
-			mostRecentTimestamp = time.Now()
-		}
-
-		// Ensure sensor exists before saving data, but only if it's online (data is recent)
-		// Use 24 hours as the threshold - if data is older than 24 hours, sensor is considered offline
-		err = database.EnsureSensorExistsIfOnline(r.Context(), apiService.DatabaseClient.DB, sensorID, sensorID, mostRecentTimestamp, 24)
-		if err != nil {
-			// If sensor is offline, log warning but don't fail - we might want to process the data anyway
-			// or we could return an error to prevent processing offline sensor data
-			apiService.Warn().Msgf("Sensor %s appears offline or error ensuring exists: %s", sensorID, err)
-			// Option 1: Skip processing offline sensor data (uncomment to enable)
-			// w.Header().Set("Content-Type", "application/json")
-			// w.WriteHeader(http.StatusBadRequest)
-			// json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Sensor appears offline: %v", err)})
-			// return
-
-			// Option 2: Continue processing but log warning (current behavior)
-			// Try to ensure sensor exists without online check as fallback
-			err = database.EnsureSensorExists(r.Context(), apiService.DatabaseClient.DB, sensorID, sensorID)
-			if err != nil {
-				apiService.Error().Msgf("Failed to ensure sensor exists for sensor_id: %s, error: %s", sensorID, err)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to ensure sensor exists"})
-				return
-			}
-		}
-
-		// Iterate over each BatteryLevelData item and save to the database
+		var measurements []database.SensorMeasurement
 		for _, batteryData := range request.BatteryLevelData {
-			sensorBatteryData := database.SensorBatteryData{
-				SensorID:     sensorID,
-				Date:         batteryData.Date,
-				BatteryLevel: batteryData.BatteryLevel,
-			}
+			measurements = append(measurements, database.SensorMeasurement{
+				MeasurementType: database.MeasurementTypeBatteryLevel,
+				Date:            batteryData.Date,
+				Value:           batteryData.BatteryLevel,
+			})
+		}
 
-			err = sensorBatteryData.Save(r.Context(), apiService.DatabaseClient.DB)
-			if err != nil {
-				apiService.Error().Msgf("Failed to save battery data for sensor_id: %s, data: %+v, error: %s", sensorID, sensorBatteryData, err)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to save battery data"})
-				return
-			}
+		response, ok := saveSensorMeasurements(apiService, w, r, sensorID, measurements, r.URL.Query().Get("on_conflict"))
+		if !ok {
+			return
 		}
 
 		// Send success response
-		apiService.Trace().Msgf("Successfully saved battery data for sensor_id: %s", sensorID)
+		response.Message = "Battery data saved successfully"
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Battery data saved successfully"})
+		json.NewEncoder(w).Encode(response)
 	}
 }
 
@@ -1805,6 +1596,12 @@ func CreateCreateUserHandler(apiService *APIService) http.HandlerFunc {
 			return
 		}
 
+		apiService.publishWebhookEvent(ctx, database.WebhookEventUserCreated, api.WebhookUserCreated{
+			Username:  loginAuth.UserName,
+			Role:      loginAuth.Role,
+			CreatedBy: currentUser,
+		})
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "User created successfully"})
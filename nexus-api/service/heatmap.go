@@ -0,0 +1,372 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/geo"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HeatmapMethodIDW = "idw"
+
+	HeatmapFormatJSON = "json"
+	HeatmapFormatPNG  = "png"
+
+	defaultHeatmapCellSize = 10.0
+	maxHeatmapCellSize     = 10000.0
+	defaultHeatmapPower    = 2.0
+	maxHeatmapPower        = 10.0
+	defaultHeatmapRadius   = 500.0
+	maxHeatmapRadius       = 100000.0
+	// maxHeatmapCells bounds the work done and the size of the grid returned per request
+	maxHeatmapCells = 250000
+	// heatmapAlpha leaves the map under a PNG heatmap visible through it
+	heatmapAlpha = 200
+)
+
+// heatmapQuery is the surface asked for by a heatmap request, over either BoundingBox
+// or the bounds of the zone ZoneID
+type heatmapQuery struct {
+	BoundingBox     *geo.BoundingBox
+	ZoneID          *uuid.UUID
+	At              time.Time
+	CellSizeMeters  float64
+	MeasurementType string
+	Method          string
+	Power           float64
+	RadiusMeters    float64
+	Format          string
+}
+
+// newHeatmapInterpolator returns the interpolator for method, samples further than
+// radius metres from a cell are left out of its value
+func newHeatmapInterpolator(method string, power float64, radius float64) (geo.Interpolator, error) {
+	switch method {
+	case "", HeatmapMethodIDW:
+		return geo.InverseDistanceWeighting{Power: power, MaxDistanceMeters: radius}, nil
+	default:
+		return nil, fmt.Errorf("method must be %q", HeatmapMethodIDW)
+	}
+}
+
+// heatmapGridSize returns the number of columns and rows of cells of about cellSize
+// metres it takes to cover box, at least one of each, or an error when that is more than
+// maxHeatmapCells. The cells are counted in floating point, a tiny cell_size over a wide
+// box would overflow counting them as ints
+func heatmapGridSize(box geo.BoundingBox, cellSize float64) (int, int, error) {
+	width, height := box.Size()
+	columns, rows := max(1, math.Ceil(width/cellSize)), max(1, math.Ceil(height/cellSize))
+	if columns*rows > maxHeatmapCells {
+		return 0, 0, fmt.Errorf("A heatmap of %.0f by %.0f cells is larger than the limit of %d cells, use a larger cell_size", columns, rows, maxHeatmapCells)
+	}
+
+	return int(columns), int(rows), nil
+}
+
+// noSurface is the surface of an area without any samples, it has no estimates
+type noSurface struct{}
+
+func (noSurface) At(geo.Point) (float64, bool) {
+	return math.NaN(), false
+}
+
+// heatmapValues returns the cells of the grid row by row, rounded to 4 decimals and nil
+// where there is no estimate, along with the range of the values
+func heatmapValues(grid geo.Grid) ([][]*float64, *float64, *float64) {
+	var minimum, maximum *float64
+	values := make([][]*float64, 0, grid.Rows)
+	for row := 0; row < grid.Rows; row++ {
+		cells := make([]*float64, grid.Columns)
+		for column := 0; column < grid.Columns; column++ {
+			value := grid.Value(column, row)
+			if math.IsNaN(value) {
+				continue
+			}
+
+			value = math.Round(value*10000) / 10000
+			cells[column] = &value
+			if minimum == nil || value < *minimum {
+				minimum = cells[column]
+			}
+			if maximum == nil || value > *maximum {
+				maximum = cells[column]
+			}
+		}
+		values = append(values, cells)
+	}
+
+	return values, minimum, maximum
+}
+
+// heatmapColorStops run from brown for the lowest values to blue-green for the highest,
+// so dry soil reads as dry and wet soil as wet
+var heatmapColorStops = []color.NRGBA{
+	{R: 140, G: 81, B: 10},
+	{R: 216, G: 179, B: 101},
+	{R: 245, G: 245, B: 245},
+	{R: 90, G: 180, B: 172},
+	{R: 1, G: 102, B: 94},
+}
+
+// heatmapColor returns the colour of value on a scale from low to high, values outside
+// the scale take the colour of its nearest end
+func heatmapColor(value float64, low float64, high float64) color.NRGBA {
+	position := 0.5
+	if high > low {
+		position = math.Max(0, math.Min(1, (value-low)/(high-low)))
+	}
+
+	scaled := position * float64(len(heatmapColorStops)-1)
+	stop := min(int(scaled), len(heatmapColorStops)-2)
+	fraction := scaled - float64(stop)
+	from, to := heatmapColorStops[stop], heatmapColorStops[stop+1]
+	blend := func(a uint8, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + fraction*(float64(b)-float64(a))))
+	}
+
+	return color.NRGBA{R: blend(from.R, to.R), G: blend(from.G, to.G), B: blend(from.B, to.B), A: heatmapAlpha}
+}
+
+// writeHeatmapPNG draws the grid one pixel per cell, north up, coloured on a scale from
+// low to high. Cells without a value are transparent
+func writeHeatmapPNG(w io.Writer, grid geo.Grid, low float64, high float64) error {
+	img := image.NewNRGBA(image.Rect(0, 0, grid.Columns, grid.Rows))
+	for row := 0; row < grid.Rows; row++ {
+		for column := 0; column < grid.Columns; column++ {
+			value := grid.Value(column, row)
+			if math.IsNaN(value) {
+				continue
+			}
+			img.SetNRGBA(column, row, heatmapColor(value, low, high))
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// CreateGetHeatmapHandler returns a handler that interpolates a measurement type across
+// a bounding box or zone at a point in time, from the newest reading of each sensor
+// within radius of the area that was still online then. The grid is returned as JSON,
+// or with format=png as an image to overlay on a map whose bounds and value scale are
+// in the X-Heatmap-Bounds, X-Heatmap-Min and X-Heatmap-Max headers
+func CreateGetHeatmapHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		query, err := parseHeatmapQuery(r, time.Now())
+		var interpolator geo.Interpolator
+		if err == nil {
+			interpolator, err = newHeatmapInterpolator(query.Method, query.Power, query.RadiusMeters)
+		}
+		var converter unitConverter
+		if err == nil {
+			converter, err = parseUnitsParameter(r.URL.Query().Get("units"))
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		measurementType, err := database.GetMeasurementType(r.Context(), apiService.DatabaseClient.DB, query.MeasurementType)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoMeasurementType) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown measurement type %q", query.MeasurementType)})
+				return
+			}
+
+			apiService.Error().Msgf("Error retrieving measurement type %s, error: %s", query.MeasurementType, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		// a zone's heatmap covers its bounds and leaves the cells outside it empty
+		var box geo.BoundingBox
+		var mask func(geo.Point) bool
+		if query.ZoneID != nil {
+			zone, err := database.GetZone(r.Context(), apiService.DatabaseClient.DB, *query.ZoneID)
+			if err != nil {
+				if errors.Is(err, database.ErrorNoZone) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusNotFound)
+					json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Zone not found"})
+					return
+				}
+
+				apiService.Error().Msgf("Error retrieving zone %s: %s", query.ZoneID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+
+			polygon, err := geo.ParsePolygon(zone.Boundary)
+			if err != nil {
+				apiService.Error().Msgf("Error parsing boundary of zone %s: %s", zone.ID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+			box, mask = polygon.Bounds(), polygon.Contains
+		} else {
+			box = *query.BoundingBox
+		}
+
+		columns, rows, err := heatmapGridSize(box, query.CellSizeMeters)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		sensors, err := apiService.DatabaseClient.GetAllSensors(r.Context(), username, false)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving sensors: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+		// sensors outside the area still count towards the cells within their reach
+		reach := box.Expand(query.RadiusMeters)
+		sensors, _ = filterSensorsByLocation(sensors, sensorLocationFilter{BoundingBox: &reach})
+
+		// only readings recent enough for their sensor to count as online at the
+		// time asked for are used
+		filter := database.SensorMeasurementFilter{
+			MeasurementTypes: []string{measurementType.ID},
+			Start:            query.At,
+			End:              query.At,
+		}
+		sensorsByID := make(map[string]database.Sensor, len(sensors))
+		for _, sensor := range sensors {
+			filter.SensorIDs = append(filter.SensorIDs, sensor.ID)
+			sensorsByID[sensor.ID] = sensor
+			if start := query.At.Add(-sensor.OnlineThreshold()); start.Before(filter.Start) {
+				filter.Start = start
+			}
+		}
+
+		// an empty filter would select every sensor's readings
+		var readings []database.SensorMeasurement
+		var calibrations database.SensorCalibrations
+		if len(filter.SensorIDs) > 0 {
+			if r.URL.Query().Get("raw") != "true" {
+				calibrations, err = database.GetSensorCalibrations(r.Context(), apiService.DatabaseClient.DB, filter.SensorIDs, filter.MeasurementTypes)
+				if err != nil {
+					apiService.Error().Msgf("Error retrieving %s calibrations for the heatmap: %s", measurementType.ID, err)
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusInternalServerError)
+					json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+					return
+				}
+			}
+
+			readings, err = database.GetSensorMeasurementsAt(r.Context(), apiService.DatabaseClient.DB, filter)
+			if err != nil {
+				apiService.Error().Msgf("Error retrieving %s readings for the heatmap: %s", measurementType.ID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+		}
+
+		samples := make([]geo.Sample, 0, len(readings))
+		heatmap := api.Heatmap{
+			MeasurementType: measurementType.ID,
+			Unit:            converter.Unit(measurementType.Unit),
+			At:              query.At.UTC(),
+			Method:          query.Method,
+			BoundingBox:     []float64{box.West, box.South, box.East, box.North},
+			Columns:         columns,
+			Rows:            rows,
+			CellSizeMeters:  query.CellSizeMeters,
+			Samples:         make([]api.HeatmapSample, 0, len(readings)),
+		}
+		for _, reading := range readings {
+			sensor := sensorsByID[reading.SensorID]
+			if query.At.Sub(reading.Date) > sensor.OnlineThreshold() {
+				continue
+			}
+
+			value := converter.Value(measurementType.Unit, calibrations.Calibrate(reading.SensorID, reading.MeasurementType, reading.Date, reading.Value))
+			samples = append(samples, geo.Sample{
+				Point: geo.Point{Longitude: sensor.Longitude, Latitude: sensor.Latitude},
+				Value: value,
+			})
+			heatmap.Samples = append(heatmap.Samples, api.HeatmapSample{
+				SensorID:  reading.SensorID,
+				Latitude:  sensor.Latitude,
+				Longitude: sensor.Longitude,
+				Date:      reading.Date.UTC(),
+				Value:     value,
+			})
+		}
+
+		surface, err := interpolator.Fit(samples)
+		if errors.Is(err, geo.ErrorNoSamples) {
+			surface, err = noSurface{}, nil
+		}
+		if err != nil {
+			apiService.Error().Msgf("Error interpolating %d %s readings: %s", len(samples), measurementType.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		grid := geo.SampleGrid(surface, box, columns, rows, mask)
+		heatmap.Values, heatmap.Min, heatmap.Max = heatmapValues(grid)
+
+		apiService.Debug().Msgf("Sending back a %d by %d %s heatmap from %d sensors", columns, rows, measurementType.ID, len(samples))
+
+		if query.Format != HeatmapFormatPNG {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(heatmap)
+			return
+		}
+
+		// colours are scaled to the plausible range of the measurement type where it
+		// has one, so images taken at different times can be compared
+		var low, high float64
+		if measurementType.MinValue != nil && measurementType.MaxValue != nil {
+			low = converter.Value(measurementType.Unit, *measurementType.MinValue)
+			high = converter.Value(measurementType.Unit, *measurementType.MaxValue)
+		} else if heatmap.Min != nil {
+			low, high = *heatmap.Min, *heatmap.Max
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("X-Heatmap-Bounds", fmt.Sprintf("%g,%g,%g,%g", box.West, box.South, box.East, box.North))
+		w.Header().Set("X-Heatmap-Min", strconv.FormatFloat(low, 'g', -1, 64))
+		w.Header().Set("X-Heatmap-Max", strconv.FormatFloat(high, 'g', -1, 64))
+		w.Header().Set("X-Heatmap-Unit", heatmap.Unit)
+		w.WriteHeader(http.StatusOK)
+		err = writeHeatmapPNG(w, grid, low, high)
+		if err != nil {
+			apiService.Error().Msgf("Error writing %s heatmap image: %s", measurementType.ID, err)
+		}
+	}
+}
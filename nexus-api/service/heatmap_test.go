@@ -0,0 +1,103 @@
+package service
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"math"
+	"nexus-api/geo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestNewHeatmapInterpolator(t *testing.T) {
+	// execute test
+	interpolator, err := newHeatmapInterpolator(HeatmapMethodIDW, 2, 500)
+	_, unknownErr := newHeatmapInterpolator("kriging", 2, 500)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, geo.InverseDistanceWeighting{Power: 2, MaxDistanceMeters: 500}, interpolator)
+	assert.Error(t, unknownErr)
+}
+
+func TestUnitTestHeatmapGridSize(t *testing.T) {
+	// setup test data
+	box := geo.BoundingBox{West: 10, South: 0, East: 10.001, North: 0.002}
+
+	// execute test
+	columns, rows, err := heatmapGridSize(box, 10)
+	assert.NoError(t, err)
+	oneColumn, oneRow, err := heatmapGridSize(box, 1000)
+	assert.NoError(t, err)
+
+	// assert results
+	assert.Equal(t, 12, columns, "111m wide in 10m cells")
+	assert.Equal(t, 23, rows, "222m high in 10m cells")
+	assert.Equal(t, 1, oneColumn)
+	assert.Equal(t, 1, oneRow)
+}
+
+func TestUnitTestHeatmapGridSizeRefusesTooManyCells(t *testing.T) {
+	// setup test data
+	world := geo.BoundingBox{West: -180, South: 0, East: 180, North: 1e-16}
+	field := geo.BoundingBox{West: 10, South: 0, East: 10.1, North: 0.1}
+
+	// execute test
+	_, _, overflowErr := heatmapGridSize(world, 6e-12)
+	_, _, largeErr := heatmapGridSize(field, 1)
+
+	// assert results
+	assert.Error(t, overflowErr, "a cell count that overflows an int is still refused")
+	assert.Error(t, largeErr)
+}
+
+func TestUnitTestHeatmapValues(t *testing.T) {
+	// setup test data
+	grid := geo.Grid{Columns: 2, Rows: 2, Values: []float64{12.34567, math.NaN(), 30, 18}}
+
+	// execute test
+	values, minimum, maximum := heatmapValues(grid)
+
+	// assert results
+	assert.Len(t, values, 2)
+	assert.Equal(t, 12.3457, *values[0][0])
+	assert.Nil(t, values[0][1])
+	assert.Equal(t, 30.0, *values[1][0])
+	assert.Equal(t, 12.3457, *minimum)
+	assert.Equal(t, 30.0, *maximum)
+
+	_, minimum, maximum = heatmapValues(geo.Grid{Columns: 1, Rows: 1, Values: []float64{math.NaN()}})
+	assert.Nil(t, minimum)
+	assert.Nil(t, maximum)
+}
+
+func TestUnitTestHeatmapColor(t *testing.T) {
+	// execute & assert results
+	assert.Equal(t, color.NRGBA{R: 140, G: 81, B: 10, A: heatmapAlpha}, heatmapColor(0, 0, 100), "dry is brown")
+	assert.Equal(t, color.NRGBA{R: 1, G: 102, B: 94, A: heatmapAlpha}, heatmapColor(100, 0, 100), "wet is blue-green")
+	assert.Equal(t, color.NRGBA{R: 245, G: 245, B: 245, A: heatmapAlpha}, heatmapColor(50, 0, 100))
+	assert.Equal(t, heatmapColor(100, 0, 100), heatmapColor(150, 0, 100), "values past the scale take its end")
+	assert.Equal(t, heatmapColor(50, 0, 100), heatmapColor(7, 7, 7), "a flat scale is its middle")
+}
+
+func TestUnitTestWriteHeatmapPNG(t *testing.T) {
+	// setup test data
+	grid := geo.Grid{Columns: 3, Rows: 2, Values: []float64{0, 50, 100, math.NaN(), 25, 75}}
+	var buffer bytes.Buffer
+
+	// execute test
+	err := writeHeatmapPNG(&buffer, grid, 0, 100)
+
+	// assert results
+	assert.NoError(t, err)
+	img, err := png.Decode(&buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, img.Bounds().Dx())
+	assert.Equal(t, 2, img.Bounds().Dy())
+	assert.Equal(t, heatmapColor(0, 0, 100), color.NRGBAModel.Convert(img.At(0, 0)), "row 0 is the north row")
+	assert.Equal(t, heatmapColor(100, 0, 100), color.NRGBAModel.Convert(img.At(2, 0)))
+	_, _, _, alpha := img.At(0, 1).RGBA()
+	assert.Zero(t, alpha, "cells without a value are transparent")
+}
@@ -0,0 +1,612 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxImportFileSize bounds the size of an uploaded import file
+	maxImportFileSize = 64 << 20
+	// importRowChunkSize is the number of rows saved in each transaction
+	// and between updates of the job's progress
+	importRowChunkSize = 500
+	// maxImportJobErrors bounds the row errors stored for a job so a file
+	// imported with the wrong mapping doesn't store an error for every row
+	maxImportJobErrors = 1000
+
+	defaultImportErrorsPageSize = 100
+	maxImportErrorsPageSize     = 1000
+)
+
+// importColumns is a mapping resolved against the header of an imported file
+type importColumns struct {
+	sensorIDColumn        int
+	sensorID              string
+	dateColumn            int
+	dateFormat            string
+	measurementTypeColumn int
+	valueColumn           int
+	// valueColumns maps column indexes to measurement types for wide files
+	valueColumns map[int]string
+	// registered maps each registered measurement type id to its unit
+	registered map[string]string
+}
+
+// newImportColumns checks the mapping of an import and finds the columns it names in
+// the file's header, registered maps each registered measurement type id to its unit
+func newImportColumns(mapping api.SensorDataImportMapping, header []string, registered map[string]string) (importColumns, error) {
+	columns := importColumns{
+		sensorIDColumn:        -1,
+		measurementTypeColumn: -1,
+		valueColumn:           -1,
+		dateFormat:            mapping.DateFormat,
+		registered:            registered,
+	}
+	if columns.dateFormat == "" {
+		columns.dateFormat = time.RFC3339
+	}
+
+	headerIndexes := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if _, ok := headerIndexes[name]; !ok {
+			headerIndexes[name] = i
+		}
+	}
+
+	column := func(field string, name string) (int, error) {
+		index, ok := headerIndexes[name]
+		if !ok {
+			return -1, fmt.Errorf("%s %q is not a column of the file", field, name)
+		}
+
+		return index, nil
+	}
+
+	var err error
+	switch {
+	case mapping.SensorIDColumn != "" && mapping.SensorID != "":
+		return columns, fmt.Errorf("only one of sensor_id_column and sensor_id can be set")
+	case mapping.SensorIDColumn != "":
+		columns.sensorIDColumn, err = column("sensor_id_column", mapping.SensorIDColumn)
+		if err != nil {
+			return columns, err
+		}
+	case mapping.SensorID != "":
+		columns.sensorID = mapping.SensorID
+	default:
+		return columns, fmt.Errorf("one of sensor_id_column or sensor_id is required")
+	}
+
+	if mapping.DateColumn == "" {
+		return columns, fmt.Errorf("date_column is required")
+	}
+	columns.dateColumn, err = column("date_column", mapping.DateColumn)
+	if err != nil {
+		return columns, err
+	}
+
+	longFormat := mapping.MeasurementTypeColumn != "" || mapping.ValueColumn != ""
+	switch {
+	case longFormat && len(mapping.ValueColumns) > 0:
+		return columns, fmt.Errorf("value_columns can't be combined with measurement_type_column and value_column")
+	case longFormat:
+		if mapping.MeasurementTypeColumn == "" || mapping.ValueColumn == "" {
+			return columns, fmt.Errorf("measurement_type_column and value_column must be set together")
+		}
+		columns.measurementTypeColumn, err = column("measurement_type_column", mapping.MeasurementTypeColumn)
+		if err != nil {
+			return columns, err
+		}
+		columns.valueColumn, err = column("value_column", mapping.ValueColumn)
+		if err != nil {
+			return columns, err
+		}
+	case len(mapping.ValueColumns) > 0:
+		columns.valueColumns = make(map[int]string, len(mapping.ValueColumns))
+		for name, measurementType := range mapping.ValueColumns {
+			index, err := column("value column", name)
+			if err != nil {
+				return columns, err
+			}
+
+			resolved, err := resolveMeasurementTypes([]string{measurementType}, registered)
+			if err != nil {
+				return columns, err
+			}
+			columns.valueColumns[index] = resolved[0]
+		}
+	default:
+		return columns, fmt.Errorf("either value_columns or measurement_type_column and value_column are required")
+	}
+
+	return columns, nil
+}
+
+// parseRow returns the readings in a row of the file, or the reasons the row is invalid.
+// The readings of a row are only returned if every value in it is valid
+func (c importColumns) parseRow(record []string) ([]database.SensorMeasurement, []string) {
+	var problems []string
+
+	cell := func(index int) string {
+		if index >= len(record) {
+			return ""
+		}
+
+		return strings.TrimSpace(record[index])
+	}
+
+	parseValue := func(name string, raw string) (float64, bool) {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			problems = append(problems, fmt.Sprintf("%s value %q is not a number", name, raw))
+			return 0, false
+		}
+
+		return value, true
+	}
+
+	sensorID := c.sensorID
+	if c.sensorIDColumn >= 0 {
+		sensorID = cell(c.sensorIDColumn)
+		if sensorID == "" {
+			problems = append(problems, "sensor id is empty")
+		}
+	}
+
+	rawDate := cell(c.dateColumn)
+	date, err := time.Parse(c.dateFormat, rawDate)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("date %q does not match format %q", rawDate, c.dateFormat))
+	}
+
+	var measurements []database.SensorMeasurement
+	if c.valueColumns == nil {
+		measurementType := cell(c.measurementTypeColumn)
+		if alias, ok := measurementTypeAliases[measurementType]; ok {
+			measurementType = alias
+		}
+		if _, ok := c.registered[measurementType]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown measurement type %q", measurementType))
+		}
+
+		value, ok := parseValue(measurementType, cell(c.valueColumn))
+		if ok {
+			measurements = append(measurements, database.SensorMeasurement{SensorID: sensorID, MeasurementType: measurementType, Date: date, Value: value})
+		}
+	} else {
+		// visit columns in file order so readings and problems are reported consistently
+		indexes := make([]int, 0, len(c.valueColumns))
+		for index := range c.valueColumns {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+
+		for _, index := range indexes {
+			raw := cell(index)
+			if raw == "" {
+				continue
+			}
+
+			measurementType := c.valueColumns[index]
+			value, ok := parseValue(measurementType, raw)
+			if ok {
+				measurements = append(measurements, database.SensorMeasurement{SensorID: sensorID, MeasurementType: measurementType, Date: date, Value: value})
+			}
+		}
+
+		if len(measurements) == 0 && len(problems) == 0 {
+			problems = append(problems, "row has no values")
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	return measurements, nil
+}
+
+// importJobToAPI converts a stored import job to its api representation
+func importJobToAPI(job database.ImportJob) api.ImportJob {
+	apiJob := api.ImportJob{
+		ID:                  job.ID.String(),
+		Status:              job.Status,
+		FileName:            job.FileName,
+		OnConflict:          job.OnConflict,
+		CreatedBy:           job.CreatedBy,
+		TotalRows:           job.TotalRows,
+		ProcessedRows:       job.ProcessedRows,
+		InsertedReadings:    job.InsertedReadings,
+		UpdatedReadings:     job.UpdatedReadings,
+		DuplicateReadings:   job.DuplicateReadings,
+		QuarantinedReadings: job.QuarantinedReadings,
+		RejectedRows:        job.RejectedRows,
+		Error:               job.Error,
+		CreatedAt:           job.CreatedAt,
+		StartedAt:           job.StartedAt,
+		FinishedAt:          job.FinishedAt,
+	}
+	// the mapping was validated before it was stored
+	json.Unmarshal(job.Mapping, &apiJob.Mapping)
+
+	return apiJob
+}
+
+// runSensorDataImport saves the rows of an import job in chunks, recording the
+// job's progress and the rows that could not be saved as it goes
+func runSensorDataImport(ctx context.Context, apiService *APIService, job database.ImportJob, columns importColumns, rows [][]string) {
+	db := apiService.DatabaseClient.DB
+
+	startedAt := time.Now()
+	job.Status = database.ImportJobStatusRunning
+	job.StartedAt = &startedAt
+	err := job.UpdateProgress(ctx, db)
+	if err != nil {
+		apiService.Error().Msgf("Error starting import job %s: %s", job.ID, err)
+	}
+
+	fail := func(err error) {
+		apiService.Error().Msgf("Import job %s failed after %d rows: %s", job.ID, job.ProcessedRows, err)
+		finishedAt := time.Now()
+		job.Status = database.ImportJobStatusFailed
+		job.Error = err.Error()
+		job.FinishedAt = &finishedAt
+		// the job may have failed because ctx was cancelled, record the failure regardless
+		err = job.UpdateProgress(context.Background(), db)
+		if err != nil {
+			apiService.Error().Msgf("Error recording failure of import job %s: %s", job.ID, err)
+		}
+	}
+
+	// sensorExists caches whether each sensor named in the file is registered
+	sensorExists := make(map[string]bool)
+	var storedErrors int
+
+	for chunkStart := 0; chunkStart < len(rows); chunkStart += importRowChunkSize {
+		chunkEnd := min(chunkStart+importRowChunkSize, len(rows))
+
+		var measurements []database.SensorMeasurement
+		// measurementRows holds the file row number of each reading
+		var measurementRows []int
+		rowProblems := make(map[int][]string)
+
+		for i := chunkStart; i < chunkEnd; i++ {
+			// the header is row 1
+			rowNumber := i + 2
+
+			rowMeasurements, problems := columns.parseRow(rows[i])
+			if len(problems) > 0 {
+				rowProblems[rowNumber] = problems
+				continue
+			}
+
+			sensorID := rowMeasurements[0].SensorID
+			exists, ok := sensorExists[sensorID]
+			if !ok {
+				_, err := database.GetSensorByID(ctx, db, sensorID)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					fail(fmt.Errorf("error checking sensor %s exists: %w", sensorID, err))
+					return
+				}
+				exists = err == nil
+				sensorExists[sensorID] = exists
+			}
+			if !exists {
+				rowProblems[rowNumber] = []string{fmt.Sprintf("sensor %q is not registered", sensorID)}
+				continue
+			}
+
+			for _, measurement := range rowMeasurements {
+				measurements = append(measurements, measurement)
+				measurementRows = append(measurementRows, rowNumber)
+			}
+		}
+
+		results, err := database.InsertSensorMeasurements(ctx, db, measurements, job.OnConflict)
+		if err != nil {
+			fail(fmt.Errorf("error saving rows %d to %d: %w", chunkStart+2, chunkEnd+1, err))
+			return
+		}
+
+		for i, result := range results {
+			switch result.Status {
+			case database.MeasurementStatusInserted:
+				job.InsertedReadings++
+			case database.MeasurementStatusUpdated:
+				job.UpdatedReadings++
+			case database.MeasurementStatusDuplicate:
+				job.DuplicateReadings++
+			case database.MeasurementStatusQuarantined:
+				job.QuarantinedReadings++
+			case database.MeasurementStatusRejected:
+				rowNumber := measurementRows[i]
+				rowProblems[rowNumber] = append(rowProblems[rowNumber], result.Reason)
+			}
+		}
+
+		rowNumbers := make([]int, 0, len(rowProblems))
+		for rowNumber := range rowProblems {
+			rowNumbers = append(rowNumbers, rowNumber)
+		}
+		sort.Ints(rowNumbers)
+
+		var jobErrors []database.ImportJobError
+		for _, rowNumber := range rowNumbers {
+			job.RejectedRows++
+			if storedErrors >= maxImportJobErrors {
+				continue
+			}
+
+			storedErrors++
+			jobErrors = append(jobErrors, database.ImportJobError{
+				JobID:     job.ID,
+				RowNumber: rowNumber,
+				Message:   strings.Join(rowProblems[rowNumber], "; "),
+			})
+		}
+
+		err = database.SaveImportJobErrors(ctx, db, jobErrors)
+		if err != nil {
+			fail(fmt.Errorf("error saving row errors: %w", err))
+			return
+		}
+
+		job.ProcessedRows = chunkEnd
+		err = job.UpdateProgress(ctx, db)
+		if err != nil {
+			fail(fmt.Errorf("error saving progress: %w", err))
+			return
+		}
+	}
+
+	finishedAt := time.Now()
+	job.Status = database.ImportJobStatusCompleted
+	job.FinishedAt = &finishedAt
+	err = job.UpdateProgress(ctx, db)
+	if err != nil {
+		apiService.Error().Msgf("Error completing import job %s: %s", job.ID, err)
+		return
+	}
+
+	apiService.Info().Msgf("Import job %s completed, rows: %d, inserted: %d, updated: %d, duplicates: %d, quarantined: %d, rejected rows: %d",
+		job.ID, job.TotalRows, job.InsertedReadings, job.UpdatedReadings, job.DuplicateReadings, job.QuarantinedReadings, job.RejectedRows)
+}
+
+// CreateImportSensorDataHandler returns a handler that accepts a multipart upload of a csv
+// file of historical readings in the "file" field, the json column mapping in the
+// "mapping" field and optionally "on_conflict". The file and mapping are checked before
+// the job is created, the rows are then saved in the background
+func CreateImportSensorDataHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := r.Context().Value(UsernameContextKey).(string)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			apiService.Debug().Msgf("Error parsing import form: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to parse form data"})
+			return
+		}
+
+		var mapping api.SensorDataImportMapping
+		err := json.Unmarshal([]byte(r.FormValue("mapping")), &mapping)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "mapping must be a json column mapping"})
+			return
+		}
+
+		onConflict, err := parseOnConflict(r.FormValue("on_conflict"))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "No file provided"})
+			return
+		}
+		defer file.Close()
+
+		reader := csv.NewReader(file)
+		// short rows are reported as row errors rather than failing the file
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("invalid csv file: %s", err)})
+			return
+		}
+		if len(records) == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "file has no header row"})
+			return
+		}
+
+		measurementTypes, err := database.GetMeasurementTypes(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving measurement types: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		registered := make(map[string]string, len(measurementTypes))
+		for _, measurementType := range measurementTypes {
+			registered[measurementType.ID] = measurementType.Unit
+		}
+
+		columns, err := newImportColumns(mapping, records[0], registered)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		encodedMapping, err := json.Marshal(mapping)
+		if err != nil {
+			apiService.Error().Msgf("Error encoding import mapping: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		job := database.ImportJob{
+			Status:     database.ImportJobStatusPending,
+			FileName:   fileHeader.Filename,
+			Mapping:    encodedMapping,
+			OnConflict: onConflict,
+			CreatedBy:  username,
+			TotalRows:  len(records) - 1,
+		}
+
+		err = job.Save(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error creating import job for %s: %s", fileHeader.Filename, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to create import job"})
+			return
+		}
+
+		apiService.Debug().Msgf("User %s started import job %s of %d rows from %s", username, job.ID, job.TotalRows, job.FileName)
+
+		// the job outlives the request so it runs under the service's context
+		go runSensorDataImport(apiService.Ctx, apiService, job, columns, records[1:])
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(importJobToAPI(job))
+	}
+}
+
+// getImportJob looks up the job named in the request path, answering the request
+// directly and returning false if it can't be found
+func getImportJob(apiService *APIService, w http.ResponseWriter, r *http.Request) (database.ImportJob, bool) {
+	jobID, err := uuid.Parse(mux.Vars(r)["job_id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Import job not found"})
+		return database.ImportJob{}, false
+	}
+
+	job, err := database.GetImportJob(r.Context(), apiService.DatabaseClient.DB, jobID)
+	if err != nil {
+		if errors.Is(err, database.ErrorNoImportJob) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Import job not found"})
+			return database.ImportJob{}, false
+		}
+
+		apiService.Error().Msgf("Error retrieving import job %s: %s", jobID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return database.ImportJob{}, false
+	}
+
+	return job, true
+}
+
+// CreateGetImportJobHandler returns a handler that reports the progress of an import job
+func CreateGetImportJobHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := getImportJob(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(importJobToAPI(job))
+	}
+}
+
+// CreateGetImportJobErrorsHandler returns a handler that pages through the rows of
+// an import job that were not saved, using the after_row and limit query parameters
+func CreateGetImportJobErrorsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := getImportJob(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		afterRow, limit, err := parseImportErrorsPage(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		// fetch one more error than the limit to tell if there is another page
+		jobErrors, err := database.GetImportJobErrors(r.Context(), apiService.DatabaseClient.DB, job.ID, afterRow, limit+1)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving errors of import job %s: %s", job.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetImportJobErrorsResponse{
+			JobID:  job.ID.String(),
+			Errors: make([]api.ImportJobError, 0, len(jobErrors)),
+		}
+
+		if len(jobErrors) > limit {
+			jobErrors = jobErrors[:limit]
+			nextAfterRow := jobErrors[limit-1].RowNumber
+			response.NextAfterRow = &nextAfterRow
+		}
+
+		for _, jobError := range jobErrors {
+			response.Errors = append(response.Errors, api.ImportJobError{
+				RowNumber: jobError.RowNumber,
+				Message:   jobError.Message,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
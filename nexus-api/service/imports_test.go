@@ -0,0 +1,96 @@
+package service
+
+import (
+	"nexus-api/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testImportRegistered = map[string]string{"soil_moisture": "%", "soil_temperature": "°C", "soil_ph": "pH"}
+
+func TestUnitTestImportColumnsWideFile(t *testing.T) {
+	// setup test data
+	header := []string{"device", " timestamp", "moisture_pct", "temp_c", "notes"}
+	mapping := api.SensorDataImportMapping{
+		SensorIDColumn: "device",
+		DateColumn:     "timestamp",
+		DateFormat:     "2006-01-02 15:04:05",
+		ValueColumns:   map[string]string{"moisture_pct": "moisture", "temp_c": "soil_temperature"},
+	}
+
+	// execute test
+	columns, err := newImportColumns(mapping, header, testImportRegistered)
+	assert.NoError(t, err)
+	measurements, problems := columns.parseRow([]string{"2CF7F1C0649007B3", "2025-03-01 06:30:00", "31.5", "", "dry spell"})
+
+	// assert results
+	assert.Empty(t, problems)
+	if assert.Equal(t, 1, len(measurements)) {
+		assert.Equal(t, "2CF7F1C0649007B3", measurements[0].SensorID)
+		assert.Equal(t, "soil_moisture", measurements[0].MeasurementType)
+		assert.Equal(t, time.Date(2025, 3, 1, 6, 30, 0, 0, time.UTC), measurements[0].Date)
+		assert.Equal(t, 31.5, measurements[0].Value)
+	}
+
+	measurements, problems = columns.parseRow([]string{"2CF7F1C0649007B3", "2025-03-01T06:30:00Z", "wet", "12"})
+	assert.Nil(t, measurements)
+	assert.Equal(t, []string{
+		`date "2025-03-01T06:30:00Z" does not match format "2006-01-02 15:04:05"`,
+		`soil_moisture value "wet" is not a number`,
+	}, problems)
+
+	_, problems = columns.parseRow([]string{"2CF7F1C0649007B3", "2025-03-01 06:30:00", "", ""})
+	assert.Equal(t, []string{"row has no values"}, problems)
+}
+
+func TestUnitTestImportColumnsLongFile(t *testing.T) {
+	// setup test data
+	header := []string{"date", "type", "value"}
+	mapping := api.SensorDataImportMapping{
+		SensorID:              "2CF7F1C0649007B3",
+		DateColumn:            "date",
+		MeasurementTypeColumn: "type",
+		ValueColumn:           "value",
+	}
+
+	// execute test
+	columns, err := newImportColumns(mapping, header, testImportRegistered)
+	assert.NoError(t, err)
+	measurements, problems := columns.parseRow([]string{"2025-03-01T06:30:00+02:00", "soil_ph", "6.8"})
+
+	// assert results
+	assert.Empty(t, problems)
+	if assert.Equal(t, 1, len(measurements)) {
+		assert.Equal(t, "2CF7F1C0649007B3", measurements[0].SensorID)
+		assert.Equal(t, "soil_ph", measurements[0].MeasurementType)
+		assert.True(t, measurements[0].Date.Equal(time.Date(2025, 3, 1, 4, 30, 0, 0, time.UTC)))
+	}
+
+	_, problems = columns.parseRow([]string{"2025-03-01T06:30:00Z", "humidity", "NaN"})
+	assert.Equal(t, []string{`unknown measurement type "humidity"`, `humidity value "NaN" is not a number`}, problems)
+
+	// short rows are invalid rather than out of range
+	_, problems = columns.parseRow([]string{"2025-03-01T06:30:00Z"})
+	assert.NotEmpty(t, problems)
+}
+
+func TestUnitTestImportColumnsRejectsInvalidMappings(t *testing.T) {
+	header := []string{"sensor", "date", "type", "value", "moisture"}
+
+	for name, mapping := range map[string]api.SensorDataImportMapping{
+		"no sensor":           {DateColumn: "date", ValueColumns: map[string]string{"moisture": "moisture"}},
+		"two sensor sources":  {SensorID: "a", SensorIDColumn: "sensor", DateColumn: "date", ValueColumns: map[string]string{"moisture": "moisture"}},
+		"no date":             {SensorID: "a", ValueColumns: map[string]string{"moisture": "moisture"}},
+		"missing column":      {SensorID: "a", DateColumn: "timestamp", ValueColumns: map[string]string{"moisture": "moisture"}},
+		"no values":           {SensorID: "a", DateColumn: "date"},
+		"half of long format": {SensorID: "a", DateColumn: "date", ValueColumn: "value"},
+		"long and wide":       {SensorID: "a", DateColumn: "date", MeasurementTypeColumn: "type", ValueColumn: "value", ValueColumns: map[string]string{"moisture": "moisture"}},
+		"unknown type":        {SensorID: "a", DateColumn: "date", ValueColumns: map[string]string{"moisture": "humidity"}},
+	} {
+		_, err := newImportColumns(mapping, header, testImportRegistered)
+
+		assert.Error(t, err, name)
+	}
+}
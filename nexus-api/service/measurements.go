@@ -0,0 +1,870 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/uptrace/bun"
+)
+
+// maxRetentionDays bounds the retention periods of a measurement type, about a century
+const maxRetentionDays = 36500
+
+// measurementTypeIDPattern restricts measurement type ids to values that are safe in urls
+var measurementTypeIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// sensorOnlineStatus returns whether a sensor whose most recent reading was taken at
+// latest is online given its online threshold and the time of that reading, both nil
+// if it has no readings
+func sensorOnlineStatus(latest time.Time, threshold time.Duration) (*bool, *time.Time) {
+	if latest.IsZero() {
+		return nil, nil
+	}
+
+	isOnline := time.Since(latest) <= threshold
+
+	return &isOnline, &latest
+}
+
+// sensorMeasurementsPage is a page of a sensor's readings of one measurement type
+// along with the sensor's online status
+type sensorMeasurementsPage struct {
+	MeasurementType database.MeasurementType
+	// Unit of the page's values, the measurement type's canonical unit
+	// unless other units were asked for
+	Unit  string
+	Units unitConverter
+	// SensorIDs are the sensors whose readings were read, more than one
+	// when the history of the sensors it replaced was asked for
+	SensorIDs []string
+	// Calibrations of the sensors applied to their readings, empty when
+	// the sensors aren't calibrated or raw readings were asked for
+	Calibrations      database.SensorCalibrations
+	Measurements      []database.SensorMeasurement
+	NextCursor        string
+	IsOnline          *bool
+	LastDataTimestamp *time.Time
+	// Resolution is set when the range reaches back past the raw readings' retention
+	// and the measurements are the averages of the rollups of that resolution
+	Resolution string
+	// RawRetentionCutoff is set when raw readings were asked for without a start and
+	// readings before it are only kept as rollups
+	RawRetentionCutoff *time.Time
+}
+
+// readSensorMeasurements does the work shared by the endpoints that return a sensor's
+// readings. Errors and requests for aggregated buckets are answered directly, in
+// which case false is returned and the caller has nothing left to write
+func readSensorMeasurements(apiService *APIService, w http.ResponseWriter, r *http.Request, sensorID string, measurementTypeID string) (sensorMeasurementsPage, bool) {
+	var page sensorMeasurementsPage
+
+	measurementType, err := database.GetMeasurementType(r.Context(), apiService.DatabaseClient.DB, measurementTypeID)
+	if err != nil {
+		if errors.Is(err, database.ErrorNoMeasurementType) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown measurement type %q", measurementTypeID)})
+			return page, false
+		}
+
+		apiService.Error().Msgf("Error retrieving measurement type %s, error: %s", measurementTypeID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return page, false
+	}
+	page.MeasurementType = measurementType
+
+	query, err := parseSensorDataQuery(r)
+	if err != nil {
+		apiService.Debug().Msgf("Invalid %s data query for sensor_id: %s, error: %s", measurementTypeID, sensorID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+		return page, false
+	}
+
+	page.Units, err = parseUnitsParameter(r.URL.Query().Get("units"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+		return page, false
+	}
+	page.Unit = page.Units.Unit(measurementType.Unit)
+
+	resolution, err := parseResolution(r, query)
+	if err != nil {
+		apiService.Debug().Msgf("Invalid %s data resolution for sensor_id: %s, error: %s", measurementTypeID, sensorID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+		return page, false
+	}
+
+	// ranges reaching back past the raw readings' retention are read from the
+	// rollups unless raw readings or a page of them were asked for explicitly,
+	// which only reach back to the cutoff so older starts are refused and open
+	// ones are told where the raw readings stop
+	if r.URL.Query().Get("resolution") == "" && query.Limit == 0 && query.After == nil {
+		page.Resolution = retentionResolution([]database.MeasurementType{measurementType}, query.Start, time.Now())
+	} else if cutoff := measurementType.RawRetentionCutoff(time.Now()); resolution == "" && !cutoff.IsZero() {
+		if !query.Start.IsZero() && query.Start.Before(cutoff) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("start is before %s, when raw %s readings start being rolled up, read older readings with a resolution and without limit or cursor", cutoff.Format(time.RFC3339), measurementTypeID)})
+			return page, false
+		}
+
+		page.RawRetentionCutoff = &cutoff
+	}
+
+	// with include_replaced=true the readings of every sensor in the chain of
+	// replacements the sensor is part of are read as one history
+	page.SensorIDs = []string{sensorID}
+	if r.URL.Query().Get("include_replaced") == "true" {
+		page.SensorIDs, err = database.GetSensorChain(r.Context(), apiService.DatabaseClient.DB, sensorID)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving replacements of sensor_id: %s, error: %s", sensorID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return page, false
+		}
+	}
+
+	// readings are calibrated unless raw=true asks for them as stored
+	if r.URL.Query().Get("raw") != "true" {
+		page.Calibrations, err = database.GetSensorCalibrations(r.Context(), apiService.DatabaseClient.DB, page.SensorIDs, []string{measurementTypeID})
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving %s calibrations for sensor_id: %s, error: %s", measurementTypeID, sensorID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return page, false
+		}
+	}
+
+	// The page may not include the newest reading, so look it up separately
+	mostRecentTimestamp, err := database.GetLatestSensorMeasurementDate(r.Context(), apiService.DatabaseClient.DB, page.SensorIDs, measurementTypeID)
+	if err != nil {
+		apiService.Error().Msgf("Error retrieving latest %s data timestamp for sensor_id: %s, error: %s", measurementTypeID, sensorID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return page, false
+	}
+
+	thresholds, err := database.GetSensorOnlineThresholds(r.Context(), apiService.DatabaseClient.DB, []string{sensorID})
+	if err != nil {
+		apiService.Error().Msgf("Error retrieving online threshold for sensor_id: %s, error: %s", sensorID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return page, false
+	}
+
+	threshold, ok := thresholds[sensorID]
+	if !ok {
+		threshold = database.DefaultSensorOnlineThreshold
+	}
+
+	// Check if sensor is online based on most recent data timestamp
+	page.IsOnline, page.LastDataTimestamp = sensorOnlineStatus(mostRecentTimestamp, threshold)
+	if page.IsOnline != nil && !*page.IsOnline {
+		apiService.Warn().Msgf("Sensor %s appears offline: last %s data timestamp (%v) is older than %s", sensorID, measurementTypeID, mostRecentTimestamp, threshold)
+	}
+
+	if resolution != "" {
+		serveSensorDataBuckets(apiService, w, r, sensorID, measurementType, resolution, query, page)
+		return page, false
+	}
+
+	// the caller asked for readings, so the rollups are returned as readings
+	// holding their average to keep the shape of the response
+	if page.Resolution != "" {
+		buckets, err := getSensorDataBuckets(r.Context(), apiService.DatabaseClient.DB, page, page.Resolution, query)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving %s %s data buckets for sensor_id: %s, error: %s", page.Resolution, measurementTypeID, sensorID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return page, false
+		}
+
+		if len(buckets) == 0 {
+			apiService.Debug().Msgf("No %s data found for sensor_id: %s", measurementTypeID, sensorID)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "No data found"})
+			return page, false
+		}
+
+		page.Measurements = rollupMeasurements(page, sensorID, buckets)
+
+		apiService.Debug().Msgf("Sending back %d %s %s rollups as readings for sensor_id: %s", len(page.Measurements), page.Resolution, measurementTypeID, sensorID)
+
+		return page, true
+	}
+
+	data, nextCursor, err := database.GetSensorMeasurements(r.Context(), apiService.DatabaseClient.DB, page.SensorIDs, measurementTypeID, query)
+	if err != nil {
+		if errors.Is(err, database.ErrorNoSensorMeasurements) {
+			apiService.Debug().Msgf("No %s data found for sensor_id: %s", measurementTypeID, sensorID)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "No data found"})
+			return page, false
+		}
+
+		apiService.Error().Msgf("Error retrieving %s data for sensor_id: %s, error: %s", measurementTypeID, sensorID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return page, false
+	}
+	for i := range data {
+		calibrated := page.Calibrations.Calibrate(data[i].SensorID, data[i].MeasurementType, data[i].Date, data[i].Value)
+		data[i].Value = page.Units.Value(measurementType.Unit, calibrated)
+	}
+	page.Measurements = data
+
+	if nextCursor != nil {
+		page.NextCursor = nextCursor.Encode()
+	}
+
+	apiService.Debug().Msgf("Sending back %d %s data records for sensor_id: %s", len(data), measurementTypeID, sensorID)
+
+	return page, true
+}
+
+// serveSensorDataBuckets writes the aggregated readings of a sensor for the
+// requested resolution along with the sensor's online status
+func serveSensorDataBuckets(apiService *APIService, w http.ResponseWriter, r *http.Request, sensorID string, measurementType database.MeasurementType, resolution string, query database.SensorDataQuery, page sensorMeasurementsPage) {
+	buckets, err := getSensorDataBuckets(r.Context(), apiService.DatabaseClient.DB, page, resolution, query)
+	if err != nil {
+		apiService.Error().Msgf("Error retrieving %s %s data buckets for sensor_id: %s, error: %s", resolution, measurementType.ID, sensorID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	if len(buckets) == 0 {
+		apiService.Debug().Msgf("No %s data found for sensor_id: %s", measurementType.ID, sensorID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "No data found"})
+		return
+	}
+
+	response := api.GetSensorDataBucketsResponse{
+		SensorID:          sensorID,
+		MeasurementType:   measurementType.ID,
+		Unit:              page.Unit,
+		Resolution:        resolution,
+		IsOnline:          page.IsOnline,
+		LastDataTimestamp: page.LastDataTimestamp,
+	}
+	for _, bucket := range buckets {
+		response.Buckets = append(response.Buckets, page.Units.Bucket(measurementType.Unit, bucket))
+	}
+
+	apiService.Debug().Msgf("Sending back %d %s %s buckets for sensor_id: %s", len(response.Buckets), resolution, measurementType.ID, sensorID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// getSensorDataBuckets returns the buckets of resolution of the page's sensors,
+// calibrated when the page has calibrations, ordered as query asks
+func getSensorDataBuckets(ctx context.Context, db *bun.DB, page sensorMeasurementsPage, resolution string, query database.SensorDataQuery) ([]api.SensorDataBucket, error) {
+	if len(page.Calibrations) > 0 {
+		return getCalibratedSensorDataBuckets(ctx, db, page, resolution, query)
+	}
+
+	stored, err := database.GetSensorMeasurementBuckets(ctx, db, page.SensorIDs, page.MeasurementType.ID, resolution, query)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]api.SensorDataBucket, 0, len(stored))
+	for _, b := range stored {
+		buckets = append(buckets, sensorDataBucketToAPI(b))
+	}
+
+	return buckets, nil
+}
+
+// rollupMeasurements returns the buckets as readings of the page's measurement type
+// dated at the start of the bucket and holding its average in the page's unit. The
+// readings are credited to sensorID unless the page combines several sensors' history
+func rollupMeasurements(page sensorMeasurementsPage, sensorID string, buckets []api.SensorDataBucket) []database.SensorMeasurement {
+	if len(page.SensorIDs) > 1 {
+		sensorID = ""
+	}
+
+	measurements := make([]database.SensorMeasurement, 0, len(buckets))
+	for _, bucket := range buckets {
+		measurements = append(measurements, database.SensorMeasurement{
+			SensorID:        sensorID,
+			MeasurementType: page.MeasurementType.ID,
+			Date:            bucket.BucketStart,
+			Value:           page.Units.Value(page.MeasurementType.Unit, bucket.Avg),
+		})
+	}
+
+	return measurements
+}
+
+// sensorDataBucketToAPI converts a bucket of readings to its api representation
+func sensorDataBucketToAPI(bucket database.SensorDataBucket) api.SensorDataBucket {
+	return api.SensorDataBucket{
+		BucketStart: bucket.BucketStart.UTC(),
+		Min:         bucket.Min,
+		Max:         bucket.Max,
+		Avg:         bucket.Avg,
+		Count:       bucket.Count,
+	}
+}
+
+// getCalibratedSensorDataBuckets returns the buckets of the page's sensors with each
+// sensor's buckets calibrated before they are combined, ordered as query asks
+func getCalibratedSensorDataBuckets(ctx context.Context, db *bun.DB, page sensorMeasurementsPage, resolution string, query database.SensorDataQuery) ([]api.SensorDataBucket, error) {
+	filter := database.SensorMeasurementFilter{
+		SensorIDs:        page.SensorIDs,
+		MeasurementTypes: []string{page.MeasurementType.ID},
+		Start:            query.Start,
+		End:              query.End,
+	}
+
+	stored, err := database.GetSensorMeasurementSeriesBuckets(ctx, db, filter, resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]api.SensorDataBucket, 0, len(stored))
+	for _, b := range stored {
+		buckets = append(buckets, sensorDataBucketToAPI(page.Calibrations.CalibrateBucket(b.SensorID, b.MeasurementType, b.SensorDataBucket)))
+	}
+	buckets = mergeSensorDataBuckets(buckets)
+
+	if query.Order == database.SortOrderDescending {
+		slices.Reverse(buckets)
+	}
+
+	return buckets, nil
+}
+
+// saveSensorMeasurements does the work shared by the endpoints that store a sensor's
+// readings, making sure the sensor exists before saving the batch in one transaction.
+// Errors are answered directly, in which case false is returned
+func saveSensorMeasurements(apiService *APIService, w http.ResponseWriter, r *http.Request, sensorID string, measurements []database.SensorMeasurement, onConflict string) (api.SetSensorMeasurementsResponse, bool) {
+	var response api.SetSensorMeasurementsResponse
+
+	onConflict, err := parseOnConflict(onConflict)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+		return response, false
+	}
+
+	// Check if we have any data points to determine sensor online status
+	var mostRecentTimestamp time.Time
+	if len(measurements) > 0 {
+		// Find the most recent timestamp in the batch
+		mostRecentTimestamp = measurements[0].Date
+		for _, measurement := range measurements {
+			if measurement.Date.After(mostRecentTimestamp) {
+				mostRecentTimestamp = measurement.Date
+			}
+		}
+	} else {
+		// No data in request, use current time (sensor is sending data, so it's online)
+		mostRecentTimestamp = time.Now()
+	}
+
+	// Ensure sensor exists before saving data, but only if it's online (data is recent)
+	// New sensors have the default reporting interval, so use the default threshold
+	err = database.EnsureSensorExistsIfOnline(r.Context(), apiService.DatabaseClient.DB, sensorID, sensorID, mostRecentTimestamp, database.DefaultSensorOnlineThreshold)
+	if errors.Is(err, database.ErrorSensorDecommissioned) {
+		apiService.Warn().Msgf("Refusing %d readings for decommissioned sensor_id: %s", len(measurements), sensorID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Sensor is decommissioned"})
+		return response, false
+	}
+	if err != nil {
+		// If sensor is offline, log warning but don't fail, the readings are still stored
+		apiService.Warn().Msgf("Sensor %s appears offline or error ensuring exists: %s", sensorID, err)
+
+		// Try to ensure sensor exists without online check as fallback
+		err = database.EnsureSensorExists(r.Context(), apiService.DatabaseClient.DB, sensorID, sensorID)
+		if err != nil {
+			apiService.Error().Msgf("Failed to ensure sensor exists for sensor_id: %s, error: %s", sensorID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to ensure sensor exists"})
+			return response, false
+		}
+	}
+
+	for i := range measurements {
+		measurements[i].SensorID = sensorID
+	}
+
+	results, err := database.InsertSensorMeasurements(r.Context(), apiService.DatabaseClient.DB, measurements, onConflict)
+	if err != nil {
+		apiService.Error().Msgf("Failed to save batch of %d readings for sensor_id: %s, error: %s", len(measurements), sensorID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to save sensor data, no readings were saved"})
+		return response, false
+	}
+
+	response.Results = make([]api.SensorMeasurementResult, 0, len(results))
+	for i, result := range results {
+		switch result.Status {
+		case database.MeasurementStatusInserted:
+			response.Inserted++
+		case database.MeasurementStatusUpdated:
+			response.Updated++
+		case database.MeasurementStatusDuplicate:
+			response.Duplicates++
+		case database.MeasurementStatusQuarantined:
+			response.Quarantined++
+		case database.MeasurementStatusRejected:
+			response.Rejected++
+		}
+
+		response.Results = append(response.Results, api.SensorMeasurementResult{
+			Index:  i,
+			Status: result.Status,
+			ID:     result.ID,
+			Reason: result.Reason,
+		})
+	}
+
+	apiService.Trace().Msgf("Saved readings for sensor_id: %s, inserted: %d, updated: %d, duplicates: %d, quarantined: %d, rejected: %d",
+		sensorID, response.Inserted, response.Updated, response.Duplicates, response.Quarantined, response.Rejected)
+
+	// the readings stored are checked against the alert rules watching them, sent to
+	// webhook subscriptions and battery levels checked against users' low battery
+	// notifications, readings gateways publish over MQTT are saved through these
+	// endpoints too. They are stored whatever the outcome, so errors are only logged
+	var stored []database.SensorMeasurement
+	var batteryStored bool
+	created := api.WebhookReadingsCreated{SensorID: sensorID}
+	for i, result := range results {
+		if result.Status == database.MeasurementStatusInserted || result.Status == database.MeasurementStatusUpdated {
+			stored = append(stored, measurements[i])
+			batteryStored = batteryStored || measurements[i].MeasurementType == database.MeasurementTypeBatteryLevel
+			created.Readings = append(created.Readings, api.WebhookReading{
+				ID:              result.ID,
+				MeasurementType: measurements[i].MeasurementType,
+				Date:            measurements[i].Date,
+				Value:           measurements[i].Value,
+			})
+		}
+	}
+	if len(stored) > 0 {
+		apiService.publishWebhookEvent(r.Context(), database.WebhookEventReadingsCreated, created)
+
+		if batteryStored && apiService.emailClient != nil {
+			apiService.notifyLowBattery(r.Context(), sensorID, time.Now())
+		}
+
+		alerts, err := evaluateSensorAlertRules(r.Context(), apiService.DatabaseClient.DB, sensorID, stored)
+		if err != nil {
+			apiService.Error().Msgf("Error evaluating alert rules for sensor_id: %s, error: %s", sensorID, err)
+		}
+		for _, alert := range alerts {
+			apiService.Info().Msgf("Alert %d of rule %s for sensor_id: %s is %s", alert.ID, alert.RuleName, sensorID, alert.State(time.Now()))
+		}
+	}
+
+	return response, true
+}
+
+// CreateGetSensorMeasurementsHandler returns a handler for reading a sensor's
+// readings of any registered measurement type
+func CreateGetSensorMeasurementsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		sensorID := vars["sensor_id"]
+		measurementTypeID := vars["measurement_type"]
+
+		page, ok := readSensorMeasurements(apiService, w, r, sensorID, measurementTypeID)
+		if !ok {
+			return
+		}
+
+		response := api.GetSensorMeasurementsResponse{
+			SensorID:           sensorID,
+			MeasurementType:    page.MeasurementType.ID,
+			Unit:               page.Unit,
+			Measurements:       make([]api.SensorMeasurement, 0, len(page.Measurements)),
+			IsOnline:           page.IsOnline,
+			LastDataTimestamp:  page.LastDataTimestamp,
+			NextCursor:         page.NextCursor,
+			Resolution:         page.Resolution,
+			RawRetentionCutoff: page.RawRetentionCutoff,
+		}
+		for _, d := range page.Measurements {
+			measurement := api.SensorMeasurement{
+				ID:    d.ID,
+				Date:  d.Date,
+				Value: d.Value,
+			}
+			// say which sensor took each reading of a combined history
+			if len(page.SensorIDs) > 1 {
+				measurement.SensorID = d.SensorID
+			}
+			response.Measurements = append(response.Measurements, measurement)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateSetSensorMeasurementsHandler returns a handler for storing a sensor's
+// readings of any registered measurement type
+func CreateSetSensorMeasurementsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		sensorID := vars["sensor_id"]
+		measurementTypeID := vars["measurement_type"]
+
+		_, err := database.GetMeasurementType(r.Context(), apiService.DatabaseClient.DB, measurementTypeID)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoMeasurementType) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown measurement type %q", measurementTypeID)})
+				return
+			}
+
+			apiService.Error().Msgf("Error retrieving measurement type %s, error: %s", measurementTypeID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		var request api.SetSensorMeasurementsRequest
+		err = json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request"})
+			return
+		}
+
+		var measurements []database.SensorMeasurement
+		for _, measurement := range request.Measurements {
+			measurements = append(measurements, database.SensorMeasurement{
+				MeasurementType: measurementTypeID,
+				Date:            measurement.Date,
+				Value:           measurement.Value,
+			})
+		}
+
+		onConflict := request.OnConflict
+		if onConflict == "" {
+			onConflict = r.URL.Query().Get("on_conflict")
+		}
+
+		response, ok := saveSensorMeasurements(apiService, w, r, sensorID, measurements, onConflict)
+		if !ok {
+			return
+		}
+
+		response.Message = "Measurements saved successfully"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// measurementTypeToAPI converts a registry entry to its api representation
+func measurementTypeToAPI(measurementType database.MeasurementType) api.MeasurementType {
+	return api.MeasurementType{
+		ID:                  measurementType.ID,
+		DisplayName:         measurementType.DisplayName,
+		Unit:                measurementType.Unit,
+		MinValue:            measurementType.MinValue,
+		MaxValue:            measurementType.MaxValue,
+		MaxRatePerHour:      measurementType.MaxRatePerHour,
+		MQTTIdentifier:      measurementType.MQTTIdentifier,
+		RawRetentionDays:    measurementType.RawRetentionDays,
+		HourlyRetentionDays: measurementType.HourlyRetentionDays,
+		DailyRetentionDays:  measurementType.DailyRetentionDays,
+	}
+}
+
+// validateMeasurementType returns an error describing the first
+// invalid field of a measurement type that is being saved
+func validateMeasurementType(measurementType database.MeasurementType) error {
+	if !measurementTypeIDPattern.MatchString(measurementType.ID) {
+		return fmt.Errorf("id must start with a lowercase letter and contain only lowercase letters, digits and underscores")
+	}
+
+	if measurementType.DisplayName == "" {
+		return fmt.Errorf("display_name is required")
+	}
+
+	if measurementType.Unit == "" {
+		return fmt.Errorf("unit is required")
+	}
+
+	if measurementType.MinValue != nil && measurementType.MaxValue != nil && *measurementType.MinValue > *measurementType.MaxValue {
+		return fmt.Errorf("min_value must not be greater than max_value")
+	}
+
+	if measurementType.MaxRatePerHour != nil && !(*measurementType.MaxRatePerHour > 0) {
+		return fmt.Errorf("max_rate_per_hour must be greater than 0")
+	}
+
+	return validateRetentionPolicy(measurementType.RawRetentionDays, measurementType.HourlyRetentionDays, measurementType.DailyRetentionDays)
+}
+
+// validateRetentionPolicy returns an error if the retention periods, in days with 0 for
+// forever, are out of range or would delete a tier before the finer tier it summarises
+func validateRetentionPolicy(rawDays int, hourlyDays int, dailyDays int) error {
+	for name, days := range map[string]int{
+		"raw_retention_days":    rawDays,
+		"hourly_retention_days": hourlyDays,
+		"daily_retention_days":  dailyDays,
+	} {
+		if days < 0 || days > maxRetentionDays {
+			return fmt.Errorf("%s must be between 0 (keep forever) and %d", name, maxRetentionDays)
+		}
+	}
+
+	// a tier kept forever outlasts any other
+	outlasts := func(days int, other int) bool {
+		return days == 0 || (other != 0 && days >= other)
+	}
+
+	if !outlasts(hourlyDays, rawDays) {
+		return fmt.Errorf("hourly_retention_days must not be shorter than raw_retention_days")
+	}
+
+	if !outlasts(dailyDays, hourlyDays) || !outlasts(dailyDays, rawDays) {
+		return fmt.Errorf("daily_retention_days must not be shorter than hourly_retention_days or raw_retention_days")
+	}
+
+	return nil
+}
+
+// CreateGetMeasurementTypesHandler returns a handler that lists the measurement registry
+func CreateGetMeasurementTypesHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		measurementTypes, err := database.GetMeasurementTypes(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving measurement types: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetMeasurementTypesResponse{
+			MeasurementTypes: make([]api.MeasurementType, 0, len(measurementTypes)),
+		}
+		for _, measurementType := range measurementTypes {
+			response.MeasurementTypes = append(response.MeasurementTypes, measurementTypeToAPI(measurementType))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateCreateMeasurementTypeHandler returns a handler that adds a measurement type to the registry (admin only)
+func CreateCreateMeasurementTypeHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request api.MeasurementType
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request"})
+			return
+		}
+
+		measurementType := database.MeasurementType{
+			ID:                  request.ID,
+			DisplayName:         request.DisplayName,
+			Unit:                request.Unit,
+			MinValue:            request.MinValue,
+			MaxValue:            request.MaxValue,
+			MaxRatePerHour:      request.MaxRatePerHour,
+			MQTTIdentifier:      request.MQTTIdentifier,
+			RawRetentionDays:    request.RawRetentionDays,
+			HourlyRetentionDays: request.HourlyRetentionDays,
+			DailyRetentionDays:  request.DailyRetentionDays,
+		}
+
+		err = validateMeasurementType(measurementType)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		err = measurementType.Save(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			if errors.Is(err, database.ErrorDuplicateMeasurementType) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+				return
+			}
+
+			apiService.Error().Msgf("Error creating measurement type %s: %s", measurementType.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to create measurement type"})
+			return
+		}
+
+		apiService.Info().Msgf("Created measurement type %s", measurementType.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(measurementTypeToAPI(measurementType))
+	}
+}
+
+// applyMeasurementTypeUpdate returns the measurement type with the fields set in the
+// request changed, fields left out of the request are kept
+func applyMeasurementTypeUpdate(measurementType database.MeasurementType, request api.UpdateMeasurementTypeRequest) (database.MeasurementType, error) {
+	if request.ClearMinValue && request.MinValue != nil {
+		return measurementType, fmt.Errorf("min_value cannot be set and cleared at once")
+	}
+	if request.ClearMaxValue && request.MaxValue != nil {
+		return measurementType, fmt.Errorf("max_value cannot be set and cleared at once")
+	}
+
+	if request.DisplayName != nil {
+		measurementType.DisplayName = *request.DisplayName
+	}
+	if request.Unit != nil {
+		measurementType.Unit = *request.Unit
+	}
+	if request.MinValue != nil {
+		measurementType.MinValue = request.MinValue
+	}
+	if request.MaxValue != nil {
+		measurementType.MaxValue = request.MaxValue
+	}
+	if request.MaxRatePerHour != nil {
+		measurementType.MaxRatePerHour = request.MaxRatePerHour
+	}
+	if request.MQTTIdentifier != nil {
+		measurementType.MQTTIdentifier = *request.MQTTIdentifier
+	}
+	if request.RawRetentionDays != nil {
+		measurementType.RawRetentionDays = *request.RawRetentionDays
+	}
+	if request.HourlyRetentionDays != nil {
+		measurementType.HourlyRetentionDays = *request.HourlyRetentionDays
+	}
+	if request.DailyRetentionDays != nil {
+		measurementType.DailyRetentionDays = *request.DailyRetentionDays
+	}
+	if request.ClearMinValue {
+		measurementType.MinValue = nil
+	}
+	if request.ClearMaxValue {
+		measurementType.MaxValue = nil
+	}
+
+	return measurementType, nil
+}
+
+// CreateUpdateMeasurementTypeHandler returns a handler that changes a registered measurement type (admin only)
+func CreateUpdateMeasurementTypeHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		measurementTypeID := mux.Vars(r)["measurement_type"]
+
+		var request api.UpdateMeasurementTypeRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request"})
+			return
+		}
+
+		measurementType, err := database.GetMeasurementType(r.Context(), apiService.DatabaseClient.DB, measurementTypeID)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoMeasurementType) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown measurement type %q", measurementTypeID)})
+				return
+			}
+
+			apiService.Error().Msgf("Error retrieving measurement type %s, error: %s", measurementTypeID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		measurementType, err = applyMeasurementTypeUpdate(measurementType, request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		err = validateMeasurementType(measurementType)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		err = measurementType.Update(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			if errors.Is(err, database.ErrorDuplicateMeasurementType) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+				return
+			}
+
+			apiService.Error().Msgf("Error updating measurement type %s: %s", measurementTypeID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to update measurement type"})
+			return
+		}
+
+		apiService.Info().Msgf("Updated measurement type %s", measurementTypeID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(measurementTypeToAPI(measurementType))
+	}
+}
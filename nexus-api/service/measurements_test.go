@@ -0,0 +1,122 @@
+package service
+
+import (
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestValidateMeasurementTypeAcceptsValidType(t *testing.T) {
+	// setup test data
+	minValue, maxValue := float64(0), float64(14)
+	measurementType := database.MeasurementType{
+		ID:          "soil_ph",
+		DisplayName: "Soil pH",
+		Unit:        "pH",
+		MinValue:    &minValue,
+		MaxValue:    &maxValue,
+	}
+
+	// execute test
+	err := validateMeasurementType(measurementType)
+
+	// assert results
+	assert.NoError(t, err)
+}
+
+func TestUnitTestValidateMeasurementTypeRejectsInvalidValues(t *testing.T) {
+	minValue, maxValue := float64(10), float64(1)
+	zeroRate := float64(0)
+
+	for name, measurementType := range map[string]database.MeasurementType{
+		"empty id":            {DisplayName: "Soil pH", Unit: "pH"},
+		"id with slash":       {ID: "soil/ph", DisplayName: "Soil pH", Unit: "pH"},
+		"uppercase id":        {ID: "SoilPH", DisplayName: "Soil pH", Unit: "pH"},
+		"missing name":        {ID: "soil_ph", Unit: "pH"},
+		"missing unit":        {ID: "soil_ph", DisplayName: "Soil pH"},
+		"min above the max":   {ID: "soil_ph", DisplayName: "Soil pH", Unit: "pH", MinValue: &minValue, MaxValue: &maxValue},
+		"zero max rate":       {ID: "soil_ph", DisplayName: "Soil pH", Unit: "pH", MaxRatePerHour: &zeroRate},
+		"negative retention":  {ID: "soil_ph", DisplayName: "Soil pH", Unit: "pH", RawRetentionDays: -1},
+		"hourly before raw":   {ID: "soil_ph", DisplayName: "Soil pH", Unit: "pH", RawRetentionDays: 90, HourlyRetentionDays: 30},
+		"daily before hourly": {ID: "soil_ph", DisplayName: "Soil pH", Unit: "pH", RawRetentionDays: 90, HourlyRetentionDays: 730, DailyRetentionDays: 365},
+	} {
+		err := validateMeasurementType(measurementType)
+
+		assert.Error(t, err, "expected error for %s", name)
+	}
+}
+
+func TestUnitTestValidateRetentionPolicy(t *testing.T) {
+	assert.NoError(t, validateRetentionPolicy(0, 0, 0), "keeping everything forever")
+	assert.NoError(t, validateRetentionPolicy(90, 730, 0), "raw 90 days, hourly 2 years, daily forever")
+	assert.NoError(t, validateRetentionPolicy(90, 0, 0))
+	assert.Error(t, validateRetentionPolicy(0, 730, 0), "hourly rollups of raw readings kept forever")
+	assert.Error(t, validateRetentionPolicy(90, 0, 365), "daily rollups deleted before the hourly ones")
+	assert.Error(t, validateRetentionPolicy(maxRetentionDays+1, 0, 0))
+}
+
+func TestUnitTestApplyMeasurementTypeUpdateClearsBounds(t *testing.T) {
+	// setup test data
+	minValue, maxValue, newMaxValue := float64(0), float64(14), float64(12)
+	measurementType := database.MeasurementType{
+		ID:          "soil_ph",
+		DisplayName: "Soil pH",
+		Unit:        "pH",
+		MinValue:    &minValue,
+		MaxValue:    &maxValue,
+	}
+
+	// execute test
+	updated, err := applyMeasurementTypeUpdate(measurementType, api.UpdateMeasurementTypeRequest{ClearMinValue: true, MaxValue: &newMaxValue})
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Nil(t, updated.MinValue)
+	assert.Equal(t, newMaxValue, *updated.MaxValue)
+	assert.Equal(t, minValue, *measurementType.MinValue, "the stored type should be left as it was")
+
+	unchanged, err := applyMeasurementTypeUpdate(measurementType, api.UpdateMeasurementTypeRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, measurementType, unchanged)
+
+	updated, err = applyMeasurementTypeUpdate(measurementType, api.UpdateMeasurementTypeRequest{ClearMaxValue: true})
+	assert.NoError(t, err)
+	assert.Nil(t, updated.MaxValue)
+
+	_, err = applyMeasurementTypeUpdate(measurementType, api.UpdateMeasurementTypeRequest{ClearMaxValue: true, MaxValue: &newMaxValue})
+	assert.Error(t, err)
+	_, err = applyMeasurementTypeUpdate(measurementType, api.UpdateMeasurementTypeRequest{ClearMinValue: true, MinValue: &minValue})
+	assert.Error(t, err)
+}
+
+func TestUnitTestRollupMeasurements(t *testing.T) {
+	// setup test data
+	hour := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	buckets := []api.SensorDataBucket{
+		{BucketStart: hour, Min: 10, Max: 20, Avg: 15, Count: 2},
+		{BucketStart: hour.Add(time.Hour), Min: 30, Max: 30, Avg: 30, Count: 1},
+	}
+	converter, err := parseUnitsParameter("fraction")
+	assert.NoError(t, err)
+	page := sensorMeasurementsPage{
+		MeasurementType: database.MeasurementType{ID: database.MeasurementTypeSoilMoisture, Unit: "%"},
+		Units:           converter,
+		SensorIDs:       []string{"a"},
+	}
+
+	// execute test
+	measurements := rollupMeasurements(page, "a", buckets)
+
+	// assert results
+	assert.Equal(t, []database.SensorMeasurement{
+		{SensorID: "a", MeasurementType: database.MeasurementTypeSoilMoisture, Date: hour, Value: 0.15},
+		{SensorID: "a", MeasurementType: database.MeasurementTypeSoilMoisture, Date: hour.Add(time.Hour), Value: 0.3},
+	}, measurements)
+
+	// a combined history's rollups are not credited to one sensor
+	page.SensorIDs = []string{"a", "b"}
+	assert.Empty(t, rollupMeasurements(page, "a", buckets)[0].SensorID)
+}
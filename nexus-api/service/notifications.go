@@ -0,0 +1,794 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/clients/email"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// claimed emails are left alone by other instances of the service for the lease, long
+	// enough to send a whole batch to a server that times out
+	emailSendLease     = 5 * time.Minute
+	emailSendBatchSize = 20
+	// an email fails once the server refused it maxEmailAttempts times or refused it for
+	// good, the delay before each retry doubles from emailRetryBaseDelay up to maxEmailRetryDelay
+	maxEmailAttempts    = 6
+	emailRetryBaseDelay = time.Minute
+	maxEmailRetryDelay  = time.Hour
+	// maxEmailErrorLength bounds the error kept for the last attempt to send an email
+	maxEmailErrorLength = 1000
+
+	defaultDigestHour     = 7
+	maxEmailAddressLength = 254
+	// quietHoursLayout is how quiet hours are written, minutes after midnight in the user's time zone
+	quietHoursLayout = "15:04"
+
+	defaultEmailsPageSize = 100
+	maxEmailsPageSize     = 1000
+)
+
+// emailRecipient is who an email is to, every email template greets them by name and
+// shows times in their time zone
+type emailRecipient struct {
+	Username string
+	TimeZone *time.Location
+}
+
+type sensorOfflineEmail struct {
+	emailRecipient
+	SensorID       string
+	SensorName     string
+	SensorLocation string
+	LastSeenAt     time.Time
+}
+
+type batteryLowEmail struct {
+	emailRecipient
+	SensorID       string
+	SensorName     string
+	SensorLocation string
+	BatteryLevel   float64
+	Threshold      float64
+	ReadAt         time.Time
+}
+
+type dailyDigestSensor struct {
+	ID         string
+	Name       string
+	LastSeenAt *time.Time
+}
+
+type dailyDigestBattery struct {
+	ID    string
+	Name  string
+	Level float64
+}
+
+// dailyDigestEmail is the state of the farm over the day before a digest, the same for
+// every user apart from who it is to
+type dailyDigestEmail struct {
+	emailRecipient
+	Since            time.Time
+	SensorsInService int
+	OnlineSensors    int
+	OfflineSensors   []dailyDigestSensor
+	LowBatteries     []dailyDigestBattery
+	AlertsFired      int
+	OpenAlerts       int
+}
+
+type alertEscalatedEmail struct {
+	emailRecipient
+	AlertID         int64
+	RuleName        string
+	SensorID        string
+	MeasurementType string
+	Operator        string
+	Threshold       float64
+	FiredValue      float64
+	FiredAt         time.Time
+	DelayMinutes    int
+}
+
+type testEmail struct {
+	emailRecipient
+	SentAt time.Time
+}
+
+// recordEmailOutcome updates an email with the outcome of an attempt to send it at now,
+// scheduling a retry if the server refused it for now and it has attempts left
+func recordEmailOutcome(outgoing *database.Email, err error, now time.Time) {
+	outgoing.Attempts++
+	outgoing.LastAttemptAt = &now
+
+	if err == nil {
+		outgoing.Status = database.EmailStatusSent
+		outgoing.SentAt = &now
+		outgoing.LastError = ""
+		return
+	}
+
+	outgoing.LastError = err.Error()
+	if len(outgoing.LastError) > maxEmailErrorLength {
+		outgoing.LastError = outgoing.LastError[:maxEmailErrorLength]
+	}
+
+	if email.IsPermanent(err) || outgoing.Attempts >= maxEmailAttempts {
+		outgoing.Status = database.EmailStatusFailed
+		return
+	}
+	outgoing.NextAttemptAt = now.Add(retryDelay(outgoing.Attempts, emailRetryBaseDelay, maxEmailRetryDelay))
+}
+
+// newNotificationEmail renders the email of kind for the user with the given preferences,
+// due at now or at the end of their quiet hours
+func newNotificationEmail(renderer *email.Renderer, preferences database.NotificationPreferences, kind string, data interface{}, now time.Time) (database.Email, error) {
+	message, err := renderer.Render(kind, data)
+	if err != nil {
+		return database.Email{}, fmt.Errorf("error %s rendering %s email", err, kind)
+	}
+
+	return database.Email{
+		UserName:      preferences.UserName,
+		Recipient:     preferences.Email,
+		Kind:          kind,
+		Subject:       message.Subject,
+		TextBody:      message.Text,
+		HTMLBody:      message.HTML,
+		Status:        database.EmailStatusPending,
+		NextAttemptAt: preferences.SendAt(now),
+	}, nil
+}
+
+// uniqueNotificationRecipients keeps the first subscription of each user, so users
+// subscribed to a sensor and to every sensor get one email
+func uniqueNotificationRecipients(recipients []database.NotificationRecipient) []database.NotificationRecipient {
+	seen := make(map[string]bool, len(recipients))
+	unique := make([]database.NotificationRecipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		if !seen[recipient.Preferences.UserName] {
+			seen[recipient.Preferences.UserName] = true
+			unique = append(unique, recipient)
+		}
+	}
+
+	return unique
+}
+
+// sensorDisplayName returns the name of a sensor shown in emails
+func sensorDisplayName(sensor database.Sensor) string {
+	if sensor.Name == "" {
+		return sensor.ID
+	}
+
+	return sensor.Name
+}
+
+// queueSensorOfflineEmails queues emails to the users subscribed to the sensor going
+// offline, tx is the transaction recording that it went offline
+func (as *APIService) queueSensorOfflineEmails(ctx context.Context, tx bun.IDB, sensor database.Sensor, now time.Time) error {
+	recipients, err := database.GetNotificationRecipients(ctx, tx, database.NotificationKindSensorOffline, sensor.ID)
+	if err != nil {
+		return err
+	}
+
+	var emails []database.Email
+	for _, recipient := range uniqueNotificationRecipients(recipients) {
+		outgoing, err := newNotificationEmail(as.emailRenderer, recipient.Preferences, database.NotificationKindSensorOffline, sensorOfflineEmail{
+			emailRecipient: emailRecipient{Username: recipient.Preferences.UserName, TimeZone: recipient.Preferences.Location()},
+			SensorID:       sensor.ID,
+			SensorName:     sensorDisplayName(sensor),
+			SensorLocation: sensor.Location,
+			LastSeenAt:     *sensor.LastSeenAt,
+		}, now)
+		if err != nil {
+			return err
+		}
+		emails = append(emails, outgoing)
+	}
+
+	return database.QueueEmails(ctx, tx, emails)
+}
+
+// notifyLowBattery queues emails to the users subscribed to the sensor's battery whose
+// threshold its newest battery level just dropped below. The readings are already saved,
+// so errors are only logged
+func (as *APIService) notifyLowBattery(ctx context.Context, sensorID string, now time.Time) {
+	var sensor *database.Sensor
+	notified := make(map[string]bool)
+
+	err := database.UpdateLowBatteryNotifications(ctx, as.DatabaseClient.DB, sensorID, func(ctx context.Context, tx bun.Tx, recipient database.NotificationRecipient, battery database.SensorLastSeen) error {
+		if notified[recipient.Preferences.UserName] {
+			return nil
+		}
+		notified[recipient.Preferences.UserName] = true
+
+		if sensor == nil {
+			found, err := database.GetSensorByID(ctx, as.DatabaseClient.DB, sensorID)
+			if err != nil {
+				return err
+			}
+			sensor = &found
+		}
+
+		outgoing, err := newNotificationEmail(as.emailRenderer, recipient.Preferences, database.NotificationKindBatteryLow, batteryLowEmail{
+			emailRecipient: emailRecipient{Username: recipient.Preferences.UserName, TimeZone: recipient.Preferences.Location()},
+			SensorID:       sensor.ID,
+			SensorName:     sensorDisplayName(*sensor),
+			SensorLocation: sensor.Location,
+			BatteryLevel:   battery.LastValue,
+			Threshold:      recipient.Subscription.Threshold(),
+			ReadAt:         battery.LastDate,
+		}, now)
+		if err != nil {
+			return err
+		}
+
+		return database.QueueEmails(ctx, tx, []database.Email{outgoing})
+	})
+	if err != nil {
+		as.Error().Msgf("error %s queueing low battery emails for sensor %s", err, sensorID)
+	}
+}
+
+// buildDailyDigest sums up the sensors in service and their newest readings at now along
+// with the alerts fired over the day before
+func buildDailyDigest(sensors []database.Sensor, lastSeen []database.SensorLastSeen, alertsFired int, openAlerts int, now time.Time) dailyDigestEmail {
+	digest := dailyDigestEmail{
+		Since:            now.Add(-24 * time.Hour),
+		SensorsInService: len(sensors),
+		AlertsFired:      alertsFired,
+		OpenAlerts:       openAlerts,
+	}
+
+	batteries := make(map[string]float64)
+	for _, newest := range lastSeen {
+		if newest.MeasurementType == database.MeasurementTypeBatteryLevel {
+			batteries[newest.SensorID] = newest.LastValue
+		}
+	}
+
+	sort.Slice(sensors, func(i, j int) bool {
+		if sensors[i].Name != sensors[j].Name {
+			return sensors[i].Name < sensors[j].Name
+		}
+		return sensors[i].ID < sensors[j].ID
+	})
+
+	for _, sensor := range sensors {
+		if sensor.IsOnlineAt(now) {
+			digest.OnlineSensors++
+		} else {
+			digest.OfflineSensors = append(digest.OfflineSensors, dailyDigestSensor{ID: sensor.ID, Name: sensorDisplayName(sensor), LastSeenAt: sensor.LastSeenAt})
+		}
+
+		if level, ok := batteries[sensor.ID]; ok && level < database.DefaultBatteryLowThreshold {
+			digest.LowBatteries = append(digest.LowBatteries, dailyDigestBattery{ID: sensor.ID, Name: sensorDisplayName(sensor), Level: level})
+		}
+	}
+
+	return digest
+}
+
+// queueDailyDigests queues the daily digest of the users whose digest hour has come and
+// who haven't had today's digest, the digest is only summed up when one is due
+func (as *APIService) queueDailyDigests(ctx context.Context, now time.Time) {
+	var digest *dailyDigestEmail
+
+	err := database.ClaimDailyDigests(ctx, as.DatabaseClient.DB, now, func(ctx context.Context, tx bun.Tx, preferences database.NotificationPreferences) error {
+		if digest == nil {
+			sensors, err := as.DatabaseClient.GetAllSensors(ctx, "", false)
+			if err != nil {
+				return err
+			}
+			lastSeen, err := database.GetSensorLastSeen(ctx, as.DatabaseClient.DB)
+			if err != nil {
+				return err
+			}
+			alertsFired, openAlerts, err := database.DigestAlertCounts(ctx, tx, now.Add(-24*time.Hour))
+			if err != nil {
+				return err
+			}
+
+			summary := buildDailyDigest(sensors, lastSeen, alertsFired, openAlerts, now)
+			digest = &summary
+		}
+
+		data := *digest
+		data.emailRecipient = emailRecipient{Username: preferences.UserName, TimeZone: preferences.Location()}
+
+		outgoing, err := newNotificationEmail(as.emailRenderer, preferences, database.NotificationKindDailyDigest, data, now)
+		if err != nil {
+			return err
+		}
+
+		as.Debug().Msgf("Queueing daily digest for %s", preferences.UserName)
+
+		return database.QueueEmails(ctx, tx, []database.Email{outgoing})
+	})
+	if err != nil {
+		as.Error().Msgf("error %s queueing daily digests", err)
+	}
+}
+
+// sendEmails sends the emails due at now in batches until none are left
+func (as *APIService) sendEmails(ctx context.Context, now time.Time) {
+	err := sendInBatches(ctx, emailSendBatchSize, func(ctx context.Context) (int, error) {
+		emails, err := database.ClaimEmails(ctx, as.DatabaseClient.DB, now, emailSendLease, emailSendBatchSize)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, outgoing := range emails {
+			err := as.emailClient.Send(ctx, email.Message{
+				To:      outgoing.Recipient,
+				Subject: outgoing.Subject,
+				Text:    outgoing.TextBody,
+				HTML:    outgoing.HTMLBody,
+			})
+			recordEmailOutcome(&outgoing, err, time.Now())
+			if err != nil {
+				as.Warn().Msgf("email %d of kind %s to %s failed attempt %d: %s", outgoing.ID, outgoing.Kind, outgoing.UserName, outgoing.Attempts, err)
+			}
+
+			err = database.RecordEmailAttempt(ctx, as.DatabaseClient.DB, &outgoing)
+			if err != nil {
+				as.Error().Msgf("error %s recording attempt of email %d", err, outgoing.ID)
+			}
+		}
+
+		return len(emails), nil
+	})
+	if err != nil {
+		as.Error().Msgf("error %s claiming due emails", err)
+	}
+}
+
+// parseQuietHours parses the start and end of quiet hours written as HH:MM into minutes
+// after midnight, both are empty for no quiet hours
+func parseQuietHours(start string, end string) (*int, *int, error) {
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	if start == "" && end == "" {
+		return nil, nil, nil
+	}
+	if start == "" || end == "" {
+		return nil, nil, fmt.Errorf("quiet_hours_start and quiet_hours_end must be set together")
+	}
+
+	minutes := make([]int, 0, 2)
+	for _, value := range []string{start, end} {
+		parsed, err := time.Parse(quietHoursLayout, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("quiet hours must be written as HH:MM, got %q", value)
+		}
+		minutes = append(minutes, parsed.Hour()*60+parsed.Minute())
+	}
+
+	if minutes[0] == minutes[1] {
+		return nil, nil, fmt.Errorf("quiet hours must not start and end at the same time")
+	}
+
+	return &minutes[0], &minutes[1], nil
+}
+
+// formatQuietHour writes minutes after midnight as HH:MM, empty when not set
+func formatQuietHour(minutes *int) string {
+	if minutes == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%02d:%02d", *minutes/60, *minutes%60)
+}
+
+// parseNotificationPreferences validates the preferences a user asked for
+func parseNotificationPreferences(username string, request api.UpdateNotificationPreferencesRequest) (database.NotificationPreferences, error) {
+	preferences := database.NotificationPreferences{
+		UserName:   username,
+		Enabled:    request.Enabled == nil || *request.Enabled,
+		TimeZone:   strings.TrimSpace(request.TimeZone),
+		DigestHour: defaultDigestHour,
+	}
+
+	address, err := mail.ParseAddress(strings.TrimSpace(request.Email))
+	if err != nil || len(address.Address) > maxEmailAddressLength {
+		return preferences, fmt.Errorf("email must be a valid email address")
+	}
+	preferences.Email = address.Address
+
+	if preferences.TimeZone == "" {
+		preferences.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(preferences.TimeZone); err != nil || preferences.TimeZone == "Local" {
+		return preferences, fmt.Errorf("unknown timezone %q, use an IANA time zone name such as Africa/Nairobi", preferences.TimeZone)
+	}
+
+	preferences.QuietHoursStart, preferences.QuietHoursEnd, err = parseQuietHours(request.QuietHoursStart, request.QuietHoursEnd)
+	if err != nil {
+		return preferences, err
+	}
+
+	if request.DigestHour != nil {
+		if *request.DigestHour < 0 || *request.DigestHour > 23 {
+			return preferences, fmt.Errorf("digest_hour must be between 0 and 23")
+		}
+		preferences.DigestHour = *request.DigestHour
+	}
+
+	return preferences, nil
+}
+
+// parseNotificationSubscription validates a subscription a user asked for
+func parseNotificationSubscription(username string, request api.CreateNotificationSubscriptionRequest) (database.NotificationSubscription, error) {
+	subscription := database.NotificationSubscription{
+		UserName:         username,
+		Kind:             strings.TrimSpace(request.Kind),
+		SensorID:         strings.TrimSpace(request.SensorID),
+		BatteryThreshold: request.BatteryThreshold,
+	}
+
+	switch subscription.Kind {
+	case database.NotificationKindSensorOffline, database.NotificationKindBatteryLow:
+	case database.NotificationKindDailyDigest:
+		if subscription.SensorID != "" {
+			return subscription, fmt.Errorf("the daily digest covers every sensor, sensor_id must not be set")
+		}
+	default:
+		return subscription, fmt.Errorf("unknown kind %q, must be one of %s", subscription.Kind, strings.Join(database.NotificationKinds, ", "))
+	}
+
+	if subscription.BatteryThreshold != nil {
+		if subscription.Kind != database.NotificationKindBatteryLow {
+			return subscription, fmt.Errorf("battery_threshold is only used by %s subscriptions", database.NotificationKindBatteryLow)
+		}
+		if *subscription.BatteryThreshold <= 0 || *subscription.BatteryThreshold > 100 {
+			return subscription, fmt.Errorf("battery_threshold must be a percentage above 0 and at most 100")
+		}
+	}
+
+	return subscription, nil
+}
+
+// notificationPreferencesToAPI converts preferences to their api representation
+func notificationPreferencesToAPI(preferences database.NotificationPreferences) api.NotificationPreferences {
+	response := api.NotificationPreferences{
+		Email:           preferences.Email,
+		Enabled:         preferences.Enabled,
+		TimeZone:        preferences.TimeZone,
+		QuietHoursStart: formatQuietHour(preferences.QuietHoursStart),
+		QuietHoursEnd:   formatQuietHour(preferences.QuietHoursEnd),
+		DigestHour:      preferences.DigestHour,
+	}
+	if !preferences.UpdatedAt.IsZero() {
+		updatedAt := preferences.UpdatedAt
+		response.UpdatedAt = &updatedAt
+	}
+
+	return response
+}
+
+// notificationSubscriptionToAPI converts a subscription to its api representation
+func notificationSubscriptionToAPI(subscription database.NotificationSubscription) api.NotificationSubscription {
+	return api.NotificationSubscription{
+		ID:               subscription.ID.String(),
+		Kind:             subscription.Kind,
+		SensorID:         subscription.SensorID,
+		BatteryThreshold: subscription.BatteryThreshold,
+		CreatedAt:        subscription.CreatedAt,
+	}
+}
+
+// emailToAPI converts an email to its api representation
+func emailToAPI(outgoing database.Email) api.Email {
+	response := api.Email{
+		ID:            outgoing.ID,
+		Recipient:     outgoing.Recipient,
+		Kind:          outgoing.Kind,
+		Subject:       outgoing.Subject,
+		Status:        outgoing.Status,
+		Attempts:      outgoing.Attempts,
+		LastAttemptAt: outgoing.LastAttemptAt,
+		LastError:     outgoing.LastError,
+		SentAt:        outgoing.SentAt,
+		CreatedAt:     outgoing.CreatedAt,
+	}
+	if outgoing.Status == database.EmailStatusPending {
+		nextAttemptAt := outgoing.NextAttemptAt
+		response.NextAttemptAt = &nextAttemptAt
+	}
+
+	return response
+}
+
+// CreateGetNotificationPreferencesHandler returns a handler that retrieves the current
+// user's notification preferences, the defaults with notifications disabled until they
+// save some
+func CreateGetNotificationPreferencesHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		preferences, err := database.GetNotificationPreferences(r.Context(), apiService.DatabaseClient.DB, username)
+		if errors.Is(err, database.ErrorNoNotificationPreferences) {
+			preferences, err = database.NotificationPreferences{UserName: username, TimeZone: "UTC", DigestHour: defaultDigestHour}, nil
+		}
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving notification preferences of %s: %s", username, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(notificationPreferencesToAPI(preferences))
+	}
+}
+
+// CreateUpdateNotificationPreferencesHandler returns a handler that replaces the current
+// user's notification preferences
+func CreateUpdateNotificationPreferencesHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.UpdateNotificationPreferencesRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		preferences, err := parseNotificationPreferences(username, request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		err = preferences.Save(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error saving notification preferences of %s: %s", username, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(notificationPreferencesToAPI(preferences))
+	}
+}
+
+// CreateGetNotificationSubscriptionsHandler returns a handler that lists the current
+// user's notification subscriptions
+func CreateGetNotificationSubscriptionsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		subscriptions, err := database.GetNotificationSubscriptions(r.Context(), apiService.DatabaseClient.DB, username)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving notification subscriptions of %s: %s", username, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetNotificationSubscriptionsResponse{
+			Subscriptions: make([]api.NotificationSubscription, 0, len(subscriptions)),
+		}
+		for _, subscription := range subscriptions {
+			response.Subscriptions = append(response.Subscriptions, notificationSubscriptionToAPI(subscription))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateCreateNotificationSubscriptionHandler returns a handler that subscribes the
+// current user to a kind of notification
+func CreateCreateNotificationSubscriptionHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.CreateNotificationSubscriptionRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		subscription, err := parseNotificationSubscription(username, request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		if subscription.SensorID != "" {
+			_, err = database.GetSensorByID(r.Context(), apiService.DatabaseClient.DB, subscription.SensorID)
+			if errors.Is(err, sql.ErrNoRows) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown sensor %q", subscription.SensorID)})
+				return
+			}
+		}
+		if err == nil {
+			err = subscription.Save(r.Context(), apiService.DatabaseClient.DB)
+		}
+		if err != nil {
+			if errors.Is(err, database.ErrorDuplicateNotificationSubscription) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Already subscribed to this notification"})
+				return
+			}
+
+			apiService.Error().Msgf("Error saving %s notification subscription of %s: %s", subscription.Kind, username, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("%s subscribed to %s notifications", username, subscription.Kind)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(notificationSubscriptionToAPI(subscription))
+	}
+}
+
+// CreateDeleteNotificationSubscriptionHandler returns a handler that unsubscribes the
+// current user from one of their notification subscriptions
+func CreateDeleteNotificationSubscriptionHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		subscriptionID, err := uuid.Parse(mux.Vars(r)["subscription_id"])
+		if err == nil {
+			err = database.DeleteNotificationSubscription(r.Context(), apiService.DatabaseClient.DB, username, subscriptionID)
+		} else {
+			err = database.ErrorNoNotificationSubscription
+		}
+		if err != nil {
+			if errors.Is(err, database.ErrorNoNotificationSubscription) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Notification subscription not found"})
+				return
+			}
+
+			apiService.Error().Msgf("Error deleting notification subscription %s of %s: %s", subscriptionID, username, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Notification subscription deleted successfully"})
+	}
+}
+
+// CreateGetEmailsHandler returns a handler that pages through the emails sent or waiting
+// to be sent to the current user newest first
+func CreateGetEmailsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		filter, err := parseEmailFilter(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+		filter.UserName = username
+
+		emails, err := database.GetEmails(r.Context(), apiService.DatabaseClient.DB, filter)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving emails of %s: %s", username, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetEmailsResponse{
+			Emails: make([]api.Email, 0, len(emails)),
+		}
+
+		if len(emails) > filter.Limit {
+			emails = emails[:filter.Limit]
+			nextBeforeID := emails[filter.Limit-1].ID
+			response.NextBeforeID = &nextBeforeID
+		}
+
+		for _, outgoing := range emails {
+			response.Emails = append(response.Emails, emailToAPI(outgoing))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateSendTestEmailHandler returns a handler that queues a test email to the address in
+// the current user's notification preferences, sent right away whatever their quiet hours
+func CreateSendTestEmailHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		if apiService.emailClient == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Email is not configured on this server"})
+			return
+		}
+
+		preferences, err := database.GetNotificationPreferences(r.Context(), apiService.DatabaseClient.DB, username)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoNotificationPreferences) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Set an email address in your notification preferences first"})
+				return
+			}
+
+			apiService.Error().Msgf("Error retrieving notification preferences of %s: %s", username, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		now := time.Now()
+		preferences.QuietHoursStart, preferences.QuietHoursEnd = nil, nil
+		outgoing, err := newNotificationEmail(apiService.emailRenderer, preferences, database.EmailKindTest, testEmail{
+			emailRecipient: emailRecipient{Username: username, TimeZone: preferences.Location()},
+			SentAt:         now,
+		}, now)
+		queued := []database.Email{outgoing}
+		if err == nil {
+			err = database.QueueEmails(r.Context(), apiService.DatabaseClient.DB, queued)
+		}
+		if err != nil {
+			apiService.Error().Msgf("Error queueing test email to %s: %s", username, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(emailToAPI(queued[0]))
+	}
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"net/textproto"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/clients/email"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestRecordEmailOutcome(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	refusal := &textproto.Error{Code: 451, Msg: "try again later"}
+
+	// execute test and assert results
+	outgoing := database.Email{Status: database.EmailStatusPending}
+	recordEmailOutcome(&outgoing, refusal, now)
+	assert.Equal(t, database.EmailStatusPending, outgoing.Status)
+	assert.Equal(t, 1, outgoing.Attempts)
+	assert.Equal(t, now.Add(time.Minute), outgoing.NextAttemptAt)
+	assert.Equal(t, refusal.Error(), outgoing.LastError)
+
+	recordEmailOutcome(&outgoing, nil, now.Add(time.Minute))
+	assert.Equal(t, database.EmailStatusSent, outgoing.Status)
+	assert.Equal(t, 2, outgoing.Attempts)
+	assert.Equal(t, now.Add(time.Minute), *outgoing.SentAt)
+	assert.Empty(t, outgoing.LastError)
+
+	// the server refusing the recipient for good fails the email right away
+	refused := database.Email{Status: database.EmailStatusPending}
+	recordEmailOutcome(&refused, &textproto.Error{Code: 550, Msg: "no such mailbox"}, now)
+	assert.Equal(t, database.EmailStatusFailed, refused.Status)
+	assert.Equal(t, 1, refused.Attempts)
+
+	// the last attempt fails the email instead of scheduling another
+	exhausted := database.Email{Status: database.EmailStatusPending, Attempts: maxEmailAttempts - 1}
+	recordEmailOutcome(&exhausted, errors.New("connection refused"), now)
+	assert.Equal(t, database.EmailStatusFailed, exhausted.Status)
+	assert.Equal(t, maxEmailAttempts, exhausted.Attempts)
+	assert.Nil(t, exhausted.SentAt)
+}
+
+func TestUnitTestNotificationPreferencesSendAtDefersQuietHours(t *testing.T) {
+	// setup test data
+	nairobi, err := time.LoadLocation("Africa/Nairobi")
+	assert.NoError(t, err)
+	start, end := 22*60, 6*60+30
+	preferences := database.NotificationPreferences{TimeZone: "Africa/Nairobi", QuietHoursStart: &start, QuietHoursEnd: &end}
+
+	// execute test and assert results
+	afternoon := time.Date(2025, 6, 1, 15, 0, 0, 0, nairobi)
+	assert.Equal(t, afternoon, preferences.SendAt(afternoon))
+
+	lateEvening := time.Date(2025, 6, 1, 23, 15, 0, 0, nairobi)
+	assert.True(t, time.Date(2025, 6, 2, 6, 30, 0, 0, nairobi).Equal(preferences.SendAt(lateEvening)))
+
+	earlyMorning := time.Date(2025, 6, 2, 3, 0, 0, 0, nairobi)
+	assert.True(t, time.Date(2025, 6, 2, 6, 30, 0, 0, nairobi).Equal(preferences.SendAt(earlyMorning)))
+
+	endOfQuietHours := time.Date(2025, 6, 2, 6, 30, 0, 0, nairobi)
+	assert.Equal(t, endOfQuietHours, preferences.SendAt(endOfQuietHours))
+
+	// quiet hours within a day, now given in another time zone
+	lunchStart, lunchEnd := 12*60, 14*60
+	lunch := database.NotificationPreferences{TimeZone: "Africa/Nairobi", QuietHoursStart: &lunchStart, QuietHoursEnd: &lunchEnd}
+	assert.True(t, time.Date(2025, 6, 1, 14, 0, 0, 0, nairobi).Equal(lunch.SendAt(time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC))))
+
+	assert.Equal(t, lateEvening, database.NotificationPreferences{TimeZone: "UTC"}.SendAt(lateEvening))
+}
+
+func TestUnitTestParseNotificationPreferences(t *testing.T) {
+	// setup test data
+	digestHour := 18
+
+	// execute test
+	preferences, err := parseNotificationPreferences("alice", api.UpdateNotificationPreferencesRequest{
+		Email:           " Alice <alice@farm.example> ",
+		TimeZone:        "Africa/Nairobi",
+		QuietHoursStart: "22:00",
+		QuietHoursEnd:   "06:30",
+		DigestHour:      &digestHour,
+	})
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", preferences.UserName)
+	assert.Equal(t, "alice@farm.example", preferences.Email)
+	assert.True(t, preferences.Enabled)
+	assert.Equal(t, 22*60, *preferences.QuietHoursStart)
+	assert.Equal(t, 6*60+30, *preferences.QuietHoursEnd)
+	assert.Equal(t, 18, preferences.DigestHour)
+	assert.Equal(t, "06:30", notificationPreferencesToAPI(preferences).QuietHoursEnd)
+
+	preferences, err = parseNotificationPreferences("alice", api.UpdateNotificationPreferencesRequest{Email: "alice@farm.example"})
+	assert.NoError(t, err)
+	assert.Equal(t, "UTC", preferences.TimeZone)
+	assert.Equal(t, defaultDigestHour, preferences.DigestHour)
+	assert.Nil(t, preferences.QuietHoursStart)
+
+	badHour := 24
+	for name, request := range map[string]api.UpdateNotificationPreferencesRequest{
+		"missing email":       {},
+		"invalid email":       {Email: "alice"},
+		"unknown timezone":    {Email: "alice@farm.example", TimeZone: "Mars/Olympus"},
+		"local timezone":      {Email: "alice@farm.example", TimeZone: "Local"},
+		"half of quiet hours": {Email: "alice@farm.example", QuietHoursStart: "22:00"},
+		"invalid quiet hours": {Email: "alice@farm.example", QuietHoursStart: "10pm", QuietHoursEnd: "06:00"},
+		"empty quiet hours":   {Email: "alice@farm.example", QuietHoursStart: "06:00", QuietHoursEnd: "06:00"},
+		"digest hour":         {Email: "alice@farm.example", DigestHour: &badHour},
+	} {
+		_, err := parseNotificationPreferences("alice", request)
+
+		assert.Error(t, err, "expected error for %s", name)
+	}
+}
+
+func TestUnitTestParseNotificationSubscription(t *testing.T) {
+	// setup test data
+	threshold := 35.0
+	tooHigh := 120.0
+
+	// execute test
+	subscription, err := parseNotificationSubscription("alice", api.CreateNotificationSubscriptionRequest{
+		Kind:             database.NotificationKindBatteryLow,
+		SensorID:         " 2cf7f1c0 ",
+		BatteryThreshold: &threshold,
+	})
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", subscription.UserName)
+	assert.Equal(t, "2cf7f1c0", subscription.SensorID)
+	assert.Equal(t, threshold, subscription.Threshold())
+
+	subscription, err = parseNotificationSubscription("alice", api.CreateNotificationSubscriptionRequest{Kind: database.NotificationKindBatteryLow})
+	assert.NoError(t, err)
+	assert.Equal(t, database.DefaultBatteryLowThreshold, subscription.Threshold())
+
+	for name, request := range map[string]api.CreateNotificationSubscriptionRequest{
+		"unknown kind":             {Kind: "sensor_online"},
+		"digest for a sensor":      {Kind: database.NotificationKindDailyDigest, SensorID: "2cf7f1c0"},
+		"threshold without kind":   {Kind: database.NotificationKindSensorOffline, BatteryThreshold: &threshold},
+		"threshold out of percent": {Kind: database.NotificationKindBatteryLow, BatteryThreshold: &tooHigh},
+	} {
+		_, err := parseNotificationSubscription("alice", request)
+
+		assert.Error(t, err, "expected error for %s", name)
+	}
+}
+
+func TestUnitTestBuildDailyDigest(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+	stale := now.Add(-72 * time.Hour)
+	sensors := []database.Sensor{
+		{ID: "c", Name: "South 1", LastSeenAt: &recent},
+		{ID: "a", Name: "North 1", LastSeenAt: &stale},
+		{ID: "b", Name: "", LastSeenAt: nil},
+	}
+	lastSeen := []database.SensorLastSeen{
+		{SensorID: "a", MeasurementType: database.MeasurementTypeBatteryLevel, LastValue: 55},
+		{SensorID: "c", MeasurementType: database.MeasurementTypeBatteryLevel, LastValue: 12},
+		{SensorID: "c", MeasurementType: database.MeasurementTypeSoilMoisture, LastValue: 5},
+	}
+
+	// execute test
+	digest := buildDailyDigest(sensors, lastSeen, 4, 1, now)
+
+	// assert results
+	assert.Equal(t, now.Add(-24*time.Hour), digest.Since)
+	assert.Equal(t, 3, digest.SensorsInService)
+	assert.Equal(t, 1, digest.OnlineSensors)
+	assert.Equal(t, []dailyDigestSensor{{ID: "b", Name: "b"}, {ID: "a", Name: "North 1", LastSeenAt: &stale}}, digest.OfflineSensors)
+	assert.Equal(t, []dailyDigestBattery{{ID: "c", Name: "South 1", Level: 12}}, digest.LowBatteries)
+	assert.Equal(t, 4, digest.AlertsFired)
+	assert.Equal(t, 1, digest.OpenAlerts)
+}
+
+func TestUnitTestNewNotificationEmailRendersEveryKind(t *testing.T) {
+	// setup test data
+	renderer, err := email.NewRenderer()
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC)
+	start, end := 22*60, 6*60
+	preferences := database.NotificationPreferences{UserName: "alice", Email: "alice@farm.example", TimeZone: "UTC", QuietHoursStart: &start, QuietHoursEnd: &end}
+	recipient := emailRecipient{Username: "alice", TimeZone: time.UTC}
+
+	// execute test and assert results
+	for kind, data := range map[string]interface{}{
+		database.NotificationKindSensorOffline: sensorOfflineEmail{emailRecipient: recipient, SensorID: "a", SensorName: "North 1", LastSeenAt: now},
+		database.NotificationKindBatteryLow:    batteryLowEmail{emailRecipient: recipient, SensorID: "a", SensorName: "North 1", BatteryLevel: 15, Threshold: 20, ReadAt: now},
+		database.NotificationKindDailyDigest:   buildDailyDigest(nil, nil, 0, 0, now),
+		database.EmailKindTest:                 testEmail{emailRecipient: recipient, SentAt: now},
+	} {
+		if digest, ok := data.(dailyDigestEmail); ok {
+			digest.emailRecipient = recipient
+			data = digest
+		}
+
+		outgoing, err := newNotificationEmail(renderer, preferences, kind, data, now)
+
+		assert.NoError(t, err, kind)
+		assert.Equal(t, "alice", outgoing.UserName, kind)
+		assert.Equal(t, "alice@farm.example", outgoing.Recipient, kind)
+		assert.Equal(t, kind, outgoing.Kind, kind)
+		assert.Equal(t, database.EmailStatusPending, outgoing.Status, kind)
+		assert.Equal(t, time.Date(2025, 6, 2, 6, 0, 0, 0, time.UTC), outgoing.NextAttemptAt.UTC(), kind)
+		assert.Contains(t, outgoing.TextBody, "Hi alice", kind)
+		assert.Contains(t, outgoing.HTMLBody, "Hi alice", kind)
+	}
+}
+
+func TestUnitTestNewAlertEscalatedEmailIgnoresQuietHours(t *testing.T) {
+	// setup test data
+	renderer, err := email.NewRenderer()
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC)
+	start, end := 22*60, 6*60
+	preferences := database.NotificationPreferences{UserName: "bob", Email: "bob@farm.example", TimeZone: "UTC", QuietHoursStart: &start, QuietHoursEnd: &end}
+	alert := database.Alert{ID: 42, RuleName: "Field dry", SensorID: "2cf7f1c0", MeasurementType: "soil_moisture", Operator: "below", Threshold: 20, FiredValue: 11, FiredAt: now.Add(-time.Hour)}
+
+	// execute test
+	outgoing, err := newAlertEscalatedEmail(renderer, preferences, alert, 30*time.Minute, now)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, database.EmailKindAlertEscalated, outgoing.Kind)
+	assert.Equal(t, "bob@farm.example", outgoing.Recipient)
+	assert.Equal(t, now, outgoing.NextAttemptAt, "escalations are sent during quiet hours")
+	assert.Equal(t, "Critical alert Field dry on sensor 2cf7f1c0 was escalated to you", outgoing.Subject)
+	assert.Contains(t, outgoing.TextBody, "within 30 minutes")
+	assert.Contains(t, outgoing.HTMLBody, "Hi bob")
+}
@@ -0,0 +1,736 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/geo"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DateOnlyFormat is the day precision format accepted for query dates
+	DateOnlyFormat = "2006-01-02"
+	// MaxSensorDataPageSize is the largest limit accepted for a page of sensor readings
+	MaxSensorDataPageSize = 10000
+	// MaxQuerySensors is the most sensors that can be requested in one sensor query
+	MaxQuerySensors = 100
+)
+
+// parseQueryTime parses a date query parameter which may either be a full
+// RFC3339 timestamp or a YYYY-MM-DD date. Dates used as the end of a range
+// are moved to the last instant of that day so the whole day is included
+func parseQueryTime(value string, endOfDay bool) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+
+	parsed, err := time.Parse(DateOnlyFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use RFC3339 or YYYY-MM-DD", value)
+	}
+
+	if endOfDay {
+		parsed = parsed.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	return parsed, nil
+}
+
+// firstQueryValue returns the value of the first of the named query
+// parameters that is set, used to support older parameter names
+func firstQueryValue(r *http.Request, names ...string) string {
+	for _, name := range names {
+		if value := r.URL.Query().Get(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// parseSensorDataQuery reads the start, end, limit, order and cursor
+// query parameters shared by the sensor reading endpoints, start_date
+// and end_date are accepted as aliases for start and end
+func parseSensorDataQuery(r *http.Request) (database.SensorDataQuery, error) {
+	var query database.SensorDataQuery
+	var err error
+
+	if start := firstQueryValue(r, "start", "start_date"); start != "" {
+		query.Start, err = parseQueryTime(start, false)
+		if err != nil {
+			return query, fmt.Errorf("start: %w", err)
+		}
+	}
+
+	if end := firstQueryValue(r, "end", "end_date"); end != "" {
+		query.End, err = parseQueryTime(end, true)
+		if err != nil {
+			return query, fmt.Errorf("end: %w", err)
+		}
+	}
+
+	if !query.Start.IsZero() && !query.End.IsZero() && query.End.Before(query.Start) {
+		return query, fmt.Errorf("end must not be before start")
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > MaxSensorDataPageSize {
+			return query, fmt.Errorf("limit must be a number between 1 and %d", MaxSensorDataPageSize)
+		}
+	}
+
+	switch order := r.URL.Query().Get("order"); order {
+	case "", database.SortOrderAscending:
+		query.Order = database.SortOrderAscending
+	case database.SortOrderDescending:
+		query.Order = database.SortOrderDescending
+	default:
+		return query, fmt.Errorf("order must be %q or %q", database.SortOrderAscending, database.SortOrderDescending)
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		after, err := database.DecodeSensorDataCursor(cursor)
+		if err != nil {
+			return query, err
+		}
+		query.After = &after
+	}
+
+	return query, nil
+}
+
+// ResolutionRaw requests the individual readings rather than aggregated buckets
+const ResolutionRaw = "raw"
+
+// parseResolution reads the resolution query parameter, returning an empty
+// string when individual readings are requested. Buckets are not paged so
+// limit and cursor are rejected alongside a resolution
+func parseResolution(r *http.Request, query database.SensorDataQuery) (string, error) {
+	resolution := r.URL.Query().Get("resolution")
+	if resolution == "" || resolution == ResolutionRaw {
+		return "", nil
+	}
+
+	if !database.ValidResolution(resolution) {
+		return "", fmt.Errorf("resolution must be one of %q, %q, %q, %q or %q", ResolutionRaw,
+			database.ResolutionHourly, database.ResolutionDaily, database.ResolutionWeekly, database.ResolutionMonthly)
+	}
+
+	if query.Limit != 0 || query.After != nil {
+		return "", fmt.Errorf("limit and cursor can not be used with resolution %q", resolution)
+	}
+
+	return resolution, nil
+}
+
+// parseOnConflict validates how a batch of readings should treat readings that
+// are already stored, defaulting to keeping the stored reading
+func parseOnConflict(value string) (string, error) {
+	switch value {
+	case "", database.OnConflictIgnore:
+		return database.OnConflictIgnore, nil
+	case database.OnConflictUpdate:
+		return database.OnConflictUpdate, nil
+	default:
+		return "", fmt.Errorf("on_conflict must be %q or %q", database.OnConflictIgnore, database.OnConflictUpdate)
+	}
+}
+
+// measurementTypeAliases are the short names the ui uses for the original
+// measurement types, accepted wherever a list of measurement types is
+var measurementTypeAliases = map[string]string{
+	"moisture":    database.MeasurementTypeSoilMoisture,
+	"temperature": database.MeasurementTypeSoilTemperature,
+	"battery":     database.MeasurementTypeBatteryLevel,
+}
+
+// queryList returns the values of a query parameter that may be repeated and/or
+// hold a comma separated list, leaving out empty values
+func queryList(r *http.Request, name string) []string {
+	var values []string
+	for _, value := range r.URL.Query()[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+
+	return values
+}
+
+// resolveMeasurementTypes maps aliases to measurement type ids, returning an
+// error for any type that is not one of the registered types
+func resolveMeasurementTypes(types []string, registered map[string]string) ([]string, error) {
+	var resolved []string
+	for _, measurementType := range types {
+		if alias, ok := measurementTypeAliases[measurementType]; ok {
+			measurementType = alias
+		}
+
+		if _, ok := registered[measurementType]; !ok {
+			return nil, fmt.Errorf("unknown measurement type %q", measurementType)
+		}
+
+		resolved = append(resolved, measurementType)
+	}
+
+	return resolved, nil
+}
+
+// parseSensorMeasurementFilter reads the ids, types, start and end query parameters
+// used to select readings across sensors, registered maps each registered
+// measurement type id to its unit
+func parseSensorMeasurementFilter(r *http.Request, registered map[string]string) (database.SensorMeasurementFilter, error) {
+	var filter database.SensorMeasurementFilter
+	var err error
+
+	filter.SensorIDs = queryList(r, "ids")
+
+	filter.MeasurementTypes, err = resolveMeasurementTypes(queryList(r, "types"), registered)
+	if err != nil {
+		return filter, err
+	}
+
+	if start := firstQueryValue(r, "start", "start_date"); start != "" {
+		filter.Start, err = parseQueryTime(start, false)
+		if err != nil {
+			return filter, fmt.Errorf("start: %w", err)
+		}
+	}
+
+	if end := firstQueryValue(r, "end", "end_date"); end != "" {
+		filter.End, err = parseQueryTime(end, true)
+		if err != nil {
+			return filter, fmt.Errorf("end: %w", err)
+		}
+	}
+
+	if !filter.Start.IsZero() && !filter.End.IsZero() && filter.End.Before(filter.Start) {
+		return filter, fmt.Errorf("end must not be before start")
+	}
+
+	return filter, nil
+}
+
+// parseImportErrorsPage reads the after_row and limit query parameters used
+// to page through the row errors of an import job
+func parseImportErrorsPage(r *http.Request) (int, int, error) {
+	afterRow := 0
+	if raw := r.URL.Query().Get("after_row"); raw != "" {
+		var err error
+		afterRow, err = strconv.Atoi(raw)
+		if err != nil || afterRow < 0 {
+			return 0, 0, fmt.Errorf("after_row must be a row number")
+		}
+	}
+
+	limit := defaultImportErrorsPageSize
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxImportErrorsPageSize {
+			return 0, 0, fmt.Errorf("limit must be a number between 1 and %d", maxImportErrorsPageSize)
+		}
+	}
+
+	return afterRow, limit, nil
+}
+
+// parseCoverageRange reads the start and end query parameters of a coverage report,
+// the range defaults to the defaultCoverageRange before now and is capped at now
+func parseCoverageRange(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	return parsePastRange(r, now, defaultCoverageRange, maxCoverageRange)
+}
+
+// parsePastRange reads the start and end query parameters of a range of time that
+// is capped at now, start defaults to defaultRange before the end
+func parsePastRange(r *http.Request, now time.Time, defaultRange time.Duration, maxRange time.Duration) (time.Time, time.Time, error) {
+	end := now
+	if raw := firstQueryValue(r, "end", "end_date"); raw != "" {
+		parsed, err := parseQueryTime(raw, true)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("end: %w", err)
+		}
+		if parsed.Before(end) {
+			end = parsed
+		}
+	}
+
+	start := end.Add(-defaultRange)
+	if raw := firstQueryValue(r, "start", "start_date"); raw != "" {
+		var err error
+		start, err = parseQueryTime(raw, false)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("start: %w", err)
+		}
+	}
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must be before end and in the past")
+	}
+	if end.Sub(start) > maxRange {
+		return time.Time{}, time.Time{}, fmt.Errorf("range must be at most %d days", int(maxRange.Hours()/24))
+	}
+
+	return start, end, nil
+}
+
+// parseZoneSeriesQuery reads the types, resolution, start and end query parameters of a
+// zone's series, registered maps each registered measurement type id to its unit. Soil
+// moisture and temperature are aggregated in hourly buckets over the last week by default
+func parseZoneSeriesQuery(r *http.Request, registered map[string]string, now time.Time) (database.SensorMeasurementFilter, string, error) {
+	var filter database.SensorMeasurementFilter
+	var err error
+
+	types := queryList(r, "types")
+	if len(types) == 0 {
+		types = []string{database.MeasurementTypeSoilMoisture, database.MeasurementTypeSoilTemperature}
+	}
+	resolved, err := resolveMeasurementTypes(types, registered)
+	if err != nil {
+		return filter, "", err
+	}
+
+	seen := make(map[string]bool)
+	for _, measurementType := range resolved {
+		if !seen[measurementType] {
+			seen[measurementType] = true
+			filter.MeasurementTypes = append(filter.MeasurementTypes, measurementType)
+		}
+	}
+
+	resolution := r.URL.Query().Get("resolution")
+	if resolution == "" {
+		resolution = database.ResolutionHourly
+	}
+	if !database.ValidResolution(resolution) {
+		return filter, "", fmt.Errorf("resolution must be one of %q, %q, %q or %q",
+			database.ResolutionHourly, database.ResolutionDaily, database.ResolutionWeekly, database.ResolutionMonthly)
+	}
+
+	filter.Start, filter.End, err = parsePastRange(r, now, defaultZoneSeriesRange, maxZoneSeriesRange)
+	if err != nil {
+		return filter, "", err
+	}
+
+	return filter, resolution, nil
+}
+
+// parseQuarantineFilter reads the status, sensor_id, measurement_type, after_id and limit
+// query parameters used to list quarantined readings, pending readings are listed when
+// no status is given
+func parseQuarantineFilter(r *http.Request) (database.QuarantineFilter, error) {
+	query := r.URL.Query()
+	filter := database.QuarantineFilter{
+		Status:          database.QuarantineStatusPending,
+		SensorID:        query.Get("sensor_id"),
+		MeasurementType: query.Get("measurement_type"),
+		Limit:           defaultQuarantinePageSize,
+	}
+
+	switch status := query.Get("status"); status {
+	case "":
+	case "all":
+		filter.Status = ""
+	case database.QuarantineStatusPending, database.QuarantineStatusReleased, database.QuarantineStatusDiscarded:
+		filter.Status = status
+	default:
+		return filter, fmt.Errorf("status must be pending, released, discarded or all")
+	}
+
+	if raw := query.Get("after_id"); raw != "" {
+		afterID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || afterID < 0 {
+			return filter, fmt.Errorf("after_id must be a quarantined reading id")
+		}
+		filter.AfterID = afterID
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxQuarantinePageSize {
+			return filter, fmt.Errorf("limit must be a number between 1 and %d", maxQuarantinePageSize)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseQuerySensorsRequest validates a request for the series of several sensors,
+// returning the filter selecting their readings and the resolution, empty for raw
+// readings. Repeated sensors and types are dropped and every registered measurement
+// type is selected when none are requested
+func parseQuerySensorsRequest(request api.QuerySensorsRequest, registered map[string]string) (database.SensorMeasurementFilter, string, error) {
+	var filter database.SensorMeasurementFilter
+
+	seenSensors := make(map[string]bool)
+	for _, sensorID := range request.SensorIDs {
+		sensorID = strings.TrimSpace(sensorID)
+		if sensorID == "" || seenSensors[sensorID] {
+			continue
+		}
+		seenSensors[sensorID] = true
+		filter.SensorIDs = append(filter.SensorIDs, sensorID)
+	}
+
+	if len(filter.SensorIDs) == 0 || len(filter.SensorIDs) > MaxQuerySensors {
+		return filter, "", fmt.Errorf("sensor_ids must list between 1 and %d sensors", MaxQuerySensors)
+	}
+
+	measurementTypes, err := resolveMeasurementTypes(request.MeasurementTypes, registered)
+	if err != nil {
+		return filter, "", err
+	}
+
+	if len(measurementTypes) == 0 {
+		for measurementType := range registered {
+			measurementTypes = append(measurementTypes, measurementType)
+		}
+		sort.Strings(measurementTypes)
+	}
+
+	seenTypes := make(map[string]bool)
+	for _, measurementType := range measurementTypes {
+		if !seenTypes[measurementType] {
+			seenTypes[measurementType] = true
+			filter.MeasurementTypes = append(filter.MeasurementTypes, measurementType)
+		}
+	}
+
+	filter.Start = request.Start
+	filter.End = request.End
+	if !filter.Start.IsZero() && !filter.End.IsZero() && filter.End.Before(filter.Start) {
+		return filter, "", fmt.Errorf("end must not be before start")
+	}
+
+	resolution := request.Resolution
+	if resolution == ResolutionRaw {
+		resolution = ""
+	}
+
+	if resolution != "" && !database.ValidResolution(resolution) {
+		return filter, "", fmt.Errorf("resolution must be one of %q, %q, %q, %q or %q", ResolutionRaw,
+			database.ResolutionHourly, database.ResolutionDaily, database.ResolutionWeekly, database.ResolutionMonthly)
+	}
+
+	return filter, resolution, nil
+}
+
+// sensorLocationFilter selects sensors by where they were placed, zero values select every sensor
+type sensorLocationFilter struct {
+	BoundingBox  *geo.BoundingBox
+	Near         *geo.Point
+	RadiusMeters float64
+}
+
+// IsZero returns whether the filter selects every sensor
+func (f sensorLocationFilter) IsZero() bool {
+	return f.BoundingBox == nil && f.Near == nil
+}
+
+// parseSensorLocationFilter reads the bbox query parameter, west,south,east,north in
+// degrees, and the lat, lon and radius query parameters selecting the sensors within
+// radius metres of a point
+func parseSensorLocationFilter(r *http.Request) (sensorLocationFilter, error) {
+	var filter sensorLocationFilter
+	query := r.URL.Query()
+
+	if raw := query.Get("bbox"); raw != "" {
+		box, err := geo.ParseBoundingBox(raw)
+		if err != nil {
+			return filter, fmt.Errorf("bbox: %w", err)
+		}
+		filter.BoundingBox = &box
+	}
+
+	rawLatitude, rawLongitude, rawRadius := query.Get("lat"), query.Get("lon"), query.Get("radius")
+	if rawLatitude == "" && rawLongitude == "" && rawRadius == "" {
+		return filter, nil
+	}
+
+	latitude, err := strconv.ParseFloat(rawLatitude, 64)
+	if err != nil || !(latitude >= -90 && latitude <= 90) {
+		return filter, fmt.Errorf("lat, lon and radius must be set together, lat between -90 and 90")
+	}
+	longitude, err := strconv.ParseFloat(rawLongitude, 64)
+	if err != nil || !(longitude >= -180 && longitude <= 180) {
+		return filter, fmt.Errorf("lat, lon and radius must be set together, lon between -180 and 180")
+	}
+	radius, err := strconv.ParseFloat(rawRadius, 64)
+	if err != nil || !(radius > 0 && radius <= math.Pi*geo.EarthRadiusMeters) {
+		return filter, fmt.Errorf("lat, lon and radius must be set together, radius a positive number of metres")
+	}
+
+	filter.Near = &geo.Point{Longitude: longitude, Latitude: latitude}
+	filter.RadiusMeters = radius
+
+	return filter, nil
+}
+
+// parseHeatmapQuery reads the bbox or zone_id, at, cell_size, measurement_type, power,
+// radius and format query parameters of a heatmap, leaving the interpolation method
+// to newHeatmapInterpolator
+func parseHeatmapQuery(r *http.Request, now time.Time) (heatmapQuery, error) {
+	query := r.URL.Query()
+	heatmap := heatmapQuery{
+		At:              now,
+		CellSizeMeters:  defaultHeatmapCellSize,
+		MeasurementType: database.MeasurementTypeSoilMoisture,
+		Method:          HeatmapMethodIDW,
+		Power:           defaultHeatmapPower,
+		RadiusMeters:    defaultHeatmapRadius,
+		Format:          query.Get("format"),
+	}
+
+	rawBoundingBox, rawZoneID := query.Get("bbox"), query.Get("zone_id")
+	if (rawBoundingBox == "") == (rawZoneID == "") {
+		return heatmap, fmt.Errorf("one of bbox and zone_id is required")
+	}
+	if rawBoundingBox != "" {
+		box, err := geo.ParseBoundingBox(rawBoundingBox)
+		if err != nil {
+			return heatmap, fmt.Errorf("bbox: %w", err)
+		}
+		if box.West >= box.East || box.South == box.North {
+			return heatmap, fmt.Errorf("bbox must enclose an area and not cross the antimeridian")
+		}
+		heatmap.BoundingBox = &box
+	} else {
+		zoneID, err := uuid.Parse(rawZoneID)
+		if err != nil {
+			return heatmap, fmt.Errorf("zone_id must be the id of a zone")
+		}
+		heatmap.ZoneID = &zoneID
+	}
+
+	if raw := query.Get("at"); raw != "" {
+		at, err := parseQueryTime(raw, false)
+		if err != nil {
+			return heatmap, fmt.Errorf("at: %w", err)
+		}
+		heatmap.At = at
+	}
+
+	if raw := query.Get("method"); raw != "" {
+		heatmap.Method = raw
+	}
+
+	if raw := query.Get("measurement_type"); raw != "" {
+		heatmap.MeasurementType = raw
+		if alias, ok := measurementTypeAliases[raw]; ok {
+			heatmap.MeasurementType = alias
+		}
+	}
+
+	for _, parameter := range []struct {
+		name  string
+		value *float64
+		max   float64
+	}{
+		{"cell_size", &heatmap.CellSizeMeters, maxHeatmapCellSize},
+		{"power", &heatmap.Power, maxHeatmapPower},
+		{"radius", &heatmap.RadiusMeters, maxHeatmapRadius},
+	} {
+		raw := query.Get(parameter.name)
+		if raw == "" {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(value > 0 && value <= parameter.max) {
+			return heatmap, fmt.Errorf("%s must be a positive number up to %g", parameter.name, parameter.max)
+		}
+		*parameter.value = value
+	}
+
+	switch heatmap.Format {
+	case "", HeatmapFormatJSON, HeatmapFormatPNG:
+	default:
+		return heatmap, fmt.Errorf("format must be %q or %q", HeatmapFormatJSON, HeatmapFormatPNG)
+	}
+
+	return heatmap, nil
+}
+
+// parseAlertFilter reads the rule_id, sensor_id, state, start, end, before_id and limit
+// query parameters of the alert history
+func parseAlertFilter(r *http.Request) (database.AlertFilter, error) {
+	query := r.URL.Query()
+	filter := database.AlertFilter{
+		SensorID: query.Get("sensor_id"),
+		Limit:    defaultAlertsPageSize,
+	}
+
+	if raw := query.Get("rule_id"); raw != "" {
+		ruleID, err := uuid.Parse(raw)
+		if err != nil {
+			return filter, fmt.Errorf("rule_id must be the id of an alert rule")
+		}
+		filter.RuleID = &ruleID
+	}
+
+	switch state := query.Get("state"); state {
+	case "", database.AlertStateFiring, database.AlertStateAcknowledged, database.AlertStateSnoozed, database.AlertStateResolved:
+		filter.State = state
+	default:
+		return filter, fmt.Errorf("state must be %s, %s, %s or %s", database.AlertStateFiring, database.AlertStateAcknowledged, database.AlertStateSnoozed, database.AlertStateResolved)
+	}
+
+	for _, parameter := range []struct {
+		name     string
+		value    *time.Time
+		endOfDay bool
+	}{
+		{"start", &filter.Start, false},
+		{"end", &filter.End, true},
+	} {
+		raw := query.Get(parameter.name)
+		if raw == "" {
+			continue
+		}
+
+		parsed, err := parseQueryTime(raw, parameter.endOfDay)
+		if err != nil {
+			return filter, fmt.Errorf("%s: %w", parameter.name, err)
+		}
+		*parameter.value = parsed
+	}
+	if !filter.Start.IsZero() && !filter.End.IsZero() && filter.End.Before(filter.Start) {
+		return filter, fmt.Errorf("end must not be before start")
+	}
+
+	if raw := query.Get("before_id"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID < 1 {
+			return filter, fmt.Errorf("before_id must be an alert id")
+		}
+		filter.BeforeID = beforeID
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxAlertsPageSize {
+			return filter, fmt.Errorf("limit must be a number between 1 and %d", maxAlertsPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseWebhookDeliveryFilter parses the query parameters selecting a page of a webhook
+// subscription's delivery log, the subscription is taken from the path by the caller
+func parseWebhookDeliveryFilter(r *http.Request) (database.WebhookDeliveryFilter, error) {
+	query := r.URL.Query()
+	filter := database.WebhookDeliveryFilter{
+		Limit: defaultWebhookDeliveriesPageSize,
+	}
+
+	switch status := query.Get("status"); status {
+	case "", database.WebhookDeliveryStatusPending, database.WebhookDeliveryStatusDelivered, database.WebhookDeliveryStatusFailed:
+		filter.Status = status
+	default:
+		return filter, fmt.Errorf("status must be %s, %s or %s", database.WebhookDeliveryStatusPending, database.WebhookDeliveryStatusDelivered, database.WebhookDeliveryStatusFailed)
+	}
+
+	if raw := query.Get("before_id"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID < 1 {
+			return filter, fmt.Errorf("before_id must be a webhook delivery id")
+		}
+		filter.BeforeID = beforeID
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxWebhookDeliveriesPageSize {
+			return filter, fmt.Errorf("limit must be a number between 1 and %d", maxWebhookDeliveriesPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseEmailFilter reads the status, before_id and limit query parameters selecting a
+// page of the emails sent to a user
+func parseEmailFilter(r *http.Request) (database.EmailFilter, error) {
+	query := r.URL.Query()
+	filter := database.EmailFilter{
+		Limit: defaultEmailsPageSize,
+	}
+
+	switch status := query.Get("status"); status {
+	case "", database.EmailStatusPending, database.EmailStatusSent, database.EmailStatusFailed:
+		filter.Status = status
+	default:
+		return filter, fmt.Errorf("status must be %s, %s or %s", database.EmailStatusPending, database.EmailStatusSent, database.EmailStatusFailed)
+	}
+
+	if raw := query.Get("before_id"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID < 1 {
+			return filter, fmt.Errorf("before_id must be an email id")
+		}
+		filter.BeforeID = beforeID
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxEmailsPageSize {
+			return filter, fmt.Errorf("limit must be a number between 1 and %d", maxEmailsPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseBatteryForecastQuery reads the cutoff, window_days and within_days query
+// parameters of a battery forecast
+func parseBatteryForecastQuery(r *http.Request) (batteryForecastQuery, error) {
+	query := r.URL.Query()
+	forecast := batteryForecastQuery{
+		Cutoff:     defaultBatteryCutoff,
+		Window:     defaultBatteryForecastWindow,
+		WithinDays: defaultBatterySwapWithinDays,
+	}
+
+	if raw := query.Get("cutoff"); raw != "" {
+		cutoff, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(cutoff >= 0 && cutoff < 100) {
+			return forecast, fmt.Errorf("cutoff must be a battery level from 0 up to 100")
+		}
+		forecast.Cutoff = cutoff
+	}
+
+	maxWindowDays := int(maxBatteryForecastWindow.Hours() / 24)
+	if raw := query.Get("window_days"); raw != "" {
+		windowDays, err := strconv.Atoi(raw)
+		if err != nil || windowDays < 1 || windowDays > maxWindowDays {
+			return forecast, fmt.Errorf("window_days must be a number between 1 and %d", maxWindowDays)
+		}
+		forecast.Window = time.Duration(windowDays) * 24 * time.Hour
+	}
+
+	if raw := query.Get("within_days"); raw != "" {
+		withinDays, err := strconv.Atoi(raw)
+		if err != nil || withinDays < 0 || withinDays > maxBatterySwapWithinDays {
+			return forecast, fmt.Errorf("within_days must be a number between 0 and %d", maxBatterySwapWithinDays)
+		}
+		forecast.WithinDays = withinDays
+	}
+
+	return forecast, nil
+}
@@ -0,0 +1,513 @@
+package service
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/geo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestParseSensorDataQueryAcceptsDatesAndTimestamps(t *testing.T) {
+	// setup test data
+	request := httptest.NewRequest("GET", "/sensors/abc/moisture_data?start=2025-01-02&end=2025-01-03T12:00:00Z&limit=50&order=desc", nil)
+
+	// execute test
+	query, err := parseSensorDataQuery(request)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.True(t, query.Start.Equal(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, query.End.Equal(time.Date(2025, 1, 3, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 50, query.Limit)
+	assert.Equal(t, database.SortOrderDescending, query.Order)
+}
+
+func TestUnitTestParseSensorDataQueryIncludesWholeEndDay(t *testing.T) {
+	// setup test data
+	request := httptest.NewRequest("GET", "/sensors/abc/battery_data?start_date=2025-01-02&end_date=2025-01-02", nil)
+
+	// execute test
+	query, err := parseSensorDataQuery(request)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.True(t, query.End.After(time.Date(2025, 1, 2, 23, 59, 59, 0, time.UTC)))
+	assert.True(t, query.End.Before(time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestUnitTestParseSensorDataQueryRejectsInvalidValues(t *testing.T) {
+	for _, rawQuery := range []string{
+		"start=yesterday",
+		"limit=0",
+		"limit=1000000",
+		"order=sideways",
+		"cursor=not-a-cursor",
+		"start=2025-01-03&end=2025-01-02",
+	} {
+		request := httptest.NewRequest("GET", "/sensors/abc/moisture_data?"+rawQuery, nil)
+
+		_, err := parseSensorDataQuery(request)
+
+		assert.Error(t, err, "expected error for query %s", rawQuery)
+	}
+}
+
+func TestUnitTestSensorDataCursorRoundTrips(t *testing.T) {
+	// setup test data
+	cursor := database.SensorDataCursor{Date: time.Date(2025, 4, 5, 6, 7, 8, 9, time.UTC), ID: 42}
+
+	// execute test
+	request := httptest.NewRequest("GET", "/sensors/abc/moisture_data?cursor="+cursor.Encode(), nil)
+	query, err := parseSensorDataQuery(request)
+
+	// assert results
+	assert.NoError(t, err)
+	if assert.NotNil(t, query.After) {
+		assert.True(t, cursor.Date.Equal(query.After.Date))
+		assert.Equal(t, cursor.ID, query.After.ID)
+	}
+}
+
+func TestUnitTestParseResolution(t *testing.T) {
+	for rawQuery, expected := range map[string]string{
+		"":                   "",
+		"resolution=raw":     "",
+		"resolution=hourly":  database.ResolutionHourly,
+		"resolution=daily":   database.ResolutionDaily,
+		"resolution=weekly":  database.ResolutionWeekly,
+		"resolution=monthly": database.ResolutionMonthly,
+	} {
+		request := httptest.NewRequest("GET", "/sensors/abc/temperature_data?"+rawQuery, nil)
+		query, err := parseSensorDataQuery(request)
+		assert.NoError(t, err)
+
+		resolution, err := parseResolution(request, query)
+
+		assert.NoError(t, err, "unexpected error for query %s", rawQuery)
+		assert.Equal(t, expected, resolution)
+	}
+}
+
+func TestUnitTestParseResolutionRejectsInvalidValues(t *testing.T) {
+	for _, rawQuery := range []string{
+		"resolution=minutely",
+		"resolution=daily&limit=10",
+		"resolution=daily&cursor=" + database.SensorDataCursor{ID: 1}.Encode(),
+	} {
+		request := httptest.NewRequest("GET", "/sensors/abc/temperature_data?"+rawQuery, nil)
+		query, err := parseSensorDataQuery(request)
+		assert.NoError(t, err)
+
+		_, err = parseResolution(request, query)
+
+		assert.Error(t, err, "expected error for query %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseOnConflict(t *testing.T) {
+	for value, expected := range map[string]string{
+		"":       database.OnConflictIgnore,
+		"ignore": database.OnConflictIgnore,
+		"update": database.OnConflictUpdate,
+	} {
+		onConflict, err := parseOnConflict(value)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, onConflict)
+	}
+
+	_, err := parseOnConflict("replace")
+	assert.Error(t, err)
+}
+
+func TestUnitTestParseSensorMeasurementFilter(t *testing.T) {
+	// setup test data
+	registered := map[string]string{"soil_moisture": "%", "battery_level": "%", "soil_ph": "pH"}
+	request := httptest.NewRequest("GET", "/exports/sensors?ids=a,b&ids=c&types=moisture,%20soil_ph&start=2025-01-01&end=2025-01-31", nil)
+
+	// execute test
+	filter, err := parseSensorMeasurementFilter(request, registered)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, filter.SensorIDs)
+	assert.Equal(t, []string{"soil_moisture", "soil_ph"}, filter.MeasurementTypes)
+	assert.True(t, filter.Start.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, filter.End.After(time.Date(2025, 1, 31, 23, 59, 59, 0, time.UTC)))
+}
+
+func TestUnitTestParseSensorMeasurementFilterRejectsInvalidValues(t *testing.T) {
+	registered := map[string]string{"soil_moisture": "%"}
+
+	for _, rawQuery := range []string{
+		"types=humidity",
+		"start=last-week",
+		"start=2025-02-01&end=2025-01-01",
+	} {
+		request := httptest.NewRequest("GET", "/exports/sensors?"+rawQuery, nil)
+
+		_, err := parseSensorMeasurementFilter(request, registered)
+
+		assert.Error(t, err, "expected error for query %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseImportErrorsPage(t *testing.T) {
+	afterRow, limit, err := parseImportErrorsPage(httptest.NewRequest("GET", "/imports/sensor_data/id/errors", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, afterRow)
+	assert.Equal(t, defaultImportErrorsPageSize, limit)
+
+	afterRow, limit, err = parseImportErrorsPage(httptest.NewRequest("GET", "/imports/sensor_data/id/errors?after_row=42&limit=10", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 42, afterRow)
+	assert.Equal(t, 10, limit)
+
+	for _, rawQuery := range []string{"after_row=-1", "after_row=first", "limit=0", "limit=1001"} {
+		_, _, err := parseImportErrorsPage(httptest.NewRequest("GET", "/imports/sensor_data/id/errors?"+rawQuery, nil))
+
+		assert.Error(t, err, "expected error for query %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseQuerySensorsRequest(t *testing.T) {
+	// setup test data
+	registered := map[string]string{"soil_moisture": "%", "battery_level": "%", "soil_ph": "pH"}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// execute test
+	filter, resolution, err := parseQuerySensorsRequest(api.QuerySensorsRequest{
+		SensorIDs:        []string{"a", " b", "a", ""},
+		MeasurementTypes: []string{"moisture", "soil_moisture", "soil_ph"},
+		Start:            start,
+		Resolution:       "daily",
+	}, registered)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, "daily", resolution)
+	assert.Equal(t, []string{"a", "b"}, filter.SensorIDs)
+	assert.Equal(t, []string{"soil_moisture", "soil_ph"}, filter.MeasurementTypes)
+	assert.Equal(t, start, filter.Start)
+	assert.True(t, filter.End.IsZero())
+
+	// every registered type is selected by default and raw means no resolution
+	filter, resolution, err = parseQuerySensorsRequest(api.QuerySensorsRequest{SensorIDs: []string{"a"}, Resolution: "raw"}, registered)
+	assert.NoError(t, err)
+	assert.Equal(t, "", resolution)
+	assert.Equal(t, []string{"battery_level", "soil_moisture", "soil_ph"}, filter.MeasurementTypes)
+}
+
+func TestUnitTestParseQuerySensorsRequestRejectsInvalidRequests(t *testing.T) {
+	registered := map[string]string{"soil_moisture": "%"}
+	tooManySensors := make([]string, MaxQuerySensors+1)
+	for i := range tooManySensors {
+		tooManySensors[i] = fmt.Sprintf("sensor-%d", i)
+	}
+
+	for name, request := range map[string]api.QuerySensorsRequest{
+		"no sensors":       {},
+		"too many sensors": {SensorIDs: tooManySensors},
+		"unknown type":     {SensorIDs: []string{"a"}, MeasurementTypes: []string{"humidity"}},
+		"bad resolution":   {SensorIDs: []string{"a"}, Resolution: "yearly"},
+		"inverted window":  {SensorIDs: []string{"a"}, Start: time.Now(), End: time.Now().Add(-time.Hour)},
+	} {
+		_, _, err := parseQuerySensorsRequest(request, registered)
+
+		assert.Error(t, err, name)
+	}
+}
+
+func TestUnitTestParseCoverageRangeDefaultsToLastWeek(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	request := httptest.NewRequest("GET", "/sensors/abc/coverage?end=2030-01-01", nil)
+
+	// execute test
+	start, end, err := parseCoverageRange(request, now)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, now, end, "the range never extends past now")
+	assert.Equal(t, now.AddDate(0, 0, -7), start)
+}
+
+func TestUnitTestParseCoverageRangeRejectsInvalidRanges(t *testing.T) {
+	now := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+
+	for _, rawQuery := range []string{
+		"start=last-week",
+		"start=2025-06-09",
+		"start=2025-06-05&end=2025-06-04",
+		"start=2023-01-01&end=2025-01-01",
+	} {
+		request := httptest.NewRequest("GET", "/sensors/abc/coverage?"+rawQuery, nil)
+
+		_, _, err := parseCoverageRange(request, now)
+
+		assert.Error(t, err, "expected error for %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseZoneSeriesQueryDefaults(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	registered := map[string]string{
+		database.MeasurementTypeSoilMoisture:    "%",
+		database.MeasurementTypeSoilTemperature: "°C",
+		database.MeasurementTypeBatteryLevel:    "%",
+	}
+	request := httptest.NewRequest("GET", "/zones/abc/series", nil)
+
+	// execute test
+	filter, resolution, err := parseZoneSeriesQuery(request, registered, now)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, database.ResolutionHourly, resolution)
+	assert.Equal(t, []string{database.MeasurementTypeSoilMoisture, database.MeasurementTypeSoilTemperature}, filter.MeasurementTypes)
+	assert.Equal(t, now.AddDate(0, 0, -7), filter.Start)
+	assert.Equal(t, now, filter.End)
+
+	request = httptest.NewRequest("GET", "/zones/abc/series?types=moisture,soil_moisture&resolution=daily", nil)
+	filter, resolution, err = parseZoneSeriesQuery(request, registered, now)
+	assert.NoError(t, err)
+	assert.Equal(t, database.ResolutionDaily, resolution)
+	assert.Equal(t, []string{database.MeasurementTypeSoilMoisture}, filter.MeasurementTypes, "repeated types are dropped")
+}
+
+func TestUnitTestParseZoneSeriesQueryRejectsInvalidQueries(t *testing.T) {
+	now := time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+	registered := map[string]string{database.MeasurementTypeSoilMoisture: "%"}
+
+	for _, rawQuery := range []string{
+		"types=soil_ph",
+		"resolution=raw",
+		"resolution=minutely",
+		"start=2025-06-05&end=2025-06-04",
+		"start=2023-01-01&end=2025-01-01",
+	} {
+		request := httptest.NewRequest("GET", "/zones/abc/series?types=moisture&"+rawQuery, nil)
+
+		_, _, err := parseZoneSeriesQuery(request, registered, now)
+
+		assert.Error(t, err, "expected error for %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseSensorLocationFilter(t *testing.T) {
+	// setup test data
+	request := httptest.NewRequest("GET", "/sensors?bbox=10,50,12,52&lat=51&lon=11&radius=500", nil)
+
+	// execute test
+	filter, err := parseSensorLocationFilter(request)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.False(t, filter.IsZero())
+	assert.Equal(t, 12.0, filter.BoundingBox.East)
+	assert.Equal(t, 51.0, filter.Near.Latitude)
+	assert.Equal(t, 11.0, filter.Near.Longitude)
+	assert.Equal(t, 500.0, filter.RadiusMeters)
+
+	filter, err = parseSensorLocationFilter(httptest.NewRequest("GET", "/sensors", nil))
+	assert.NoError(t, err)
+	assert.True(t, filter.IsZero())
+
+	for _, rawQuery := range []string{
+		"bbox=10,50,12",
+		"lat=51&lon=11",
+		"lat=51&radius=500",
+		"lat=95&lon=11&radius=500",
+		"lat=51&lon=11&radius=0",
+		"lat=51&lon=11&radius=far",
+	} {
+		_, err := parseSensorLocationFilter(httptest.NewRequest("GET", "/sensors?"+rawQuery, nil))
+
+		assert.Error(t, err, "expected error for %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseHeatmapQuery(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	request := httptest.NewRequest("GET", "/heatmap?bbox=10,50,10.01,50.01&at=2025-05-31T06:00:00Z&cell_size=25&measurement_type=temperature&power=3&radius=200&format=png", nil)
+
+	// execute test
+	query, err := parseHeatmapQuery(request, now)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, geo.BoundingBox{West: 10, South: 50, East: 10.01, North: 50.01}, *query.BoundingBox)
+	assert.Nil(t, query.ZoneID)
+	assert.Equal(t, time.Date(2025, 5, 31, 6, 0, 0, 0, time.UTC), query.At)
+	assert.Equal(t, 25.0, query.CellSizeMeters)
+	assert.Equal(t, database.MeasurementTypeSoilTemperature, query.MeasurementType)
+	assert.Equal(t, 3.0, query.Power)
+	assert.Equal(t, 200.0, query.RadiusMeters)
+	assert.Equal(t, HeatmapFormatPNG, query.Format)
+
+	query, err = parseHeatmapQuery(httptest.NewRequest("GET", "/heatmap?zone_id=6f1c2a8e-3b7d-4c1e-9a2f-5d8e7b6c4a31", nil), now)
+	assert.NoError(t, err)
+	assert.Nil(t, query.BoundingBox)
+	assert.Equal(t, "6f1c2a8e-3b7d-4c1e-9a2f-5d8e7b6c4a31", query.ZoneID.String())
+	assert.Equal(t, now, query.At)
+	assert.Equal(t, defaultHeatmapCellSize, query.CellSizeMeters)
+	assert.Equal(t, database.MeasurementTypeSoilMoisture, query.MeasurementType)
+	assert.Equal(t, HeatmapMethodIDW, query.Method)
+	assert.Equal(t, defaultHeatmapPower, query.Power)
+	assert.Equal(t, defaultHeatmapRadius, query.RadiusMeters)
+
+	for _, rawQuery := range []string{
+		"",
+		"bbox=10,50,10.01,50.01&zone_id=6f1c2a8e-3b7d-4c1e-9a2f-5d8e7b6c4a31",
+		"bbox=179,50,-179,51",
+		"bbox=10,50,10,51",
+		"zone_id=north-field",
+		"bbox=10,50,10.01,50.01&at=yesterday",
+		"bbox=10,50,10.01,50.01&cell_size=0",
+		"bbox=10,50,10.01,50.01&power=-1",
+		"bbox=10,50,10.01,50.01&radius=far",
+		"bbox=10,50,10.01,50.01&format=tiff",
+	} {
+		_, err := parseHeatmapQuery(httptest.NewRequest("GET", "/heatmap?"+rawQuery, nil), now)
+
+		assert.Error(t, err, "expected error for %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseAlertFilter(t *testing.T) {
+	// setup test data
+	request := httptest.NewRequest("GET", "/alerts?rule_id=6f1c2a8e-3b7d-4c1e-9a2f-5d8e7b6c4a31&sensor_id=abc&state=firing&start=2025-06-01&end=2025-06-02&before_id=40&limit=10", nil)
+
+	// execute test
+	filter, err := parseAlertFilter(request)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, "6f1c2a8e-3b7d-4c1e-9a2f-5d8e7b6c4a31", filter.RuleID.String())
+	assert.Equal(t, "abc", filter.SensorID)
+	assert.Equal(t, database.AlertStateFiring, filter.State)
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), filter.Start)
+	assert.Equal(t, time.Date(2025, 6, 2, 23, 59, 59, 999999999, time.UTC), filter.End)
+	assert.Equal(t, int64(40), filter.BeforeID)
+	assert.Equal(t, 10, filter.Limit)
+
+	filter, err = parseAlertFilter(httptest.NewRequest("GET", "/alerts", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, defaultAlertsPageSize, filter.Limit)
+	assert.Nil(t, filter.RuleID)
+
+	for _, rawQuery := range []string{
+		"rule_id=dry",
+		"state=open",
+		"start=yesterday",
+		"start=2025-06-02&end=2025-06-01",
+		"before_id=0",
+		"limit=0",
+		"limit=5000",
+	} {
+		_, err := parseAlertFilter(httptest.NewRequest("GET", "/alerts?"+rawQuery, nil))
+
+		assert.Error(t, err, "expected error for %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseWebhookDeliveryFilter(t *testing.T) {
+	// setup test data
+	request := httptest.NewRequest("GET", "/admin/webhooks/6f1c2a8e-3b7d-4c1e-9a2f-5d8e7b6c4a31/deliveries?status=failed&before_id=40&limit=10", nil)
+
+	// execute test
+	filter, err := parseWebhookDeliveryFilter(request)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, database.WebhookDeliveryStatusFailed, filter.Status)
+	assert.Equal(t, int64(40), filter.BeforeID)
+	assert.Equal(t, 10, filter.Limit)
+
+	filter, err = parseWebhookDeliveryFilter(httptest.NewRequest("GET", "/admin/webhooks/6f1c2a8e-3b7d-4c1e-9a2f-5d8e7b6c4a31/deliveries", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, defaultWebhookDeliveriesPageSize, filter.Limit)
+	assert.Empty(t, filter.Status)
+
+	for _, rawQuery := range []string{
+		"status=retrying",
+		"before_id=0",
+		"limit=0",
+		"limit=5000",
+	} {
+		_, err := parseWebhookDeliveryFilter(httptest.NewRequest("GET", "/admin/webhooks/6f1c2a8e-3b7d-4c1e-9a2f-5d8e7b6c4a31/deliveries?"+rawQuery, nil))
+
+		assert.Error(t, err, "expected error for %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseEmailFilter(t *testing.T) {
+	// setup test data
+	request := httptest.NewRequest("GET", "/notifications/emails?status=pending&before_id=12&limit=5", nil)
+
+	// execute test
+	filter, err := parseEmailFilter(request)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, database.EmailStatusPending, filter.Status)
+	assert.Equal(t, int64(12), filter.BeforeID)
+	assert.Equal(t, 5, filter.Limit)
+
+	filter, err = parseEmailFilter(httptest.NewRequest("GET", "/notifications/emails", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, defaultEmailsPageSize, filter.Limit)
+	assert.Empty(t, filter.Status)
+
+	for _, rawQuery := range []string{
+		"status=delivered",
+		"before_id=abc",
+		"limit=-1",
+		"limit=5000",
+	} {
+		_, err := parseEmailFilter(httptest.NewRequest("GET", "/notifications/emails?"+rawQuery, nil))
+
+		assert.Error(t, err, "expected error for %s", rawQuery)
+	}
+}
+
+func TestUnitTestParseBatteryForecastQuery(t *testing.T) {
+	// setup test data
+	request := httptest.NewRequest("GET", "/sensors/battery_forecast?cutoff=15.5&window_days=60&within_days=7", nil)
+
+	// execute test
+	query, err := parseBatteryForecastQuery(request)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, 15.5, query.Cutoff)
+	assert.Equal(t, 60*24*time.Hour, query.Window)
+	assert.Equal(t, 7, query.WithinDays)
+
+	query, err = parseBatteryForecastQuery(httptest.NewRequest("GET", "/sensors/battery_forecast", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, defaultBatteryCutoff, query.Cutoff)
+	assert.Equal(t, defaultBatteryForecastWindow, query.Window)
+	assert.Equal(t, defaultBatterySwapWithinDays, query.WithinDays)
+
+	for _, rawQuery := range []string{
+		"cutoff=low",
+		"cutoff=-1",
+		"cutoff=100",
+		"window_days=0",
+		"window_days=400",
+		"within_days=-1",
+		"within_days=soon",
+	} {
+		_, err := parseBatteryForecastQuery(httptest.NewRequest("GET", "/sensors/battery_forecast?"+rawQuery, nil))
+
+		assert.Error(t, err, "expected error for %s", rawQuery)
+	}
+}
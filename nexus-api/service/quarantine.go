@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultQuarantinePageSize = 100
+	maxQuarantinePageSize     = 1000
+)
+
+// quarantinedReadingToAPI converts a quarantined reading to its api representation
+func quarantinedReadingToAPI(reading database.QuarantinedReading) api.QuarantinedReading {
+	return api.QuarantinedReading{
+		ID:              reading.ID,
+		SensorID:        reading.SensorID,
+		MeasurementType: reading.MeasurementType,
+		Date:            reading.Date,
+		Value:           reading.Value,
+		ReasonCode:      reading.ReasonCode,
+		Reason:          reading.Reason,
+		Status:          reading.Status,
+		CreatedAt:       reading.CreatedAt,
+		ReviewedAt:      reading.ReviewedAt,
+		ReviewedBy:      reading.ReviewedBy,
+		MeasurementID:   reading.MeasurementID,
+	}
+}
+
+// CreateGetQuarantinedReadingsHandler returns a handler that lists the readings held back
+// on ingest, pending review by default (admin only)
+func CreateGetQuarantinedReadingsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseQuarantineFilter(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		readings, err := database.GetQuarantinedReadings(r.Context(), apiService.DatabaseClient.DB, filter)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving quarantined readings: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetQuarantinedReadingsResponse{
+			Readings: make([]api.QuarantinedReading, 0, len(readings)),
+		}
+
+		if len(readings) > filter.Limit {
+			readings = readings[:filter.Limit]
+			nextAfterID := readings[filter.Limit-1].ID
+			response.NextAfterID = &nextAfterID
+		}
+
+		for _, reading := range readings {
+			response.Readings = append(response.Readings, quarantinedReadingToAPI(reading))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateReleaseQuarantinedReadingHandler returns a handler that stores a quarantined reading
+// an admin judged genuine as a reading of its sensor (admin only)
+func CreateReleaseQuarantinedReadingHandler(apiService *APIService) http.HandlerFunc {
+	return createReviewQuarantinedReadingHandler(apiService, "released", database.ReleaseQuarantinedReading)
+}
+
+// CreateDiscardQuarantinedReadingHandler returns a handler that marks a quarantined reading
+// as discarded so it is never stored (admin only)
+func CreateDiscardQuarantinedReadingHandler(apiService *APIService) http.HandlerFunc {
+	return createReviewQuarantinedReadingHandler(apiService, "discarded", database.DiscardQuarantinedReading)
+}
+
+// createReviewQuarantinedReadingHandler returns a handler that applies review to the
+// pending quarantined reading in the path and responds with the reviewed reading
+func createReviewQuarantinedReadingHandler(apiService *APIService, action string, review func(ctx context.Context, db *bun.DB, id int64, reviewedBy string) (database.QuarantinedReading, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		id, err := strconv.ParseInt(mux.Vars(r)["reading_id"], 10, 64)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid quarantined reading id"})
+			return
+		}
+
+		reading, err := review(r.Context(), apiService.DatabaseClient.DB, id, username)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case errors.Is(err, database.ErrorNoQuarantinedReading):
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Quarantined reading not found"})
+			case errors.Is(err, database.ErrorQuarantinedReadingReviewed):
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Quarantined reading has already been reviewed"})
+			case errors.Is(err, database.ErrorReadingAlreadyStored):
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "A reading is already stored for this sensor, measurement type and date"})
+			default:
+				apiService.Error().Msgf("Error reviewing quarantined reading %d: %s", id, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			}
+			return
+		}
+
+		apiService.Info().Msgf("Quarantined reading %d of sensor %s %s by %s", reading.ID, reading.SensorID, action, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(quarantinedReadingToAPI(reading))
+	}
+}
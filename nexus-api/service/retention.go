@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"nexus-api/clients/database"
+	"time"
+)
+
+// retentionResolution returns the bucket resolution to read the measurement types at
+// from start when no resolution was asked for, so a range reaching back past the raw
+// readings' retention is read from the rollups. It is empty if the raw readings from
+// start are all kept, hourly if the hourly rollups are and daily otherwise. A zero start
+// is an open range, which reaches back past every cutoff
+func retentionResolution(measurementTypes []database.MeasurementType, start time.Time, now time.Time) string {
+	var resolution string
+	for _, measurementType := range measurementTypes {
+		cutoff := measurementType.RawRetentionCutoff(now)
+		if cutoff.IsZero() || (!start.IsZero() && !start.Before(cutoff)) {
+			continue
+		}
+
+		if measurementType.HourlyRetentionDays > 0 && (start.IsZero() || start.Before(now.AddDate(0, 0, -measurementType.HourlyRetentionDays))) {
+			return database.ResolutionDaily
+		}
+
+		resolution = database.ResolutionHourly
+	}
+
+	return resolution
+}
+
+// applyRetentionPolicies applies the retention policy of each measurement type at now,
+// a failure for one type is logged and doesn't stop the others
+func (as *APIService) applyRetentionPolicies(ctx context.Context, now time.Time) {
+	measurementTypes, err := database.GetMeasurementTypes(ctx, as.DatabaseClient.DB)
+	if err != nil {
+		as.Error().Msgf("error %s retrieving measurement types for retention", err)
+		return
+	}
+
+	for _, measurementType := range measurementTypes {
+		if measurementType.RawRetentionDays == 0 && measurementType.HourlyRetentionDays == 0 && measurementType.DailyRetentionDays == 0 {
+			continue
+		}
+
+		result, err := database.ApplyRetentionPolicy(ctx, as.DatabaseClient.DB, measurementType, now)
+		if err != nil {
+			as.Error().Msgf("error %s applying retention policy of %s", err, measurementType.ID)
+			continue
+		}
+
+		if result != (database.RetentionResult{}) {
+			as.Info().Msgf("retention of %s rolled up %d raw readings, deleted %d hourly and %d daily rollups",
+				measurementType.ID, result.RolledUp, result.DeletedHourly, result.DeletedDaily)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"nexus-api/clients/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestRawRetentionCutoff(t *testing.T) {
+	now := time.Date(2025, 6, 10, 15, 30, 0, 0, time.UTC)
+
+	assert.True(t, database.MeasurementType{}.RawRetentionCutoff(now).IsZero())
+	assert.Equal(t, time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC), database.MeasurementType{RawRetentionDays: 90}.RawRetentionCutoff(now))
+}
+
+func TestUnitTestRetentionResolution(t *testing.T) {
+	now := time.Date(2025, 6, 10, 15, 30, 0, 0, time.UTC)
+	forever := database.MeasurementType{ID: "soil_ph"}
+	policy := database.MeasurementType{ID: "soil_moisture", RawRetentionDays: 90, HourlyRetentionDays: 730}
+
+	for name, test := range map[string]struct {
+		measurementTypes []database.MeasurementType
+		start            time.Time
+		resolution       string
+	}{
+		"no start":               {[]database.MeasurementType{policy}, time.Time{}, database.ResolutionDaily},
+		"no start, hourly kept":  {[]database.MeasurementType{{RawRetentionDays: 90}}, time.Time{}, database.ResolutionHourly},
+		"no start, raw kept":     {[]database.MeasurementType{forever}, time.Time{}, ""},
+		"raw kept forever":       {[]database.MeasurementType{forever}, now.AddDate(-5, 0, 0), ""},
+		"within raw retention":   {[]database.MeasurementType{policy}, now.AddDate(0, 0, -30), ""},
+		"past raw retention":     {[]database.MeasurementType{policy}, now.AddDate(0, 0, -120), database.ResolutionHourly},
+		"past hourly retention":  {[]database.MeasurementType{policy}, now.AddDate(-3, 0, 0), database.ResolutionDaily},
+		"any type past raw":      {[]database.MeasurementType{forever, policy}, now.AddDate(0, 0, -120), database.ResolutionHourly},
+		"hourly rollups forever": {[]database.MeasurementType{{RawRetentionDays: 90}}, now.AddDate(-3, 0, 0), database.ResolutionHourly},
+	} {
+		assert.Equal(t, test.resolution, retentionResolution(test.measurementTypes, test.start, now), name)
+	}
+}
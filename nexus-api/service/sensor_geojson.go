@@ -0,0 +1,121 @@
+package service
+
+import (
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/geo"
+	"sort"
+	"time"
+)
+
+const (
+	SensorsFormatJSON    = "json"
+	SensorsFormatGeoJSON = "geojson"
+)
+
+// sensorPlaced returns whether the sensor has been placed at coordinates, 0,0 is unplaced
+func sensorPlaced(sensor database.Sensor) bool {
+	return sensor.Latitude != 0 || sensor.Longitude != 0
+}
+
+// filterSensorsByLocation returns the sensors selected by the filter, along with the
+// distance in metres of each of them from the filter's point if it has one, in which
+// case the sensors are ordered nearest first
+func filterSensorsByLocation(sensors []database.Sensor, filter sensorLocationFilter) ([]database.Sensor, map[string]float64) {
+	if filter.IsZero() {
+		return sensors, nil
+	}
+
+	var distances map[string]float64
+	if filter.Near != nil {
+		distances = make(map[string]float64)
+	}
+
+	var selected []database.Sensor
+	for _, sensor := range sensors {
+		if !sensorPlaced(sensor) {
+			continue
+		}
+
+		point := geo.Point{Longitude: sensor.Longitude, Latitude: sensor.Latitude}
+		if filter.BoundingBox != nil && !filter.BoundingBox.Contains(point) {
+			continue
+		}
+		if filter.Near != nil {
+			distance := geo.Distance(*filter.Near, point)
+			if distance > filter.RadiusMeters {
+				continue
+			}
+			distances[sensor.ID] = distance
+		}
+
+		selected = append(selected, sensor)
+	}
+
+	if filter.Near != nil {
+		sort.SliceStable(selected, func(i, j int) bool {
+			return distances[selected[i].ID] < distances[selected[j].ID]
+		})
+	}
+
+	return selected, distances
+}
+
+// sensorsToGeoJSON returns the sensors as a FeatureCollection of points. Each feature's
+// properties hold the sensor's state at now and, for each measurement type it reported,
+// its newest reading under the type's id with the reading's unit and time alongside
+func sensorsToGeoJSON(sensors []database.Sensor, lastReadings map[string][]database.SensorLastSeen, units map[string]string, distances map[string]float64, now time.Time) api.GeoJSONFeatureCollection {
+	collection := api.GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]api.GeoJSONFeature, 0, len(sensors)),
+	}
+
+	for _, sensor := range sensors {
+		state, stateSince := sensorState(sensor.LastSeenAt, sensor.OnlineSince, sensor.OnlineThreshold(), now)
+
+		feature := api.GeoJSONFeature{
+			Type: "Feature",
+			ID:   sensor.ID,
+			Properties: map[string]interface{}{
+				"name":              sensor.Name,
+				"location":          sensor.Location,
+				"installation_date": sensor.InstallationDate.UTC(),
+				"state":             state,
+				"is_online":         state == SensorStateOnline,
+			},
+		}
+		if sensorPlaced(sensor) {
+			feature.Geometry = &api.GeoJSONGeometry{
+				Type:        "Point",
+				Coordinates: []float64{sensor.Longitude, sensor.Latitude},
+			}
+		}
+
+		properties := feature.Properties
+		if stateSince != nil {
+			properties["state_since"] = stateSince.UTC()
+		}
+		if sensor.LastSeenAt != nil {
+			properties["last_seen"] = sensor.LastSeenAt.UTC()
+		}
+		if sensor.ZoneID != nil {
+			properties["zone_id"] = sensor.ZoneID.String()
+		}
+		if sensor.DecommissionedAt != nil {
+			properties["decommissioned_at"] = sensor.DecommissionedAt.UTC()
+		}
+		if distance, ok := distances[sensor.ID]; ok {
+			properties["distance_meters"] = distance
+		}
+
+		for _, reading := range lastReadings[sensor.ID] {
+			properties[reading.MeasurementType] = reading.LastValue
+			properties[reading.MeasurementType+"_unit"] = units[reading.MeasurementType]
+			properties[reading.MeasurementType+"_at"] = reading.LastDate.UTC()
+		}
+
+		collection.Features = append(collection.Features, feature)
+	}
+
+	return collection
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"encoding/json"
+	"nexus-api/clients/database"
+	"nexus-api/geo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestFilterSensorsByLocation(t *testing.T) {
+	// setup test data
+	sensors := []database.Sensor{
+		{ID: "far", SensorCoordinates: database.SensorCoordinates{Latitude: 51.6, Longitude: 10.5}},
+		{ID: "near", SensorCoordinates: database.SensorCoordinates{Latitude: 51.501, Longitude: 10.5}},
+		{ID: "unplaced"},
+		{ID: "outside", SensorCoordinates: database.SensorCoordinates{Latitude: 40, Longitude: 10.5}},
+	}
+	box := geo.BoundingBox{West: 10, South: 51, East: 11, North: 52}
+
+	// execute test
+	all, distances := filterSensorsByLocation(sensors, sensorLocationFilter{})
+	inBox, _ := filterSensorsByLocation(sensors, sensorLocationFilter{BoundingBox: &box})
+	nearby, nearbyDistances := filterSensorsByLocation(sensors, sensorLocationFilter{
+		Near:         &geo.Point{Longitude: 10.5, Latitude: 51.5},
+		RadiusMeters: 20000,
+	})
+
+	// assert results
+	assert.Len(t, all, 4, "no filter selects every sensor, placed or not")
+	assert.Nil(t, distances)
+
+	var ids []string
+	for _, sensor := range inBox {
+		ids = append(ids, sensor.ID)
+	}
+	assert.Equal(t, []string{"far", "near"}, ids)
+
+	ids = nil
+	for _, sensor := range nearby {
+		ids = append(ids, sensor.ID)
+	}
+	assert.Equal(t, []string{"near", "far"}, ids, "nearest first")
+	assert.InDelta(t, 111, nearbyDistances["near"], 1)
+	assert.InDelta(t, 11120, nearbyDistances["far"], 10)
+}
+
+func TestUnitTestSensorsToGeoJSON(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	lastSeenAt := now.Add(-10 * time.Minute)
+	sensors := []database.Sensor{
+		{
+			ID:                "abc",
+			Name:              "North probe",
+			SensorCoordinates: database.SensorCoordinates{Latitude: 51.5, Longitude: 10.5},
+			LastSeenAt:        &lastSeenAt,
+		},
+		{ID: "unplaced", Name: "Spare probe"},
+	}
+	lastReadings := map[string][]database.SensorLastSeen{
+		"abc": {{SensorID: "abc", MeasurementType: database.MeasurementTypeSoilMoisture, LastDate: lastSeenAt, LastValue: 31.5}},
+	}
+	units := map[string]string{database.MeasurementTypeSoilMoisture: "%"}
+
+	// execute test
+	collection := sensorsToGeoJSON(sensors, lastReadings, units, map[string]float64{"abc": 12.5}, now)
+
+	// assert results
+	data, err := json.Marshal(collection)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "FeatureCollection",
+		"features": [
+			{
+				"type": "Feature",
+				"id": "abc",
+				"geometry": {"type": "Point", "coordinates": [10.5, 51.5]},
+				"properties": {
+					"name": "North probe",
+					"location": "",
+					"installation_date": "0001-01-01T00:00:00Z",
+					"state": "online",
+					"is_online": true,
+					"state_since": "2025-06-01T11:50:00Z",
+					"last_seen": "2025-06-01T11:50:00Z",
+					"distance_meters": 12.5,
+					"soil_moisture": 31.5,
+					"soil_moisture_unit": "%",
+					"soil_moisture_at": "2025-06-01T11:50:00Z"
+				}
+			},
+			{
+				"type": "Feature",
+				"id": "unplaced",
+				"geometry": null,
+				"properties": {
+					"name": "Spare probe",
+					"location": "",
+					"installation_date": "0001-01-01T00:00:00Z",
+					"state": "never_seen",
+					"is_online": false
+				}
+			}
+		]
+	}`, string(data))
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"sort"
+	"time"
+)
+
+// sensorSeriesKey identifies the series of one sensor and measurement type
+type sensorSeriesKey struct {
+	SensorID        string
+	MeasurementType string
+}
+
+// sensorChainOwners returns the ids of every sensor in the chains of replacements of the
+// requested sensors and, for each of them, the requested sensors whose series its
+// readings belong to. Requested sensors without a chain are their own
+func sensorChainOwners(requested []string, chains map[string][]string) ([]string, map[string][]string) {
+	var sensorIDs []string
+	owners := make(map[string][]string)
+	for _, sensorID := range requested {
+		chain, ok := chains[sensorID]
+		if !ok {
+			chain = []string{sensorID}
+		}
+
+		for _, member := range chain {
+			if _, seen := owners[member]; !seen {
+				sensorIDs = append(sensorIDs, member)
+			}
+			owners[member] = append(owners[member], sensorID)
+		}
+	}
+
+	return sensorIDs, owners
+}
+
+// mergeSensorDataBuckets combines buckets of several sensors that start at the same
+// time into one, returning the buckets in time order
+func mergeSensorDataBuckets(buckets []api.SensorDataBucket) []api.SensorDataBucket {
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return buckets[i].BucketStart.Before(buckets[j].BucketStart)
+	})
+
+	var merged []api.SensorDataBucket
+	for _, bucket := range buckets {
+		if len(merged) == 0 || !merged[len(merged)-1].BucketStart.Equal(bucket.BucketStart) {
+			merged = append(merged, bucket)
+			continue
+		}
+
+		last := &merged[len(merged)-1]
+		last.Min = math.Min(last.Min, bucket.Min)
+		last.Max = math.Max(last.Max, bucket.Max)
+		last.Avg = (last.Avg*float64(last.Count) + bucket.Avg*float64(bucket.Count)) / float64(last.Count+bucket.Count)
+		last.Count += bucket.Count
+	}
+
+	return merged
+}
+
+// CreateQuerySensorsHandler returns a handler that returns the readings, or buckets of
+// readings, of several sensors and measurement types in one response so dashboards
+// don't need a request per sensor per measurement type. Raw series are capped at
+// MaxSensorDataPageSize readings and marked as truncated when cut short
+func CreateQuerySensorsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request api.QuerySensorsRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		measurementTypes, err := database.GetMeasurementTypes(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving measurement types: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		units := make(map[string]string, len(measurementTypes))
+		for _, measurementType := range measurementTypes {
+			units[measurementType.ID] = measurementType.Unit
+		}
+
+		filter, resolution, err := parseQuerySensorsRequest(request, units)
+		var converter unitConverter
+		if err == nil {
+			converter, err = parseUnits(request.Units)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		// each physical sensor's readings go to the series of the requested
+		// sensors whose chain of replacements it is part of
+		requested := filter.SensorIDs
+		owners := make(map[string][]string, len(requested))
+		for _, sensorID := range requested {
+			owners[sensorID] = []string{sensorID}
+		}
+		if request.IncludeReplaced {
+			chains, err := database.GetSensorChains(r.Context(), apiService.DatabaseClient.DB, requested)
+			if err != nil {
+				apiService.Error().Msgf("Error retrieving replacements of sensors %v: %s", requested, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+
+			filter.SensorIDs, owners = sensorChainOwners(requested, chains)
+		}
+
+		// ranges reaching back past the raw readings' retention are
+		// read from the rollups unless raw readings were asked for
+		if request.Resolution == "" {
+			var queried []database.MeasurementType
+			for _, measurementType := range measurementTypes {
+				for _, id := range filter.MeasurementTypes {
+					if measurementType.ID == id {
+						queried = append(queried, measurementType)
+					}
+				}
+			}
+
+			resolution = retentionResolution(queried, filter.Start, time.Now())
+		}
+
+		// readings are calibrated unless they were asked for as stored
+		var calibrations database.SensorCalibrations
+		if !request.Raw {
+			calibrations, err = database.GetSensorCalibrations(r.Context(), apiService.DatabaseClient.DB, filter.SensorIDs, filter.MeasurementTypes)
+			if err != nil {
+				apiService.Error().Msgf("Error retrieving calibrations of sensors %v: %s", requested, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+		}
+
+		series := make(map[sensorSeriesKey]*api.SensorSeries, len(requested)*len(filter.MeasurementTypes))
+		response := api.QuerySensorsResponse{
+			Resolution: ResolutionRaw,
+			Series:     make([]api.SensorSeries, 0, len(requested)*len(filter.MeasurementTypes)),
+		}
+		if resolution != "" {
+			response.Resolution = resolution
+		}
+
+		latestDates, err := database.GetLatestSensorMeasurementDates(r.Context(), apiService.DatabaseClient.DB, filter)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving latest reading dates for sensors %v: %s", requested, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		thresholds, err := database.GetSensorOnlineThresholds(r.Context(), apiService.DatabaseClient.DB, requested)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving online thresholds for sensors %v: %s", requested, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		latest := make(map[sensorSeriesKey]time.Time, len(latestDates))
+		for _, latestDate := range latestDates {
+			for _, owner := range owners[latestDate.SensorID] {
+				key := sensorSeriesKey{owner, latestDate.MeasurementType}
+				if latestDate.Date.After(latest[key]) {
+					latest[key] = latestDate.Date
+				}
+			}
+		}
+
+		// every requested series is returned, in request order, even if it has no readings
+		for _, sensorID := range requested {
+			threshold, ok := thresholds[sensorID]
+			if !ok {
+				threshold = database.DefaultSensorOnlineThreshold
+			}
+
+			for _, measurementType := range filter.MeasurementTypes {
+				key := sensorSeriesKey{sensorID, measurementType}
+				isOnline, lastDataTimestamp := sensorOnlineStatus(latest[key], threshold)
+				response.Series = append(response.Series, api.SensorSeries{
+					SensorID:          sensorID,
+					MeasurementType:   measurementType,
+					Unit:              converter.Unit(units[measurementType]),
+					IsOnline:          isOnline,
+					LastDataTimestamp: lastDataTimestamp,
+				})
+			}
+		}
+		for i := range response.Series {
+			series[sensorSeriesKey{response.Series[i].SensorID, response.Series[i].MeasurementType}] = &response.Series[i]
+		}
+
+		if resolution != "" {
+			buckets, err := database.GetSensorMeasurementSeriesBuckets(r.Context(), apiService.DatabaseClient.DB, filter, resolution)
+			if err != nil {
+				apiService.Error().Msgf("Error retrieving %s buckets for sensors %v: %s", resolution, requested, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+
+			for _, bucket := range buckets {
+				calibrated := sensorDataBucketToAPI(calibrations.CalibrateBucket(bucket.SensorID, bucket.MeasurementType, bucket.SensorDataBucket))
+				converted := converter.Bucket(units[bucket.MeasurementType], calibrated)
+				for _, owner := range owners[bucket.SensorID] {
+					s := series[sensorSeriesKey{owner, bucket.MeasurementType}]
+					s.Buckets = append(s.Buckets, converted)
+				}
+			}
+
+			if request.IncludeReplaced {
+				for i := range response.Series {
+					response.Series[i].Buckets = mergeSensorDataBuckets(response.Series[i].Buckets)
+				}
+			}
+		} else {
+			measurements, err := database.GetSensorMeasurementSeries(r.Context(), apiService.DatabaseClient.DB, filter, MaxSensorDataPageSize)
+			if err != nil {
+				apiService.Error().Msgf("Error retrieving readings for sensors %v: %s", requested, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+
+			for _, measurement := range measurements {
+				for _, owner := range owners[measurement.SensorID] {
+					s := series[sensorSeriesKey{owner, measurement.MeasurementType}]
+					reading := api.SensorMeasurement{
+						ID:    measurement.ID,
+						Date:  measurement.Date,
+						Value: converter.Value(units[measurement.MeasurementType], calibrations.Calibrate(measurement.SensorID, measurement.MeasurementType, measurement.Date, measurement.Value)),
+					}
+					if request.IncludeReplaced {
+						reading.SensorID = measurement.SensorID
+					}
+					s.Measurements = append(s.Measurements, reading)
+				}
+			}
+
+			// the earliest readings of each physical sensor were fetched, so once merged
+			// in date order the first MaxSensorDataPageSize are the series' earliest
+			for i := range response.Series {
+				s := &response.Series[i]
+				if request.IncludeReplaced {
+					sort.SliceStable(s.Measurements, func(a, b int) bool {
+						if s.Measurements[a].Date.Equal(s.Measurements[b].Date) {
+							return s.Measurements[a].ID < s.Measurements[b].ID
+						}
+						return s.Measurements[a].Date.Before(s.Measurements[b].Date)
+					})
+				}
+				if len(s.Measurements) > MaxSensorDataPageSize {
+					s.Measurements = s.Measurements[:MaxSensorDataPageSize]
+					s.Truncated = true
+				}
+			}
+		}
+
+		apiService.Debug().Msgf("Sending back %d series for %d sensors", len(response.Series), len(requested))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
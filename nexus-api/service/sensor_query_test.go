@@ -0,0 +1,39 @@
+package service
+
+import (
+	"nexus-api/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestSensorChainOwners(t *testing.T) {
+	chains := map[string][]string{
+		"old": {"old", "new"},
+		"new": {"old", "new"},
+	}
+
+	sensorIDs, owners := sensorChainOwners([]string{"new", "other", "old"}, chains)
+
+	assert.Equal(t, []string{"old", "new", "other"}, sensorIDs)
+	assert.Equal(t, []string{"new", "old"}, owners["old"])
+	assert.Equal(t, []string{"new", "old"}, owners["new"])
+	assert.Equal(t, []string{"other"}, owners["other"])
+}
+
+func TestUnitTestMergeSensorDataBuckets(t *testing.T) {
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	nextDay := day.AddDate(0, 0, 1)
+
+	merged := mergeSensorDataBuckets([]api.SensorDataBucket{
+		{BucketStart: nextDay, Min: 5, Max: 5, Avg: 5, Count: 1},
+		{BucketStart: day, Min: 10, Max: 20, Avg: 15, Count: 2},
+		{BucketStart: day, Min: 4, Max: 12, Avg: 12, Count: 1},
+	})
+
+	assert.Equal(t, []api.SensorDataBucket{
+		{BucketStart: day, Min: 4, Max: 20, Avg: 14, Count: 3},
+		{BucketStart: nextDay, Min: 5, Max: 5, Avg: 5, Count: 1},
+	}, merged)
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"time"
+)
+
+const (
+	SensorStateOnline    = "online"
+	SensorStateOffline   = "offline"
+	SensorStateNeverSeen = "never_seen"
+)
+
+// sensorState works out whether a sensor is online at now from the time of its newest
+// reading, when it last came back online and its online threshold, returning the state
+// and when it began
+func sensorState(lastSeenAt *time.Time, onlineSince *time.Time, threshold time.Duration, now time.Time) (string, *time.Time) {
+	if lastSeenAt == nil {
+		return SensorStateNeverSeen, nil
+	}
+
+	if now.Sub(*lastSeenAt) <= threshold {
+		if onlineSince == nil {
+			return SensorStateOnline, lastSeenAt
+		}
+		return SensorStateOnline, onlineSince
+	}
+
+	offlineSince := lastSeenAt.Add(threshold)
+
+	return SensorStateOffline, &offlineSince
+}
+
+// CreateGetSensorsStatusHandler returns a handler that reports the state of every sensor in service,
+// optionally only those in the state given by the state query parameter. It reads the
+// last seen times maintained as readings are saved rather than the readings themselves
+func CreateGetSensorsStatusHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := r.Context().Value(UsernameContextKey).(string)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+
+		stateFilter := r.URL.Query().Get("state")
+		switch stateFilter {
+		case "", SensorStateOnline, SensorStateOffline, SensorStateNeverSeen:
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("state must be %q, %q or %q", SensorStateOnline, SensorStateOffline, SensorStateNeverSeen)})
+			return
+		}
+
+		sensors, err := apiService.DatabaseClient.GetAllSensors(r.Context(), username, false)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving sensors: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		lastSeen, err := database.GetSensorLastSeen(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving sensor last seen times: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		measurementTypes, err := database.GetMeasurementTypes(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving measurement types: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		units := make(map[string]string, len(measurementTypes))
+		for _, measurementType := range measurementTypes {
+			units[measurementType.ID] = measurementType.Unit
+		}
+
+		lastReadings := make(map[string][]database.SensorLastSeen)
+		for _, reading := range lastSeen {
+			lastReadings[reading.SensorID] = append(lastReadings[reading.SensorID], reading)
+		}
+
+		now := time.Now()
+		response := api.GetSensorsStatusResponse{Sensors: []api.SensorStatus{}}
+		for _, sensor := range sensors {
+			state, stateSince := sensorState(sensor.LastSeenAt, sensor.OnlineSince, sensor.OnlineThreshold(), now)
+
+			switch state {
+			case SensorStateOnline:
+				response.Online++
+			case SensorStateOffline:
+				response.Offline++
+			default:
+				response.NeverSeen++
+			}
+
+			if stateFilter != "" && state != stateFilter {
+				continue
+			}
+
+			status := api.SensorStatus{
+				SensorID:     sensor.ID,
+				Name:         sensor.Name,
+				Location:     sensor.Location,
+				State:        state,
+				IsOnline:     state == SensorStateOnline,
+				LastSeen:     sensor.LastSeenAt,
+				StateSince:   stateSince,
+				LastReadings: []api.SensorLastReading{},
+			}
+			if stateSince != nil && stateSince.Before(now) {
+				status.StateDurationSeconds = int64(now.Sub(*stateSince).Seconds())
+			}
+
+			for _, reading := range lastReadings[sensor.ID] {
+				status.LastReadings = append(status.LastReadings, api.SensorLastReading{
+					MeasurementType: reading.MeasurementType,
+					Unit:            units[reading.MeasurementType],
+					Date:            reading.LastDate,
+					Value:           reading.LastValue,
+				})
+
+				if reading.MeasurementType == database.MeasurementTypeBatteryLevel {
+					batteryLevel, batteryLevelDate := reading.LastValue, reading.LastDate
+					status.BatteryLevel = &batteryLevel
+					status.BatteryLevelDate = &batteryLevelDate
+				}
+			}
+
+			response.Sensors = append(response.Sensors, status)
+		}
+
+		apiService.Debug().Msgf("Sending back status of %d sensors, online: %d, offline: %d, never seen: %d",
+			len(response.Sensors), response.Online, response.Offline, response.NeverSeen)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
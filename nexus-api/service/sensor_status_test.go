@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestSensorState(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-2 * time.Hour)
+	stale := now.Add(-30 * time.Hour)
+	onlineSince := now.Add(-72 * time.Hour)
+
+	// execute test and assert results
+	state, since := sensorState(nil, nil, 24*time.Hour, now)
+	assert.Equal(t, SensorStateNeverSeen, state)
+	assert.Nil(t, since)
+
+	state, since = sensorState(&recent, &onlineSince, 24*time.Hour, now)
+	assert.Equal(t, SensorStateOnline, state)
+	assert.Equal(t, onlineSince, *since)
+
+	// sensors seen before online_since was tracked fall back to their last reading
+	state, since = sensorState(&recent, nil, 24*time.Hour, now)
+	assert.Equal(t, SensorStateOnline, state)
+	assert.Equal(t, recent, *since)
+
+	// offline sensors went offline when their last reading became too old
+	state, since = sensorState(&stale, &onlineSince, 24*time.Hour, now)
+	assert.Equal(t, SensorStateOffline, state)
+	assert.Equal(t, now.Add(-6*time.Hour), *since)
+
+	// sensors that report twice a day stay online longer
+	state, _ = sensorState(&stale, &onlineSince, 36*time.Hour, now)
+	assert.Equal(t, SensorStateOnline, state)
+
+	// and sensors that report every few minutes go offline sooner
+	state, since = sensorState(&recent, &onlineSince, 30*time.Minute, now)
+	assert.Equal(t, SensorStateOffline, state)
+	assert.Equal(t, now.Add(-90*time.Minute), *since)
+}
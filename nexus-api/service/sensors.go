@@ -0,0 +1,409 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxSensorIntervalSeconds bounds the reporting interval and grace period of a sensor
+	maxSensorIntervalSeconds = 30 * 24 * 60 * 60
+	// maxSensorTextLength is the size of the name and location columns
+	maxSensorTextLength = 255
+	// maxDecommissionReasonLength bounds the note kept with a decommissioned sensor
+	maxDecommissionReasonLength = 1000
+)
+
+// sensorToAPI converts a stored sensor to its api representation
+func sensorToAPI(sensor database.Sensor) api.Sensor {
+	apiSensor := api.Sensor{
+		ID:                       sensor.ID,
+		Name:                     sensor.Name,
+		Location:                 sensor.Location,
+		InstallationDate:         sensor.InstallationDate,
+		SensorCoordinates:        api.SensorCoordinates{Latitude: sensor.Latitude, Longitude: sensor.Longitude},
+		LastSeenAt:               sensor.LastSeenAt,
+		OnlineSince:              sensor.OnlineSince,
+		ReportingIntervalSeconds: sensor.ReportingIntervalSeconds,
+		OfflineGraceSeconds:      sensor.OfflineGraceSeconds,
+		DecommissionedAt:         sensor.DecommissionedAt,
+		DecommissionReason:       sensor.DecommissionReason,
+		DecommissionedBy:         sensor.DecommissionedBy,
+		ReplacesSensorID:         sensor.ReplacesSensorID,
+		ZoneAssignedManually:     sensor.ZoneAssignedManually,
+	}
+	if sensor.ZoneID != nil {
+		apiSensor.ZoneID = sensor.ZoneID.String()
+	}
+
+	return apiSensor
+}
+
+// validateSensorCoordinates returns an error if the coordinates are not a point on earth
+func validateSensorCoordinates(coordinates database.SensorCoordinates) error {
+	if !(coordinates.Latitude >= -90 && coordinates.Latitude <= 90) {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+
+	if !(coordinates.Longitude >= -180 && coordinates.Longitude <= 180) {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+
+	return nil
+}
+
+// validateSensor returns an error describing the first
+// invalid field of a sensor that is being saved
+func validateSensor(sensor database.Sensor) error {
+	if strings.TrimSpace(sensor.Name) == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+
+	if len(sensor.Name) > maxSensorTextLength || len(sensor.Location) > maxSensorTextLength {
+		return fmt.Errorf("name and location must be at most %d characters", maxSensorTextLength)
+	}
+
+	if sensor.InstallationDate.IsZero() {
+		return fmt.Errorf("installation_date is required")
+	}
+
+	if sensor.InstallationDate.After(time.Now().Add(24 * time.Hour)) {
+		return fmt.Errorf("installation_date must not be in the future")
+	}
+
+	err := validateSensorCoordinates(sensor.SensorCoordinates)
+	if err != nil {
+		return err
+	}
+
+	if sensor.ReportingIntervalSeconds < 1 || sensor.ReportingIntervalSeconds > maxSensorIntervalSeconds {
+		return fmt.Errorf("reporting_interval_seconds must be between 1 and %d", maxSensorIntervalSeconds)
+	}
+
+	if sensor.OfflineGraceSeconds < 0 || sensor.OfflineGraceSeconds > maxSensorIntervalSeconds {
+		return fmt.Errorf("offline_grace_seconds must be between 0 and %d", maxSensorIntervalSeconds)
+	}
+
+	return nil
+}
+
+// getSensor looks up the sensor named in the request path, answering the request
+// directly and returning false if it can't be found
+func getSensor(apiService *APIService, w http.ResponseWriter, r *http.Request) (database.Sensor, bool) {
+	sensorID := mux.Vars(r)["sensor_id"]
+
+	sensor, err := database.GetSensorByID(r.Context(), apiService.DatabaseClient.DB, sensorID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Sensor not found"})
+			return database.Sensor{}, false
+		}
+
+		apiService.Error().Msgf("Error retrieving sensor %s: %s", sensorID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return database.Sensor{}, false
+	}
+
+	return sensor, true
+}
+
+// CreateUpdateSensorHandler returns a handler that changes the metadata, coordinates
+// and settings of a sensor, leaving fields that are not in the request unchanged
+func CreateUpdateSensorHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request api.UpdateSensorRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		var columns []string
+		if request.Name != nil {
+			sensor.Name = strings.TrimSpace(*request.Name)
+			columns = append(columns, "name")
+		}
+		if request.Location != nil {
+			sensor.Location = strings.TrimSpace(*request.Location)
+			columns = append(columns, "location")
+		}
+		if request.Latitude != nil {
+			sensor.Latitude = *request.Latitude
+			columns = append(columns, "latitude")
+		}
+		if request.Longitude != nil {
+			sensor.Longitude = *request.Longitude
+			columns = append(columns, "longitude")
+		}
+		if request.InstallationDate != nil {
+			sensor.InstallationDate = *request.InstallationDate
+			columns = append(columns, "installation_date")
+		}
+		if request.ReportingIntervalSeconds != nil {
+			sensor.ReportingIntervalSeconds = *request.ReportingIntervalSeconds
+			columns = append(columns, "reporting_interval_seconds")
+		}
+		if request.OfflineGraceSeconds != nil {
+			sensor.OfflineGraceSeconds = *request.OfflineGraceSeconds
+			columns = append(columns, "offline_grace_seconds")
+		}
+
+		err = validateSensor(sensor)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		if len(columns) > 0 {
+			err = sensor.Update(r.Context(), apiService.DatabaseClient.DB, columns...)
+			if err != nil {
+				apiService.Error().Msgf("Error updating sensor %s: %s", sensor.ID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to update sensor"})
+				return
+			}
+
+			apiService.Info().Msgf("Updated %v of sensor %s", columns, sensor.ID)
+		}
+
+		// a sensor that moved may have moved into another zone
+		if request.Latitude != nil || request.Longitude != nil {
+			placeSensorInZone(apiService, r, &sensor)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(sensorToAPI(sensor))
+	}
+}
+
+// CreateDecommissionSensorHandler returns a handler that takes a sensor out of service.
+// It is hidden from the sensor list and status and its live readings are refused, but
+// its history stays queryable. The request body with the reason is optional
+func CreateDecommissionSensorHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := r.Context().Value(UsernameContextKey).(string)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+
+		var request api.DecommissionSensorRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil && !errors.Is(err, io.EOF) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		reason := strings.TrimSpace(request.Reason)
+		if len(reason) > maxDecommissionReasonLength {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("reason must be at most %d characters", maxDecommissionReasonLength)})
+			return
+		}
+
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		if sensor.IsDecommissioned() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Sensor is already decommissioned"})
+			return
+		}
+
+		now := time.Now()
+		sensor.DecommissionedAt = &now
+		sensor.DecommissionReason = reason
+		sensor.DecommissionedBy = username
+
+		err = sensor.Update(r.Context(), apiService.DatabaseClient.DB, "decommissioned_at", "decommission_reason", "decommissioned_by")
+		if err != nil {
+			apiService.Error().Msgf("Error decommissioning sensor %s: %s", sensor.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to decommission sensor"})
+			return
+		}
+
+		apiService.Info().Msgf("Sensor %s decommissioned by %s, reason: %q", sensor.ID, username, reason)
+
+		apiService.publishWebhookEvent(r.Context(), database.WebhookEventSensorDecommissioned, api.WebhookSensorEvent{Sensor: sensorToAPI(sensor)})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Sensor decommissioned successfully"})
+	}
+}
+
+// CreateRecommissionSensorHandler returns a handler that puts a decommissioned sensor back in service
+func CreateRecommissionSensorHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		if !sensor.IsDecommissioned() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Sensor is not decommissioned"})
+			return
+		}
+
+		sensor.DecommissionedAt = nil
+		sensor.DecommissionReason = ""
+		sensor.DecommissionedBy = ""
+
+		err := sensor.Update(r.Context(), apiService.DatabaseClient.DB, "decommissioned_at", "decommission_reason", "decommissioned_by")
+		if err != nil {
+			apiService.Error().Msgf("Error recommissioning sensor %s: %s", sensor.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to recommission sensor"})
+			return
+		}
+
+		apiService.Info().Msgf("Sensor %s recommissioned", sensor.ID)
+
+		// zones may have changed while the sensor was out of service
+		placeSensorInZone(apiService, r, &sensor)
+
+		apiService.publishWebhookEvent(r.Context(), database.WebhookEventSensorRecommissioned, api.WebhookSensorEvent{Sensor: sensorToAPI(sensor)})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(sensorToAPI(sensor))
+	}
+}
+
+// CreatePurgeSensorHandler returns an admin handler that permanently deletes a sensor
+// and its history. Only decommissioned sensors can be purged so a sensor in service
+// can't lose its readings by mistake
+func CreatePurgeSensorHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		if !sensor.IsDecommissioned() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Sensor must be decommissioned before it is purged"})
+			return
+		}
+
+		err := database.DeleteSensor(r.Context(), apiService.DatabaseClient.DB, sensor.ID)
+		if err != nil {
+			apiService.Error().Msgf("Error purging sensor %s: %s", sensor.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to purge sensor"})
+			return
+		}
+
+		apiService.Warn().Msgf("Sensor %s and its history purged by %s", sensor.ID, username)
+
+		apiService.publishWebhookEvent(r.Context(), database.WebhookEventSensorDeleted, api.WebhookSensorEvent{Sensor: sensorToAPI(sensor), Purged: true})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Sensor purged successfully"})
+	}
+}
+
+// CreateReplaceSensorHandler returns a handler that records a new sensor installed in place
+// of the sensor in the path. The new sensor is created if needed and takes the old
+// sensor's name, location, coordinates and reporting settings, the old sensor is
+// decommissioned and the history of both can then be read together
+func CreateReplaceSensorHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := r.Context().Value(UsernameContextKey).(string)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Unauthorized"})
+			return
+		}
+
+		var request api.ReplaceSensorRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		sensorID := mux.Vars(r)["sensor_id"]
+		newSensorID := strings.TrimSpace(request.NewSensorID)
+		if newSensorID == "" || newSensorID == sensorID {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "new_sensor_id must be set to a different sensor"})
+			return
+		}
+
+		replacement, err := database.ReplaceSensor(r.Context(), apiService.DatabaseClient.DB, sensorID, newSensorID, username)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Sensor not found"})
+			case errors.Is(err, database.ErrorSensorAlreadyReplaced),
+				errors.Is(err, database.ErrorSensorReplacesAnother),
+				errors.Is(err, database.ErrorSensorDecommissioned):
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			default:
+				apiService.Error().Msgf("Error replacing sensor %s with %s: %s", sensorID, newSensorID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to replace sensor"})
+			}
+			return
+		}
+
+		apiService.Info().Msgf("Sensor %s replaced by %s by %s", sensorID, newSensorID, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(sensorToAPI(replacement))
+	}
+}
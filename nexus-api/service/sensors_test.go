@@ -0,0 +1,46 @@
+package service
+
+import (
+	"nexus-api/clients/database"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestValidateSensor(t *testing.T) {
+	valid := database.Sensor{
+		ID:                       "2CF7F1C0649007B3",
+		Name:                     "Plot Probe",
+		InstallationDate:         time.Now(),
+		SensorCoordinates:        database.SensorCoordinates{Latitude: -90, Longitude: 180},
+		ReportingIntervalSeconds: 600,
+		OfflineGraceSeconds:      0,
+	}
+	assert.NoError(t, validateSensor(valid))
+
+	for name, mutate := range map[string]func(*database.Sensor){
+		"blank name":          func(s *database.Sensor) { s.Name = "  " },
+		"long location":       func(s *database.Sensor) { s.Location = strings.Repeat("a", maxSensorTextLength+1) },
+		"no install date":     func(s *database.Sensor) { s.InstallationDate = time.Time{} },
+		"future install date": func(s *database.Sensor) { s.InstallationDate = time.Now().Add(48 * time.Hour) },
+		"latitude too high":   func(s *database.Sensor) { s.Latitude = 90.01 },
+		"longitude too low":   func(s *database.Sensor) { s.Longitude = -180.01 },
+		"no interval":         func(s *database.Sensor) { s.ReportingIntervalSeconds = 0 },
+		"negative grace":      func(s *database.Sensor) { s.OfflineGraceSeconds = -1 },
+		"interval too long":   func(s *database.Sensor) { s.ReportingIntervalSeconds = maxSensorIntervalSeconds + 1 },
+	} {
+		sensor := valid
+		mutate(&sensor)
+		assert.Error(t, validateSensor(sensor), name)
+	}
+}
+
+func TestUnitTestSensorOnlineThreshold(t *testing.T) {
+	sensor := database.Sensor{ReportingIntervalSeconds: 600, OfflineGraceSeconds: 300}
+	assert.Equal(t, 15*time.Minute, sensor.OnlineThreshold())
+
+	// sensors loaded without their settings use the default
+	assert.Equal(t, 24*time.Hour, database.Sensor{}.OnlineThreshold())
+}
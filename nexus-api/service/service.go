@@ -2,20 +2,51 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"nexus-api/clients/database"
 	"nexus-api/clients/database/schemas/postgres/migrations"
+	"nexus-api/clients/email"
+	"nexus-api/clients/webhook"
 	"nexus-api/logging"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// RetentionInterval is how often readings past their retention are rolled up and pruned
+const RetentionInterval = 1 * time.Hour
+
+// AlertEscalationInterval is how often unacknowledged critical alerts are checked for escalation
+const AlertEscalationInterval = 1 * time.Minute
+
+// DefaultAlertEscalationDelay is how long a critical alert can go unacknowledged before
+// it escalates when the config doesn't say
+const DefaultAlertEscalationDelay = 30 * time.Minute
+
+// WebhookDeliveryInterval is how often due webhook deliveries are sent
+const WebhookDeliveryInterval = 5 * time.Second
+
+// SensorOnlineCheckInterval is how often sensors are checked for going offline or coming back online
+const SensorOnlineCheckInterval = 1 * time.Minute
+
+// EmailSendInterval is how often due notification emails are sent
+const EmailSendInterval = 5 * time.Second
+
+// DailyDigestInterval is how often users are checked for their daily digest being due
+const DailyDigestInterval = 1 * time.Minute
+
 type APIConfig struct {
 	ServiceLogger  *logging.ServiceLogger
 	DatabaseConfig database.PostgresDatabaseConfig
 	APIPort        string
+	// AlertEscalationDelay is how long a critical alert can go unacknowledged before it
+	// escalates, DefaultAlertEscalationDelay when zero
+	AlertEscalationDelay time.Duration
+	// SMTP is the server notification emails are relayed through, no emails are sent
+	// when its Host is empty
+	SMTP email.Config
 }
 
 type APIService struct {
@@ -23,14 +54,52 @@ type APIService struct {
 	Ctx            context.Context
 	Config         APIConfig
 	DatabaseClient *database.PostgresClient
+	webhookClient  *webhook.Client
+	// emailClient is nil when no smtp server is configured
+	emailClient   *email.Client
+	emailRenderer *email.Renderer
 	*logging.ServiceLogger
 }
 
 func (as *APIService) Run(ctx context.Context) error {
+	// imports run in the background of the process that accepted them,
+	// any left unfinished by the last run of the service will never finish
+	interruptedImports, err := database.FailInterruptedImportJobs(ctx, as.DatabaseClient.DB)
+	if err != nil {
+		as.Warn().Msgf("error %s marking interrupted import jobs as failed", err)
+	} else if interruptedImports > 0 {
+		as.Info().Msgf("marked %d interrupted import jobs as failed", interruptedImports)
+	}
+
 	// run background routine to delete any expired cookies
 	go func() {
 		as.ExpireCookies(ctx)
 	}()
+	// run background routine to roll up and prune readings past their retention
+	go func() {
+		as.EnforceRetention(ctx)
+	}()
+	// run background routine to escalate critical alerts nobody acknowledged
+	go func() {
+		as.EscalateAlerts(ctx)
+	}()
+	// run background routine to send queued webhook deliveries
+	go func() {
+		as.DeliverWebhooks(ctx)
+	}()
+	// run background routine to announce sensors going offline or coming back online
+	go func() {
+		as.MonitorSensorsOnline(ctx)
+	}()
+	// run background routines to email users their notifications, if there is a server to send them with
+	if as.emailClient != nil {
+		go func() {
+			as.SendEmails(ctx)
+		}()
+		go func() {
+			as.SendDailyDigests(ctx)
+		}()
+	}
 	// run api service listening on the configured port
 	return as.server.ListenAndServe()
 }
@@ -45,6 +114,32 @@ func NewAPIService(ctx context.Context, config APIConfig) (APIService, error) {
 		return nexusAPI, fmt.Errorf("error %s creating database client with %+v", err, config.DatabaseConfig)
 	}
 
+	// create email renderer and client, notification emails are only sent when an smtp server is configured
+	emailRenderer, err := email.NewRenderer()
+	if err != nil {
+		return nexusAPI, fmt.Errorf("error %s parsing email templates", err)
+	}
+
+	var emailClient *email.Client
+	if config.SMTP.Host != "" {
+		emailClient, err = email.NewClient(config.SMTP)
+		if err != nil {
+			return nexusAPI, fmt.Errorf("error %s creating email client for %s", err, config.SMTP.Host)
+		}
+
+		// credentials can only be sent once the connection is encrypted, so a server
+		// that can't take them would otherwise only fail on the first notification
+		if config.SMTP.Username != "" {
+			err = emailClient.Check(ctx)
+			if errors.Is(err, email.ErrorUnencryptedAuth) {
+				return nexusAPI, fmt.Errorf("error %s checking smtp server %s", err, config.SMTP.Host)
+			}
+			if err != nil {
+				config.ServiceLogger.Warn().Msgf("error %s checking smtp server %s, notification emails will be retried", err, config.SMTP.Host)
+			}
+		}
+	}
+
 	// run migrations based on configuration
 	if config.DatabaseConfig.RunDatabaseMigrations {
 		go func() {
@@ -103,8 +198,49 @@ func NewAPIService(ctx context.Context, config APIConfig) (APIService, error) {
 	// Route to add a new sensor
 	router.HandleFunc("/sensors", CorsMiddleware(AuthMiddleware(CreateAddSensorHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
 
+	// Route to get the online state, last readings and battery level of every sensor
+	router.HandleFunc("/sensors/status", CorsMiddleware(AuthMiddleware(CreateGetSensorsStatusHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+
+	// Route to get the series of several sensors in one request
+	router.HandleFunc("/sensors/query", CorsMiddleware(AuthMiddleware(CreateQuerySensorsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+
+	// Route to change the settings of a sensor
+	router.HandleFunc("/sensors/{sensor_id}", CorsMiddleware(AuthMiddleware(CreateUpdateSensorHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPatch, http.MethodOptions)
+
 	// Route to delete a sensor
-	router.HandleFunc("/sensors/{sensor_id}", CorsMiddleware(AuthMiddleware(CreateDeleteSensorHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete, http.MethodOptions)
+	router.HandleFunc("/sensors/{sensor_id}", CorsMiddleware(AuthMiddleware(CreateDecommissionSensorHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete, http.MethodOptions)
+	router.HandleFunc("/sensors/{sensor_id}/replace", CorsMiddleware(AuthMiddleware(CreateReplaceSensorHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/sensors/{sensor_id}/recommission", CorsMiddleware(AuthMiddleware(CreateRecommissionSensorHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/sensors/{sensor_id}/coverage", CorsMiddleware(AuthMiddleware(CreateGetSensorCoverageHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/sensors/{sensor_id}/calibrations", CorsMiddleware(AuthMiddleware(CreateGetSensorCalibrationsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/sensors/{sensor_id}/calibrations", CorsMiddleware(AuthMiddleware(CreateCreateSensorCalibrationHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost)
+	router.HandleFunc("/sensors/{sensor_id}/calibrations/{calibration_id}", CorsMiddleware(AuthMiddleware(CreateDeleteSensorCalibrationHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete, http.MethodOptions)
+
+	// Zones sensors are placed in by their coordinates or by hand
+	router.HandleFunc("/zones", CorsMiddleware(AuthMiddleware(CreateGetZonesHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/zones", CorsMiddleware(AuthMiddleware(CreateCreateZoneHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost)
+	router.HandleFunc("/zones/{zone_id}", CorsMiddleware(AuthMiddleware(CreateGetZoneHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/zones/{zone_id}", CorsMiddleware(AuthMiddleware(CreateUpdateZoneHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPatch)
+	router.HandleFunc("/zones/{zone_id}", CorsMiddleware(AuthMiddleware(CreateDeleteZoneHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete)
+	router.HandleFunc("/zones/{zone_id}/series", CorsMiddleware(AuthMiddleware(CreateGetZoneSeriesHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/sensors/{sensor_id}/zone", CorsMiddleware(AuthMiddleware(CreateAssignSensorZoneHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPut, http.MethodOptions)
+
+	// Alert rules evaluated as readings are saved, the alerts they fired and what users did about them
+	router.HandleFunc("/alert_rules", CorsMiddleware(AuthMiddleware(CreateGetAlertRulesHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/alert_rules", CorsMiddleware(AuthMiddleware(CreateCreateAlertRuleHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost)
+	router.HandleFunc("/alert_rules/{rule_id}", CorsMiddleware(AuthMiddleware(CreateGetAlertRuleHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/alert_rules/{rule_id}", CorsMiddleware(AuthMiddleware(CreateUpdateAlertRuleHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPatch)
+	router.HandleFunc("/alert_rules/{rule_id}", CorsMiddleware(AuthMiddleware(CreateDeleteAlertRuleHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete)
+	router.HandleFunc("/alerts", CorsMiddleware(AuthMiddleware(CreateGetAlertsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/alerts/{alert_id}", CorsMiddleware(AuthMiddleware(CreateGetAlertHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/alerts/{alert_id}/events", CorsMiddleware(AuthMiddleware(CreateGetAlertEventsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/alerts/{alert_id}/acknowledge", CorsMiddleware(AuthMiddleware(CreateAcknowledgeAlertHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/alerts/{alert_id}/snooze", CorsMiddleware(AuthMiddleware(CreateSnoozeAlertHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/alerts/{alert_id}/assign", CorsMiddleware(AuthMiddleware(CreateAssignAlertHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/alerts/{alert_id}/resolve", CorsMiddleware(AuthMiddleware(CreateResolveAlertHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+
+	// Surfaces interpolated between the sensors' readings
+	router.HandleFunc("/heatmap", CorsMiddleware(AuthMiddleware(CreateGetHeatmapHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
 
 	// Drone image routes
 	router.HandleFunc("/drone_images", CorsMiddleware(AuthMiddleware(CreateGetDroneImagesHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
@@ -117,6 +253,32 @@ func NewAPIService(ctx context.Context, config APIConfig) (APIService, error) {
 	router.HandleFunc("/sensors/{sensor_id}/battery_data", CorsMiddleware(AuthMiddleware(CreateGetSensorBatteryDataHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
 	router.HandleFunc("/sensors/{sensor_id}/battery_data", CorsMiddleware(AuthMiddleware(CreateSetSensorBatteryDataHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost)
 
+	// Routes to forecast when batteries need swapping, for one sensor or the sensors due soon
+	router.HandleFunc("/sensors/{sensor_id}/battery_forecast", CorsMiddleware(AuthMiddleware(CreateGetSensorBatteryForecastHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/sensors/battery_forecast", CorsMiddleware(AuthMiddleware(CreateGetBatteryForecastsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+
+	// Measurement registry and readings of any registered measurement type
+	router.HandleFunc("/measurement_types", CorsMiddleware(AuthMiddleware(CreateGetMeasurementTypesHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/sensors/{sensor_id}/measurements/{measurement_type}", CorsMiddleware(AuthMiddleware(CreateGetSensorMeasurementsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/sensors/{sensor_id}/measurements/{measurement_type}", CorsMiddleware(AuthMiddleware(CreateSetSensorMeasurementsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost)
+
+	// Streaming export of readings across sensors
+	router.HandleFunc("/exports/sensors", CorsMiddleware(AuthMiddleware(CreateExportSensorDataHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+
+	// Historical sensor data imports
+	router.HandleFunc("/imports/sensor_data", CorsMiddleware(AuthMiddleware(CreateImportSensorDataHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/imports/sensor_data/{job_id}", CorsMiddleware(AuthMiddleware(CreateGetImportJobHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/imports/sensor_data/{job_id}/errors", CorsMiddleware(AuthMiddleware(CreateGetImportJobErrorsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+
+	// Notifications emailed to the current user
+	router.HandleFunc("/notifications/preferences", CorsMiddleware(AuthMiddleware(CreateGetNotificationPreferencesHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/notifications/preferences", CorsMiddleware(AuthMiddleware(CreateUpdateNotificationPreferencesHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPut)
+	router.HandleFunc("/notifications/subscriptions", CorsMiddleware(AuthMiddleware(CreateGetNotificationSubscriptionsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/notifications/subscriptions", CorsMiddleware(AuthMiddleware(CreateCreateNotificationSubscriptionHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost)
+	router.HandleFunc("/notifications/subscriptions/{subscription_id}", CorsMiddleware(AuthMiddleware(CreateDeleteNotificationSubscriptionHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete, http.MethodOptions)
+	router.HandleFunc("/notifications/emails", CorsMiddleware(AuthMiddleware(CreateGetEmailsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/notifications/test", CorsMiddleware(AuthMiddleware(CreateSendTestEmailHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+
 	// Admin routes
 	router.HandleFunc("/admin/users", CorsMiddleware(AdminMiddleware(CreateGetAllUsersHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
 	router.HandleFunc("/admin/users", CorsMiddleware(AdminMiddleware(CreateCreateUserHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
@@ -124,6 +286,20 @@ func NewAPIService(ctx context.Context, config APIConfig) (APIService, error) {
 	router.HandleFunc("/admin/users/{username}", CorsMiddleware(AdminMiddleware(CreateUpdateUserRoleHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPatch, http.MethodOptions)
 	router.HandleFunc("/admin/users/{username}/remove-admin", CorsMiddleware(AdminMiddleware(CreateRemoveAdminHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete, http.MethodOptions)
 	router.HandleFunc("/admin/users/{username}", CorsMiddleware(AdminMiddleware(CreateDeleteUserHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete, http.MethodOptions)
+	router.HandleFunc("/admin/sensors/coverage", CorsMiddleware(AdminMiddleware(CreateGetSensorsCoverageHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/admin/sensors/{sensor_id}", CorsMiddleware(AdminMiddleware(CreatePurgeSensorHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete, http.MethodOptions)
+	router.HandleFunc("/admin/quarantine", CorsMiddleware(AdminMiddleware(CreateGetQuarantinedReadingsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/admin/quarantine/{reading_id}/release", CorsMiddleware(AdminMiddleware(CreateReleaseQuarantinedReadingHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/admin/quarantine/{reading_id}/discard", CorsMiddleware(AdminMiddleware(CreateDiscardQuarantinedReadingHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/admin/measurement_types", CorsMiddleware(AdminMiddleware(CreateCreateMeasurementTypeHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/admin/measurement_types/{measurement_type}", CorsMiddleware(AdminMiddleware(CreateUpdateMeasurementTypeHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPatch, http.MethodOptions)
+	router.HandleFunc("/admin/webhooks", CorsMiddleware(AdminMiddleware(CreateGetWebhookSubscriptionsHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/admin/webhooks", CorsMiddleware(AdminMiddleware(CreateCreateWebhookSubscriptionHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/webhooks/{subscription_id}", CorsMiddleware(AdminMiddleware(CreateGetWebhookSubscriptionHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/admin/webhooks/{subscription_id}", CorsMiddleware(AdminMiddleware(CreateUpdateWebhookSubscriptionHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPatch)
+	router.HandleFunc("/admin/webhooks/{subscription_id}", CorsMiddleware(AdminMiddleware(CreateDeleteWebhookSubscriptionHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodDelete)
+	router.HandleFunc("/admin/webhooks/{subscription_id}/deliveries", CorsMiddleware(AdminMiddleware(CreateGetWebhookDeliveriesHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/admin/webhooks/{subscription_id}/deliveries/replay", CorsMiddleware(AdminMiddleware(CreateReplayWebhookDeliveriesHandler(&nexusAPI), &nexusAPI))).Methods(http.MethodPost, http.MethodOptions)
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%s", config.APIPort),
@@ -135,6 +311,9 @@ func NewAPIService(ctx context.Context, config APIConfig) (APIService, error) {
 		Ctx:            ctx,
 		Config:         config,
 		DatabaseClient: &databaseClient,
+		webhookClient:  webhook.NewClient(webhookTimeout),
+		emailClient:    emailClient,
+		emailRenderer:  emailRenderer,
 		ServiceLogger:  config.ServiceLogger,
 	}
 
@@ -160,3 +339,106 @@ func (as *APIService) ExpireCookies(ctx context.Context) {
 		}
 	}
 }
+
+// EnforceRetention applies the retention policy of every measurement type each
+// RetentionInterval, rolling up raw readings past their retention before they are deleted
+func (as *APIService) EnforceRetention(ctx context.Context) {
+	ticker := time.NewTicker(RetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			as.Trace().Msgf("EnforceRetention routine running %+v", t)
+			as.applyRetentionPolicies(ctx, t)
+		}
+	}
+}
+
+// EscalateAlerts escalates the critical alerts nobody acknowledged within the escalation
+// delay to the escalation recipients of their rule each AlertEscalationInterval
+func (as *APIService) EscalateAlerts(ctx context.Context) {
+	ticker := time.NewTicker(AlertEscalationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			as.Trace().Msgf("EscalateAlerts routine running %+v", t)
+			as.escalateAlerts(ctx, t)
+		}
+	}
+}
+
+// DeliverWebhooks sends the webhook deliveries that are due each WebhookDeliveryInterval,
+// retrying the ones endpoints refused with exponential backoff
+func (as *APIService) DeliverWebhooks(ctx context.Context) {
+	ticker := time.NewTicker(WebhookDeliveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			as.Trace().Msgf("DeliverWebhooks routine running %+v", t)
+			as.deliverWebhooks(ctx, t)
+		}
+	}
+}
+
+// MonitorSensorsOnline announces the sensors that went offline or came back online to
+// webhook subscriptions, and emails the users subscribed to them going offline, each
+// SensorOnlineCheckInterval
+func (as *APIService) MonitorSensorsOnline(ctx context.Context) {
+	ticker := time.NewTicker(SensorOnlineCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			as.Trace().Msgf("MonitorSensorsOnline routine running %+v", t)
+			as.checkSensorsOnline(ctx, t)
+		}
+	}
+}
+
+// SendEmails sends the notification emails that are due each EmailSendInterval, retrying
+// the ones the smtp server refused for now with exponential backoff
+func (as *APIService) SendEmails(ctx context.Context) {
+	ticker := time.NewTicker(EmailSendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			as.Trace().Msgf("SendEmails routine running %+v", t)
+			as.sendEmails(ctx, t)
+		}
+	}
+}
+
+// SendDailyDigests queues the daily digest of each subscribed user once a day at their
+// digest hour, checking every DailyDigestInterval
+func (as *APIService) SendDailyDigests(ctx context.Context) {
+	ticker := time.NewTicker(DailyDigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			as.Trace().Msgf("SendDailyDigests routine running %+v", t)
+			as.queueDailyDigests(ctx, t)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"nexus-api/api"
+	"strings"
+)
+
+// unitConversion converts values from the canonical unit of a measurement type to Unit
+type unitConversion struct {
+	Unit    string
+	Convert func(value float64) float64
+}
+
+// unitDimension groups the units that can be converted into each other, each
+// unit that can be asked for converts the canonical units of its dimension
+type unitDimension struct {
+	Name  string
+	Units map[string]map[string]unitConversion
+}
+
+var (
+	temperatureDimension = unitDimension{
+		Name: "temperature",
+		Units: map[string]map[string]unitConversion{
+			"celsius": {
+				api.UnitFahrenheit: {api.UnitCelsius, func(value float64) float64 { return (value - 32) * 5 / 9 }},
+			},
+			"fahrenheit": {
+				api.UnitCelsius: {api.UnitFahrenheit, func(value float64) float64 { return value*9/5 + 32 }},
+			},
+		},
+	}
+	proportionDimension = unitDimension{
+		Name: "proportion",
+		Units: map[string]map[string]unitConversion{
+			"percent": {
+				api.UnitFraction: {api.UnitPercent, func(value float64) float64 { return value * 100 }},
+			},
+			"fraction": {
+				api.UnitPercent: {api.UnitFraction, func(value float64) float64 { return value / 100 }},
+			},
+		},
+	}
+	unitDimensions = []unitDimension{temperatureDimension, proportionDimension}
+)
+
+// unitConverter converts values from the canonical units of measurement types
+// to the units that were asked for, keyed by canonical unit
+type unitConverter map[string]unitConversion
+
+// parseUnits reads the units readings are converted to, given as names such as
+// fahrenheit or fraction. At most one unit of each dimension can be asked for
+func parseUnits(names []string) (unitConverter, error) {
+	converter := make(unitConverter)
+	asked := make(map[string]string)
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, dimension := range unitDimensions {
+			conversions, ok := dimension.Units[name]
+			if !ok {
+				continue
+			}
+			found = true
+
+			if previous, ok := asked[dimension.Name]; ok && previous != name {
+				return nil, fmt.Errorf("units %s and %s are both %s units, ask for one of them", previous, name, dimension.Name)
+			}
+			asked[dimension.Name] = name
+
+			for canonical, conversion := range conversions {
+				converter[canonical] = conversion
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown unit %q, units must be celsius, fahrenheit, percent or fraction", name)
+		}
+	}
+
+	return converter, nil
+}
+
+// parseUnitsParameter reads the comma separated units query parameter
+func parseUnitsParameter(raw string) (unitConverter, error) {
+	if raw == "" {
+		return unitConverter{}, nil
+	}
+
+	return parseUnits(strings.Split(raw, ","))
+}
+
+// Unit returns the unit values in the canonical unit are converted to
+func (c unitConverter) Unit(canonical string) string {
+	if conversion, ok := c[canonical]; ok {
+		return conversion.Unit
+	}
+
+	return canonical
+}
+
+// Value converts a value in the canonical unit
+func (c unitConverter) Value(canonical string, value float64) float64 {
+	if conversion, ok := c[canonical]; ok {
+		return conversion.Convert(value)
+	}
+
+	return value
+}
+
+// Bucket converts the min, max and average of a bucket of values in the canonical unit,
+// the conversions keep the order of values so min and max stay in place
+func (c unitConverter) Bucket(canonical string, bucket api.SensorDataBucket) api.SensorDataBucket {
+	conversion, ok := c[canonical]
+	if !ok {
+		return bucket
+	}
+
+	bucket.Min = conversion.Convert(bucket.Min)
+	bucket.Max = conversion.Convert(bucket.Max)
+	bucket.Avg = conversion.Convert(bucket.Avg)
+
+	return bucket
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"nexus-api/api"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestParseUnitsConvertsCanonicalUnits(t *testing.T) {
+	// execute test
+	converter, err := parseUnitsParameter("Fahrenheit, fraction")
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, api.UnitFahrenheit, converter.Unit(api.UnitCelsius))
+	assert.InDelta(t, 68.0, converter.Value(api.UnitCelsius, 20), 1e-9)
+	assert.Equal(t, api.UnitFraction, converter.Unit(api.UnitPercent))
+	assert.InDelta(t, 0.35, converter.Value(api.UnitPercent, 35), 1e-9)
+	assert.Equal(t, "pH", converter.Unit("pH"), "units without a conversion are left alone")
+	assert.Equal(t, 6.5, converter.Value("pH", 6.5))
+
+	bucket := converter.Bucket(api.UnitCelsius, api.SensorDataBucket{BucketStart: time.Now(), Min: -40, Max: 100, Avg: 0, Count: 3})
+	assert.InDelta(t, -40.0, bucket.Min, 1e-9)
+	assert.InDelta(t, 212.0, bucket.Max, 1e-9)
+	assert.InDelta(t, 32.0, bucket.Avg, 1e-9)
+	assert.Equal(t, 3, bucket.Count)
+}
+
+func TestUnitTestParseUnitsKeepsCanonicalUnitsByDefault(t *testing.T) {
+	for _, raw := range []string{"", "celsius", "celsius,percent"} {
+		converter, err := parseUnitsParameter(raw)
+
+		assert.NoError(t, err, raw)
+		assert.Equal(t, api.UnitCelsius, converter.Unit(api.UnitCelsius), raw)
+		assert.Equal(t, 21.5, converter.Value(api.UnitCelsius, 21.5), raw)
+		assert.Equal(t, 40.0, converter.Value(api.UnitPercent, 40), raw)
+	}
+}
+
+func TestUnitTestParseUnitsRejectsInvalidUnits(t *testing.T) {
+	for _, raw := range []string{"kelvin", "celsius,fahrenheit", "fraction,percent"} {
+		_, err := parseUnitsParameter(raw)
+
+		assert.Error(t, err, "expected error for %s", raw)
+	}
+}
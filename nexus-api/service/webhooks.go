@@ -0,0 +1,615 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/clients/webhook"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// webhookTimeout is how long an endpoint has to answer a delivery
+	webhookTimeout = 10 * time.Second
+	// claimed deliveries are left alone by other instances of the service for the lease,
+	// long enough to send a whole batch to endpoints that time out
+	webhookDeliveryLease     = 5 * time.Minute
+	webhookDeliveryBatchSize = 20
+	// a delivery fails once it has been refused maxWebhookAttempts times, the delay before
+	// each retry doubles from webhookRetryBaseDelay up to maxWebhookRetryDelay
+	maxWebhookAttempts    = 8
+	webhookRetryBaseDelay = 30 * time.Second
+	maxWebhookRetryDelay  = time.Hour
+	// maxWebhookErrorLength bounds the error kept for the last attempt of a delivery
+	maxWebhookErrorLength = 1000
+
+	minWebhookSecretLength      = 16
+	maxWebhookURLLength         = 2048
+	maxWebhookDescriptionLength = 255
+
+	defaultWebhookDeliveriesPageSize = 100
+	maxWebhookDeliveriesPageSize     = 1000
+)
+
+// recordWebhookOutcome updates a delivery with the outcome of an attempt to send it at now,
+// scheduling a retry if it was refused and has attempts left
+func recordWebhookOutcome(delivery *database.WebhookDelivery, result webhook.Result, err error, now time.Time) {
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.LastStatusCode = result.StatusCode
+	delivery.LastResponse = result.Body
+
+	if err == nil {
+		delivery.Status = database.WebhookDeliveryStatusDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if len(delivery.LastError) > maxWebhookErrorLength {
+		delivery.LastError = delivery.LastError[:maxWebhookErrorLength]
+	}
+
+	if delivery.Attempts >= maxWebhookAttempts {
+		delivery.Status = database.WebhookDeliveryStatusFailed
+		return
+	}
+	delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts, webhookRetryBaseDelay, maxWebhookRetryDelay))
+}
+
+// newWebhookEvent wraps the data of an event of eventType that happened at now in the
+// body every subscription to the type is sent
+func newWebhookEvent(eventType string, data interface{}, now time.Time) (database.WebhookEvent, error) {
+	event := database.WebhookEvent{ID: uuid.New(), Type: eventType}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return event, err
+	}
+
+	event.Payload, err = json.Marshal(api.WebhookEvent{
+		ID:        event.ID.String(),
+		Type:      eventType,
+		CreatedAt: now.UTC(),
+		Data:      encoded,
+	})
+
+	return event, err
+}
+
+// addWebhookEvent queues deliveries of an event to the subscriptions to its type, db can
+// be the transaction saving what the event announces
+func addWebhookEvent(ctx context.Context, db bun.IDB, eventType string, data interface{}, now time.Time) error {
+	event, err := newWebhookEvent(eventType, data, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.AddWebhookDeliveries(ctx, db, event)
+
+	return err
+}
+
+// publishWebhookEvent queues deliveries of an event that just happened. What it announces
+// is already saved, so errors are only logged
+func (as *APIService) publishWebhookEvent(ctx context.Context, eventType string, data interface{}) {
+	err := addWebhookEvent(ctx, as.DatabaseClient.DB, eventType, data, time.Now())
+	if err != nil {
+		as.Error().Msgf("error %s queueing %s webhook deliveries", err, eventType)
+	}
+}
+
+// deliverWebhooks sends the deliveries due at now in batches until none are left
+func (as *APIService) deliverWebhooks(ctx context.Context, now time.Time) {
+	err := sendInBatches(ctx, webhookDeliveryBatchSize, func(ctx context.Context) (int, error) {
+		deliveries, subscriptions, err := database.ClaimWebhookDeliveries(ctx, as.DatabaseClient.DB, now, webhookDeliveryLease, webhookDeliveryBatchSize)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, delivery := range deliveries {
+			subscription := subscriptions[delivery.SubscriptionID]
+			result, err := as.webhookClient.Send(ctx, webhook.Delivery{
+				ID:        delivery.ID,
+				EventType: delivery.EventType,
+				URL:       subscription.URL,
+				Secret:    subscription.Secret,
+				Body:      delivery.Payload,
+			}, time.Now())
+			recordWebhookOutcome(&delivery, result, err, time.Now())
+			if err != nil {
+				as.Warn().Msgf("webhook delivery %d of %s to subscription %s failed attempt %d: %s", delivery.ID, delivery.EventType, subscription.ID, delivery.Attempts, err)
+			}
+
+			err = database.RecordWebhookAttempt(ctx, as.DatabaseClient.DB, &delivery)
+			if err != nil {
+				as.Error().Msgf("error %s recording attempt of webhook delivery %d", err, delivery.ID)
+			}
+		}
+
+		return len(deliveries), nil
+	})
+	if err != nil {
+		as.Error().Msgf("error %s claiming due webhook deliveries", err)
+	}
+}
+
+// checkSensorsOnline announces the sensors that went offline or came back online since
+// they were last checked, emailing the users subscribed to the ones that went offline
+func (as *APIService) checkSensorsOnline(ctx context.Context, now time.Time) {
+	err := database.UpdateSensorsReportedOnline(ctx, as.DatabaseClient.DB, now, func(ctx context.Context, tx bun.Tx, sensor database.Sensor) error {
+		eventType := database.WebhookEventSensorOffline
+		if *sensor.ReportedOnline {
+			eventType = database.WebhookEventSensorOnline
+		}
+		as.Info().Msgf("Sensor %s last seen at %s, announcing %s", sensor.ID, sensor.LastSeenAt, eventType)
+
+		if !*sensor.ReportedOnline && as.emailClient != nil {
+			err := as.queueSensorOfflineEmails(ctx, tx, sensor, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		return addWebhookEvent(ctx, tx, eventType, api.WebhookSensorEvent{Sensor: sensorToAPI(sensor)}, now)
+	})
+	if err != nil {
+		as.Error().Msgf("error %s checking which sensors went offline or came back online", err)
+	}
+}
+
+// generateWebhookSecret returns a random secret for a subscription created without one
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// validateWebhookURL makes sure deliveries can be posted to an endpoint
+func validateWebhookURL(raw string) error {
+	if len(raw) > maxWebhookURLLength {
+		return fmt.Errorf("url must be at most %d characters", maxWebhookURLLength)
+	}
+
+	endpoint, err := url.Parse(raw)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https url")
+	}
+
+	return nil
+}
+
+// validateWebhookSecret makes sure a secret chosen by an admin is hard enough to guess
+func validateWebhookSecret(secret string) error {
+	if len(secret) < minWebhookSecretLength {
+		return fmt.Errorf("secret must be at least %d characters", minWebhookSecretLength)
+	}
+
+	return nil
+}
+
+// parseWebhookEventTypes validates the event types of a subscription, dropping repeats
+func parseWebhookEventTypes(eventTypes []string) ([]string, error) {
+	known := make(map[string]bool, len(database.WebhookEventTypes))
+	for _, eventType := range database.WebhookEventTypes {
+		known[eventType] = true
+	}
+
+	parsed := make([]string, 0, len(eventTypes))
+	seen := make(map[string]bool)
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !known[eventType] {
+			return nil, fmt.Errorf("unknown event type %q, must be one of %s", eventType, strings.Join(database.WebhookEventTypes, ", "))
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			parsed = append(parsed, eventType)
+		}
+	}
+
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("event_types must name at least one event type")
+	}
+
+	return parsed, nil
+}
+
+// webhookSubscriptionToAPI converts a subscription to its api representation, the secret
+// is only included when it was just generated or set
+func webhookSubscriptionToAPI(subscription database.WebhookSubscription, withSecret bool) api.WebhookSubscription {
+	response := api.WebhookSubscription{
+		ID:          subscription.ID.String(),
+		URL:         subscription.URL,
+		EventTypes:  subscription.EventTypes,
+		Description: subscription.Description,
+		Enabled:     subscription.Enabled,
+		CreatedBy:   subscription.CreatedBy,
+		CreatedAt:   subscription.CreatedAt,
+		UpdatedAt:   subscription.UpdatedAt,
+	}
+	if withSecret {
+		response.Secret = subscription.Secret
+	}
+
+	return response
+}
+
+// webhookDeliveryToAPI converts a delivery to its api representation
+func webhookDeliveryToAPI(delivery database.WebhookDelivery) api.WebhookDelivery {
+	response := api.WebhookDelivery{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID.String(),
+		EventID:        delivery.EventID.String(),
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		Replays:        delivery.Replays,
+		LastAttemptAt:  delivery.LastAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		LastResponse:   delivery.LastResponse,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+	if delivery.Status == database.WebhookDeliveryStatusPending {
+		nextAttemptAt := delivery.NextAttemptAt
+		response.NextAttemptAt = &nextAttemptAt
+	}
+
+	return response
+}
+
+// getWebhookSubscription looks up the subscription in the path, answering the request
+// directly and returning false if it doesn't exist or can't be retrieved
+func getWebhookSubscription(apiService *APIService, w http.ResponseWriter, r *http.Request) (database.WebhookSubscription, bool) {
+	subscriptionID, err := uuid.Parse(mux.Vars(r)["subscription_id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Webhook subscription not found"})
+		return database.WebhookSubscription{}, false
+	}
+
+	subscription, err := database.GetWebhookSubscription(r.Context(), apiService.DatabaseClient.DB, subscriptionID)
+	if err != nil {
+		if errors.Is(err, database.ErrorNoWebhookSubscription) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Webhook subscription not found"})
+			return database.WebhookSubscription{}, false
+		}
+
+		apiService.Error().Msgf("Error retrieving webhook subscription %s: %s", subscriptionID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return database.WebhookSubscription{}, false
+	}
+
+	return subscription, true
+}
+
+// CreateGetWebhookSubscriptionsHandler returns a handler that lists every webhook subscription (admin only)
+func CreateGetWebhookSubscriptionsHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subscriptions, err := database.GetWebhookSubscriptions(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving webhook subscriptions: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetWebhookSubscriptionsResponse{
+			Subscriptions: make([]api.WebhookSubscription, 0, len(subscriptions)),
+		}
+		for _, subscription := range subscriptions {
+			response.Subscriptions = append(response.Subscriptions, webhookSubscriptionToAPI(subscription, false))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateGetWebhookSubscriptionHandler returns a handler that retrieves a webhook subscription (admin only)
+func CreateGetWebhookSubscriptionHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subscription, ok := getWebhookSubscription(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(webhookSubscriptionToAPI(subscription, false))
+	}
+}
+
+// CreateCreateWebhookSubscriptionHandler returns a handler that registers an endpoint to be
+// sent events of the given types, responding with the subscription and the secret its
+// deliveries are signed with (admin only)
+func CreateCreateWebhookSubscriptionHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.CreateWebhookSubscriptionRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		subscription := database.WebhookSubscription{
+			URL:         strings.TrimSpace(request.URL),
+			Secret:      request.Secret,
+			Description: strings.TrimSpace(request.Description),
+			Enabled:     request.Enabled == nil || *request.Enabled,
+			CreatedBy:   username,
+		}
+
+		err = validateWebhookURL(subscription.URL)
+		if err == nil {
+			subscription.EventTypes, err = parseWebhookEventTypes(request.EventTypes)
+		}
+		if err == nil && subscription.Secret != "" {
+			err = validateWebhookSecret(subscription.Secret)
+		}
+		if err == nil && len(subscription.Description) > maxWebhookDescriptionLength {
+			err = fmt.Errorf("description must be at most %d characters", maxWebhookDescriptionLength)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		if subscription.Secret == "" {
+			subscription.Secret, err = generateWebhookSecret()
+			if err != nil {
+				apiService.Error().Msgf("Error generating webhook secret: %s", err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+		}
+
+		err = subscription.Save(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error saving webhook subscription to %s: %s", subscription.URL, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Webhook subscription %s to %s for %s added by %s", subscription.ID, subscription.URL, strings.Join(subscription.EventTypes, ", "), username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(webhookSubscriptionToAPI(subscription, true))
+	}
+}
+
+// CreateUpdateWebhookSubscriptionHandler returns a handler that changes the fields of a
+// webhook subscription that are set in the request (admin only)
+func CreateUpdateWebhookSubscriptionHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.UpdateWebhookSubscriptionRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		subscription, ok := getWebhookSubscription(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		if request.URL != nil {
+			subscription.URL = strings.TrimSpace(*request.URL)
+			err = validateWebhookURL(subscription.URL)
+		}
+		if err == nil && request.EventTypes != nil {
+			subscription.EventTypes, err = parseWebhookEventTypes(request.EventTypes)
+		}
+		if err == nil && request.Secret != nil {
+			subscription.Secret = *request.Secret
+			err = validateWebhookSecret(subscription.Secret)
+		}
+		if err == nil && request.Description != nil {
+			subscription.Description = strings.TrimSpace(*request.Description)
+			if len(subscription.Description) > maxWebhookDescriptionLength {
+				err = fmt.Errorf("description must be at most %d characters", maxWebhookDescriptionLength)
+			}
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if request.Enabled != nil {
+			subscription.Enabled = *request.Enabled
+		}
+
+		err = subscription.Update(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoWebhookSubscription) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Webhook subscription not found"})
+				return
+			}
+
+			apiService.Error().Msgf("Error updating webhook subscription %s: %s", subscription.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Webhook subscription %s updated by %s", subscription.ID, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(webhookSubscriptionToAPI(subscription, request.Secret != nil))
+	}
+}
+
+// CreateDeleteWebhookSubscriptionHandler returns a handler that deletes a webhook
+// subscription along with its delivery log (admin only)
+func CreateDeleteWebhookSubscriptionHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		subscription, ok := getWebhookSubscription(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		err := database.DeleteWebhookSubscription(r.Context(), apiService.DatabaseClient.DB, subscription.ID)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoWebhookSubscription) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Webhook subscription not found"})
+				return
+			}
+
+			apiService.Error().Msgf("Error deleting webhook subscription %s: %s", subscription.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Webhook subscription %s to %s deleted by %s", subscription.ID, subscription.URL, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Webhook subscription deleted successfully"})
+	}
+}
+
+// CreateGetWebhookDeliveriesHandler returns a handler that pages through the delivery log
+// of a webhook subscription newest first (admin only)
+func CreateGetWebhookDeliveriesHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseWebhookDeliveryFilter(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		subscription, ok := getWebhookSubscription(apiService, w, r)
+		if !ok {
+			return
+		}
+		filter.SubscriptionID = subscription.ID
+
+		deliveries, err := database.GetWebhookDeliveries(r.Context(), apiService.DatabaseClient.DB, filter)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving deliveries of webhook subscription %s: %s", subscription.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetWebhookDeliveriesResponse{
+			Deliveries: make([]api.WebhookDelivery, 0, len(deliveries)),
+		}
+
+		if len(deliveries) > filter.Limit {
+			deliveries = deliveries[:filter.Limit]
+			nextBeforeID := deliveries[filter.Limit-1].ID
+			response.NextBeforeID = &nextBeforeID
+		}
+
+		for _, delivery := range deliveries {
+			response.Deliveries = append(response.Deliveries, webhookDeliveryToAPI(delivery))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateReplayWebhookDeliveriesHandler returns a handler that queues failed deliveries of
+// a webhook subscription to be sent again with a fresh set of attempts, every failed
+// delivery unless the request names some. The request body is optional (admin only)
+func CreateReplayWebhookDeliveriesHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.ReplayWebhookDeliveriesRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil && !errors.Is(err, io.EOF) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+		if len(request.DeliveryIDs) > maxWebhookDeliveriesPageSize {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("at most %d deliveries can be replayed at once", maxWebhookDeliveriesPageSize)})
+			return
+		}
+
+		subscription, ok := getWebhookSubscription(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		replayed, err := database.ReplayWebhookDeliveries(r.Context(), apiService.DatabaseClient.DB, subscription.ID, request.DeliveryIDs, time.Now())
+		if err != nil {
+			apiService.Error().Msgf("Error replaying deliveries of webhook subscription %s: %s", subscription.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("%d failed deliveries of webhook subscription %s replayed by %s", replayed, subscription.ID, username)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api.ReplayWebhookDeliveriesResponse{Replayed: replayed})
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/clients/webhook"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitTestRecordWebhookOutcome(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	refusal := errors.New("unexpected response status 503")
+
+	// execute test and assert results
+	delivery := database.WebhookDelivery{Status: database.WebhookDeliveryStatusPending}
+	recordWebhookOutcome(&delivery, webhook.Result{StatusCode: http.StatusServiceUnavailable, Body: "busy"}, refusal, now)
+	assert.Equal(t, database.WebhookDeliveryStatusPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, now.Add(30*time.Second), delivery.NextAttemptAt)
+	assert.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+	assert.Equal(t, "busy", delivery.LastResponse)
+	assert.Equal(t, refusal.Error(), delivery.LastError)
+
+	recordWebhookOutcome(&delivery, webhook.Result{StatusCode: http.StatusOK}, nil, now.Add(time.Minute))
+	assert.Equal(t, database.WebhookDeliveryStatusDelivered, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Equal(t, now.Add(time.Minute), *delivery.DeliveredAt)
+	assert.Empty(t, delivery.LastError)
+
+	// the last attempt fails the delivery instead of scheduling another
+	exhausted := database.WebhookDelivery{Status: database.WebhookDeliveryStatusPending, Attempts: maxWebhookAttempts - 1}
+	recordWebhookOutcome(&exhausted, webhook.Result{}, errors.New("connection refused"), now)
+	assert.Equal(t, database.WebhookDeliveryStatusFailed, exhausted.Status)
+	assert.Equal(t, maxWebhookAttempts, exhausted.Attempts)
+	assert.Zero(t, exhausted.LastStatusCode)
+	assert.Nil(t, exhausted.DeliveredAt)
+}
+
+func TestUnitTestNewWebhookEventWrapsData(t *testing.T) {
+	// setup test data
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.FixedZone("EAT", 3*60*60))
+
+	// execute test
+	event, err := newWebhookEvent(database.WebhookEventUserCreated, api.WebhookUserCreated{Username: "alice", Role: "user", CreatedBy: "admin"}, now)
+
+	// assert results
+	assert.NoError(t, err)
+	assert.Equal(t, database.WebhookEventUserCreated, event.Type)
+
+	var body api.WebhookEvent
+	assert.NoError(t, json.Unmarshal(event.Payload, &body))
+	assert.Equal(t, event.ID.String(), body.ID)
+	assert.Equal(t, database.WebhookEventUserCreated, body.Type)
+	assert.Equal(t, now.UTC(), body.CreatedAt)
+	assert.JSONEq(t, `{"username":"alice","role":"user","created_by":"admin"}`, string(body.Data))
+}
+
+func TestUnitTestValidateWebhookSubscription(t *testing.T) {
+	// execute test and assert results
+	assert.NoError(t, validateWebhookURL("https://erp.example.com/hooks/nexus?source=farm"))
+	assert.NoError(t, validateWebhookURL("http://10.0.0.12:9000/irrigation"))
+	for _, invalid := range []string{"", "erp.example.com/hooks", "ftp://erp.example.com", "https://", "/hooks/nexus"} {
+		assert.Error(t, validateWebhookURL(invalid), "expected error for %q", invalid)
+	}
+
+	assert.NoError(t, validateWebhookSecret("0123456789abcdef"))
+	assert.Error(t, validateWebhookSecret("too-short"))
+
+	eventTypes, err := parseWebhookEventTypes([]string{" sensor.offline", "sensor.online", "sensor.offline"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sensor.offline", "sensor.online"}, eventTypes)
+
+	_, err = parseWebhookEventTypes([]string{"sensor.renamed"})
+	assert.Error(t, err)
+	_, err = parseWebhookEventTypes(nil)
+	assert.Error(t, err)
+}
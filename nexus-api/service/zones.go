@@ -0,0 +1,644 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"nexus-api/geo"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// maxZoneTextLength bounds the name and crop type of a zone
+	maxZoneTextLength = 255
+	// a zone's series cover the week before now unless a range is given
+	defaultZoneSeriesRange = 7 * 24 * time.Hour
+	maxZoneSeriesRange     = 366 * 24 * time.Hour
+)
+
+// zoneBoundary is the parsed boundary of a zone
+type zoneBoundary struct {
+	ZoneID  uuid.UUID
+	Polygon geo.Polygon
+}
+
+// parseZoneBoundaries parses the boundaries of the zones, smallest first so a sensor
+// in zones that overlap is placed in the most specific of them
+func parseZoneBoundaries(zones []database.Zone) ([]zoneBoundary, error) {
+	boundaries := make([]zoneBoundary, 0, len(zones))
+	for _, zone := range zones {
+		polygon, err := geo.ParsePolygon(zone.Boundary)
+		if err != nil {
+			return nil, fmt.Errorf("zone %s: %w", zone.ID, err)
+		}
+		boundaries = append(boundaries, zoneBoundary{ZoneID: zone.ID, Polygon: polygon})
+	}
+
+	sort.SliceStable(boundaries, func(i, j int) bool {
+		return boundaries[i].Polygon.Area() < boundaries[j].Polygon.Area()
+	})
+
+	return boundaries, nil
+}
+
+// automaticSensorZones works out the zones of the sensors in service that weren't assigned
+// to a zone by hand from their coordinates, returning the sensors whose zone changed
+// mapped to their new zone, nil for none. Sensors at 0,0 haven't been placed yet
+func automaticSensorZones(boundaries []zoneBoundary, sensors []database.Sensor) map[string]*uuid.UUID {
+	changes := make(map[string]*uuid.UUID)
+	for _, sensor := range sensors {
+		if sensor.ZoneAssignedManually || sensor.IsDecommissioned() {
+			continue
+		}
+
+		var zoneID *uuid.UUID
+		if sensorPlaced(sensor) {
+			point := geo.Point{Longitude: sensor.Longitude, Latitude: sensor.Latitude}
+			for _, boundary := range boundaries {
+				if boundary.Polygon.Contains(point) {
+					zoneID = &boundary.ZoneID
+					break
+				}
+			}
+		}
+
+		if zoneID == nil && sensor.ZoneID == nil {
+			continue
+		}
+		if zoneID != nil && sensor.ZoneID != nil && *zoneID == *sensor.ZoneID {
+			continue
+		}
+		changes[sensor.ID] = zoneID
+	}
+
+	return changes
+}
+
+// updateSensorZones moves the sensors whose zone follows their coordinates to the zone
+// they are in, every such sensor when sensors is nil, returning the sensors moved
+func updateSensorZones(ctx context.Context, db *bun.DB, sensors []database.Sensor) (map[string]*uuid.UUID, error) {
+	zones, err := database.GetZones(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	boundaries, err := parseZoneBoundaries(zones)
+	if err != nil {
+		return nil, err
+	}
+
+	if sensors == nil {
+		sensors, err = database.GetAutomaticallyZonedSensors(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changes := automaticSensorZones(boundaries, sensors)
+	err = database.UpdateAutomaticSensorZones(ctx, db, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// placeSensorInZone moves a sensor whose zone follows its coordinates to the zone it
+// is in, failing to do so is logged and leaves the sensor where it was
+func placeSensorInZone(apiService *APIService, r *http.Request, sensor *database.Sensor) {
+	moved, err := updateSensorZones(r.Context(), apiService.DatabaseClient.DB, []database.Sensor{*sensor})
+	if err != nil {
+		apiService.Error().Msgf("Error placing sensor %s in the zone its coordinates are in: %s", sensor.ID, err)
+		return
+	}
+
+	if zoneID, ok := moved[sensor.ID]; ok {
+		sensor.ZoneID = zoneID
+		apiService.Info().Msgf("Sensor %s moved to zone %v by its coordinates", sensor.ID, zoneID)
+	}
+}
+
+// combineZoneBuckets combines the buckets of a zone's sensors, each sensor having at most
+// one bucket starting at any time, into the zone's buckets in time order. The mean is
+// taken over the sensors' averages so sensors that report more often don't outweigh the rest
+func combineZoneBuckets(buckets []api.SensorDataBucket) []api.ZoneDataBucket {
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return buckets[i].BucketStart.Before(buckets[j].BucketStart)
+	})
+
+	combined := []api.ZoneDataBucket{}
+	for _, bucket := range buckets {
+		if len(combined) == 0 || !combined[len(combined)-1].BucketStart.Equal(bucket.BucketStart) {
+			combined = append(combined, api.ZoneDataBucket{
+				BucketStart: bucket.BucketStart,
+				Mean:        bucket.Avg,
+				Min:         bucket.Min,
+				Max:         bucket.Max,
+				SensorCount: 1,
+				Count:       bucket.Count,
+			})
+			continue
+		}
+
+		last := &combined[len(combined)-1]
+		last.Mean = (last.Mean*float64(last.SensorCount) + bucket.Avg) / float64(last.SensorCount+1)
+		last.Min = math.Min(last.Min, bucket.Min)
+		last.Max = math.Max(last.Max, bucket.Max)
+		last.SensorCount++
+		last.Count += bucket.Count
+	}
+
+	return combined
+}
+
+// zoneToAPI converts a stored zone and the ids of its sensors to its api representation
+func zoneToAPI(zone database.Zone, sensorIDs []string) api.Zone {
+	if sensorIDs == nil {
+		sensorIDs = []string{}
+	}
+
+	return api.Zone{
+		ID:        zone.ID.String(),
+		Name:      zone.Name,
+		CropType:  zone.CropType,
+		Boundary:  zone.Boundary,
+		SensorIDs: sensorIDs,
+		CreatedBy: zone.CreatedBy,
+		CreatedAt: zone.CreatedAt,
+		UpdatedAt: zone.UpdatedAt,
+	}
+}
+
+// validateZone checks the name and crop type of a zone and parses its boundary,
+// returning the boundary as a GeoJSON Polygon geometry
+func validateZone(name string, cropType string, boundary json.RawMessage) (json.RawMessage, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(name) > maxZoneTextLength || len(cropType) > maxZoneTextLength {
+		return nil, fmt.Errorf("name and crop_type must be at most %d characters", maxZoneTextLength)
+	}
+
+	if len(boundary) == 0 {
+		return nil, fmt.Errorf("boundary is required")
+	}
+	polygon, err := geo.ParsePolygon(boundary)
+	if err != nil {
+		return nil, fmt.Errorf("boundary: %w", err)
+	}
+
+	return json.Marshal(polygon)
+}
+
+// getZone looks up the zone named in the request path, answering the request
+// directly and returning false if it can't be found
+func getZone(apiService *APIService, w http.ResponseWriter, r *http.Request) (database.Zone, bool) {
+	zoneID, err := uuid.Parse(mux.Vars(r)["zone_id"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Zone not found"})
+		return database.Zone{}, false
+	}
+
+	zone, err := database.GetZone(r.Context(), apiService.DatabaseClient.DB, zoneID)
+	if err != nil {
+		if errors.Is(err, database.ErrorNoZone) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Zone not found"})
+			return database.Zone{}, false
+		}
+
+		apiService.Error().Msgf("Error retrieving zone %s: %s", zoneID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return database.Zone{}, false
+	}
+
+	return zone, true
+}
+
+// writeZone answers the request with the zone and the sensors in service in it
+func writeZone(apiService *APIService, w http.ResponseWriter, r *http.Request, zone database.Zone, status int) {
+	zoneSensorIDs, err := database.GetZoneSensorIDs(r.Context(), apiService.DatabaseClient.DB, []uuid.UUID{zone.ID}, false)
+	if err != nil {
+		apiService.Error().Msgf("Error retrieving sensors of zone %s: %s", zone.ID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(zoneToAPI(zone, zoneSensorIDs[zone.ID]))
+}
+
+// CreateGetZonesHandler returns a handler that lists every zone and the sensors in it
+func CreateGetZonesHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zones, err := database.GetZones(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving zones: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		zoneIDs := make([]uuid.UUID, 0, len(zones))
+		for _, zone := range zones {
+			zoneIDs = append(zoneIDs, zone.ID)
+		}
+
+		zoneSensorIDs, err := database.GetZoneSensorIDs(r.Context(), apiService.DatabaseClient.DB, zoneIDs, false)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving sensors of zones: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		response := api.GetZonesResponse{Zones: make([]api.Zone, 0, len(zones))}
+		for _, zone := range zones {
+			response.Zones = append(response.Zones, zoneToAPI(zone, zoneSensorIDs[zone.ID]))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// CreateGetZoneHandler returns a handler that returns a zone and the sensors in it
+func CreateGetZoneHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zone, ok := getZone(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		writeZone(apiService, w, r, zone, http.StatusOK)
+	}
+}
+
+// CreateCreateZoneHandler returns a handler that adds a zone, the sensors whose
+// coordinates are inside its boundary are moved to it
+func CreateCreateZoneHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.CreateZoneRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		zone := database.Zone{
+			Name:      strings.TrimSpace(request.Name),
+			CropType:  strings.TrimSpace(request.CropType),
+			CreatedBy: username,
+		}
+		zone.Boundary, err = validateZone(zone.Name, zone.CropType, request.Boundary)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		err = zone.Save(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			if errors.Is(err, database.ErrorDuplicateZone) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Zone %q already exists", zone.Name)})
+				return
+			}
+
+			apiService.Error().Msgf("Error saving zone %s: %s", zone.Name, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Zone %s (%s) added by %s", zone.Name, zone.ID, username)
+
+		moved, err := updateSensorZones(r.Context(), apiService.DatabaseClient.DB, nil)
+		if err != nil {
+			apiService.Error().Msgf("Error placing sensors in zone %s: %s", zone.ID, err)
+		} else if len(moved) > 0 {
+			apiService.Info().Msgf("Moved %d sensors to the zones their coordinates are in", len(moved))
+		}
+
+		writeZone(apiService, w, r, zone, http.StatusCreated)
+	}
+}
+
+// CreateUpdateZoneHandler returns a handler that changes the name, crop type or
+// boundary of a zone, leaving fields that are not in the request unchanged
+func CreateUpdateZoneHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.UpdateZoneRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		zone, ok := getZone(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		if request.Name != nil {
+			zone.Name = strings.TrimSpace(*request.Name)
+		}
+		if request.CropType != nil {
+			zone.CropType = strings.TrimSpace(*request.CropType)
+		}
+		boundary := zone.Boundary
+		if len(request.Boundary) > 0 {
+			boundary = request.Boundary
+		}
+
+		zone.Boundary, err = validateZone(zone.Name, zone.CropType, boundary)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		err = zone.Update(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrorDuplicateZone):
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Zone %q already exists", zone.Name)})
+			case errors.Is(err, database.ErrorNoZone):
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Zone not found"})
+			default:
+				apiService.Error().Msgf("Error updating zone %s: %s", zone.ID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			}
+			return
+		}
+
+		apiService.Info().Msgf("Zone %s (%s) updated by %s", zone.Name, zone.ID, username)
+
+		if len(request.Boundary) > 0 {
+			moved, err := updateSensorZones(r.Context(), apiService.DatabaseClient.DB, nil)
+			if err != nil {
+				apiService.Error().Msgf("Error placing sensors in zone %s: %s", zone.ID, err)
+			} else if len(moved) > 0 {
+				apiService.Info().Msgf("Moved %d sensors to the zones their coordinates are in", len(moved))
+			}
+		}
+
+		writeZone(apiService, w, r, zone, http.StatusOK)
+	}
+}
+
+// CreateDeleteZoneHandler returns a handler that deletes a zone, its sensors are moved
+// to any other zone their coordinates are in. Their readings are kept
+func CreateDeleteZoneHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		zone, ok := getZone(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		err := database.DeleteZone(r.Context(), apiService.DatabaseClient.DB, zone.ID)
+		if err != nil {
+			if errors.Is(err, database.ErrorNoZone) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Zone not found"})
+				return
+			}
+
+			apiService.Error().Msgf("Error deleting zone %s: %s", zone.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		apiService.Info().Msgf("Zone %s (%s) deleted by %s", zone.Name, zone.ID, username)
+
+		moved, err := updateSensorZones(r.Context(), apiService.DatabaseClient.DB, nil)
+		if err != nil {
+			apiService.Error().Msgf("Error placing the sensors of zone %s in other zones: %s", zone.ID, err)
+		} else if len(moved) > 0 {
+			apiService.Info().Msgf("Moved %d sensors to the zones their coordinates are in", len(moved))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api.SuccessResponse{Message: "Zone deleted successfully"})
+	}
+}
+
+// CreateAssignSensorZoneHandler returns a handler that assigns a sensor to a zone by hand,
+// regardless of its coordinates. Assigning it to no zone places it by its coordinates again
+func CreateAssignSensorZoneHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+
+		var request api.AssignSensorZoneRequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Invalid request body"})
+			return
+		}
+
+		sensor, ok := getSensor(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		var zoneID *uuid.UUID
+		if request.ZoneID != nil {
+			id, err := uuid.Parse(*request.ZoneID)
+			if err != nil {
+				err = database.ErrorNoZone
+			} else {
+				_, err = database.GetZone(r.Context(), apiService.DatabaseClient.DB, id)
+			}
+			if err != nil {
+				if errors.Is(err, database.ErrorNoZone) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(api.ErrorResponse{Error: fmt.Sprintf("Unknown zone %q", *request.ZoneID)})
+					return
+				}
+
+				apiService.Error().Msgf("Error retrieving zone %s: %s", *request.ZoneID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+			zoneID = &id
+		}
+
+		err = database.SetSensorZone(r.Context(), apiService.DatabaseClient.DB, sensor.ID, zoneID)
+		if err != nil {
+			apiService.Error().Msgf("Error assigning sensor %s to zone %v: %s", sensor.ID, request.ZoneID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Failed to assign sensor to zone"})
+			return
+		}
+		sensor.ZoneID = zoneID
+		sensor.ZoneAssignedManually = zoneID != nil
+
+		if zoneID == nil {
+			apiService.Info().Msgf("Sensor %s placed by its coordinates again by %s", sensor.ID, username)
+			placeSensorInZone(apiService, r, &sensor)
+		} else {
+			apiService.Info().Msgf("Sensor %s assigned to zone %s by %s", sensor.ID, zoneID, username)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(sensorToAPI(sensor))
+	}
+}
+
+// CreateGetZoneSeriesHandler returns a handler that aggregates the readings of every sensor
+// that is or was in a zone into buckets holding the mean, min and max across the sensors.
+// Readings are calibrated unless raw is true and converted to the units asked for
+func CreateGetZoneSeriesHandler(apiService *APIService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zone, ok := getZone(apiService, w, r)
+		if !ok {
+			return
+		}
+
+		measurementTypes, err := database.GetMeasurementTypes(r.Context(), apiService.DatabaseClient.DB)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving measurement types: %s", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+
+		units := make(map[string]string, len(measurementTypes))
+		for _, measurementType := range measurementTypes {
+			units[measurementType.ID] = measurementType.Unit
+		}
+
+		filter, resolution, err := parseZoneSeriesQuery(r, units, time.Now())
+		var converter unitConverter
+		if err == nil {
+			converter, err = parseUnitsParameter(r.URL.Query().Get("units"))
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		// sensors taken out of service still count towards the zone's history
+		zoneSensorIDs, err := database.GetZoneSensorIDs(r.Context(), apiService.DatabaseClient.DB, []uuid.UUID{zone.ID}, true)
+		if err != nil {
+			apiService.Error().Msgf("Error retrieving sensors of zone %s: %s", zone.ID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+			return
+		}
+		filter.SensorIDs = zoneSensorIDs[zone.ID]
+
+		response := api.GetZoneSeriesResponse{
+			ZoneID:     zone.ID.String(),
+			Resolution: resolution,
+			Start:      filter.Start.UTC(),
+			End:        filter.End.UTC(),
+			SensorIDs:  []string{},
+			Series:     make([]api.ZoneSeries, 0, len(filter.MeasurementTypes)),
+		}
+		if filter.SensorIDs != nil {
+			response.SensorIDs = filter.SensorIDs
+		}
+
+		// an empty filter would select every sensor's readings
+		sensorBuckets := make(map[string][]api.SensorDataBucket, len(filter.MeasurementTypes))
+		if len(filter.SensorIDs) > 0 {
+			var calibrations database.SensorCalibrations
+			if r.URL.Query().Get("raw") != "true" {
+				calibrations, err = database.GetSensorCalibrations(r.Context(), apiService.DatabaseClient.DB, filter.SensorIDs, filter.MeasurementTypes)
+				if err != nil {
+					apiService.Error().Msgf("Error retrieving calibrations of the sensors of zone %s: %s", zone.ID, err)
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusInternalServerError)
+					json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+					return
+				}
+			}
+
+			buckets, err := database.GetSensorMeasurementSeriesBuckets(r.Context(), apiService.DatabaseClient.DB, filter, resolution)
+			if err != nil {
+				apiService.Error().Msgf("Error retrieving %s buckets of the sensors of zone %s: %s", resolution, zone.ID, err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(api.ErrorResponse{Error: "Internal server error"})
+				return
+			}
+
+			for _, bucket := range buckets {
+				calibrated := sensorDataBucketToAPI(calibrations.CalibrateBucket(bucket.SensorID, bucket.MeasurementType, bucket.SensorDataBucket))
+				sensorBuckets[bucket.MeasurementType] = append(sensorBuckets[bucket.MeasurementType], converter.Bucket(units[bucket.MeasurementType], calibrated))
+			}
+		}
+
+		for _, measurementType := range filter.MeasurementTypes {
+			response.Series = append(response.Series, api.ZoneSeries{
+				MeasurementType: measurementType,
+				Unit:            converter.Unit(units[measurementType]),
+				Buckets:         combineZoneBuckets(sensorBuckets[measurementType]),
+			})
+		}
+
+		apiService.Debug().Msgf("Sending back %d series of zone %s from %d sensors", len(response.Series), zone.ID, len(filter.SensorIDs))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
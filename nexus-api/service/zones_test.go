@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/json"
+	"nexus-api/api"
+	"nexus-api/clients/database"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// testZone returns a zone bounded by the square from west,south to east,north
+func testZone(name string, west, south, east, north float64) database.Zone {
+	boundary, _ := json.Marshal(map[string]any{
+		"type":        "Polygon",
+		"coordinates": [][][]float64{{{west, south}, {east, south}, {east, north}, {west, north}, {west, south}}},
+	})
+
+	return database.Zone{ID: uuid.New(), Name: name, Boundary: boundary}
+}
+
+func TestUnitTestAutomaticSensorZonesPlacesSensorsByCoordinates(t *testing.T) {
+	// setup test data
+	farm := testZone("farm", 10, 50, 12, 52)
+	block := testZone("north block", 10, 51, 11, 52)
+	boundaries, err := parseZoneBoundaries([]database.Zone{farm, block})
+	assert.NoError(t, err)
+
+	decommissionedAt := time.Now()
+	sensors := []database.Sensor{
+		{ID: "in-block", SensorCoordinates: database.SensorCoordinates{Latitude: 51.5, Longitude: 10.5}},
+		{ID: "in-farm", SensorCoordinates: database.SensorCoordinates{Latitude: 50.5, Longitude: 11.5}, ZoneID: &block.ID},
+		{ID: "outside", SensorCoordinates: database.SensorCoordinates{Latitude: 40, Longitude: 11}, ZoneID: &farm.ID},
+		{ID: "unplaced"},
+		{ID: "unchanged", SensorCoordinates: database.SensorCoordinates{Latitude: 50.5, Longitude: 11.5}, ZoneID: &farm.ID},
+		{ID: "manual", SensorCoordinates: database.SensorCoordinates{Latitude: 51.5, Longitude: 10.5}, ZoneID: &farm.ID, ZoneAssignedManually: true},
+		{ID: "decommissioned", SensorCoordinates: database.SensorCoordinates{Latitude: 51.5, Longitude: 10.5}, DecommissionedAt: &decommissionedAt},
+	}
+
+	// execute test
+	changes := automaticSensorZones(boundaries, sensors)
+
+	// assert results
+	assert.Len(t, changes, 3)
+	assert.Equal(t, &block.ID, changes["in-block"], "sensors in overlapping zones go to the smallest")
+	assert.Equal(t, &farm.ID, changes["in-farm"])
+	assert.Contains(t, changes, "outside")
+	assert.Nil(t, changes["outside"], "sensors outside every zone are taken out of their zone")
+}
+
+func TestUnitTestCombineZoneBucketsWeighsSensorsEqually(t *testing.T) {
+	// setup test data
+	hour := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	buckets := []api.SensorDataBucket{
+		{BucketStart: hour.Add(time.Hour), Min: 20, Max: 24, Avg: 22, Count: 4},
+		{BucketStart: hour, Min: 30, Max: 40, Avg: 35, Count: 60},
+		{BucketStart: hour, Min: 10, Max: 20, Avg: 15, Count: 4},
+	}
+
+	// execute test
+	combined := combineZoneBuckets(buckets)
+
+	// assert results
+	assert.Equal(t, []api.ZoneDataBucket{
+		{BucketStart: hour, Mean: 25, Min: 10, Max: 40, SensorCount: 2, Count: 64},
+		{BucketStart: hour.Add(time.Hour), Mean: 22, Min: 20, Max: 24, SensorCount: 1, Count: 4},
+	}, combined)
+	assert.NotNil(t, combineZoneBuckets(nil), "zones without readings have an empty series")
+}
+
+func TestUnitTestValidateZone(t *testing.T) {
+	boundary := json.RawMessage(`{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[10, 50, 120], [12, 50, 120], [12, 52, 120], [10, 50, 120]]]}}`)
+
+	stored, err := validateZone("north block", "maize", boundary)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "Polygon", "coordinates": [[[10, 50], [12, 50], [12, 52], [10, 50]]]}`, string(stored), "boundaries are stored as bare polygons")
+
+	_, err = validateZone("", "maize", boundary)
+	assert.Error(t, err, "missing name")
+	_, err = validateZone(strings.Repeat("a", maxZoneTextLength+1), "maize", boundary)
+	assert.Error(t, err, "name too long")
+	_, err = validateZone("north block", "maize", nil)
+	assert.Error(t, err, "missing boundary")
+	_, err = validateZone("north block", "maize", json.RawMessage(`{"type": "Point", "coordinates": [10, 50]}`))
+	assert.Error(t, err, "boundary that isn't a polygon")
+}